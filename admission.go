@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority is the priority class of a query or batch. When the number of
+// requests in flight to a host is limited with PoolConfig.MaxInFlightPerHost,
+// requests waiting for admission are dispatched in the order of their priority
+// and in FIFO order within the same priority.
+type Priority int8
+
+const (
+	// PriorityLow is meant for background work, such as batch jobs.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default priority of queries and batches.
+	PriorityNormal Priority = 0
+	// PriorityHigh is meant for latency-critical requests.
+	PriorityHigh Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", int8(p))
+	}
+}
+
+// queue returns the index of the admission queue for the priority, lower
+// indexes are dispatched first.
+func (p Priority) queue() int {
+	switch {
+	case p >= PriorityHigh:
+		return 0
+	case p <= PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// ErrOverloaded is returned when a request can't be admitted to a host because
+// the host already has PoolConfig.MaxInFlightPerHost requests in flight, and no
+// slot became free within PoolConfig.MaxQueueWait.
+type ErrOverloaded struct {
+	// Host is the host that rejected the request.
+	Host *HostInfo
+	// Priority is the priority of the rejected request.
+	Priority Priority
+}
+
+func (e *ErrOverloaded) Error() string {
+	return fmt.Sprintf("gocql: too many requests in flight to host %s, rejected %s priority request", e.Host.ConnectAddress(), e.Priority)
+}
+
+type admissionWaiter struct {
+	// ready is closed once a slot is handed over to the waiter.
+	ready chan struct{}
+}
+
+// hostAdmission limits the number of requests in flight to a single host.
+type hostAdmission struct {
+	limit   int
+	maxWait time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	// waiters holds the waiting requests for each priority queue, see Priority.queue.
+	waiters [3][]*admissionWaiter
+}
+
+func newHostAdmission(limit int, maxWait time.Duration) *hostAdmission {
+	if limit <= 0 {
+		return nil
+	}
+	return &hostAdmission{
+		limit:   limit,
+		maxWait: maxWait,
+	}
+}
+
+// acquire takes a slot for a request with priority p. If no slot is free, it
+// waits for up to maxWait for one. It reports whether the request was admitted,
+// err is set if ctx is done before a slot became free.
+// Every successful acquire must be followed by a release.
+func (a *hostAdmission) acquire(ctx context.Context, p Priority) (admitted bool, err error) {
+	a.mu.Lock()
+	if a.inFlight < a.limit {
+		a.inFlight++
+		a.mu.Unlock()
+		return true, nil
+	}
+	if a.maxWait <= 0 {
+		a.mu.Unlock()
+		return false, nil
+	}
+
+	w := &admissionWaiter{ready: make(chan struct{})}
+	q := p.queue()
+	a.waiters[q] = append(a.waiters[q], w)
+	a.mu.Unlock()
+
+	timer := time.NewTimer(a.maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return true, nil
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	a.mu.Lock()
+	removed := false
+	for i, candidate := range a.waiters[q] {
+		if candidate == w {
+			a.waiters[q] = append(a.waiters[q][:i], a.waiters[q][i+1:]...)
+			removed = true
+			break
+		}
+	}
+	a.mu.Unlock()
+
+	if !removed {
+		// the slot was handed over concurrently, pass it on
+		a.release()
+	}
+
+	return false, err
+}
+
+// release frees a slot, handing it over to the waiting request with the
+// highest priority if there is one.
+func (a *hostAdmission) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for q := range a.waiters {
+		if len(a.waiters[q]) == 0 {
+			continue
+		}
+		w := a.waiters[q][0]
+		a.waiters[q][0] = nil
+		a.waiters[q] = a.waiters[q][1:]
+		close(w.ready)
+		return
+	}
+
+	a.inFlight--
+}
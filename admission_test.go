@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostAdmissionRejectsOverLimit(t *testing.T) {
+	a := newHostAdmission(2, 0)
+
+	for i := 0; i < 2; i++ {
+		if ok, err := a.acquire(context.Background(), PriorityNormal); !ok || err != nil {
+			t.Fatalf("acquire %d: expected to be admitted, got %v %v", i, ok, err)
+		}
+	}
+	if ok, err := a.acquire(context.Background(), PriorityHigh); ok || err != nil {
+		t.Fatalf("expected to be rejected, got %v %v", ok, err)
+	}
+
+	a.release()
+	if ok, err := a.acquire(context.Background(), PriorityLow); !ok || err != nil {
+		t.Fatalf("expected to be admitted after release, got %v %v", ok, err)
+	}
+}
+
+func TestHostAdmissionPriorityOrder(t *testing.T) {
+	a := newHostAdmission(1, time.Minute)
+	if ok, _ := a.acquire(context.Background(), PriorityNormal); !ok {
+		t.Fatal("expected to be admitted")
+	}
+
+	admitted := make(chan Priority, 3)
+	waiters := 0
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		p := p
+		go func() {
+			if ok, err := a.acquire(context.Background(), p); !ok || err != nil {
+				t.Errorf("expected %v to be admitted, got %v %v", p, ok, err)
+			}
+			admitted <- p
+		}()
+
+		// wait for the request to be queued so that the order is deterministic
+		waiters++
+		for {
+			a.mu.Lock()
+			queued := len(a.waiters[0]) + len(a.waiters[1]) + len(a.waiters[2])
+			a.mu.Unlock()
+			if queued == waiters {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, expected := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		a.release()
+		if p := <-admitted; p != expected {
+			t.Fatalf("expected %v to be admitted, got %v", expected, p)
+		}
+	}
+
+	a.release()
+	if a.inFlight != 0 {
+		t.Fatalf("expected no requests in flight, got %d", a.inFlight)
+	}
+}
+
+func TestHostAdmissionWaitTimeout(t *testing.T) {
+	a := newHostAdmission(1, 10*time.Millisecond)
+	if ok, _ := a.acquire(context.Background(), PriorityNormal); !ok {
+		t.Fatal("expected to be admitted")
+	}
+
+	if ok, err := a.acquire(context.Background(), PriorityHigh); ok || err != nil {
+		t.Fatalf("expected to be rejected, got %v %v", ok, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err := a.acquire(ctx, PriorityHigh); ok || err != context.Canceled {
+		t.Fatalf("expected %v, got %v %v", context.Canceled, ok, err)
+	}
+
+	a.release()
+	if a.inFlight != 0 || len(a.waiters[0]) != 0 {
+		t.Fatalf("expected no requests in flight or waiting, got %d in flight and %d waiting", a.inFlight, len(a.waiters[0]))
+	}
+}
@@ -1585,7 +1585,7 @@ func injectInvalidPreparedStatement(t *testing.T, session *Session, table string
 	conn := getRandomConn(t, session)
 
 	flight := new(inflightPrepare)
-	key := session.stmtsLRU.keyFor(conn.host.HostID(), "", stmt)
+	key := session.stmtsLRU.keyFor(conn.version, conn.host.HostID(), "", stmt)
 	session.stmtsLRU.add(key, flight)
 
 	flight.preparedStatment = &preparedStatment{
@@ -1753,27 +1753,27 @@ func TestPrepare_PreparedCacheEviction(t *testing.T) {
 
 	// Walk through all the configured hosts and test cache retention and eviction
 	for _, host := range session.ring.hosts {
-		_, ok := session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(host.HostID(), session.cfg.Keyspace, "SELECT id,mod FROM prepcachetest WHERE id = 0"))
+		_, ok := session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(byte(session.ProtocolVersion()), host.HostID(), session.cfg.Keyspace, "SELECT id,mod FROM prepcachetest WHERE id = 0"))
 		if ok {
 			t.Errorf("expected first select to be purged but was in cache for host=%q", host)
 		}
 
-		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(host.HostID(), session.cfg.Keyspace, "SELECT id,mod FROM prepcachetest WHERE id = 1"))
+		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(byte(session.ProtocolVersion()), host.HostID(), session.cfg.Keyspace, "SELECT id,mod FROM prepcachetest WHERE id = 1"))
 		if !ok {
 			t.Errorf("exepected second select to be in cache for host=%q", host)
 		}
 
-		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(host.HostID(), session.cfg.Keyspace, "INSERT INTO prepcachetest (id,mod) VALUES (?, ?)"))
+		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(byte(session.ProtocolVersion()), host.HostID(), session.cfg.Keyspace, "INSERT INTO prepcachetest (id,mod) VALUES (?, ?)"))
 		if !ok {
 			t.Errorf("expected insert to be in cache for host=%q", host)
 		}
 
-		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(host.HostID(), session.cfg.Keyspace, "UPDATE prepcachetest SET mod = ? WHERE id = ?"))
+		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(byte(session.ProtocolVersion()), host.HostID(), session.cfg.Keyspace, "UPDATE prepcachetest SET mod = ? WHERE id = ?"))
 		if !ok {
 			t.Errorf("expected update to be in cached for host=%q", host)
 		}
 
-		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(host.HostID(), session.cfg.Keyspace, "DELETE FROM prepcachetest WHERE id = ?"))
+		_, ok = session.stmtsLRU.lru.Get(session.stmtsLRU.keyFor(byte(session.ProtocolVersion()), host.HostID(), session.cfg.Keyspace, "DELETE FROM prepcachetest WHERE id = ?"))
 		if !ok {
 			t.Errorf("expected delete to be cached for host=%q", host)
 		}
@@ -2525,6 +2525,7 @@ func TestAggregateMetadata(t *testing.T) {
 		ReturnType:    NativeType{typ: TypeDouble, proto: protoVer},
 		StateType: TupleTypeInfo{
 			NativeType: NativeType{typ: TypeTuple, proto: protoVer},
+			Frozen:     true,
 
 			Elems: []TypeInfo{
 				NativeType{typ: TypeInt, proto: protoVer},
@@ -2575,6 +2576,7 @@ func TestFunctionMetadata(t *testing.T) {
 		ArgumentTypes: []TypeInfo{
 			TupleTypeInfo{
 				NativeType: NativeType{typ: TypeTuple, proto: protoVer},
+				Frozen:     true,
 
 				Elems: []TypeInfo{
 					NativeType{typ: TypeInt, proto: protoVer},
@@ -2586,6 +2588,7 @@ func TestFunctionMetadata(t *testing.T) {
 		ArgumentNames: []string{"state", "val"},
 		ReturnType: TupleTypeInfo{
 			NativeType: NativeType{typ: TypeTuple, proto: protoVer},
+			Frozen:     true,
 
 			Elems: []TypeInfo{
 				NativeType{typ: TypeInt, proto: protoVer},
@@ -2607,6 +2610,7 @@ func TestFunctionMetadata(t *testing.T) {
 		ArgumentTypes: []TypeInfo{
 			TupleTypeInfo{
 				NativeType: NativeType{typ: TypeTuple, proto: protoVer},
+				Frozen:     true,
 
 				Elems: []TypeInfo{
 					NativeType{typ: TypeInt, proto: protoVer},
@@ -3708,7 +3712,7 @@ func TestPrepareExecuteMetadataChangedFlag(t *testing.T) {
 	require.Len(t, row, 1, "Expected to retrieve a single column")
 	require.Equal(t, 1, row["id"])
 
-	stmtCacheKey := session.stmtsLRU.keyFor(conn.host.HostID(), conn.currentKeyspace, queryBeforeTableAltering.stmt)
+	stmtCacheKey := session.stmtsLRU.keyFor(conn.version, conn.host.HostID(), conn.currentKeyspace, queryBeforeTableAltering.stmt)
 	inflight, _ := session.stmtsLRU.get(stmtCacheKey)
 	preparedStatementBeforeTableAltering := inflight.preparedStatment
 
@@ -27,6 +27,7 @@ package gocql
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"net"
 	"time"
 )
@@ -40,6 +41,69 @@ type PoolConfig struct {
 	// It is not supported to use a single HostSelectionPolicy in multiple sessions
 	// (even if you close the old session before using in a new session).
 	HostSelectionPolicy HostSelectionPolicy
+
+	// MaxConnectionAge is the maximum amount of time a connection is used before
+	// it is replaced with a new one. The replacement is established before the old
+	// connection is drained, so the pool never drops below its configured size.
+	// This helps to rebalance load behind L4 load balancers and after token
+	// ownership changes. Disabled if <= 0 (default: 0).
+	MaxConnectionAge time.Duration
+
+	// MaxConnectionAgeJitter adds a random duration in [0, MaxConnectionAgeJitter)
+	// to MaxConnectionAge of every connection, so that connections created at
+	// the same time are not all replaced at once. Ignored if MaxConnectionAge
+	// is disabled (default: 0).
+	MaxConnectionAgeJitter time.Duration
+
+	// IdleTimeout is the amount of time a connection may go without being picked
+	// for a request before it is replaced with a new one. Disabled if <= 0
+	// (default: 0).
+	IdleTimeout time.Duration
+
+	// MaxInFlightPerHost limits the number of queries and batches executed
+	// concurrently on a single host. Requests over the limit wait for up to
+	// MaxQueueWait, and are dispatched by their Priority once a request finishes.
+	// If no request finishes in time, the next host of the query plan is tried,
+	// and *ErrOverloaded is returned if all hosts are over the limit.
+	// Unlimited if <= 0 (default: 0).
+	MaxInFlightPerHost int
+
+	// MaxQueueWait is the maximum amount of time a request waits for admission
+	// to a host which has MaxInFlightPerHost requests in flight. If <= 0, requests
+	// over the limit are rejected right away (default: 0).
+	MaxQueueWait time.Duration
+}
+
+// connMaxAge returns the lifetime of a new connection, including jitter.
+// It returns 0 if the connection lifetime is unlimited.
+func (p PoolConfig) connMaxAge() time.Duration {
+	if p.MaxConnectionAge <= 0 {
+		return 0
+	}
+	age := p.MaxConnectionAge
+	if p.MaxConnectionAgeJitter > 0 {
+		age += time.Duration(rand.Int63n(int64(p.MaxConnectionAgeJitter)))
+	}
+	return age
+}
+
+// connMaintenanceInterval returns how often the pool checks for expired and idle
+// connections, or 0 if neither MaxConnectionAge nor IdleTimeout is set.
+func (p PoolConfig) connMaintenanceInterval() time.Duration {
+	var interval time.Duration
+	for _, d := range []time.Duration{p.MaxConnectionAge, p.IdleTimeout} {
+		if d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	if interval == 0 {
+		return 0
+	}
+	interval /= 2
+	if interval > time.Second {
+		interval = time.Second
+	}
+	return interval
 }
 
 func (p PoolConfig) buildPool(session *Session) *policyConnPool {
@@ -116,10 +180,30 @@ type ClusterConfig struct {
 	// Default: Quorum
 	Consistency Consistency
 
-	// Compression algorithm.
+	// Compression algorithm. Connecting to a host which does not support it
+	// fails with ErrCompressorNotSupported.
 	// Default: nil
 	Compressor Compressor
 
+	// MaxResponseBodySize limits the size of response frame bodies as read from the network,
+	// compressed or not. Larger responses are discarded without being buffered, and their
+	// query fails with *ErrResponseTooLarge, the connection stays usable.
+	// Default: 0, which only applies the protocol limit of 256MiB.
+	MaxResponseBodySize int
+
+	// MaxDecompressedBodySize limits the size of response frame bodies once decompressed.
+	// Responses over the limit fail with *ErrResponseTooLarge, the limit is checked before
+	// decompressing with compressors that can tell the decompressed size up front.
+	// Default: 0, no limit.
+	MaxDecompressedBodySize int
+
+	// MaxPageBytes limits the size of RESULT response bodies, which carry the pages of rows
+	// of queries, once decompressed. Pages over the limit fail with *ErrResponseTooLarge,
+	// lowering the page size of the query works around it.
+	// ObservedQuery.ResponseSize tells how close to the limit responses are.
+	// Default: 0, no limit.
+	MaxPageBytes int
+
 	// Default: nil
 	Authenticator Authenticator
 
@@ -127,10 +211,32 @@ type ClusterConfig struct {
 	// Default: nil
 	AuthProvider func(h *HostInfo) (Authenticator, error)
 
+	// CredentialsProvider provides the credentials of password authentication
+	// for every connection, Authenticator and AuthProvider are ignored if set.
+	// When the server rejects the credentials, fresh credentials are requested
+	// with Refresh and the connection is attempted once more.
+	// Default: nil
+	CredentialsProvider CredentialsProvider
+
+	// Codecs are consulted before the codecs registered with RegisterCodec to
+	// marshal and unmarshal the values of queries, batches and rows.
+	// Default: nil
+	Codecs *CodecRegistry
+
 	// Default retry policy to use for queries.
 	// Default: no retries.
 	RetryPolicy RetryPolicy
 
+	// Default rate limiter to use for queries and batches, consulted before every
+	// attempt, including retries.
+	// Default: nil, no rate limiting
+	RateLimiter RateLimiter
+
+	// Default speculative execution policy to use for queries and batches.
+	// Speculative executions are only used for idempotent queries.
+	// Default: NonSpeculativeExecution
+	SpeculativeExecutionPolicy SpeculativeExecutionPolicy
+
 	// ConvictionPolicy decides whether to mark host as down based on the error and host info.
 	// Default: SimpleConvictionPolicy
 	ConvictionPolicy ConvictionPolicy
@@ -236,6 +342,23 @@ type ClusterConfig struct {
 	// Use it to collect metrics / stats from frames by providing an implementation of FrameHeaderObserver.
 	FrameHeaderObserver FrameHeaderObserver
 
+	// FrameRecorder, if set, is called with every frame sent and received by the connections
+	// of the session. Use NewFrameCaptureWriter to write the frames to a capture file which
+	// can be decoded with ReplayFrameCapture.
+	//
+	// The credentials of AUTH_RESPONSE frames are never recorded.
+	FrameRecorder FrameRecorder
+
+	// RedactRecordedValues replaces the bound values of the QUERY, EXECUTE and BATCH frames
+	// passed to FrameRecorder and FrameObserver with zero bytes of the same length.
+	RedactRecordedValues bool
+
+	// FrameObserver, if set, is called with a decoded view of every frame sent and received
+	// by the connections of the session, see DecodeFrame.
+	//
+	// Decoding every frame is expensive, it is meant for debugging.
+	FrameObserver FrameObserver
+
 	// StreamObserver will be notified of stream state changes.
 	// This can be used to track in-flight protocol requests and responses.
 	StreamObserver StreamObserver
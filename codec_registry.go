@@ -0,0 +1,187 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Codec encodes and decodes a Go type which cannot implement Marshaler or
+// Unmarshaler itself, such as types of third party packages. Either function
+// may be nil, in which case the built-in conversions are used for that
+// direction.
+type Codec struct {
+	// Marshal is called with values of the registered Go type.
+	Marshal func(info TypeInfo, value interface{}) ([]byte, error)
+	// Unmarshal is called with pointers to the registered Go type. data is
+	// nil for CQL null.
+	Unmarshal func(info TypeInfo, data []byte, value interface{}) error
+}
+
+type codecKey struct {
+	typ    Type
+	custom string
+	goType reflect.Type
+}
+
+// CodecRegistry maps pairs of CQL type and Go type to codecs. Marshal and
+// Unmarshal consult the global registry, see RegisterCodec, before their
+// built-in conversions, including for elements of collections, tuples and
+// user-defined types. A registry set in ClusterConfig.Codecs is consulted
+// before the global registry for the values of its session.
+//
+// A CodecRegistry is safe for concurrent use.
+type CodecRegistry struct {
+	// n is the number of codecs, to skip the lookup when empty.
+	n      int32
+	mu     sync.RWMutex
+	codecs map[codecKey]Codec
+}
+
+// NewCodecRegistry returns an empty CodecRegistry.
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{}
+}
+
+var globalCodecs = NewCodecRegistry()
+
+// RegisterCodec registers codec globally for values of goType and the CQL
+// type typ, see CodecRegistry.Register.
+func RegisterCodec(typ Type, goType reflect.Type, codec Codec) {
+	globalCodecs.Register(typ, goType, codec)
+}
+
+// RegisterCustomCodec registers codec globally for values of goType and the
+// custom CQL type class, see CodecRegistry.RegisterCustom.
+func RegisterCustomCodec(class string, goType reflect.Type, codec Codec) {
+	globalCodecs.RegisterCustom(class, goType, codec)
+}
+
+// Register registers codec for values of goType and the CQL type typ,
+// replacing any codec previously registered for the pair. goType is the type
+// of marshaled values and the element type of unmarshaled pointers, for
+// example reflect.TypeOf(decimal.Decimal{}) is used to marshal
+// decimal.Decimal and *decimal.Decimal and to unmarshal into
+// *decimal.Decimal and **decimal.Decimal.
+//
+// Use RegisterCustom for TypeCustom.
+func (r *CodecRegistry) Register(typ Type, goType reflect.Type, codec Codec) {
+	r.register(codecKey{typ: typ, goType: goType}, codec)
+}
+
+// RegisterCustom registers codec for values of goType and the custom CQL
+// type class, such as "org.apache.cassandra.db.marshal.DynamicCompositeType".
+func (r *CodecRegistry) RegisterCustom(class string, goType reflect.Type, codec Codec) {
+	r.register(codecKey{typ: TypeCustom, custom: class, goType: goType}, codec)
+}
+
+func (r *CodecRegistry) register(key codecKey, codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.codecs == nil {
+		r.codecs = make(map[codecKey]Codec)
+	}
+	r.codecs[key] = codec
+	atomic.StoreInt32(&r.n, int32(len(r.codecs)))
+	atomic.AddUint32(&codecGeneration, 1)
+}
+
+// Unregister removes the codec registered for the CQL type typ and goType.
+func (r *CodecRegistry) Unregister(typ Type, goType reflect.Type) {
+	r.unregister(codecKey{typ: typ, goType: goType})
+}
+
+// UnregisterCustom removes the codec registered for the custom CQL type class
+// and goType.
+func (r *CodecRegistry) UnregisterCustom(class string, goType reflect.Type) {
+	r.unregister(codecKey{typ: TypeCustom, custom: class, goType: goType})
+}
+
+func (r *CodecRegistry) unregister(key codecKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.codecs, key)
+	atomic.StoreInt32(&r.n, int32(len(r.codecs)))
+	atomic.AddUint32(&codecGeneration, 1)
+}
+
+func (r *CodecRegistry) get(info TypeInfo, goType reflect.Type) (Codec, bool) {
+	if r == nil || atomic.LoadInt32(&r.n) == 0 {
+		return Codec{}, false
+	}
+
+	key := codecKey{typ: info.Type(), goType: goType}
+	if key.typ == TypeCustom {
+		key.custom = info.Custom()
+	}
+
+	r.mu.RLock()
+	codec, ok := r.codecs[key]
+	r.mu.RUnlock()
+	return codec, ok
+}
+
+// marshaler returns the Marshal function registered in r or globally for
+// info and the type of value.
+func (r *CodecRegistry) marshaler(info TypeInfo, value interface{}) func(TypeInfo, interface{}) ([]byte, error) {
+	if value == nil || r.empty() {
+		return nil
+	}
+	return r.marshalerFor(info, reflect.TypeOf(value))
+}
+
+// unmarshaler returns the Unmarshal function registered in r or globally for
+// info and the type value points to.
+func (r *CodecRegistry) unmarshaler(info TypeInfo, value interface{}) func(TypeInfo, []byte, interface{}) error {
+	if value == nil || r.empty() {
+		return nil
+	}
+	goType := reflect.TypeOf(value)
+	if goType.Kind() != reflect.Ptr {
+		return nil
+	}
+	return r.unmarshalerFor(info, goType.Elem())
+}
+
+// empty returns true if neither r nor the global registry have codecs.
+func (r *CodecRegistry) empty() bool {
+	return atomic.LoadInt32(&globalCodecs.n) == 0 && (r == nil || atomic.LoadInt32(&r.n) == 0)
+}
+
+func (r *CodecRegistry) marshalerFor(info TypeInfo, goType reflect.Type) func(TypeInfo, interface{}) ([]byte, error) {
+	for _, reg := range [...]*CodecRegistry{r, globalCodecs} {
+		if codec, ok := reg.get(info, goType); ok && codec.Marshal != nil {
+			return codec.Marshal
+		}
+	}
+	return nil
+}
+
+func (r *CodecRegistry) unmarshalerFor(info TypeInfo, goType reflect.Type) func(TypeInfo, []byte, interface{}) error {
+	for _, reg := range [...]*CodecRegistry{r, globalCodecs} {
+		if codec, ok := reg.get(info, goType); ok && codec.Unmarshal != nil {
+			return codec.Unmarshal
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testMoney is a type without Marshaler and Unmarshaler, encoded as bigint
+// cents by testMoneyCodec.
+type testMoney struct {
+	cents int64
+}
+
+var testMoneyCodec = Codec{
+	Marshal: func(info TypeInfo, value interface{}) ([]byte, error) {
+		return encBigInt(value.(testMoney).cents), nil
+	},
+	Unmarshal: func(info TypeInfo, data []byte, value interface{}) error {
+		if len(data) != 8 {
+			return fmt.Errorf("expected 8 bytes, got %d", len(data))
+		}
+		value.(*testMoney).cents = decBigInt(data)
+		return nil
+	},
+}
+
+var testMoneyType = reflect.TypeOf(testMoney{})
+
+func TestCodecRegistry(t *testing.T) {
+	codecs := NewCodecRegistry()
+	codecs.Register(TypeBigInt, testMoneyType, testMoneyCodec)
+	bigint := NewNativeType(protoVersion4, TypeBigInt)
+
+	data, err := marshalCQL(codecs, bigint, testMoney{150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, encBigInt(150)) {
+		t.Fatalf("expected the codec encoding, got %x", data)
+	}
+	if data, err := marshalCQL(codecs, bigint, &testMoney{150}); err != nil || !bytes.Equal(data, encBigInt(150)) {
+		t.Fatalf("expected pointers to be marshaled with the codec, got %x: %v", data, err)
+	}
+
+	var money testMoney
+	if err := unmarshalCQL(codecs, bigint, data, &money); err != nil {
+		t.Fatal(err)
+	}
+	if money.cents != 150 {
+		t.Fatalf("expected 150 cents, got %d", money.cents)
+	}
+
+	nullable := &testMoney{}
+	if err := unmarshalCQL(codecs, bigint, nil, &nullable); err != nil || nullable != nil {
+		t.Fatalf("expected null to unmarshal to nil, got %v: %v", nullable, err)
+	}
+	if err := unmarshalCQL(codecs, bigint, data, &nullable); err != nil || nullable == nil || nullable.cents != 150 {
+		t.Fatalf("expected 150 cents, got %v: %v", nullable, err)
+	}
+
+	// other types of the same CQL type use the built-in conversions
+	var n int64
+	if err := unmarshalCQL(codecs, bigint, data, &n); err != nil || n != 150 {
+		t.Fatalf("expected 150, got %d: %v", n, err)
+	}
+
+	// the codec is not registered globally
+	if _, err := Marshal(bigint, testMoney{150}); err == nil {
+		t.Fatal("expected Marshal to fail without the codec")
+	}
+
+	codecs.Unregister(TypeBigInt, testMoneyType)
+	if _, err := marshalCQL(codecs, bigint, testMoney{150}); err == nil {
+		t.Fatal("expected the unregistered codec not to be used")
+	}
+}
+
+func TestCodecRegistryNested(t *testing.T) {
+	codecs := NewCodecRegistry()
+	codecs.Register(TypeBigInt, testMoneyType, testMoneyCodec)
+
+	bigint := NewNativeType(protoVersion4, TypeBigInt)
+	text := NewNativeType(protoVersion4, TypeText)
+
+	tests := []struct {
+		name  string
+		info  TypeInfo
+		value interface{}
+		dest  interface{}
+	}{
+		{
+			name:  "list",
+			info:  CollectionType{NativeType: NewNativeType(protoVersion4, TypeList), Elem: bigint},
+			value: []testMoney{{1}, {2}},
+			dest:  &[]testMoney{},
+		},
+		{
+			name:  "map",
+			info:  CollectionType{NativeType: NewNativeType(protoVersion4, TypeMap), Key: text, Elem: bigint},
+			value: map[string]testMoney{"a": {1}, "b": {2}},
+			dest:  &map[string]testMoney{},
+		},
+		{
+			name:  "vector",
+			info:  VectorType{NativeType: NewCustomType(protoVersion4, TypeCustom, "org.apache.cassandra.db.marshal.VectorType"), SubType: bigint, Dimensions: 2},
+			value: []testMoney{{1}, {2}},
+			dest:  &[]testMoney{},
+		},
+		{
+			name: "tuple",
+			info: TupleTypeInfo{NativeType: NewNativeType(protoVersion4, TypeTuple), Elems: []TypeInfo{text, bigint}},
+			value: struct {
+				Name  string
+				Price testMoney
+			}{"a", testMoney{1}},
+			dest: &struct {
+				Name  string
+				Price testMoney
+			}{},
+		},
+		{
+			name: "udt",
+			info: UDTTypeInfo{
+				NativeType: NewNativeType(protoVersion4, TypeUDT),
+				Name:       "item",
+				Elements:   []UDTField{{Name: "name", Type: text}, {Name: "price", Type: bigint}},
+			},
+			value: struct {
+				Name  string    `cql:"name"`
+				Price testMoney `cql:"price"`
+			}{"a", testMoney{1}},
+			dest: &struct {
+				Name  string    `cql:"name"`
+				Price testMoney `cql:"price"`
+			}{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := marshalCQL(codecs, test.info, test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := unmarshalCQL(codecs, test.info, data, test.dest); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(test.dest).Elem().Interface(); !reflect.DeepEqual(got, test.value) {
+				t.Fatalf("expected %v, got %v", test.value, got)
+			}
+
+			if _, err := Marshal(test.info, test.value); err == nil {
+				t.Fatal("expected Marshal to fail without the codec")
+			}
+		})
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	const class = "com.example.MoneyType"
+	RegisterCodec(TypeBigInt, testMoneyType, testMoneyCodec)
+	RegisterCustomCodec(class, testMoneyType, testMoneyCodec)
+	defer globalCodecs.Unregister(TypeBigInt, testMoneyType)
+	defer globalCodecs.UnregisterCustom(class, testMoneyType)
+
+	for _, info := range []TypeInfo{NewNativeType(protoVersion4, TypeBigInt), NewCustomType(protoVersion4, TypeCustom, class)} {
+		data, err := Marshal(info, testMoney{42})
+		if err != nil {
+			t.Fatalf("%s: %v", info, err)
+		}
+		var money testMoney
+		if err := Unmarshal(info, data, &money); err != nil || money.cents != 42 {
+			t.Fatalf("%s: expected 42 cents, got %d: %v", info, money.cents, err)
+		}
+	}
+
+	if _, err := Marshal(NewCustomType(protoVersion4, TypeCustom, "com.example.OtherType"), testMoney{42}); err == nil {
+		t.Fatal("expected other custom types not to use the codec")
+	}
+
+	// codecs of a registry take precedence over global codecs
+	codecs := NewCodecRegistry()
+	codecs.Register(TypeBigInt, testMoneyType, Codec{
+		Marshal: func(info TypeInfo, value interface{}) ([]byte, error) {
+			return encBigInt(value.(testMoney).cents * 100), nil
+		},
+	})
+	data, err := marshalCQL(codecs, NewNativeType(protoVersion4, TypeBigInt), testMoney{42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, encBigInt(4200)) {
+		t.Fatalf("expected the registry codec to be used, got %x", data)
+	}
+
+	// the global codec unmarshals as the registry codec doesn't
+	var money testMoney
+	if err := unmarshalCQL(codecs, NewNativeType(protoVersion4, TypeBigInt), data, &money); err != nil || money.cents != 4200 {
+		t.Fatalf("expected 4200 cents, got %d: %v", money.cents, err)
+	}
+}
@@ -65,6 +65,12 @@ func (s SnappyCompressor) AppendDecompressedWithLength(dst, src []byte) ([]byte,
 	return snappy.Decode(dst, src)
 }
 
+// DecompressedLength returns the decompressed length of a frame body
+// compressed with AppendCompressedWithLength.
+func (s SnappyCompressor) DecompressedLength(src []byte) (int, error) {
+	return snappy.DecodedLen(src)
+}
+
 func (s SnappyCompressor) AppendCompressed(dst, src []byte) ([]byte, error) {
 	panic("SnappyCompressor.AppendCompressed is not supported")
 }
@@ -130,6 +130,20 @@ type SslOptions struct {
 	//
 	// See SslOptions documentation to see how EnableHostVerification interacts with the provided tls.Config.
 	EnableHostVerification bool
+
+	// ReloadInterval enables reloading CaPath, CertPath and KeyPath when they
+	// change, for certificates rotated while the session runs. The files are
+	// checked at most once per interval when a connection is established, and
+	// only new connections use the reloaded certificates. Certificates can
+	// also be provided by the GetClientCertificate callback of the tls.Config.
+	// Disabled if <= 0 (default: 0).
+	ReloadInterval time.Duration
+
+	// RecycleBeforeExpiry replaces connections whose client certificate
+	// expires within this duration, at a random time during the first half of
+	// it, with connections using the current certificate. It is meant to be
+	// used with ReloadInterval. Disabled if <= 0 (default: 0).
+	RecycleBeforeExpiry time.Duration
 }
 
 type ConnConfig struct {
@@ -143,11 +157,16 @@ type ConnConfig struct {
 	Compressor     Compressor
 	Authenticator  Authenticator
 	AuthProvider   func(h *HostInfo) (Authenticator, error)
-	Keepalive      time.Duration
-	Logger         StdLogger
+	// CredentialsProvider takes precedence over Authenticator and AuthProvider.
+	CredentialsProvider CredentialsProvider
+	Keepalive           time.Duration
+	Logger              StdLogger
 
 	tlsConfig       *tls.Config
 	disableCoalesce bool
+	// negotiateVersion is set when ClusterConfig.ProtoVersion is not set, the
+	// protocol version is then negotiated with every host when dialing it.
+	negotiateVersion bool
 }
 
 func (c *ConnConfig) logger() StdLogger {
@@ -178,6 +197,9 @@ type Conn struct {
 	cfg            *ConnConfig
 	frameObserver  FrameHeaderObserver
 	streamObserver StreamObserver
+	recorder       FrameRecorder
+	decodeObserver FrameObserver
+	limits         *responseLimits
 
 	headerBuf [maxFrameHeaderSize]byte
 
@@ -208,37 +230,113 @@ type Conn struct {
 
 	timeouts int64
 
+	// expiresAt is the time after which the owning hostConnPool replaces the connection.
+	// It is zero if the connection never expires.
+	expiresAt time.Time
+	// certExpiry is when the client certificate of the connection expires, it
+	// is zero if unknown.
+	certExpiry time.Time
+	// credentials are used instead of the ones of the CredentialsProvider
+	// when retrying with refreshed credentials.
+	credentials *Credentials
+	// tls is set if the connection is a TLS connection.
+	tls bool
+	// lastUsed is the unix time in nanoseconds at which the connection was last
+	// picked by a hostConnPool, accessed atomically.
+	lastUsed int64
+
 	logger StdLogger
 }
 
 // connect establishes a connection to a Cassandra node using session's connection config.
 func (s *Session) connect(ctx context.Context, host *HostInfo, errorHandler ConnErrorHandler) (*Conn, error) {
-	return s.dial(ctx, host, s.connCfg, errorHandler)
+	return s.dial(ctx, host, s.connConfig(), errorHandler)
 }
 
 // dial establishes a connection to a Cassandra node and notifies the session's connectObserver.
 func (s *Session) dial(ctx context.Context, host *HostInfo, connConfig *ConnConfig, errorHandler ConnErrorHandler) (*Conn, error) {
+	s.mu.RLock()
+	connectObserver := s.connectObserver
+	s.mu.RUnlock()
+
 	var obs ObservedConnect
-	if s.connectObserver != nil {
+	if connectObserver != nil {
 		obs.Host = host
 		obs.Start = time.Now()
 	}
 
-	conn, err := s.dialWithoutObserver(ctx, host, connConfig, errorHandler)
+	conn, err := s.dialNegotiated(ctx, host, connConfig, errorHandler)
 
-	if s.connectObserver != nil {
+	if connectObserver != nil {
 		obs.End = time.Now()
 		obs.Err = err
-		s.connectObserver.ObserveConnect(obs)
+		connectObserver.ObserveConnect(obs)
 	}
 
 	return conn, err
 }
 
+// dialNegotiated dials host with the protocol version last negotiated with
+// it, or the highest version supported by the driver, and switches to the
+// version the host reports as long as it rejects the one used. DSE nodes
+// reject v5 while listing higher DSE versions, every version is tried at most
+// once. ConnConfig.ProtoVersion is used as is when the version is not
+// negotiated.
+func (s *Session) dialNegotiated(ctx context.Context, host *HostInfo, cfg *ConnConfig, errorHandler ConnErrorHandler) (*Conn, error) {
+	if !cfg.negotiateVersion {
+		return s.dialWithoutObserver(ctx, host, cfg, errorHandler)
+	}
+
+	version := host.ProtocolVersion()
+	if version == 0 {
+		version = maxProtocolVersion
+		if host.DSEVersion() != "" {
+			// DSE nodes support their own protocol versions
+			version = protoVersionDSE2
+		}
+	}
+
+	tried := make(map[int]bool)
+	for {
+		versionCfg := *cfg
+		versionCfg.ProtoVersion = version
+
+		conn, err := s.dialWithoutObserver(ctx, host, &versionCfg, errorHandler)
+		if err == nil {
+			host.setProtocolVersion(version)
+			return conn, nil
+		}
+		tried[version] = true
+
+		proto := parseProtocolFromError(err)
+		if proto < protoVersion1 || tried[proto] || (proto > maxProtocolVersion && !isDSEVersion(byte(proto))) {
+			return nil, err
+		}
+		version = proto
+	}
+}
+
 // dialWithoutObserver establishes connection to a Cassandra node.
 //
 // dialWithoutObserver does not notify the connection observer, so you most probably want to call dial() instead.
 func (s *Session) dialWithoutObserver(ctx context.Context, host *HostInfo, cfg *ConnConfig, errorHandler ConnErrorHandler) (*Conn, error) {
+	conn, err := s.dialConn(ctx, host, cfg, errorHandler, nil)
+
+	var rejected *errCredentialsRejected
+	if err != nil && errors.As(err, &rejected) {
+		// the credentials might have been rotated, try once more with fresh ones
+		creds, refreshErr := cfg.CredentialsProvider.Refresh(ctx, rejected.creds)
+		if refreshErr != nil {
+			return nil, refreshErr
+		}
+		return s.dialConn(ctx, host, cfg, errorHandler, &creds)
+	}
+	return conn, err
+}
+
+// dialConn establishes a connection to host, authenticating with creds if
+// not nil and a CredentialsProvider is configured.
+func (s *Session) dialConn(ctx context.Context, host *HostInfo, cfg *ConnConfig, errorHandler ConnErrorHandler, creds *Credentials) (*Conn, error) {
 	dialedHost, err := cfg.HostDialer.DialHost(ctx, host)
 	if err != nil {
 		return nil, err
@@ -255,17 +353,20 @@ func (s *Session) dialWithoutObserver(ctx context.Context, host *HostInfo, cfg *
 			conn: dialedHost.Conn,
 			r:    bufio.NewReader(dialedHost.Conn),
 		},
-		cfg:           cfg,
-		calls:         make(map[int]*callReq),
-		version:       uint8(cfg.ProtoVersion),
-		addr:          dialedHost.Conn.RemoteAddr().String(),
-		errorHandler:  errorHandler,
-		compressor:    cfg.Compressor,
-		session:       s,
-		streams:       streams.New(cfg.ProtoVersion),
-		host:          host,
-		isSchemaV2:    true, // Try using "system.peers_v2" until proven otherwise
-		frameObserver: s.frameObserver,
+		cfg:            cfg,
+		calls:          make(map[int]*callReq),
+		version:        uint8(cfg.ProtoVersion),
+		addr:           dialedHost.Conn.RemoteAddr().String(),
+		errorHandler:   errorHandler,
+		compressor:     cfg.Compressor,
+		session:        s,
+		streams:        streams.New(cfg.ProtoVersion),
+		host:           host,
+		isSchemaV2:     true, // Try using "system.peers_v2" until proven otherwise
+		frameObserver:  s.frameObserver,
+		recorder:       s.cfg.FrameRecorder,
+		decodeObserver: s.cfg.FrameObserver,
+		limits:         newResponseLimits(&s.cfg),
 		w: &deadlineContextWriter{
 			w:         dialedHost.Conn,
 			timeout:   writeTimeout,
@@ -277,6 +378,10 @@ func (s *Session) dialWithoutObserver(ctx context.Context, host *HostInfo, cfg *
 		logger:         cfg.logger(),
 		streamObserver: s.streamObserver,
 		writeTimeout:   writeTimeout,
+		lastUsed:       time.Now().UnixNano(),
+		certExpiry:     dialedHost.certExpiry,
+		credentials:    creds,
+		tls:            isTLSConn(dialedHost.Conn),
 	}
 
 	if err := c.init(ctx, dialedHost); err != nil {
@@ -411,6 +516,10 @@ func (s *startupCoordinator) options(ctx context.Context, startupCompleted *atom
 		return NewErrProtocol("Unknown type of response to startup frame: %T", frame)
 	}
 
+	if s.conn.host != nil {
+		s.conn.host.setSupportedOptions(supported.supported)
+	}
+
 	return s.startup(ctx, supported.supported, startupCompleted)
 }
 
@@ -422,18 +531,15 @@ func (s *startupCoordinator) startup(ctx context.Context, supported map[string][
 	}
 
 	if s.conn.compressor != nil {
-		comp := supported["COMPRESSION"]
 		name := s.conn.compressor.Name()
-		for _, compressor := range comp {
-			if compressor == name {
-				m["COMPRESSION"] = compressor
-				break
+		if !SupportedOptions(supported).SupportsCompression(name) {
+			return &ErrCompressorNotSupported{
+				Addr:       s.conn.addr,
+				Compressor: name,
+				Supported:  supported["COMPRESSION"],
 			}
 		}
-
-		if _, ok := m["COMPRESSION"]; !ok {
-			s.conn.compressor = nil
-		}
+		m["COMPRESSION"] = name
 	}
 
 	frame, err := s.write(ctx, &writeStartupFrame{opts: m}, startupCompleted)
@@ -458,11 +564,41 @@ func (s *startupCoordinator) startup(ctx context.Context, supported map[string][
 }
 
 func (s *startupCoordinator) authenticateHandshake(ctx context.Context, authFrame *authenticateFrame, startupCompleted *atomic.Bool) error {
-	if s.conn.auth == nil {
+	auth := s.conn.auth
+	if provider := s.conn.cfg.CredentialsProvider; provider != nil {
+		creds, err := s.credentials(ctx, provider)
+		if err != nil {
+			return err
+		}
+		auth = PasswordAuthenticator{Username: creds.Username, Password: creds.Password}
+
+		err = s.authenticate(ctx, auth, authFrame, startupCompleted)
+		if reqErr, ok := err.(RequestError); ok && reqErr.Code() == ErrCodeCredentials && s.conn.credentials == nil {
+			return &errCredentialsRejected{creds: creds, err: err}
+		}
+		return err
+	}
+
+	if auth == nil {
 		return fmt.Errorf("authentication required (using %q)", authFrame.class)
 	}
+	if requiresTLS(auth) && !s.conn.tls {
+		return ErrMutualTLSRequired
+	}
+	return s.authenticate(ctx, auth, authFrame, startupCompleted)
+}
+
+// credentials returns the credentials to authenticate with, the refreshed
+// credentials of the connection if set.
+func (s *startupCoordinator) credentials(ctx context.Context, provider CredentialsProvider) (Credentials, error) {
+	if s.conn.credentials != nil {
+		return *s.conn.credentials, nil
+	}
+	return provider.Credentials(ctx)
+}
 
-	resp, challenger, err := s.conn.auth.Challenge([]byte(authFrame.class))
+func (s *startupCoordinator) authenticate(ctx context.Context, auth Authenticator, authFrame *authenticateFrame, startupCompleted *atomic.Bool) error {
+	resp, challenger, err := auth.Challenge([]byte(authFrame.class))
 	if err != nil {
 		return err
 	}
@@ -523,9 +659,18 @@ func (c *Conn) closeWithError(err error) {
 
 	for _, req := range callsToClose {
 		// we need to send the error to all waiting queries.
-		select {
-		case req.resp <- callResp{err: err}:
-		case <-req.timeout:
+		if req.continuous {
+			// the pages might not be read anymore, the pager also watches
+			// the connection context
+			select {
+			case req.resp <- callResp{err: err}:
+			default:
+			}
+		} else {
+			select {
+			case req.resp <- callResp{err: err}:
+			case <-req.timeout:
+			}
 		}
 		if req.streamObserverContext != nil {
 			req.streamObserverEndOnce.Do(func() {
@@ -633,7 +778,7 @@ func (c *Conn) heartBeat(ctx context.Context) {
 func (c *Conn) recv(ctx context.Context, startupCompleted bool) error {
 	// If startup is completed and native proto 5+ is set up then we should
 	// unwrap payload from compressed/uncompressed frame
-	if startupCompleted && c.version > protoVersion4 {
+	if startupCompleted && modernLayout(c.version) {
 		return c.recvSegment(ctx)
 	}
 
@@ -675,9 +820,13 @@ func (c *Conn) processFrame(ctx context.Context, r io.Reader) error {
 	} else if head.stream == -1 {
 		// TODO: handle cassandra event frames, we shouldnt get any currently
 		framer := newFramer(c.compressor, c.version)
+		framer.malformed = c.closeWithError
 		if err := framer.readFrame(r, &head); err != nil {
 			return err
 		}
+		if c.recordsFrames() {
+			c.recordReceived(&head, framer.buf, r != io.Reader(c.r))
+		}
 		go c.session.handleEvent(framer)
 		return nil
 	} else if head.stream <= 0 {
@@ -687,6 +836,9 @@ func (c *Conn) processFrame(ctx context.Context, r io.Reader) error {
 		if err := framer.readFrame(r, &head); err != nil {
 			return err
 		}
+		if c.recordsFrames() {
+			c.recordReceived(&head, framer.buf, r != io.Reader(c.r))
+		}
 
 		frame, err := framer.parseFrame()
 		if err != nil {
@@ -698,22 +850,29 @@ func (c *Conn) processFrame(ctx context.Context, r io.Reader) error {
 		}
 	}
 
-	c.mu.Lock()
-	if c.closed {
-		c.mu.Unlock()
-		return ErrConnectionClosed
-	}
-	call, ok := c.calls[head.stream]
-	delete(c.calls, head.stream)
-	c.mu.Unlock()
-	if call == nil || !ok {
+	call, err := c.takeCall(head.stream)
+	if err != nil {
+		return err
+	} else if call == nil {
 		c.logger.Printf("gocql: received response for stream which has no handler: header=%v\n", head)
 		return c.discardFrame(r, head)
-	} else if head.stream != call.streamID {
-		panic(fmt.Sprintf("call has incorrect streamID: got %d expected %d", call.streamID, head.stream))
 	}
 
-	framer := newFramer(c.compressor, c.version)
+	if err := c.limits.checkLength(&head); err != nil {
+		// drop the response without buffering it, the connection is fine
+		if err := c.discardFrame(r, head); err != nil {
+			return err
+		}
+		c.respond(ctx, call, callResp{err: err})
+		return nil
+	}
+
+	framer := getFramer(c.compressor, c.version)
+	// the frame is parsed by the caller, a malformed body means the node, or
+	// something in between, can't be trusted anymore so only this
+	// connection is closed.
+	framer.malformed = c.closeWithError
+	framer.limits = c.limits
 
 	err = framer.readFrame(r, &head)
 	if err != nil {
@@ -722,18 +881,53 @@ func (c *Conn) processFrame(ctx context.Context, r io.Reader) error {
 		if _, ok := err.(net.Error); ok {
 			return err
 		}
+	} else if c.recordsFrames() {
+		c.recordReceived(&head, framer.buf, r != io.Reader(c.r))
+	}
+
+	c.respond(ctx, call, callResp{framer: framer, err: err})
+	return nil
+}
+
+// takeCall removes and returns the call waiting for a response on stream, or
+// nil if there is none.
+func (c *Conn) takeCall(stream int) (*callReq, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrConnectionClosed
+	}
+	call, ok := c.calls[stream]
+	if call == nil || !call.continuous {
+		delete(c.calls, stream)
+	}
+	c.mu.Unlock()
+	if call == nil || !ok {
+		return nil, nil
+	} else if stream != call.streamID {
+		panic(fmt.Sprintf("call has incorrect streamID: got %d expected %d", call.streamID, stream))
+	}
+
+	return call, nil
+}
+
+// respond hands resp over to call.
+func (c *Conn) respond(ctx context.Context, call *callReq, resp callResp) {
+	if call.continuous {
+		c.respondContinuous(call, resp)
+		return
 	}
 
 	// we either, return a response to the caller, the caller timedout, or the
 	// connection has closed. Either way we should never block indefinatly here
 	select {
-	case call.resp <- callResp{framer: framer, err: err}:
+	case call.resp <- resp:
 	case <-call.timeout:
 		c.releaseStream(call)
+		resp.framer.release()
 	case <-ctx.Done():
+		resp.framer.release()
 	}
-
-	return nil
 }
 
 func (c *Conn) releaseStream(call *callReq) {
@@ -785,12 +979,26 @@ func (c *Conn) recvSegment(ctx context.Context) error {
 	}
 
 	const frameHeaderLength = 9
-	buf := bytes.NewBuffer(make([]byte, 0, head.length+frameHeaderLength))
-	buf.Write(frame)
-
 	// Computing how many bytes of message left to read
 	bytesToRead := head.length - len(frame) + frameHeaderLength
 
+	if err := c.limits.checkLength(&head); err != nil {
+		// drop the segments of the response without buffering them
+		if err := c.recvPartialFrames(nil, bytesToRead); err != nil {
+			return err
+		}
+		call, cerr := c.takeCall(head.stream)
+		if cerr != nil {
+			return cerr
+		} else if call != nil {
+			c.respond(ctx, call, callResp{err: err})
+		}
+		return nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, head.length+frameHeaderLength))
+	buf.Write(frame)
+
 	err = c.recvPartialFrames(buf, bytesToRead)
 	if err != nil {
 		return err
@@ -800,7 +1008,7 @@ func (c *Conn) recvSegment(ctx context.Context) error {
 }
 
 // recvPartialFrames reads proto v5 segments from Conn.r and writes decoded partial frames to dst.
-// It reads data until the bytesToRead is reached.
+// It reads data until the bytesToRead is reached, the segments are dropped if dst is nil.
 // If Conn.compressor is not nil, it processes Compressed Format segments.
 func (c *Conn) recvPartialFrames(dst *bytes.Buffer, bytesToRead int) error {
 	var (
@@ -825,6 +1033,13 @@ func (c *Conn) recvPartialFrames(dst *bytes.Buffer, bytesToRead int) error {
 			return fmt.Errorf("gocql: received self-contained segment, but expected not")
 		}
 
+		if dst == nil {
+			if read += len(frame); read > bytesToRead {
+				return fmt.Errorf("gocql: expected partial frame of length %d, got %d", bytesToRead, read)
+			}
+			continue
+		}
+
 		if totalLength := dst.Len() + len(frame); totalLength > dst.Cap() {
 			return fmt.Errorf("gocql: expected partial frame of length %d, got %d", dst.Cap(), totalLength)
 		}
@@ -860,9 +1075,11 @@ type ConnReader interface {
 // connReader implements ConnReader.
 // It retries to read data up to 5 times or returns error.
 type connReader struct {
-	conn    net.Conn
-	r       *bufio.Reader
-	timeout time.Duration
+	conn net.Conn
+	r    *bufio.Reader
+	// timeout is a time.Duration, accessed atomically as it can be changed
+	// by Session.Reconfigure.
+	timeout int64
 }
 
 func (c *connReader) Read(p []byte) (n int, err error) {
@@ -870,8 +1087,8 @@ func (c *connReader) Read(p []byte) (n int, err error) {
 
 	for i := 0; i < maxAttempts; i++ {
 		var nn int
-		if c.timeout > 0 {
-			c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		if timeout := c.GetTimeout(); timeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(timeout))
 		}
 
 		nn, err = io.ReadFull(c.r, p[n:])
@@ -917,11 +1134,11 @@ func (c *connReader) SetWriteDeadline(t time.Time) error {
 }
 
 func (c *connReader) SetTimeout(timeout time.Duration) {
-	c.timeout = timeout
+	atomic.StoreInt64(&c.timeout, int64(timeout))
 }
 
 func (c *connReader) GetTimeout() time.Duration {
-	return c.timeout
+	return time.Duration(atomic.LoadInt64(&c.timeout))
 }
 
 type callReq struct {
@@ -938,6 +1155,14 @@ type callReq struct {
 	// streamObserverEndOnce ensures that either StreamAbandoned or StreamFinished is called,
 	// but not both.
 	streamObserverEndOnce sync.Once
+
+	// continuous is set for DSE continuous paging requests, which receive
+	// several responses. finished is set once the last one was received,
+	// overflow is closed once a page was dropped because resp was full.
+	continuous   bool
+	finished     atomic.Bool
+	overflow     chan struct{}
+	overflowOnce sync.Once
 }
 
 type callResp struct {
@@ -1188,8 +1413,8 @@ func (c *Conn) execInternal(ctx context.Context, req frameBuilder, tracer Tracer
 		return nil, ErrNoStreams
 	}
 
-	// resp is basically a waiting semaphore protecting the framer
-	framer := newFramer(c.compressor, c.version)
+	// the framer only holds the request, it is released once written
+	framer := getFramer(c.compressor, c.version)
 
 	call := &callReq{
 		timeout:  make(chan struct{}),
@@ -1221,6 +1446,7 @@ func (c *Conn) execInternal(ctx context.Context, req frameBuilder, tracer Tracer
 
 	err := req.buildFrame(framer, stream)
 	if err != nil {
+		framer.release()
 		// closeWithError will block waiting for this stream to either receive a response
 		// or for us to timeout.
 		close(call.timeout)
@@ -1237,14 +1463,19 @@ func (c *Conn) execInternal(ctx context.Context, req frameBuilder, tracer Tracer
 		return nil, err
 	}
 
+	if c.recordsFrames() {
+		c.recordSent(req, framer.flags, stream, modernLayout(c.version) && startupCompleted)
+	}
+
 	var n int
 
-	if c.version > protoVersion4 && startupCompleted {
+	if modernLayout(c.version) && startupCompleted {
 		err = framer.prepareModernLayout()
 	}
 	if err == nil {
 		n, err = c.w.writeContext(ctx, framer.buf)
 	}
+	framer.release()
 	if err != nil {
 		// closeWithError will block waiting for this stream to either receive a response
 		// or for us to timeout, close the timeout chan here. Im not entirely sure
@@ -1395,7 +1626,11 @@ type inflightPrepare struct {
 }
 
 func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer, keyspace string) (*preparedStatment, error) {
-	stmtCacheKey := c.session.stmtsLRU.keyFor(c.host.HostID(), keyspace, stmt)
+	stmtCacheKey := c.session.stmtsLRU.keyFor(c.version, c.host.HostID(), keyspace, stmt)
+	return c.prepareCachedStatement(ctx, stmtCacheKey, stmt, tracer, keyspace)
+}
+
+func (c *Conn) prepareCachedStatement(ctx context.Context, stmtCacheKey, stmt string, tracer Tracer, keyspace string) (*preparedStatment, error) {
 	flight, ok := c.session.stmtsLRU.execIfMissing(stmtCacheKey, func(lru *lru.Cache) *inflightPrepare {
 		flight := &inflightPrepare{
 			done: make(chan struct{}),
@@ -1411,7 +1646,7 @@ func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer,
 			prep := &writePrepareFrame{
 				statement: stmt,
 			}
-			if c.version > protoVersion4 {
+			if supportsKeyspace(c.version) {
 				prep.keyspace = keyspace
 			}
 
@@ -1470,14 +1705,14 @@ func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer,
 	}
 }
 
-func marshalQueryValue(typ TypeInfo, value interface{}, dst *queryValues) error {
+func marshalQueryValue(codecs *CodecRegistry, plan *columnPlan, typ TypeInfo, value interface{}, dst *queryValues) error {
 	if named, ok := value.(*namedValue); ok {
 		dst.name = named.name
 		value = named.value
 	}
 
 	if _, ok := value.(unsetColumn); !ok {
-		val, err := Marshal(typ, value)
+		val, err := plan.marshal(codecs, typ, value)
 		if err != nil {
 			return err
 		}
@@ -1491,6 +1726,10 @@ func marshalQueryValue(typ TypeInfo, value interface{}, dst *queryValues) error
 }
 
 func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
+	if qry.executeAs != "" && !supportsProxyExecute(c.host, c.version) {
+		return &Iter{err: ErrExecuteAsNotSupported}
+	}
+
 	params := queryParams{
 		consistency: qry.cons,
 	}
@@ -1506,10 +1745,16 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 	if qry.pageSize > 0 {
 		params.pageSize = qry.pageSize
 	}
-	if c.version > protoVersion4 {
+	if supportsKeyspace(c.version) {
 		params.keyspace = qry.keyspace
+	}
+	if supportsNowInSeconds(c.version) {
 		params.nowInSeconds = qry.nowInSecondsValue
 	}
+	// continuous paging falls back to regular paging with other versions
+	if qry.continuous != nil && isDSEVersion(c.version) {
+		params.continuous = qry.continuous.params()
+	}
 
 	// If a keyspace for the qry is overriden,
 	// then we should use it to create stmt cache key
@@ -1554,7 +1799,7 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 			v := &params.values[i]
 			value := values[i]
 			typ := info.request.columns[i].TypeInfo
-			if err := marshalQueryValue(typ, value, v); err != nil {
+			if err := marshalQueryValue(c.session.codecs, info.request.plan(i), typ, value, v); err != nil {
 				return &Iter{err: err}
 			}
 		}
@@ -1565,7 +1810,7 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 		frame = &writeExecuteFrame{
 			preparedID:       info.id,
 			params:           params,
-			customPayload:    qry.customPayload,
+			customPayload:    withExecuteAs(qry.customPayload, qry.executeAs),
 			resultMetadataID: info.resultMetadataID,
 		}
 
@@ -1581,17 +1826,29 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 		frame = &writeQueryFrame{
 			statement:     qry.stmt,
 			params:        params,
-			customPayload: qry.customPayload,
+			customPayload: withExecuteAs(qry.customPayload, qry.executeAs),
 		}
 	}
 
-	framer, err := c.exec(ctx, frame, qry.trace)
+	var (
+		framer *framer
+		pager  *continuousPager
+		err    error
+	)
+	if params.continuous != nil {
+		pager, framer, err = c.execContinuous(ctx, frame, qry, params.continuous.nextPages)
+	} else {
+		framer, err = c.exec(ctx, frame, qry.trace)
+	}
 	if err != nil {
 		return &Iter{err: err}
 	}
 
 	resp, err := framer.parseFrame()
 	if err != nil {
+		if pager != nil {
+			pager.cancel()
+		}
 		return &Iter{err: err}
 	}
 
@@ -1607,7 +1864,7 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 			// If a RESULT/Rows message reports
 			//      changed resultset metadata with the Metadata_changed flag, the reported new
 			//      resultset metadata must be used in subsequent executions
-			stmtCacheKey := c.session.stmtsLRU.keyFor(c.host.HostID(), usedKeyspace, qry.stmt)
+			stmtCacheKey := c.session.stmtsLRU.keyFor(c.version, c.host.HostID(), usedKeyspace, qry.stmt)
 			oldInflight, ok := c.session.stmtsLRU.get(stmtCacheKey)
 			if ok {
 				newInflight := &inflightPrepare{
@@ -1633,6 +1890,7 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 			meta:    x.meta,
 			framer:  framer,
 			numRows: x.numRows,
+			codecs:  c.session.codecs,
 		}
 
 		if x.meta.noMetaData() {
@@ -1646,10 +1904,16 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 			iter.meta = x.meta
 		}
 
+		if pager != nil {
+			pager.info = info
+			return pager.page(iter, &x.meta)
+		}
+
 		if x.meta.morePages() && !qry.disableAutoPage {
 			newQry := new(Query)
 			*newQry = *qry
 			newQry.pageState = copyBytes(x.meta.pagingState)
+			newQry.pageStateVersion = int(c.version)
 			newQry.metrics = &queryMetrics{m: make(map[string]*hostMetrics)}
 
 			iter.next = &nextIter{
@@ -1676,7 +1940,7 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 		// is not consistent with regards to its schema.
 		return iter
 	case *RequestErrUnprepared:
-		stmtCacheKey := c.session.stmtsLRU.keyFor(c.host.HostID(), usedKeyspace, qry.stmt)
+		stmtCacheKey := c.session.stmtsLRU.keyFor(c.version, c.host.HostID(), usedKeyspace, qry.stmt)
 		c.session.stmtsLRU.evictPreparedID(stmtCacheKey, x.StatementId)
 		return c.executeQuery(ctx, qry)
 	case error:
@@ -1710,6 +1974,22 @@ func (c *Conn) AvailableStreams() int {
 	return c.streams.Available()
 }
 
+// inFlight returns the number of requests waiting for a response on this connection.
+func (c *Conn) inFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.calls)
+}
+
+func (c *Conn) markUsed(now time.Time) {
+	atomic.StoreInt64(&c.lastUsed, now.UnixNano())
+}
+
+// idleSince returns the time at which the connection was last picked for a request.
+func (c *Conn) idleSince() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastUsed))
+}
+
 func (c *Conn) UseKeyspace(keyspace string) error {
 	q := &writeQueryFrame{statement: `USE "` + keyspace + `"`}
 	q.params.consistency = c.session.cons
@@ -1741,6 +2021,9 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 	if c.version == protoVersion1 {
 		return &Iter{err: ErrUnsupported}
 	}
+	if batch.executeAs != "" && !supportsProxyExecute(c.host, c.version) {
+		return &Iter{err: ErrExecuteAsNotSupported}
+	}
 
 	n := len(batch.Entries)
 	req := &writeBatchFrame{
@@ -1750,11 +2033,13 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 		serialConsistency:     batch.serialCons,
 		defaultTimestamp:      batch.defaultTimestamp,
 		defaultTimestampValue: batch.defaultTimestampValue,
-		customPayload:         batch.CustomPayload,
+		customPayload:         withExecuteAs(batch.CustomPayload, batch.executeAs),
 	}
 
-	if c.version > protoVersion4 {
+	if supportsKeyspace(c.version) {
 		req.keyspace = batch.keyspace
+	}
+	if supportsNowInSeconds(c.version) {
 		req.nowInSeconds = batch.nowInSeconds
 	}
 
@@ -1803,7 +2088,7 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 				v := &b.values[j]
 				value := values[j]
 				typ := info.request.columns[j].TypeInfo
-				if err := marshalQueryValue(typ, value, v); err != nil {
+				if err := marshalQueryValue(c.session.codecs, info.request.plan(j), typ, value, v); err != nil {
 					return &Iter{err: err}
 				}
 			}
@@ -1832,7 +2117,7 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 	case *RequestErrUnprepared:
 		stmt, found := stmts[string(x.StatementId)]
 		if found {
-			key := c.session.stmtsLRU.keyFor(c.host.HostID(), usedKeyspace, stmt)
+			key := c.session.stmtsLRU.keyFor(c.version, c.host.HostID(), usedKeyspace, stmt)
 			c.session.stmtsLRU.evictPreparedID(key, x.StatementId)
 		}
 		return c.executeBatch(ctx, batch)
@@ -1841,6 +2126,7 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 			meta:    x.meta,
 			framer:  framer,
 			numRows: x.numRows,
+			codecs:  c.session.codecs,
 		}
 
 		return iter
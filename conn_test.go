@@ -41,6 +41,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -696,6 +697,45 @@ func TestQueryTimeoutClose(t *testing.T) {
 	}
 }
 
+func TestConnMalformedFrame(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 2
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for the host")
+	}
+	for i := 0; pool.Size() < 2; i++ {
+		if i == 100 {
+			t.Fatalf("expected 2 connections, got %d", pool.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn := db.getConn()
+	err = conn.executeQuery(context.Background(), db.Query("malformed")).Close()
+	if _, ok := err.(ErrProtocol); !ok {
+		t.Fatalf("expected an ErrProtocol, got %T: %v", err, err)
+	} else if !strings.Contains(err.Error(), "malformed RESULT frame at offset 16") {
+		t.Fatalf("expected the error to give the offset, got: %v", err)
+	}
+
+	if !conn.Closed() {
+		t.Fatal("expected the connection which received the malformed frame to be closed")
+	}
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatalf("expected the other connection to still be usable: %v", err)
+	}
+}
+
 func TestStream0(t *testing.T) {
 	// TODO: replace this with type check
 	const expErr = "gocql: received unexpected frame on stream 0"
@@ -979,6 +1019,44 @@ func TestSkipMetadata(t *testing.T) {
 	}
 }
 
+func TestPreparedStatementPerProtocolVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewTestServer(t, protoVersion4, ctx)
+	defer srv.Stop()
+
+	db, err := newTestSession(protoVersion4, srv.Address)
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	const stmt = "select metadata"
+	conn := db.getConn()
+
+	// a statement prepared by a connection to the host using another protocol
+	// version must neither be used nor evicted.
+	other := &inflightPrepare{done: make(chan struct{}), preparedStatment: &preparedStatment{id: []byte("v3")}}
+	close(other.done)
+	otherKey := db.stmtsLRU.keyFor(protoVersion3, conn.host.HostID(), conn.currentKeyspace, stmt)
+	db.stmtsLRU.add(otherKey, other)
+
+	info, err := conn.prepareStatement(ctx, stmt, nil, conn.currentKeyspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(info.id, other.preparedStatment.id) {
+		t.Fatal("expected the statement to be prepared with protocol v4")
+	}
+	if flight, ok := db.stmtsLRU.get(otherKey); !ok || flight != other {
+		t.Fatal("expected the statement prepared with protocol v3 to stay cached")
+	}
+	if _, ok := db.stmtsLRU.get(db.stmtsLRU.keyFor(protoVersion4, conn.host.HostID(), conn.currentKeyspace, stmt)); !ok {
+		t.Fatal("expected the statement prepared with protocol v4 to be cached")
+	}
+}
+
 type recordingFrameHeaderObserver struct {
 	t      *testing.T
 	mu     sync.Mutex
@@ -1139,6 +1217,13 @@ type TestServer struct {
 	mu     sync.Mutex
 	closed bool
 
+	// continuous paging requests by stream, nContinuousPages counts the pages
+	// sent and nRevise the revise requests received.
+	continuousMu     sync.Mutex
+	continuous       map[int]*testContinuousStream
+	nContinuousPages int64
+	nRevise          int64
+
 	// onRecv is a hook point for tests, called in receive loop.
 	onRecv func(*framer)
 }
@@ -1254,6 +1339,50 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 		case "void":
 			respFrame.writeHeader(0, opResult, head.stream)
 			respFrame.writeInt(resultKindVoid)
+		case "large":
+			// a single row with a 64KiB blob
+			respFrame.writeHeader(0, opResult, head.stream)
+			respFrame.writeInt(resultKindRows)
+			respFrame.writeInt(int32(flagGlobalTableSpec))
+			respFrame.writeInt(1)
+			respFrame.writeString("ks")
+			respFrame.writeString("t")
+			respFrame.writeString("v")
+			respFrame.writeShort(uint16(TypeBlob))
+			respFrame.writeInt(1)
+			respFrame.writeBytes(make([]byte, 64*1024))
+		case "malformed":
+			// rows result cut short in the column specs
+			respFrame.writeHeader(0, opResult, head.stream)
+			respFrame.writeInt(resultKindRows)
+			respFrame.writeInt(int32(flagGlobalTableSpec))
+			respFrame.writeInt(3)
+			respFrame.writeString("ks")
+		case "continuous":
+			// continuous <n> returns n rows, one per page with continuous paging
+			n, err := strconv.Atoi(strings.TrimSpace(query[len(first):]))
+			if err != nil {
+				srv.errorLocked(err)
+				return
+			}
+			reqFrame.readConsistency()
+			var flags uint32
+			if usesIntQueryFlags(srv.protocol) {
+				flags = uint32(reqFrame.readInt())
+			} else {
+				flags = uint32(reqFrame.readByte())
+			}
+			if flags&flagWithContinuousPaging == 0 {
+				writeTestRows(respFrame, head.stream, 0, 0, n)
+				break
+			}
+			// the continuous paging options are the last parameters
+			nextPages := 0
+			if srv.protocol >= protoVersionDSE2 {
+				nextPages = int(binary.BigEndian.Uint32(reqFrame.buf[len(reqFrame.buf)-4:]))
+			}
+			srv.streamContinuous(conn, head.stream, n, nextPages)
+			return
 		case "timeout":
 			<-srv.ctx.Done()
 			return
@@ -1291,6 +1420,28 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 	case opError:
 		respFrame.writeHeader(0, opError, head.stream)
 		respFrame.buf = append(respFrame.buf, reqFrame.buf...)
+	case opReviseRequest:
+		atomic.AddInt64(&srv.nRevise, 1)
+		revision := reqFrame.readInt()
+		stream := reqFrame.readInt()
+		srv.continuousMu.Lock()
+		st := srv.continuous[int(stream)]
+		srv.continuousMu.Unlock()
+		if st != nil {
+			switch revision {
+			case reviseCancelContinuousPaging:
+				st.cancelOnce.Do(func() { close(st.cancel) })
+			case reviseMoreContinuousPages:
+				select {
+				case st.more <- int(reqFrame.readInt()):
+				case <-st.cancel:
+				case <-srv.ctx.Done():
+					return
+				}
+			}
+		}
+		respFrame.writeHeader(0, opResult, head.stream)
+		respFrame.writeInt(resultKindVoid)
 	case opPrepare:
 		query := reqFrame.readLongString()
 		name := strings.TrimPrefix(query, "select ")
@@ -1343,7 +1494,7 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 		// <query_parameters>
 		reqFrame.readConsistency() // <consistency>
 		var flags uint32
-		if srv.protocol > protoVersion4 {
+		if usesIntQueryFlags(srv.protocol) {
 			ui := reqFrame.readInt()
 			flags = uint32(ui)
 		} else {
@@ -1401,6 +1552,90 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 	}
 }
 
+type testContinuousStream struct {
+	more       chan int
+	cancel     chan struct{}
+	cancelOnce sync.Once
+}
+
+// streamContinuous sends n one row pages on stream, waiting for revise
+// requests once nextPages pages are sent. nextPages 0 sends all pages.
+func (srv *TestServer) streamContinuous(conn net.Conn, stream, n, nextPages int) {
+	st := &testContinuousStream{
+		more:   make(chan int),
+		cancel: make(chan struct{}),
+	}
+	srv.continuousMu.Lock()
+	if srv.continuous == nil {
+		srv.continuous = make(map[int]*testContinuousStream)
+	}
+	srv.continuous[stream] = st
+	srv.continuousMu.Unlock()
+	defer func() {
+		srv.continuousMu.Lock()
+		delete(srv.continuous, stream)
+		srv.continuousMu.Unlock()
+	}()
+
+	credits := nextPages
+	for i := 1; i <= n; i++ {
+		for nextPages > 0 && credits == 0 {
+			select {
+			case more := <-st.more:
+				credits += more
+			case <-st.cancel:
+				return
+			case <-srv.ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-st.cancel:
+			return
+		default:
+		}
+
+		flags := flagContinuousPaging
+		if i == n {
+			flags |= flagLastContinuousPage
+		}
+		respFrame := newFramer(nil, srv.protocol)
+		writeTestRows(respFrame, stream, flags, i, 1)
+		respFrame.buf[0] = srv.protocol | 0x80
+		if err := respFrame.finish(); err != nil {
+			srv.errorLocked(err)
+			return
+		}
+		if err := respFrame.writeTo(conn); err != nil {
+			srv.errorLocked(err)
+			return
+		}
+		atomic.AddInt64(&srv.nContinuousPages, 1)
+		credits--
+	}
+}
+
+// writeTestRows writes a rows result with n int rows, numbered from page,
+// and the continuous paging flags.
+func writeTestRows(f *framer, stream int, flags int, page int, n int) {
+	f.writeHeader(0, opResult, stream)
+	f.writeInt(resultKindRows)
+	f.writeInt(int32(flags | flagGlobalTableSpec))
+	f.writeInt(1) // <columns_count>
+	if flags&flagContinuousPaging != 0 {
+		f.writeInt(int32(page))
+	}
+	f.writeString("ks")
+	f.writeString("t")
+	f.writeString("v")
+	f.writeShort(uint16(TypeInt))
+	f.writeInt(int32(n))
+	for i := 0; i < n; i++ {
+		f.writeInt(4)
+		f.writeInt(int32(page + i))
+	}
+}
+
 func (srv *TestServer) readFrame(conn net.Conn) (*framer, error) {
 	buf := make([]byte, srv.headerSize)
 	head, err := readHeader(conn, buf)
@@ -1517,3 +1752,123 @@ func TestConnProcessAllFramesInSingleSegment(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestConnRecvSegmentResponseTooLarge(t *testing.T) {
+	server, client, err := tcpConnPair()
+	require.NoError(t, err)
+
+	c := &Conn{
+		r: &connReader{
+			conn: server,
+			r:    bufio.NewReader(server),
+		},
+		calls:      make(map[int]*callReq),
+		version:    protoVersion5,
+		addr:       server.RemoteAddr().String(),
+		streams:    streams.New(protoVersion5),
+		isSchemaV2: true,
+		limits:     &responseLimits{body: 64 * 1024},
+		logger:     &defaultLogger{},
+	}
+
+	call := &callReq{
+		timeout:  make(chan struct{}),
+		streamID: 1,
+		resp:     make(chan callResp, 1),
+	}
+	c.calls[1] = call
+
+	// a 192KiB response spread over two segments
+	large := newFramer(nil, protoVersion5)
+	large.writeHeader(0, opResult, 1)
+	large.buf[0] |= protoDirectionMask
+	large.writeInt(resultKindVoid)
+	large.buf = append(large.buf, make([]byte, 192*1024)...)
+	large.setLength(len(large.buf) - large.headSize)
+
+	go func() {
+		for _, part := range [][]byte{large.buf[:maxSegmentPayloadSize], large.buf[maxSegmentPayloadSize:]} {
+			segment, err := newUncompressedSegment(part, false)
+			if err != nil {
+				panic(err)
+			}
+			client.Write(segment)
+		}
+	}()
+
+	require.NoError(t, c.recvSegment(context.Background()))
+
+	resp := <-call.resp
+	var tooLarge *ErrResponseTooLarge
+	require.True(t, errors.As(resp.err, &tooLarge), "unexpected error: %v", resp.err)
+	require.Equal(t, "body", tooLarge.Limit)
+	require.Equal(t, len(large.buf)-large.headSize, tooLarge.Size)
+	require.Nil(t, resp.framer)
+}
+
+type recordingQueryObserver struct {
+	mu       sync.Mutex
+	observed []ObservedQuery
+}
+
+func (o *recordingQueryObserver) ObserveQuery(ctx context.Context, q ObservedQuery) {
+	o.mu.Lock()
+	o.observed = append(o.observed, q)
+	o.mu.Unlock()
+}
+
+func (o *recordingQueryObserver) queries() []ObservedQuery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]ObservedQuery(nil), o.observed...)
+}
+
+func TestConnResponseSizeLimits(t *testing.T) {
+	tests := []struct {
+		limit string
+		cfg   func(*ClusterConfig)
+	}{
+		{"body", func(cfg *ClusterConfig) { cfg.MaxResponseBodySize = 32 * 1024 }},
+		{"decompressed", func(cfg *ClusterConfig) { cfg.MaxDecompressedBodySize = 32 * 1024 }},
+		{"page", func(cfg *ClusterConfig) { cfg.MaxPageBytes = 32 * 1024 }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.limit, func(t *testing.T) {
+			srv := NewTestServer(t, defaultProto, context.Background())
+			defer srv.Stop()
+
+			observer := &recordingQueryObserver{}
+			cluster := testCluster(defaultProto, srv.Address)
+			cluster.NumConns = 1
+			cluster.QueryObserver = observer
+			test.cfg(cluster)
+			db, err := cluster.CreateSession()
+			if err != nil {
+				t.Fatalf("NewCluster: %v", err)
+			}
+			defer db.Close()
+
+			conn := db.getConn()
+			err = db.Query("large").Exec()
+			tooLarge, ok := err.(*ErrResponseTooLarge)
+			if !ok {
+				t.Fatalf("expected *ErrResponseTooLarge, got %T: %v", err, err)
+			} else if tooLarge.Limit != test.limit || tooLarge.Max != 32*1024 || tooLarge.Size <= 64*1024 {
+				t.Fatalf("unexpected error %+v", tooLarge)
+			}
+
+			if err := db.Query("void").Exec(); err != nil {
+				t.Fatal(err)
+			}
+			if conn.Closed() || db.getConn() != conn {
+				t.Fatal("expected the connection to stay usable")
+			}
+
+			observed := observer.queries()
+			if len(observed) != 2 || observed[0].ResponseSize != tooLarge.Size || observed[1].ResponseSize != 4 {
+				t.Fatalf("unexpected observed queries %+v", observed)
+			}
+		})
+	}
+}
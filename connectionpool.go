@@ -25,6 +25,7 @@
 package gocql
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -113,13 +114,17 @@ func connConfig(cfg *ClusterConfig) (*ConnConfig, error) {
 		hostDialer HostDialer
 	)
 
+	if err := checkMutualTLS(cfg); err != nil {
+		return nil, err
+	}
+
 	hostDialer = cfg.HostDialer
 	if hostDialer == nil {
-		var tlsConfig *tls.Config
+		var tlsSource *tlsConfigSource
 
 		// TODO(zariel): move tls config setup into session init.
 		if cfg.SslOpts != nil {
-			tlsConfig, err = setupTLSConfig(cfg.SslOpts)
+			tlsSource, err = newTLSConfigSource(cfg.SslOpts, cfg.logger())
 			if err != nil {
 				return nil, err
 			}
@@ -137,24 +142,27 @@ func connConfig(cfg *ClusterConfig) (*ConnConfig, error) {
 		}
 
 		hostDialer = &defaultHostDialer{
-			dialer:    dialer,
-			tlsConfig: tlsConfig,
+			dialer: dialer,
+			tls:    tlsSource,
 		}
 	}
 
 	return &ConnConfig{
-		ProtoVersion:   cfg.ProtoVersion,
-		CQLVersion:     cfg.CQLVersion,
-		Timeout:        cfg.Timeout,
-		WriteTimeout:   cfg.WriteTimeout,
-		ConnectTimeout: cfg.ConnectTimeout,
-		Dialer:         cfg.Dialer,
-		HostDialer:     hostDialer,
-		Compressor:     cfg.Compressor,
-		Authenticator:  cfg.Authenticator,
-		AuthProvider:   cfg.AuthProvider,
-		Keepalive:      cfg.SocketKeepalive,
-		Logger:         cfg.logger(),
+		ProtoVersion:        cfg.ProtoVersion,
+		CQLVersion:          cfg.CQLVersion,
+		Timeout:             cfg.Timeout,
+		WriteTimeout:        cfg.WriteTimeout,
+		ConnectTimeout:      cfg.ConnectTimeout,
+		Dialer:              cfg.Dialer,
+		HostDialer:          hostDialer,
+		Compressor:          cfg.Compressor,
+		Authenticator:       cfg.Authenticator,
+		AuthProvider:        cfg.AuthProvider,
+		CredentialsProvider: cfg.CredentialsProvider,
+		Keepalive:           cfg.SocketKeepalive,
+		Logger:              cfg.logger(),
+
+		negotiateVersion: cfg.ProtoVersion == 0,
 	}, nil
 }
 
@@ -250,6 +258,36 @@ func (p *policyConnPool) getPoolByHostID(hostID string) (pool *hostConnPool, ok
 	return
 }
 
+// setNumConns changes the number of connections per host and resizes the
+// existing host pools accordingly.
+func (p *policyConnPool) setNumConns(numConns int) {
+	p.mu.Lock()
+	p.numConns = numConns
+	pools := make([]*hostConnPool, 0, len(p.hostConnPools))
+	for _, pool := range p.hostConnPools {
+		pools = append(pools, pool)
+	}
+	p.mu.Unlock()
+
+	for _, pool := range pools {
+		pool.resize(numConns)
+	}
+}
+
+// setTimeout changes the read timeout of all connections in the pool.
+func (p *policyConnPool) setTimeout(timeout time.Duration) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, pool := range p.hostConnPools {
+		pool.mu.RLock()
+		for _, conn := range pool.conns {
+			conn.r.SetTimeout(timeout)
+		}
+		pool.mu.RUnlock()
+	}
+}
+
 func (p *policyConnPool) Close() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -311,6 +349,12 @@ type hostConnPool struct {
 
 	pos    uint32
 	logger StdLogger
+
+	idleTimeout time.Duration
+	// admission is nil if the number of requests in flight is unlimited.
+	admission *hostAdmission
+	// quit is closed once the pool is closed to stop the maintenance goroutine.
+	quit chan struct{}
 }
 
 func (h *hostConnPool) String() string {
@@ -333,6 +377,21 @@ func newHostConnPool(session *Session, host *HostInfo, port, size int,
 		filling:  false,
 		closed:   false,
 		logger:   session.logger,
+
+		idleTimeout: session.cfg.PoolConfig.IdleTimeout,
+		admission:   newHostAdmission(session.cfg.PoolConfig.MaxInFlightPerHost, session.cfg.PoolConfig.MaxQueueWait),
+		quit:        make(chan struct{}),
+	}
+
+	interval := session.cfg.PoolConfig.connMaintenanceInterval()
+	if sslOpts := session.cfg.SslOpts; sslOpts != nil && sslOpts.RecycleBeforeExpiry > 0 {
+		// check for expiring certificates at least every second
+		if interval == 0 || interval > time.Second {
+			interval = time.Second
+		}
+	}
+	if interval > 0 {
+		go pool.maintain(interval)
 	}
 
 	// the pool is not filled or connected
@@ -374,9 +433,66 @@ func (pool *hostConnPool) Pick() *Conn {
 		}
 	}
 
+	if leastBusyConn != nil && pool.idleTimeout > 0 {
+		leastBusyConn.markUsed(time.Now())
+	}
+
 	return leastBusyConn
 }
 
+// resize changes the target size of the pool. If the pool has more connections
+// than the new size, the surplus connections are removed and drained, otherwise
+// the pool is filled.
+func (pool *hostConnPool) resize(size int) {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return
+	}
+
+	pool.size = size
+
+	var surplus []*Conn
+	if len(pool.conns) > size {
+		surplus = append(surplus, pool.conns[size:]...)
+		for i := size; i < len(pool.conns); i++ {
+			pool.conns[i] = nil
+		}
+		pool.conns = pool.conns[:size]
+	}
+	pool.mu.Unlock()
+
+	for _, conn := range surplus {
+		go pool.drain(conn)
+	}
+
+	pool.fill()
+}
+
+// admit admits a request with priority p to the host, waiting for a free slot if
+// the host has too many requests in flight. Every successful admit must be followed
+// by a call to release.
+func (pool *hostConnPool) admit(ctx context.Context, p Priority) error {
+	if pool.admission == nil {
+		return nil
+	}
+
+	admitted, err := pool.admission.acquire(ctx, p)
+	if err != nil {
+		return err
+	} else if !admitted {
+		return &ErrOverloaded{Host: pool.host, Priority: p}
+	}
+	return nil
+}
+
+// release frees the slot taken by admit.
+func (pool *hostConnPool) release() {
+	if pool.admission != nil {
+		pool.admission.release()
+	}
+}
+
 // Size returns the number of connections currently active in the pool
 func (pool *hostConnPool) Size() int {
 	pool.mu.RLock()
@@ -394,6 +510,7 @@ func (pool *hostConnPool) Close() {
 		return
 	}
 	pool.closed = true
+	close(pool.quit)
 
 	// ensure we dont try to reacquire the lock in handleError
 	// TODO: improve this as the following can happen
@@ -517,10 +634,16 @@ func (pool *hostConnPool) fillingStopped(err error) {
 	pool.mu.Lock()
 	pool.filling = false
 	count := len(pool.conns)
+	size := pool.size
 	host := pool.host
 	port := pool.port
 	pool.mu.Unlock()
 
+	if err == nil && count < size {
+		// the pool was resized while filling
+		go pool.fill()
+	}
+
 	// if we errored and the size is now zero, make sure the host is marked as down
 	// see https://github.com/apache/cassandra-gocql-driver/issues/1614
 	if gocqlDebug {
@@ -563,11 +686,31 @@ func (pool *hostConnPool) connectMany(count int) error {
 }
 
 // create a new connection to the host and add it to the pool
-func (pool *hostConnPool) connect() (err error) {
+func (pool *hostConnPool) connect() error {
+	conn, err := pool.dial()
+	if err != nil {
+		return err
+	}
+
+	// add the Conn to the pool
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.closed {
+		conn.Close()
+		return nil
+	}
+
+	pool.conns = append(pool.conns, conn)
+
+	return nil
+}
+
+// dial creates a new connection to the host without adding it to the pool.
+func (pool *hostConnPool) dial() (conn *Conn, err error) {
 	// TODO: provide a more robust connection retry mechanism, we should also
 	// be able to detect hosts that come up by trying to connect to downed ones.
 	// try to connect
-	var conn *Conn
 	reconnectionPolicy := pool.session.cfg.ReconnectionPolicy
 	for i := 0; i < reconnectionPolicy.GetMaxRetries(); i++ {
 		conn, err = pool.session.connect(pool.session.ctx, pool.host, pool)
@@ -589,31 +732,172 @@ func (pool *hostConnPool) connect() (err error) {
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if pool.keyspace != "" {
 		// set the keyspace
 		if err = conn.UseKeyspace(pool.keyspace); err != nil {
 			conn.Close()
-			return err
+			return nil, err
 		}
 	}
 
-	// add the Conn to the pool
-	pool.mu.Lock()
-	defer pool.mu.Unlock()
+	if age := pool.session.cfg.PoolConfig.connMaxAge(); age > 0 {
+		conn.expiresAt = time.Now().Add(age)
+	}
+	if sslOpts := pool.session.cfg.SslOpts; sslOpts != nil {
+		recycleAt := certRecycleTime(conn.certExpiry, sslOpts.RecycleBeforeExpiry, time.Now())
+		if !recycleAt.IsZero() && (conn.expiresAt.IsZero() || recycleAt.Before(conn.expiresAt)) {
+			conn.expiresAt = recycleAt
+		}
+	}
 
-	if pool.closed {
-		conn.Close()
+	return conn, nil
+}
+
+// maintain periodically replaces connections which exceeded their maximum age
+// or were idle for longer than the idle timeout, until the pool is closed.
+func (pool *hostConnPool) maintain(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pool.quit:
+			return
+		case <-ticker.C:
+		}
+
+		for _, conn := range pool.expiredConns(time.Now()) {
+			pool.replace(conn)
+		}
+	}
+}
+
+// expiredConns returns the connections of the pool that should be replaced.
+func (pool *hostConnPool) expiredConns(now time.Time) []*Conn {
+	certs := pool.certSource()
+
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	// a filling pool will get fresh connections anyway
+	if pool.closed || pool.filling {
 		return nil
 	}
 
-	pool.conns = append(pool.conns, conn)
+	var expired []*Conn
+	for _, conn := range pool.conns {
+		if !conn.expiresAt.IsZero() && now.After(conn.expiresAt) {
+			expired = append(expired, conn)
+		} else if pool.idleTimeout > 0 && now.Sub(conn.idleSince()) > pool.idleTimeout {
+			expired = append(expired, conn)
+		} else if certs != nil && pool.certRenewed(certs, conn, now) {
+			expired = append(expired, conn)
+		}
+	}
+
+	return expired
+}
 
+// certSource returns the source of the client certificates of new
+// connections when they are recycled before expiring, nil otherwise.
+func (pool *hostConnPool) certSource() *tlsConfigSource {
+	if sslOpts := pool.session.cfg.SslOpts; sslOpts == nil || sslOpts.RecycleBeforeExpiry <= 0 {
+		return nil
+	}
+	if dialer, ok := pool.session.connConfig().HostDialer.(*defaultHostDialer); ok {
+		return dialer.tls
+	}
 	return nil
 }
 
+// certRenewed reports whether the client certificate of conn is inside its
+// recycle period and certs loaded a certificate expiring later since, so a
+// new connection would renew it. Connections established with a certificate
+// already inside the recycle period are only replaced then, see
+// certRecycleTime.
+func (pool *hostConnPool) certRenewed(certs *tlsConfigSource, conn *Conn, now time.Time) bool {
+	if conn.certExpiry.IsZero() || now.Before(conn.certExpiry.Add(-pool.session.cfg.SslOpts.RecycleBeforeExpiry)) {
+		return false
+	}
+	return certs.certRenewed(conn.certExpiry)
+}
+
+// replace swaps conn for a newly established connection and drains conn afterward.
+// conn is kept in the pool if a new connection can't be established, so the pool
+// doesn't shrink because of the replacement.
+func (pool *hostConnPool) replace(conn *Conn) {
+	newConn, err := pool.dial()
+	if err != nil {
+		pool.logConnectErr(err)
+		return
+	}
+
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		newConn.Close()
+		return
+	}
+
+	replaced := false
+	for i, candidate := range pool.conns {
+		if candidate == conn {
+			pool.conns[i] = newConn
+			replaced = true
+			break
+		}
+	}
+
+	if !replaced {
+		// conn was closed and removed in the meantime, keep the new connection
+		// only if the pool is still missing some.
+		if len(pool.conns) >= pool.size {
+			pool.mu.Unlock()
+			newConn.Close()
+			return
+		}
+		pool.conns = append(pool.conns, newConn)
+	}
+	pool.mu.Unlock()
+
+	if gocqlDebug {
+		pool.logger.Printf("gocql: replaced connection %q to %q\n", conn.addr, pool.host.ConnectAddress())
+	}
+
+	if replaced {
+		go pool.drain(conn)
+	}
+}
+
+// drain waits until conn has no requests in flight or the request timeout passes
+// and closes conn. conn must already be removed from the pool.
+func (pool *hostConnPool) drain(conn *Conn) {
+	defer conn.Close()
+
+	timeout := conn.r.GetTimeout()
+	if timeout <= 0 {
+		timeout = 11 * time.Second
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for conn.inFlight() > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-pool.quit:
+			return
+		}
+	}
+}
+
 // handle any error from a Conn
 func (pool *hostConnPool) HandleError(conn *Conn, err error, closed bool) {
 	if !closed {
@@ -640,6 +924,12 @@ func (pool *hostConnPool) HandleError(conn *Conn, err error, closed bool) {
 		if candidate == conn {
 			// remove the connection, not preserving order
 			pool.conns[i], pool.conns = pool.conns[len(pool.conns)-1], pool.conns[:len(pool.conns)-1]
+			// keep the negotiated version when reconnecting unless the host
+			// no longer accepts it, restarts are handled by the node events
+			var protoErr *protocolError
+			if errors.As(err, &protoErr) {
+				pool.host.setProtocolVersion(0)
+			}
 
 			// lost a connection, so fill the pool
 			go pool.fill()
@@ -28,8 +28,11 @@
 package gocql
 
 import (
+	"context"
 	"crypto/tls"
+	"io"
 	"testing"
+	"time"
 )
 
 func TestSetupTLSConfig(t *testing.T) {
@@ -107,3 +110,173 @@ func TestSetupTLSConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestHostConnPoolMaxConnectionAge(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 2
+	cluster.PoolConfig.MaxConnectionAge = 100 * time.Millisecond
+	cluster.PoolConfig.MaxConnectionAgeJitter = 50 * time.Millisecond
+
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+
+	waitForPoolSize(t, pool, cluster.NumConns)
+
+	pool.mu.RLock()
+	initial := append([]*Conn(nil), pool.conns...)
+	pool.mu.RUnlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if size := pool.Size(); size != cluster.NumConns {
+			t.Fatalf("expected pool size to stay at %d, got %d", cluster.NumConns, size)
+		}
+
+		pool.mu.RLock()
+		replaced := true
+		for _, conn := range pool.conns {
+			for _, old := range initial {
+				if conn == old {
+					replaced = false
+				}
+			}
+		}
+		pool.mu.RUnlock()
+
+		if replaced {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("connections were not replaced after their maximum age")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, conn := range initial {
+		waitForConnClosed(t, conn)
+	}
+
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHostConnPoolIdleTimeout(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	cluster.PoolConfig.IdleTimeout = 100 * time.Millisecond
+
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+
+	waitForPoolSize(t, pool, cluster.NumConns)
+
+	// keep the connection busy, it must not be replaced
+	conn := pool.Pick()
+	for i := 0; i < 10; i++ {
+		if err := db.Query("void").Exec(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+	if picked := pool.Pick(); picked != conn {
+		t.Fatal("connection in use was replaced")
+	}
+
+	waitForConnClosed(t, conn)
+	if size := pool.Size(); size != cluster.NumConns {
+		t.Fatalf("expected pool size %d, got %d", cluster.NumConns, size)
+	}
+}
+
+func TestHostConnPoolKeepsProtocolVersion(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	host := db.ring.allHosts()[0]
+	pool, ok := db.pool.getPool(host)
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+	waitForPoolSize(t, pool, cluster.NumConns)
+	host.setProtocolVersion(int(defaultProto))
+
+	// a lost connection is redialed with the negotiated version
+	conn := pool.Pick()
+	conn.closeWithError(io.EOF)
+	if v := host.ProtocolVersion(); v != int(defaultProto) {
+		t.Fatalf("expected the host to keep protocol version %d, got %d", defaultProto, v)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for conn = pool.Pick(); conn == nil || conn.Closed(); conn = pool.Pick() {
+		if time.Now().After(deadline) {
+			t.Fatal("lost connection was not replaced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the version is negotiated again once the host rejects it
+	conn.closeWithError(&protocolError{
+		frame: errorFrame{
+			code:    ErrCodeProtocol,
+			message: "Invalid or unsupported protocol version (4); the lowest supported version is 3 and the greatest is 3",
+		},
+	})
+	if v := host.ProtocolVersion(); v != 0 {
+		t.Fatalf("expected the host protocol version to be reset, got %d", v)
+	}
+}
+
+func waitForPoolSize(t *testing.T, pool *hostConnPool, size int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Size() != size {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected pool size %d, got %d", size, pool.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitForConnClosed(t *testing.T, conn *Conn) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !conn.Closed() {
+		if time.Now().After(deadline) {
+			t.Fatal("replaced connection was not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
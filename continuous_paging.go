@@ -0,0 +1,343 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ContinuousPagingOptions configures DSE continuous paging, see
+// Query.ContinuousPaging.
+//
+// The node sends the pages as fast as MaxPagesPerSecond allows and they are
+// buffered until read from the Iter. With DSE_V2 the node sends at most
+// MaxEnqueuedPages pages ahead of the ones read. DSE_V1 has no such flow
+// control, once the buffer is full the request is cancelled and the Iter
+// fails with ErrContinuousPagingOverflow.
+//
+// All pages are read from the connection which returned the first one, a
+// failure after the first page is returned by the Iter and not retried.
+// Closing the Iter before the last page cancels the request.
+type ContinuousPagingOptions struct {
+	// MaxPages is the maximum number of pages returned, 0 for no limit.
+	MaxPages int
+	// MaxPagesPerSecond is the maximum number of pages sent per second, 0 for
+	// no limit.
+	MaxPagesPerSecond int
+	// MaxEnqueuedPages is the number of pages buffered for the Iter.
+	// Default: 4
+	MaxEnqueuedPages int
+}
+
+const defaultMaxEnqueuedPages = 4
+
+// ErrContinuousPagingOverflow is returned by the Iter of a continuous paging
+// request whose pages were not read as fast as the node sent them.
+var ErrContinuousPagingOverflow = errors.New("gocql: continuous paging pages were not read fast enough, increase MaxEnqueuedPages or lower MaxPagesPerSecond")
+
+func (o *ContinuousPagingOptions) params() *continuousPagingParams {
+	pages := o.MaxEnqueuedPages
+	if pages <= 0 {
+		pages = defaultMaxEnqueuedPages
+	}
+
+	return &continuousPagingParams{
+		maxPages:       o.MaxPages,
+		pagesPerSecond: o.MaxPagesPerSecond,
+		nextPages:      pages,
+	}
+}
+
+// continuousPager receives the pages of a continuous paging request.
+type continuousPager struct {
+	conn *Conn
+	call *callReq
+	qry  *Query
+	ctx  context.Context
+	// info is the prepared statement, if any, used by pages without metadata.
+	info *preparedStatment
+
+	// pages is the number of pages the node sends ahead, consumed the number
+	// of pages read since more were asked for.
+	pages     int
+	consumed  int
+	cancelled atomic.Bool
+}
+
+// execContinuous sends the continuous paging request req and waits for the
+// first page.
+func (c *Conn) execContinuous(ctx context.Context, req frameBuilder, qry *Query, pages int) (*continuousPager, *framer, error) {
+	stream, ok := c.streams.GetStream()
+	if !ok {
+		return nil, nil, ErrNoStreams
+	}
+
+	call := &callReq{
+		timeout:  make(chan struct{}),
+		streamID: stream,
+		// the node sends up to pages pages ahead and the last response might
+		// be an error
+		resp:       make(chan callResp, pages+1),
+		continuous: true,
+		overflow:   make(chan struct{}),
+	}
+	if err := c.addCall(call); err != nil {
+		return nil, nil, err
+	}
+
+	framer := getFramer(c.compressor, c.version)
+	if qry.trace != nil {
+		framer.trace()
+	}
+
+	if err := req.buildFrame(framer, stream); err != nil {
+		framer.release()
+		c.finishContinuous(call)
+		return nil, nil, err
+	}
+
+	if c.recordsFrames() {
+		c.recordSent(req, framer.flags, stream, false)
+	}
+
+	n, err := c.w.writeContext(ctx, framer.buf)
+	framer.release()
+	if err != nil {
+		if (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) && n == 0 {
+			c.finishContinuous(call)
+		} else {
+			c.closeWithError(err)
+		}
+		return nil, nil, err
+	}
+
+	p := &continuousPager{
+		conn:  c,
+		call:  call,
+		qry:   qry,
+		ctx:   qry.Context(),
+		pages: pages,
+	}
+	framer, err = p.wait()
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, framer, nil
+}
+
+// respondContinuous hands resp over to the continuous paging call, the stream
+// is released with the last response.
+func (c *Conn) respondContinuous(call *callReq, resp callResp) {
+	if resp.err != nil || resp.framer.lastContinuousPage() {
+		c.finishContinuous(call)
+	}
+
+	select {
+	case call.resp <- resp:
+		return
+	case <-call.timeout:
+		// cancelled, the remaining pages are dropped
+	default:
+		// the pages are not read fast enough, blocking would stall all the
+		// other streams of the connection
+		call.overflowOnce.Do(func() { close(call.overflow) })
+	}
+	if resp.framer != nil {
+		resp.framer.release()
+	}
+}
+
+// finishContinuous removes the continuous paging call and releases its
+// stream, the node does not send anything more on it.
+func (c *Conn) finishContinuous(call *callReq) {
+	if !call.finished.CompareAndSwap(false, true) {
+		return
+	}
+
+	c.mu.Lock()
+	if c.calls[call.streamID] == call {
+		delete(c.calls, call.streamID)
+	}
+	c.mu.Unlock()
+	c.releaseStream(call)
+}
+
+// wait returns the next response.
+func (p *continuousPager) wait() (*framer, error) {
+	var timeoutCh <-chan time.Time
+	if timeout := p.conn.r.GetTimeout(); timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	var resp callResp
+	select {
+	case resp = <-p.call.resp:
+	case <-timeoutCh:
+		p.cancel()
+		return nil, ErrTimeoutNoResponse
+	case <-p.ctx.Done():
+		p.cancel()
+		return nil, p.ctx.Err()
+	case <-p.call.overflow:
+		p.cancel()
+		return nil, ErrContinuousPagingOverflow
+	case <-p.conn.ctx.Done():
+		return nil, ErrConnectionClosed
+	}
+
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	if v := resp.framer.header.version.version(); v != p.conn.version {
+		p.cancel()
+		return nil, NewErrProtocol("unexpected protocol version in response: got %d expected %d", v, p.conn.version)
+	}
+	return resp.framer, nil
+}
+
+// nextPage waits for the next page and returns it.
+func (p *continuousPager) nextPage() *Iter {
+	framer, err := p.wait()
+	if err != nil {
+		return &Iter{err: err, host: p.conn.host}
+	}
+
+	resp, err := framer.parseFrame()
+	if err != nil {
+		p.cancel()
+		return &Iter{err: err, host: p.conn.host}
+	}
+
+	if len(framer.traceID) > 0 && p.qry.trace != nil {
+		p.qry.trace.Trace(framer.traceID)
+	}
+
+	switch x := resp.(type) {
+	case *resultRowsFrame:
+		iter := &Iter{
+			meta:    x.meta,
+			framer:  framer,
+			numRows: x.numRows,
+			host:    p.conn.host,
+			codecs:  p.conn.session.codecs,
+		}
+		if x.meta.noMetaData() {
+			if p.info == nil {
+				p.cancel()
+				return &Iter{framer: framer, err: errors.New("gocql: did not receive metadata but prepared info is nil"), host: p.conn.host}
+			}
+			iter.meta = p.info.response
+		}
+		return p.page(iter, &x.meta)
+	case error:
+		return &Iter{err: x, framer: framer, host: p.conn.host}
+	default:
+		p.cancel()
+		return &Iter{
+			err:    NewErrProtocol("Unknown type in response to continuous paging query (%T): %s", x, x),
+			framer: framer,
+			host:   p.conn.host,
+		}
+	}
+}
+
+// page links iter, the page with metadata meta, to the page following it.
+func (p *continuousPager) page(iter *Iter, meta *resultMetadata) *Iter {
+	if !meta.moreContinuousPages() {
+		return iter
+	}
+
+	iter.next = &nextIter{
+		qry:   p.qry,
+		pos:   int((1 - p.qry.prefetch) * float64(iter.numRows)),
+		pager: p,
+	}
+	if iter.next.pos < 1 {
+		iter.next.pos = 1
+	}
+
+	p.requestMore()
+	return iter
+}
+
+// requestMore asks the node for more pages once half of the buffered pages
+// were read, DSE_V1 has no flow control.
+func (p *continuousPager) requestMore() {
+	if p.conn.version < protoVersionDSE2 {
+		return
+	}
+
+	p.consumed++
+	if p.consumed < (p.pages+1)/2 {
+		return
+	}
+
+	req := &writeReviseRequestFrame{
+		revision:     reviseMoreContinuousPages,
+		targetStream: p.call.streamID,
+		nextPages:    p.consumed,
+	}
+	p.consumed = 0
+	go p.revise(req)
+}
+
+// cancel stops the request, pages received afterwards are dropped.
+func (p *continuousPager) cancel() {
+	if !p.cancelled.CompareAndSwap(false, true) {
+		return
+	}
+
+	close(p.call.timeout)
+	if p.call.finished.Load() {
+		return
+	}
+	go func() {
+		// the node sends nothing more on the stream once it acknowledged the
+		// cancellation, otherwise the stream stays reserved until the last
+		// page is received or the connection is closed
+		err := p.revise(&writeReviseRequestFrame{
+			revision:     reviseCancelContinuousPaging,
+			targetStream: p.call.streamID,
+		})
+		if err == nil {
+			p.conn.finishContinuous(p.call)
+		}
+	}()
+}
+
+func (p *continuousPager) revise(req *writeReviseRequestFrame) error {
+	framer, err := p.conn.exec(p.conn.ctx, req, nil)
+	if err == nil {
+		var resp frame
+		resp, err = framer.parseFrame()
+		if respErr, ok := resp.(error); ok {
+			err = respErr
+		}
+	}
+
+	if err != nil && !p.conn.Closed() {
+		p.conn.logger.Printf("gocql: unable to revise continuous paging request on stream %d: %v\n", req.targetStream, err)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+//go:build all || unit
+// +build all unit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContinuousPaging(t *testing.T) {
+	for name, proto := range map[string]protoVersion{"DSE_V1": protoVersionDSE1, "DSE_V2": protoVersionDSE2} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			srv := NewTestServer(t, byte(proto), ctx)
+			defer srv.Stop()
+
+			db, err := newTestSession(proto, srv.Address)
+			require.NoError(t, err)
+			defer db.Close()
+
+			// DSE_V1 has no flow control, all pages must fit in the buffer
+			opts := ContinuousPagingOptions{MaxEnqueuedPages: 2}
+			if proto == protoVersionDSE1 {
+				opts.MaxEnqueuedPages = 10
+			}
+			iter := db.Query("continuous 10").ContinuousPaging(opts).Iter()
+			if proto == protoVersionDSE2 {
+				// the first page was read and more asked for
+				time.Sleep(50 * time.Millisecond)
+				require.LessOrEqual(t, atomic.LoadInt64(&srv.nContinuousPages), int64(3))
+			}
+
+			var got []int
+			var v int
+			for iter.Scan(&v) {
+				got = append(got, v)
+			}
+			require.NoError(t, iter.Close())
+			require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, got)
+			require.EqualValues(t, 10, atomic.LoadInt64(&srv.nContinuousPages))
+			if proto == protoVersionDSE1 {
+				require.Zero(t, atomic.LoadInt64(&srv.nRevise))
+			}
+
+			// the stream is released after the last page
+			require.NoError(t, db.Query("void").Exec())
+		})
+	}
+}
+
+func TestContinuousPagingCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewTestServer(t, protoVersionDSE2, ctx)
+	defer srv.Stop()
+
+	db, err := newTestSession(protoVersionDSE2, srv.Address)
+	require.NoError(t, err)
+	defer db.Close()
+
+	conn := db.getConn()
+	streams := conn.AvailableStreams()
+	iter := conn.executeQuery(ctx, db.Query("continuous 100").ContinuousPaging(ContinuousPagingOptions{MaxEnqueuedPages: 2}))
+	var v int
+	require.True(t, iter.Scan(&v))
+	require.Equal(t, 1, v)
+	require.NoError(t, iter.Close())
+
+	require.Eventually(t, func() bool {
+		srv.continuousMu.Lock()
+		defer srv.continuousMu.Unlock()
+		return len(srv.continuous) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.Less(t, atomic.LoadInt64(&srv.nContinuousPages), int64(100))
+
+	// the node sends no last page once cancelled, the stream is released
+	// when it acknowledges the cancellation
+	require.Eventually(t, func() bool {
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		return len(conn.calls) == 0 && conn.AvailableStreams() == streams
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, db.Query("void").Exec())
+}
+
+func TestContinuousPagingOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewTestServer(t, protoVersionDSE1, ctx)
+	defer srv.Stop()
+
+	db, err := newTestSession(protoVersionDSE1, srv.Address)
+	require.NoError(t, err)
+	defer db.Close()
+
+	conn := db.getConn()
+	streams := conn.AvailableStreams()
+	iter := conn.executeQuery(ctx, db.Query("continuous 100").ContinuousPaging(ContinuousPagingOptions{MaxEnqueuedPages: 2}))
+
+	// the pages which do not fit in the buffer are dropped without blocking
+	// the other streams of the connection
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&srv.nContinuousPages) == 100
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, conn.executeQuery(ctx, db.Query("void")).Close())
+
+	var v int
+	for iter.Scan(&v) {
+	}
+	require.Equal(t, ErrContinuousPagingOverflow, iter.Close())
+
+	require.Eventually(t, func() bool {
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		return len(conn.calls) == 0 && conn.AvailableStreams() == streams
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestContinuousPagingFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewTestServer(t, protoVersion4, ctx)
+	defer srv.Stop()
+
+	db, err := newTestSession(protoVersion4, srv.Address)
+	require.NoError(t, err)
+	defer db.Close()
+
+	iter := db.Query("continuous 5").ContinuousPaging(ContinuousPagingOptions{}).Iter()
+	var got []int
+	var v int
+	for iter.Scan(&v) {
+		got = append(got, v)
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, []int{0, 1, 2, 3, 4}, got)
+	require.Zero(t, atomic.LoadInt64(&srv.nContinuousPages))
+	require.Zero(t, atomic.LoadInt64(&srv.nRevise))
+}
@@ -34,6 +34,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -201,12 +202,20 @@ func shuffleHosts(hosts []*HostInfo) []*HostInfo {
 }
 
 // this is going to be version dependant and a nightmare to maintain :(
-var protocolSupportRe = regexp.MustCompile(`the lowest supported version is \d+ and the greatest is (\d+)$`)
+var (
+	protocolSupportRe = regexp.MustCompile(`the lowest supported version is \d+ and the greatest is (\d+)$`)
+	// DSE, and later Cassandra releases, list the versions, e.g.
+	// "supported versions are (3/v3, 4/v4, 5/v5-beta, 65/dse_v1, 66/dse_v2)"
+	protocolListRe = regexp.MustCompile(`supported versions are \(([^)]*)\)`)
+)
 
 func parseProtocolFromError(err error) int {
 	// I really wish this had the actual info in the error frame...
 	matches := protocolSupportRe.FindAllStringSubmatch(err.Error(), -1)
 	if len(matches) != 1 || len(matches[0]) != 2 {
+		if max := parseProtocolList(err.Error()); max > 0 {
+			return max
+		}
 		if verr, ok := err.(*protocolError); ok {
 			return int(verr.frame.Header().version.version())
 		}
@@ -221,11 +230,36 @@ func parseProtocolFromError(err error) int {
 	return max
 }
 
+// parseProtocolList returns the greatest version, leaving out beta versions,
+// of the supported versions listed in msg.
+func parseProtocolList(msg string) int {
+	matches := protocolListRe.FindStringSubmatch(msg)
+	if len(matches) != 2 {
+		return 0
+	}
+
+	max := 0
+	for _, version := range strings.Split(matches[1], ",") {
+		parts := strings.SplitN(strings.TrimSpace(version), "/", 2)
+		if len(parts) != 2 || strings.HasSuffix(parts[1], "-beta") {
+			continue
+		}
+		v, err := strconv.Atoi(parts[0])
+		if err != nil || v > protoVersionDSE2 || (v > maxProtocolVersion && !isDSEVersion(byte(v))) {
+			continue
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
 func (c *controlConn) discoverProtocol(hosts []*HostInfo) (int, error) {
 	hosts = shuffleHosts(hosts)
 
-	connCfg := *c.session.connCfg
-	connCfg.ProtoVersion = 5 // TODO: define maxProtocol
+	connCfg := *c.session.connConfig()
+	connCfg.ProtoVersion = maxProtocolVersion
 
 	handler := connErrorHandlerFn(func(c *Conn, err error, closed bool) {
 		// we should never get here, but if we do it means we connected to a
@@ -244,7 +278,8 @@ func (c *controlConn) discoverProtocol(hosts []*HostInfo) (int, error) {
 		}
 
 		if err == nil {
-			return connCfg.ProtoVersion, nil
+			// the version was negotiated when dialing
+			return int(conn.version), nil
 		}
 
 		if proto := parseProtocolFromError(err); proto > 0 {
@@ -264,7 +299,7 @@ func (c *controlConn) connect(hosts []*HostInfo) error {
 	// node.
 	hosts = shuffleHosts(hosts)
 
-	cfg := *c.session.connCfg
+	cfg := *c.session.connConfig()
 	cfg.disableCoalesce = true
 
 	var conn *Conn
@@ -81,6 +81,24 @@ func TestParseProtocol(t *testing.T) {
 			},
 			proto: 3,
 		},
+		{
+			err: &protocolError{
+				frame: errorFrame{
+					code:    0x10,
+					message: "Invalid or unsupported protocol version (66); supported versions are (3/v3, 4/v4, 5/v5-beta, 65/dse_v1)",
+				},
+			},
+			proto: protoVersionDSE1,
+		},
+		{
+			err: &protocolError{
+				frame: errorFrame{
+					code:    0x10,
+					message: "Invalid or unsupported protocol version (66); supported versions are (3/v3, 4/v4, 5/v5, 6/v6-beta)",
+				},
+			},
+			proto: 5,
+		},
 	}
 
 	for i, test := range tests {
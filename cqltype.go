@@ -0,0 +1,491 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseCQLType parses the CQL definition of a type as it is written in
+// statements and the system_schema tables, for example
+// "map<text, frozen<list<vector<float, 3>>>>", into the TypeInfo of protocol
+// version proto.
+//
+// Collections, tuples and user-defined types wrapped in frozen<...> or
+// nested in another type have their Frozen field set. Names which are not
+// native types are user-defined types, optionally qualified with their
+// keyspace. The definition does not include their fields, so the UDTTypeInfo
+// only has the keyspace and name. Custom types are given as quoted Java class
+// names, which are parsed as with ParseCQLTypeClass.
+func ParseCQLType(def string, proto byte) (TypeInfo, error) {
+	p := &cqlTypeParser{input: def, proto: proto}
+	info, err := p.parseType()
+	if err == nil && p.skipWhitespace() < len(p.input) {
+		err = p.errorf("unexpected %q after the type", p.input[p.pos:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ParseCQLTypeClass parses the Java class name of a type, as found in the
+// schema tables of Cassandra 2 and in custom types, for example
+// "org.apache.cassandra.db.marshal.MapType(org.apache.cassandra.db.marshal.UTF8Type,org.apache.cassandra.db.marshal.Int32Type)",
+// into the TypeInfo of protocol version proto. FrozenType and nesting set the
+// Frozen field as with ParseCQLType. ReversedType is dropped, the clustering
+// order is not part of the type.
+//
+// Classes which are not CQL types are returned as custom types with the
+// class name.
+func ParseCQLTypeClass(class string, proto byte) (TypeInfo, error) {
+	parser := &typeParser{input: class, proto: proto, logger: nopLogger{}}
+	node, ok := parser.parseClassNode()
+	if parser.skipWhitespace(); !ok || parser.index != len(class) {
+		return nil, fmt.Errorf("gocql: invalid type class %q at offset %d", class, parser.index)
+	}
+	return node.typeInfo()
+}
+
+// FormatCQLType returns the CQL definition of info, which ParseCQLType
+// parses back into info. Collections, tuples and user-defined types are
+// wrapped in frozen<...> when they are Frozen or nested in other types, as
+// CQL requires. Custom types are rendered as quoted class names.
+func FormatCQLType(info TypeInfo) string {
+	var b strings.Builder
+	formatCQLType(&b, info, false)
+	return b.String()
+}
+
+func formatCQLType(b *strings.Builder, info TypeInfo, nested bool) {
+	switch info := info.(type) {
+	case VectorType:
+		b.WriteString("vector<")
+		formatCQLType(b, info.SubType, true)
+		fmt.Fprintf(b, ", %d>", info.Dimensions)
+		return
+	case CollectionType:
+		switch info.typ {
+		case TypeList, TypeSet, TypeMap:
+		default:
+			formatCQLType(b, info.NativeType, nested)
+			return
+		}
+		frozen := info.Frozen || nested
+		if frozen {
+			b.WriteString("frozen<")
+		}
+		b.WriteString(info.typ.String())
+		b.WriteByte('<')
+		if info.typ == TypeMap {
+			formatCQLType(b, info.Key, true)
+			b.WriteString(", ")
+		}
+		formatCQLType(b, info.Elem, true)
+		b.WriteByte('>')
+		if frozen {
+			b.WriteByte('>')
+		}
+		return
+	case TupleTypeInfo:
+		frozen := info.Frozen || nested
+		if frozen {
+			b.WriteString("frozen<")
+		}
+		b.WriteString("tuple<")
+		for i, elem := range info.Elems {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			formatCQLType(b, elem, true)
+		}
+		b.WriteByte('>')
+		if frozen {
+			b.WriteByte('>')
+		}
+		return
+	case UDTTypeInfo:
+		frozen := info.Frozen || nested
+		if frozen {
+			b.WriteString("frozen<")
+		}
+		if info.KeySpace != "" {
+			b.WriteString(quoteCQLIdentifier(info.KeySpace))
+			b.WriteByte('.')
+		}
+		b.WriteString(quoteCQLIdentifier(info.Name))
+		if frozen {
+			b.WriteByte('>')
+		}
+		return
+	}
+
+	if info.Type() == TypeCustom {
+		b.WriteByte('\'')
+		b.WriteString(strings.ReplaceAll(info.Custom(), "'", "''"))
+		b.WriteByte('\'')
+		return
+	}
+	b.WriteString(info.Type().String())
+}
+
+// quoteCQLIdentifier quotes name unless it is a lower case identifier which
+// does not name a type.
+func quoteCQLIdentifier(name string) string {
+	plain := name != "" && name[0] >= 'a' && name[0] <= 'z'
+	for i := 0; plain && i < len(name); i++ {
+		c := name[i]
+		plain = (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_'
+	}
+	if plain {
+		if _, ok := cqlNativeType(name); !ok && !isCQLCompositeType(name) {
+			return name
+		}
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// cqlNativeType returns the native type called name in CQL.
+func cqlNativeType(name string) (Type, bool) {
+	switch typ := getCassandraBaseType(name); typ {
+	case TypeCustom, TypeMap, TypeList, TypeSet, TypeTuple:
+		return TypeCustom, false
+	default:
+		return typ, true
+	}
+}
+
+// frozenType returns info with its Frozen field set. Only collections,
+// tuples and user-defined types can be frozen, other types are returned
+// as is.
+func frozenType(info TypeInfo) TypeInfo {
+	switch t := info.(type) {
+	case CollectionType:
+		t.Frozen = true
+		return t
+	case TupleTypeInfo:
+		t.Frozen = true
+		return t
+	case UDTTypeInfo:
+		t.Frozen = true
+		return t
+	}
+	return info
+}
+
+func isCQLCompositeType(name string) bool {
+	switch name {
+	case "frozen", "list", "set", "map", "tuple", "vector":
+		return true
+	}
+	return false
+}
+
+// cqlTypeParser parses CQL type definitions:
+//
+//	TYPE := NATIVE | COMPOSITE "<" TYPE { "," TYPE } ">" | "vector" "<" TYPE "," INT ">" | [ NAME "." ] NAME | CLASS
+//	NAME := ID | '"' { CHAR | '""' } '"'
+//	CLASS := "'" { CHAR | "''" } "'"
+type cqlTypeParser struct {
+	input string
+	pos   int
+	proto byte
+}
+
+func (p *cqlTypeParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("gocql: invalid CQL type %q at offset %d: %s", p.input, p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipWhitespace skips whitespace and returns the position of the next
+// character.
+func (p *cqlTypeParser) skipWhitespace() int {
+	for p.pos < len(p.input) && isWhitespaceChar(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.pos
+}
+
+// consume consumes c if it is the next character.
+func (p *cqlTypeParser) consume(c byte) bool {
+	if p.skipWhitespace() < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *cqlTypeParser) expect(c byte) error {
+	if !p.consume(c) {
+		if p.pos == len(p.input) {
+			return p.errorf("expected %q, got end of input", c)
+		}
+		return p.errorf("expected %q, got %q", c, p.input[p.pos])
+	}
+	return nil
+}
+
+// name reads an identifier, unquoted identifiers are case insensitive.
+func (p *cqlTypeParser) name() (name string, quoted bool, err error) {
+	if p.skipWhitespace() < len(p.input) && (p.input[p.pos] == '"' || p.input[p.pos] == '\'') {
+		s, err := p.quoted(p.input[p.pos])
+		return s, true, err
+	}
+	start := p.pos
+	for p.pos < len(p.input) && isCQLIdentifierChar(p.input[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos == len(p.input) {
+			return "", false, p.errorf("expected a type, got end of input")
+		}
+		return "", false, p.errorf("expected a type, got %q", p.input[p.pos])
+	}
+	return strings.ToLower(p.input[start:p.pos]), false, nil
+}
+
+// quoted reads a string quoted with q, in which q is escaped by doubling it.
+func (p *cqlTypeParser) quoted(q byte) (string, error) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		c := p.input[p.pos]
+		if c == q {
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == q {
+				p.pos++
+			} else {
+				p.pos++
+				return b.String(), nil
+			}
+		}
+		b.WriteByte(c)
+	}
+	return "", p.errorf("unterminated quoted name")
+}
+
+func isCQLIdentifierChar(c byte) bool {
+	return (c >= '0' && c <= '9') ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		c == '_'
+}
+
+func (p *cqlTypeParser) parseType() (TypeInfo, error) {
+	if p.skipWhitespace() < len(p.input) && p.input[p.pos] == '\'' {
+		class, err := p.quoted('\'')
+		if err != nil {
+			return nil, err
+		}
+		return ParseCQLTypeClass(class, p.proto)
+	}
+
+	name, quoted, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if !quoted {
+		if isCQLCompositeType(name) && p.consume('<') {
+			return p.parseComposite(name)
+		}
+		if typ, ok := cqlNativeType(name); ok {
+			return NewNativeType(p.proto, typ), nil
+		}
+	}
+
+	udt := UDTTypeInfo{NativeType: NewNativeType(p.proto, TypeUDT), Name: name}
+	if p.consume('.') {
+		udt.KeySpace = udt.Name
+		if udt.Name, _, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	return udt, nil
+}
+
+// parseComposite parses the parameters of the composite type name after the
+// opening '<'.
+func (p *cqlTypeParser) parseComposite(name string) (TypeInfo, error) {
+	var params []TypeInfo
+	for {
+		if name == "vector" && len(params) == 1 {
+			break
+		}
+		param, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if name != "frozen" {
+			param = frozenType(param)
+		}
+		params = append(params, param)
+		if !p.consume(',') {
+			break
+		}
+	}
+
+	var info TypeInfo
+	switch name {
+	case "frozen", "list", "set":
+		if len(params) != 1 {
+			return nil, p.errorf("%s takes 1 type, got %d", name, len(params))
+		}
+		switch name {
+		case "frozen":
+			info = frozenType(params[0])
+		case "list":
+			info = CollectionType{NativeType: NewNativeType(p.proto, TypeList), Elem: params[0]}
+		case "set":
+			info = CollectionType{NativeType: NewNativeType(p.proto, TypeSet), Elem: params[0]}
+		}
+	case "map":
+		if len(params) != 2 {
+			return nil, p.errorf("map takes 2 types, got %d", len(params))
+		}
+		info = CollectionType{NativeType: NewNativeType(p.proto, TypeMap), Key: params[0], Elem: params[1]}
+	case "tuple":
+		// tuples are always frozen
+		info = TupleTypeInfo{NativeType: NewNativeType(p.proto, TypeTuple), Elems: params, Frozen: true}
+	case "vector":
+		p.skipWhitespace()
+		start := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+			p.pos++
+		}
+		dim, err := strconv.Atoi(p.input[start:p.pos])
+		if err != nil || dim <= 0 {
+			p.pos = start
+			return nil, p.errorf("expected the dimensions of the vector")
+		}
+		info = VectorType{NativeType: NewCustomType(p.proto, TypeCustom, VECTOR_TYPE), SubType: params[0], Dimensions: dim}
+	}
+
+	if err := p.expect('>'); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// typeInfo returns the TypeInfo of the class, see ParseCQLTypeClass.
+func (class *typeParserClassNode) typeInfo() (TypeInfo, error) {
+	params := make([]TypeInfo, 0, len(class.params))
+	paramTypes := func(n int) error {
+		if n >= 0 && len(class.params) != n {
+			return fmt.Errorf("gocql: invalid type class %q: expected %d parameters, got %d", class.input, n, len(class.params))
+		}
+		for _, param := range class.params {
+			info, err := param.class.typeInfo()
+			if err != nil {
+				return err
+			}
+			params = append(params, frozenType(info))
+		}
+		return nil
+	}
+
+	switch strings.TrimPrefix(class.name, apacheCassandraTypePrefix) {
+	case "ReversedType":
+		if len(class.params) != 1 {
+			return nil, fmt.Errorf("gocql: invalid type class %q: expected 1 parameter, got %d", class.input, len(class.params))
+		}
+		return class.params[0].class.typeInfo()
+	case "FrozenType":
+		if err := paramTypes(1); err != nil {
+			return nil, err
+		}
+		return params[0], nil
+	case "ListType":
+		if err := paramTypes(1); err != nil {
+			return nil, err
+		}
+		return CollectionType{NativeType: NewNativeType(class.proto, TypeList), Elem: params[0]}, nil
+	case "SetType":
+		if err := paramTypes(1); err != nil {
+			return nil, err
+		}
+		return CollectionType{NativeType: NewNativeType(class.proto, TypeSet), Elem: params[0]}, nil
+	case "MapType":
+		if err := paramTypes(2); err != nil {
+			return nil, err
+		}
+		return CollectionType{NativeType: NewNativeType(class.proto, TypeMap), Key: params[0], Elem: params[1]}, nil
+	case "TupleType":
+		if err := paramTypes(-1); err != nil {
+			return nil, err
+		}
+		return TupleTypeInfo{NativeType: NewNativeType(class.proto, TypeTuple), Elems: params, Frozen: true}, nil
+	case "VectorType":
+		if len(class.params) != 2 {
+			return nil, fmt.Errorf("gocql: invalid type class %q: expected 2 parameters, got %d", class.input, len(class.params))
+		}
+		subType, err := class.params[0].class.typeInfo()
+		if err != nil {
+			return nil, err
+		}
+		subType = frozenType(subType)
+		dim, err := strconv.Atoi(class.params[1].class.name)
+		if err != nil || dim <= 0 {
+			return nil, fmt.Errorf("gocql: invalid type class %q: invalid vector dimensions %q", class.input, class.params[1].class.name)
+		}
+		return VectorType{NativeType: NewCustomType(class.proto, TypeCustom, VECTOR_TYPE), SubType: subType, Dimensions: dim}, nil
+	case "UserType":
+		return class.udtTypeInfo()
+	}
+
+	info := NativeType{typ: getApacheCassandraType(class.name), proto: class.proto}
+	if info.typ == TypeCustom || len(class.params) > 0 {
+		info = NewCustomType(class.proto, TypeCustom, class.input)
+	}
+	return info, nil
+}
+
+// udtTypeInfo returns the TypeInfo of a UserType class, its parameters are
+// the keyspace, the hex encoded name and the fields as hex encoded names
+// and types.
+func (class *typeParserClassNode) udtTypeInfo() (TypeInfo, error) {
+	if len(class.params) < 2 || class.params[0].name != nil || class.params[1].name != nil {
+		return nil, fmt.Errorf("gocql: invalid type class %q: expected the keyspace and name of the user-defined type", class.input)
+	}
+	name, err := hex.DecodeString(class.params[1].class.name)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: invalid type class %q: invalid name: %v", class.input, err)
+	}
+
+	udt := UDTTypeInfo{
+		NativeType: NewNativeType(class.proto, TypeUDT),
+		KeySpace:   class.params[0].class.name,
+		Name:       string(name),
+		Elements:   make([]UDTField, 0, len(class.params)-2),
+	}
+	for _, param := range class.params[2:] {
+		if param.name == nil {
+			return nil, fmt.Errorf("gocql: invalid type class %q: field without name", class.input)
+		}
+		fieldName, err := hex.DecodeString(*param.name)
+		if err != nil {
+			return nil, fmt.Errorf("gocql: invalid type class %q: invalid field name: %v", class.input, err)
+		}
+		fieldType, err := param.class.typeInfo()
+		if err != nil {
+			return nil, err
+		}
+		udt.Elements = append(udt.Elements, UDTField{Name: string(fieldName), Type: frozenType(fieldType)})
+	}
+	return udt, nil
+}
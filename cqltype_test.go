@@ -0,0 +1,208 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCQLType(t *testing.T) {
+	float := NewNativeType(protoVersion4, TypeFloat)
+	text := NewNativeType(protoVersion4, TypeText)
+	address := UDTTypeInfo{NativeType: NewNativeType(protoVersion4, TypeUDT), KeySpace: "ks", Name: "address", Frozen: true}
+
+	tests := []struct {
+		def string
+		exp TypeInfo
+	}{
+		{"int", NewNativeType(protoVersion4, TypeInt)},
+		{" BigInt ", NewNativeType(protoVersion4, TypeBigInt)},
+		{
+			"map<text, frozen<list<vector<float, 3>>>>",
+			CollectionType{
+				NativeType: NewNativeType(protoVersion4, TypeMap),
+				Key:        text,
+				Elem: CollectionType{
+					NativeType: NewNativeType(protoVersion4, TypeList),
+					Elem:       VectorType{NativeType: NewCustomType(protoVersion4, TypeCustom, VECTOR_TYPE), SubType: float, Dimensions: 3},
+					Frozen:     true,
+				},
+			},
+		},
+		{"frozen<ks.address>", address},
+		{"ks.address", UDTTypeInfo{NativeType: NewNativeType(protoVersion4, TypeUDT), KeySpace: "ks", Name: "address"}},
+		{"frozen<int>", NewNativeType(protoVersion4, TypeInt)},
+		{
+			`tuple<"Ks"."My ""Type""", address>`,
+			TupleTypeInfo{
+				NativeType: NewNativeType(protoVersion4, TypeTuple),
+				Frozen:     true,
+				Elems: []TypeInfo{
+					UDTTypeInfo{NativeType: NewNativeType(protoVersion4, TypeUDT), KeySpace: "Ks", Name: `My "Type"`, Frozen: true},
+					UDTTypeInfo{NativeType: NewNativeType(protoVersion4, TypeUDT), Name: "address", Frozen: true},
+				},
+			},
+		},
+		{"'org.apache.cassandra.db.marshal.PointType'", NewCustomType(protoVersion4, TypeCustom, "org.apache.cassandra.db.marshal.PointType")},
+		{"'org.apache.cassandra.db.marshal.UTF8Type'", NewNativeType(protoVersion4, TypeVarchar)},
+	}
+	for _, test := range tests {
+		got, err := ParseCQLType(test.def, protoVersion4)
+		if err != nil {
+			t.Errorf("%s: %v", test.def, err)
+		} else if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%s: expected %v got %v", test.def, test.exp, got)
+		}
+	}
+
+	for _, def := range []string{
+		"",
+		"list<int",
+		"list<int>>",
+		"map<text>",
+		"set<int, int>",
+		"vector<float>",
+		"vector<float, 0>",
+		"frozen<>",
+		"'org.apache.cassandra.db.marshal.UTF8Type",
+		"ks.",
+	} {
+		if _, err := ParseCQLType(def, protoVersion4); err == nil {
+			t.Errorf("%q: expected an error", def)
+		}
+	}
+}
+
+func TestParseCQLTypeClass(t *testing.T) {
+	const (
+		utf8  = "org.apache.cassandra.db.marshal.UTF8Type"
+		int32 = "org.apache.cassandra.db.marshal.Int32Type"
+	)
+	text := NewNativeType(protoVersion4, TypeVarchar)
+	integer := NewNativeType(protoVersion4, TypeInt)
+
+	tests := []struct {
+		class string
+		exp   TypeInfo
+	}{
+		{utf8, text},
+		{"org.apache.cassandra.db.marshal.ReversedType(" + int32 + ")", integer},
+		{
+			MAP_TYPE + "(" + utf8 + "," + int32 + ")",
+			CollectionType{NativeType: NewNativeType(protoVersion4, TypeMap), Key: text, Elem: integer},
+		},
+		{
+			"org.apache.cassandra.db.marshal.FrozenType(" + UDT_TYPE + "(ks,61646472657373,737472656574:" + utf8 + ",7a6970:" + LIST_TYPE + "(" + int32 + ")))",
+			UDTTypeInfo{
+				NativeType: NewNativeType(protoVersion4, TypeUDT),
+				KeySpace:   "ks",
+				Name:       "address",
+				Elements: []UDTField{
+					{Name: "street", Type: text},
+					{Name: "zip", Type: CollectionType{NativeType: NewNativeType(protoVersion4, TypeList), Elem: integer, Frozen: true}},
+				},
+				Frozen: true,
+			},
+		},
+		{
+			TUPLE_TYPE + "(" + int32 + ", " + utf8 + ")",
+			TupleTypeInfo{NativeType: NewNativeType(protoVersion4, TypeTuple), Elems: []TypeInfo{integer, text}, Frozen: true},
+		},
+		{
+			VECTOR_TYPE + "(org.apache.cassandra.db.marshal.FloatType, 3)",
+			VectorType{NativeType: NewCustomType(protoVersion4, TypeCustom, VECTOR_TYPE), SubType: NewNativeType(protoVersion4, TypeFloat), Dimensions: 3},
+		},
+		{
+			"org.apache.cassandra.db.marshal.DynamicCompositeType(s=>" + utf8 + ")",
+			nil,
+		},
+		{"com.example.MyType", NewCustomType(protoVersion4, TypeCustom, "com.example.MyType")},
+	}
+	for _, test := range tests {
+		got, err := ParseCQLTypeClass(test.class, protoVersion4)
+		if test.exp == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.class, got)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.class, err)
+		} else if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%s: expected %v got %v", test.class, test.exp, got)
+		}
+	}
+
+	for _, class := range []string{
+		"",
+		LIST_TYPE + "(" + utf8,
+		MAP_TYPE + "(" + utf8 + ")",
+		VECTOR_TYPE + "(" + utf8 + ",x)",
+		UDT_TYPE + "(ks,zz)",
+	} {
+		if _, err := ParseCQLTypeClass(class, protoVersion4); err == nil {
+			t.Errorf("%q: expected an error", class)
+		}
+	}
+}
+
+func TestFormatCQLType(t *testing.T) {
+	tests := []struct {
+		def string
+		exp string
+	}{
+		{"int", "int"},
+		{"list<int>", "list<int>"},
+		{"frozen<list<int>>", "frozen<list<int>>"},
+		{"frozen<tuple<int, text>>", "frozen<tuple<int, text>>"},
+		{"ks.address", "ks.address"},
+		{"map<text, frozen<list<vector<float, 3>>>>", "map<text, frozen<list<vector<float, 3>>>>"},
+		{"list<tuple<int, ks.address>>", "list<frozen<tuple<int, frozen<ks.address>>>>"},
+		{`set<frozen<"Ks"."My ""Type""">>`, `set<frozen<"Ks"."My ""Type""">>`},
+		{`"text"`, `"text"`},
+		{"vector<vector<float, 3>, 5>", "vector<vector<float, 3>, 5>"},
+		{"'com.example.MyType'", "'com.example.MyType'"},
+	}
+	for _, test := range tests {
+		info, err := ParseCQLType(test.def, protoVersion4)
+		if err != nil {
+			t.Errorf("%s: %v", test.def, err)
+			continue
+		}
+		got := FormatCQLType(info)
+		if got != test.exp {
+			t.Errorf("%s: expected %s got %s", test.def, test.exp, got)
+		}
+		back, err := ParseCQLType(got, protoVersion4)
+		if err != nil {
+			t.Errorf("%s: %v", got, err)
+		} else if !reflect.DeepEqual(back, info) {
+			t.Errorf("%s: expected %v to round-trip, got %v", test.def, info, back)
+		}
+	}
+
+	// the fields of user-defined types are not part of the definition
+	class := UDT_TYPE + "(ks,61646472657373,737472656574:org.apache.cassandra.db.marshal.UTF8Type)"
+	info, err := ParseCQLTypeClass(class, protoVersion4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatCQLType(info); got != "ks.address" {
+		t.Fatalf("expected ks.address got %s", got)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Credentials are the username and password of password authentication.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsProvider provides the credentials used by every authentication
+// handshake, so that rotated secrets are picked up by new connections, see
+// ClusterConfig.CredentialsProvider.
+type CredentialsProvider interface {
+	// Credentials returns the current credentials.
+	Credentials(ctx context.Context) (Credentials, error)
+	// Refresh returns fresh credentials after the server rejected the
+	// credentials rejected. Connections failing at the same time all call
+	// Refresh, the provider should avoid fetching the credentials again if
+	// they changed since rejected was returned.
+	Refresh(ctx context.Context, rejected Credentials) (Credentials, error)
+}
+
+// NewCachedCredentialsProvider returns a CredentialsProvider caching the
+// credentials returned by fetch for ttl, or until they are rejected if ttl is
+// <= 0. Rejected credentials are fetched again once, even if several
+// connections rejected them at the same time.
+func NewCachedCredentialsProvider(fetch func(ctx context.Context) (Credentials, error), ttl time.Duration) CredentialsProvider {
+	return &cachedCredentialsProvider{
+		fetch: fetch,
+		ttl:   ttl,
+	}
+}
+
+type cachedCredentialsProvider struct {
+	fetch func(ctx context.Context) (Credentials, error)
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	creds     Credentials
+	fetchedAt time.Time
+}
+
+func (p *cachedCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.fetchedAt.IsZero() && (p.ttl <= 0 || time.Since(p.fetchedAt) < p.ttl) {
+		return p.creds, nil
+	}
+	return p.fetchLocked(ctx)
+}
+
+func (p *cachedCredentialsProvider) Refresh(ctx context.Context, rejected Credentials) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.fetchedAt.IsZero() && p.creds != rejected {
+		// refreshed by another connection in the meantime
+		return p.creds, nil
+	}
+	return p.fetchLocked(ctx)
+}
+
+func (p *cachedCredentialsProvider) fetchLocked(ctx context.Context) (Credentials, error) {
+	creds, err := p.fetch(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("gocql: unable to fetch credentials: %w", err)
+	}
+	p.creds = creds
+	p.fetchedAt = time.Now()
+	return creds, nil
+}
+
+// errCredentialsRejected is returned by the authentication handshake when
+// the server rejected the credentials of the CredentialsProvider.
+type errCredentialsRejected struct {
+	creds Credentials
+	err   error
+}
+
+func (e *errCredentialsRejected) Error() string {
+	return e.err.Error()
+}
+
+func (e *errCredentialsRejected) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCachedCredentialsProvider(t *testing.T) {
+	fetches := 0
+	provider := NewCachedCredentialsProvider(func(ctx context.Context) (Credentials, error) {
+		fetches++
+		return Credentials{Username: "user", Password: string(rune('a' + fetches - 1))}, nil
+	}, time.Hour)
+	ctx := context.Background()
+
+	first, err := provider.Credentials(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := provider.Credentials(ctx); again != first || fetches != 1 {
+		t.Fatalf("expected the cached credentials, got %v after %d fetches", again, fetches)
+	}
+
+	refreshed, err := provider.Refresh(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed == first || fetches != 2 {
+		t.Fatalf("expected fresh credentials, got %v after %d fetches", refreshed, fetches)
+	}
+
+	// another connection rejected the same credentials concurrently
+	if again, _ := provider.Refresh(ctx, first); again != refreshed || fetches != 2 {
+		t.Fatalf("expected the already refreshed credentials, got %v after %d fetches", again, fetches)
+	}
+}
+
+func TestCachedCredentialsProviderTTL(t *testing.T) {
+	fetches := 0
+	provider := NewCachedCredentialsProvider(func(ctx context.Context) (Credentials, error) {
+		fetches++
+		return Credentials{Username: "user"}, nil
+	}, time.Nanosecond)
+
+	provider.Credentials(context.Background())
+	time.Sleep(time.Millisecond)
+	provider.Credentials(context.Background())
+	if fetches != 2 {
+		t.Fatalf("expected the expired credentials to be fetched again, got %d fetches", fetches)
+	}
+}
@@ -0,0 +1,443 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DecodedFrame is a decoded view of a native protocol frame, see DecodeFrame.
+//
+// Only the fields relevant to the opcode of the frame are set.
+type DecodedFrame struct {
+	Version  byte
+	Response bool
+	Flags    byte
+	Stream   int
+	Opcode   string
+	Length   int
+	TraceID  []byte
+	Warnings []string
+
+	// Options holds the options of STARTUP requests and SUPPORTED responses.
+	Options map[string][]string
+
+	// Statements holds the statement of QUERY and PREPARE requests and the
+	// statements of BATCH requests, prepared batch statements are shown as
+	// their hex encoded ID.
+	Statements []string
+	// PreparedID is the ID of EXECUTE requests and PREPARED results.
+	PreparedID        []byte
+	BatchType         string
+	Consistency       Consistency
+	SerialConsistency Consistency
+	QueryFlags        uint32
+	// Values is the number of bound values of QUERY and EXECUTE requests and
+	// of all statements of BATCH requests.
+	Values      int
+	PageSize    int
+	PagingState []byte
+	// Keyspace is the keyspace of requests, SET_KEYSPACE results and schema
+	// changes.
+	Keyspace string
+	// Events are the event types of REGISTER requests.
+	Events []string
+
+	ResultKind  string
+	ResultFlags int
+	// Columns are the columns of ROWS results and PREPARED results.
+	Columns []ColumnInfo
+	// BindColumns are the bind markers of PREPARED results.
+	BindColumns []ColumnInfo
+	// ResultMetadataID is the result metadata ID of PREPARED results and the
+	// new metadata ID of ROWS results.
+	ResultMetadataID []byte
+	Rows             int
+
+	ErrorCode    int
+	ErrorMessage string
+
+	// Event is the type of EVENT responses, Change the change of EVENT
+	// responses and SCHEMA_CHANGE results.
+	Event  string
+	Change string
+	Addr   net.IP
+	Port   int
+	// Target and Name describe the object of schema changes.
+	Target string
+	Name   string
+
+	// Authenticator is the class of AUTHENTICATE responses.
+	Authenticator string
+}
+
+// String pretty prints the frame on multiple lines, leaving out empty fields.
+func (f *DecodedFrame) String() string {
+	var buf bytes.Buffer
+	line := func(name string, value interface{}) {
+		fmt.Fprintf(&buf, "  %-18s %v\n", name+":", value)
+	}
+
+	dir := "REQ"
+	if f.Response {
+		dir = "RESP"
+	}
+	fmt.Fprintf(&buf, "%s v%d stream=%d flags=0x%02x length=%d %s\n", f.Opcode, f.Version, f.Stream, f.Flags, f.Length, dir)
+
+	if f.TraceID != nil {
+		line("trace_id", fmt.Sprintf("%x", f.TraceID))
+	}
+	for _, w := range f.Warnings {
+		line("warning", w)
+	}
+	if len(f.Options) > 0 {
+		keys := make([]string, 0, len(f.Options))
+		for k := range f.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line("option", k+"="+strings.Join(f.Options[k], ","))
+		}
+	}
+	if f.BatchType != "" {
+		line("batch_type", f.BatchType)
+	}
+	for _, stmt := range f.Statements {
+		line("statement", stmt)
+	}
+	if f.PreparedID != nil {
+		line("prepared_id", fmt.Sprintf("%x", f.PreparedID))
+	}
+	switch f.Opcode {
+	case "QUERY", "EXECUTE", "BATCH":
+		line("consistency", f.Consistency)
+		if f.SerialConsistency != 0 {
+			line("serial_consistency", f.SerialConsistency)
+		}
+		line("query_flags", fmt.Sprintf("0x%02x", f.QueryFlags))
+		line("values", f.Values)
+	}
+	if f.PageSize != 0 {
+		line("page_size", f.PageSize)
+	}
+	if f.PagingState != nil {
+		line("paging_state", fmt.Sprintf("%x", f.PagingState))
+	}
+	if f.Keyspace != "" {
+		line("keyspace", f.Keyspace)
+	}
+	if len(f.Events) > 0 {
+		line("events", strings.Join(f.Events, ","))
+	}
+	if f.ResultKind != "" {
+		line("result", f.ResultKind)
+	}
+	if f.ResultFlags != 0 {
+		line("result_flags", fmt.Sprintf("0x%02x", f.ResultFlags))
+	}
+	if f.ResultMetadataID != nil {
+		line("metadata_id", fmt.Sprintf("%x", f.ResultMetadataID))
+	}
+	for _, col := range f.BindColumns {
+		line("bind_column", fmt.Sprintf("%s.%s.%s %s", col.Keyspace, col.Table, col.Name, col.TypeInfo))
+	}
+	for _, col := range f.Columns {
+		line("column", fmt.Sprintf("%s.%s.%s %s", col.Keyspace, col.Table, col.Name, col.TypeInfo))
+	}
+	if f.ResultKind == "ROWS" {
+		line("rows", f.Rows)
+	}
+	if f.Opcode == "ERROR" {
+		line("error_code", fmt.Sprintf("0x%04x", f.ErrorCode))
+		line("error_message", f.ErrorMessage)
+	}
+	if f.Event != "" {
+		line("event", f.Event)
+	}
+	if f.Change != "" {
+		line("change", f.Change)
+	}
+	if f.Addr != nil {
+		line("address", net.JoinHostPort(f.Addr.String(), fmt.Sprint(f.Port)))
+	}
+	if f.Target != "" {
+		line("target", f.Target)
+	}
+	if f.Name != "" {
+		line("name", f.Name)
+	}
+	if f.Authenticator != "" {
+		line("authenticator", f.Authenticator)
+	}
+
+	return buf.String()
+}
+
+// DecodeFrame decodes a single request or response frame, header included.
+// Compressed frame bodies are not supported, DecodeFrame is meant to be used
+// on uncompressed frames like the ones passed to FrameRecorder.
+func DecodeFrame(p []byte) (*DecodedFrame, error) {
+	r := bytes.NewReader(p)
+	head, err := readHeader(r, make([]byte, maxFrameHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+	if head.flags&flagCompress != 0 && !modernLayout(head.version.version()) {
+		return nil, NewErrProtocol("unable to decode compressed frame body")
+	}
+
+	f := newFramer(nil, head.version.version())
+	if err := f.readFrame(r, &head); err != nil {
+		return nil, err
+	}
+
+	decoded := &DecodedFrame{
+		Version:  head.version.version(),
+		Response: head.version.response(),
+		Flags:    head.flags,
+		Stream:   head.stream,
+		Opcode:   head.op.String(),
+		Length:   head.length,
+	}
+
+	if decoded.Response {
+		err = decoded.decodeResponse(f)
+	} else {
+		err = decoded.decodeRequest(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+func (d *DecodedFrame) decodeResponse(f *framer) error {
+	frame, err := f.parseFrame()
+	if err != nil {
+		return err
+	}
+
+	d.Warnings = f.header.warnings
+	if f.traceID != nil {
+		d.TraceID = f.traceID
+	}
+
+	switch v := frame.(type) {
+	case *supportedFrame:
+		d.Options = v.supported
+	case *authenticateFrame:
+		d.Authenticator = v.class
+	case *resultVoidFrame:
+		d.ResultKind = "VOID"
+	case *resultRowsFrame:
+		d.ResultKind = "ROWS"
+		d.ResultFlags = v.meta.flags
+		d.PagingState = v.meta.pagingState
+		d.ResultMetadataID = v.meta.newMetadataID
+		d.Columns = v.meta.columns
+		d.Rows = v.numRows
+	case *resultKeyspaceFrame:
+		d.ResultKind = "SET_KEYSPACE"
+		d.Keyspace = v.keyspace
+	case *resultPreparedFrame:
+		d.ResultKind = "PREPARED"
+		d.PreparedID = v.preparedID
+		d.ResultMetadataID = v.resultMetadataID
+		d.BindColumns = v.reqMeta.columns
+		d.Columns = v.respMeta.columns
+		d.ResultFlags = v.respMeta.flags
+	case *schemaChangeKeyspace:
+		d.decodeSchemaChange("KEYSPACE", v.change, v.keyspace, "")
+	case *schemaChangeTable:
+		d.decodeSchemaChange("TABLE", v.change, v.keyspace, v.object)
+	case *schemaChangeType:
+		d.decodeSchemaChange("TYPE", v.change, v.keyspace, v.object)
+	case *schemaChangeFunction:
+		d.decodeSchemaChange("FUNCTION", v.change, v.keyspace, v.name)
+	case *schemaChangeAggregate:
+		d.decodeSchemaChange("AGGREGATE", v.change, v.keyspace, v.name)
+	case *statusChangeEventFrame:
+		d.Event = "STATUS_CHANGE"
+		d.Change, d.Addr, d.Port = v.change, v.host, v.port
+	case *topologyChangeEventFrame:
+		d.Event = "TOPOLOGY_CHANGE"
+		d.Change, d.Addr, d.Port = v.change, v.host, v.port
+	case RequestError:
+		d.ErrorCode = v.Code()
+		d.ErrorMessage = v.Message()
+	}
+
+	return nil
+}
+
+func (d *DecodedFrame) decodeSchemaChange(target, change, keyspace, name string) {
+	if d.Opcode == "EVENT" {
+		d.Event = "SCHEMA_CHANGE"
+	} else {
+		d.ResultKind = "SCHEMA_CHANGE"
+	}
+	d.Target, d.Change, d.Keyspace, d.Name = target, change, keyspace, name
+}
+
+func (d *DecodedFrame) decodeRequest(f *framer) error {
+	if f.header.flags&flagCustomPayload != 0 {
+		f.readBytesMap()
+	}
+
+	switch f.header.op {
+	case opStartup:
+		d.Options = make(map[string][]string)
+		n := int(f.readShort())
+		for i := 0; i < n && f.err == nil; i++ {
+			k := f.readString()
+			d.Options[k] = []string{f.readString()}
+		}
+	case opRegister:
+		d.Events = f.readStringList()
+	case opQuery:
+		d.Statements = []string{f.readLongString()}
+		d.decodeQueryParams(f)
+	case opPrepare:
+		d.Statements = []string{f.readLongString()}
+		if usesIntFlags(f.proto) {
+			if flags := f.readInt(); flags&int(flagWithPreparedKeyspace) != 0 {
+				d.Keyspace = f.readString()
+			}
+		}
+	case opExecute:
+		d.PreparedID = f.readShortBytes()
+		if supportsResultMetadataID(f.proto) {
+			d.ResultMetadataID = f.readShortBytes()
+		}
+		d.decodeQueryParams(f)
+	case opBatch:
+		d.decodeBatch(f)
+	}
+
+	return f.err
+}
+
+func (d *DecodedFrame) decodeQueryParams(f *framer) {
+	d.Consistency = f.readConsistency()
+	if usesIntQueryFlags(f.proto) {
+		d.QueryFlags = uint32(f.readInt())
+	} else {
+		d.QueryFlags = uint32(f.readByte())
+	}
+
+	if d.QueryFlags&flagValues != 0 {
+		d.Values = int(f.readShort())
+		for i := 0; i < d.Values && f.err == nil; i++ {
+			if d.QueryFlags&flagWithNameValues != 0 {
+				f.readString()
+			}
+			f.readBytes()
+		}
+	}
+	if d.QueryFlags&flagPageSize != 0 {
+		d.PageSize = f.readInt()
+	}
+	if d.QueryFlags&flagWithPagingState != 0 {
+		d.PagingState = f.readBytes()
+	}
+	if d.QueryFlags&flagWithSerialConsistency != 0 {
+		d.SerialConsistency = f.readConsistency()
+	}
+	if d.QueryFlags&flagDefaultTimestamp != 0 {
+		f.readInt()
+		f.readInt()
+	}
+	if supportsKeyspace(f.proto) && d.QueryFlags&flagWithKeyspace != 0 {
+		d.Keyspace = f.readString()
+	}
+}
+
+func (d *DecodedFrame) decodeBatch(f *framer) {
+	switch BatchType(f.readByte()) {
+	case LoggedBatch:
+		d.BatchType = "LOGGED"
+	case UnloggedBatch:
+		d.BatchType = "UNLOGGED"
+	case CounterBatch:
+		d.BatchType = "COUNTER"
+	}
+
+	n := int(f.readShort())
+	for i := 0; i < n && f.err == nil; i++ {
+		if kind := f.readByte(); kind == 0 {
+			d.Statements = append(d.Statements, f.readLongString())
+		} else {
+			d.Statements = append(d.Statements, fmt.Sprintf("<prepared %x>", f.readShortBytes()))
+		}
+
+		values := int(f.readShort())
+		d.Values += values
+		for j := 0; j < values && f.err == nil; j++ {
+			// named values are not supported in batches, see CASSANDRA-10246
+			f.readBytes()
+		}
+	}
+
+	d.Consistency = f.readConsistency()
+	if usesIntFlags(f.proto) {
+		d.QueryFlags = uint32(f.readInt())
+	} else {
+		d.QueryFlags = uint32(f.readByte())
+	}
+	if d.QueryFlags&flagWithSerialConsistency != 0 {
+		d.SerialConsistency = f.readConsistency()
+	}
+	if d.QueryFlags&flagDefaultTimestamp != 0 {
+		f.readInt()
+		f.readInt()
+	}
+	if supportsKeyspace(f.proto) && d.QueryFlags&flagWithKeyspace != 0 {
+		d.Keyspace = f.readString()
+	}
+}
+
+// ObservedFrame is a frame sent or received by a connection, decoded for a
+// FrameObserver.
+type ObservedFrame struct {
+	Direction FrameDirection
+	Time      time.Time
+	// Host is the host of the connection the frame was sent or received on.
+	Host *HostInfo
+	// Frame is the decoded frame, it is nil if decoding failed with Err.
+	Frame *DecodedFrame
+	Err   error
+}
+
+// FrameObserver is the interface implemented by observers of decoded frames,
+// see ClusterConfig.FrameObserver.
+//
+// Experimental, this interface and use may change
+type FrameObserver interface {
+	// ObserveFrame gets called on every frame sent and received by the
+	// connections of a session.
+	ObserveFrame(context.Context, ObservedFrame)
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFrameQuery(t *testing.T) {
+	for _, proto := range []byte{protoVersion4, protoVersion5} {
+		f := newFramer(nil, proto)
+		params := &queryParams{
+			consistency:       Quorum,
+			serialConsistency: LocalSerial,
+			pageSize:          100,
+			pagingState:       []byte{1, 2},
+			values:            []queryValues{{value: []byte("a")}, {value: nil}},
+		}
+		if err := f.writeQueryFrame(7, "SELECT * FROM ks.t WHERE k = ?", params, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := DecodeFrame(f.buf)
+		if err != nil {
+			t.Fatalf("v%d: %v", proto, err)
+		}
+		if decoded.Opcode != "QUERY" || decoded.Response || decoded.Stream != 7 || decoded.Version != proto {
+			t.Errorf("v%d: unexpected header %+v", proto, decoded)
+		}
+		if len(decoded.Statements) != 1 || decoded.Statements[0] != "SELECT * FROM ks.t WHERE k = ?" {
+			t.Errorf("v%d: unexpected statements %q", proto, decoded.Statements)
+		}
+		if decoded.Consistency != Quorum || decoded.SerialConsistency != LocalSerial {
+			t.Errorf("v%d: unexpected consistency %v/%v", proto, decoded.Consistency, decoded.SerialConsistency)
+		}
+		if decoded.Values != 2 || decoded.PageSize != 100 || !bytes.Equal(decoded.PagingState, []byte{1, 2}) {
+			t.Errorf("v%d: unexpected params %+v", proto, decoded)
+		}
+		if s := decoded.String(); !strings.Contains(s, "statement:") || !strings.Contains(s, "page_size:") {
+			t.Errorf("v%d: unexpected string %q", proto, s)
+		}
+
+		// a body cut short of the query parameters
+		f.buf = f.buf[:len(f.buf)-8]
+		f.setLength(len(f.buf) - f.headSize)
+		if _, err := DecodeFrame(f.buf); err == nil {
+			t.Errorf("v%d: expected an error for a truncated body", proto)
+		}
+	}
+}
+
+func TestDecodeFrameRows(t *testing.T) {
+	f := newFramer(nil, protoVersion4)
+	f.writeHeader(0, opResult, 2)
+	f.buf[0] |= protoDirectionMask
+	f.writeInt(resultKindRows)
+	f.writeInt(int32(flagGlobalTableSpec))
+	f.writeInt(1)
+	f.writeString("ks")
+	f.writeString("t")
+	f.writeString("v")
+	f.writeShort(uint16(TypeVarchar))
+	f.writeInt(2)
+	f.writeBytes([]byte("a"))
+	f.writeBytes([]byte("b"))
+	if err := f.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeFrame(f.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Response || decoded.ResultKind != "ROWS" || decoded.Rows != 2 {
+		t.Fatalf("unexpected frame %+v", decoded)
+	}
+	if len(decoded.Columns) != 1 || decoded.Columns[0].Name != "v" || decoded.Columns[0].TypeInfo.Type() != TypeVarchar {
+		t.Fatalf("unexpected columns %+v", decoded.Columns)
+	}
+	if s := decoded.String(); !strings.Contains(s, "ks.t.v varchar") || !strings.Contains(s, "rows:") {
+		t.Errorf("unexpected string %q", s)
+	}
+}
+
+func TestDecodeFrameError(t *testing.T) {
+	f := newFramer(nil, protoVersion4)
+	f.writeHeader(0, opError, 1)
+	f.buf[0] |= protoDirectionMask
+	f.writeInt(ErrCodeInvalid)
+	f.writeString("unconfigured table t")
+	if err := f.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeFrame(f.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ErrorCode != ErrCodeInvalid || decoded.ErrorMessage != "unconfigured table t" {
+		t.Fatalf("unexpected error %d %q", decoded.ErrorCode, decoded.ErrorMessage)
+	}
+
+	// truncated bodies are reported as errors
+	if _, err := DecodeFrame(f.buf[:len(f.buf)-4]); err == nil {
+		t.Fatal("expected an error for a truncated frame")
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 )
 
 // HostDialer allows customizing connection to cluster nodes.
@@ -51,12 +52,16 @@ type DialedHost struct {
 	// DisableCoalesce disables write coalescing for the Conn.
 	// If true, the effect is the same as if WriteCoalesceWaitTime was configured to 0.
 	DisableCoalesce bool
+
+	// certExpiry is when the client certificate of the connection expires,
+	// if known.
+	certExpiry time.Time
 }
 
 // defaultHostDialer dials host in a default way.
 type defaultHostDialer struct {
-	dialer    Dialer
-	tlsConfig *tls.Config
+	dialer Dialer
+	tls    *tlsConfigSource
 }
 
 func (hd *defaultHostDialer) DialHost(ctx context.Context, host *HostInfo) (*DialedHost, error) {
@@ -75,7 +80,17 @@ func (hd *defaultHostDialer) DialHost(ctx context.Context, host *HostInfo) (*Dia
 		return nil, err
 	}
 	addr := host.HostnameAndPort()
-	return WrapTLS(ctx, conn, addr, hd.tlsConfig)
+	if hd.tls == nil {
+		return WrapTLS(ctx, conn, addr, nil)
+	}
+
+	tlsConfig, certExpiry := hd.tls.get()
+	dialed, err := WrapTLS(ctx, conn, addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	dialed.certExpiry = certExpiry
+	return dialed, nil
 }
 
 func tlsConfigForAddr(tlsConfig *tls.Config, addr string) *tls.Config {
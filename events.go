@@ -259,6 +259,8 @@ func (s *Session) handleNodeDown(ip net.IP, port int) {
 	host, ok := s.ring.getHostByIP(ip.String())
 	if ok {
 		host.setState(NodeDown)
+		// the node may come back upgraded, negotiate again when reconnecting
+		host.setProtocolVersion(0)
 		if s.cfg.filterHost(host) {
 			return
 		}
@@ -33,7 +33,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -72,6 +71,15 @@ const (
 	protoVersion4      = 0x04
 	protoVersion5      = 0x05
 
+	// DataStax Enterprise protocol versions, they encode messages like
+	// protocol v5 but keep the frame layout and compression of v4.
+	protoVersionDSE1 = 0x41
+	protoVersionDSE2 = 0x42
+
+	// maxProtocolVersion is the highest Apache Cassandra protocol version the
+	// driver supports.
+	maxProtocolVersion = protoVersion5
+
 	maxFrameSize = 256 * 1024 * 1024
 
 	maxSegmentPayloadSize = 0x1FFFF
@@ -91,6 +99,56 @@ func (p protoVersion) version() byte {
 	return byte(p) & protoVersionMask
 }
 
+// isDSEVersion returns true if version is a DataStax Enterprise protocol
+// version.
+func isDSEVersion(version byte) bool {
+	return version >= protoVersionDSE1
+}
+
+// modernLayout returns true if frames of protocol version are carried in
+// segments once the connection is ready, which is the case from v5 on.
+func modernLayout(version byte) bool {
+	return version > protoVersion4 && !isDSEVersion(version)
+}
+
+// supportsKeyspace returns true if requests of protocol version can set the
+// keyspace they apply to.
+func supportsKeyspace(version byte) bool {
+	return version > protoVersion4 && version != protoVersionDSE1
+}
+
+// supportsNowInSeconds returns true if requests of protocol version can set
+// now_in_seconds.
+func supportsNowInSeconds(version byte) bool {
+	return modernLayout(version)
+}
+
+// usesIntFlags returns true if the flags of prepare and batch requests of
+// protocol version are an int rather than a byte. DSE_V1 is based on v4 and
+// keeps the byte flags.
+func usesIntFlags(version byte) bool {
+	return version > protoVersion4 && version != protoVersionDSE1
+}
+
+// usesIntQueryFlags returns true if the flags of the query parameters of
+// protocol version are an int rather than a byte. DSE_V1 has int flags there
+// for the continuous paging flags, which do not fit in a byte.
+func usesIntQueryFlags(version byte) bool {
+	return version > protoVersion4
+}
+
+// supportsResultMetadataID returns true if prepared statements of protocol
+// version have a result metadata ID, which is sent with each execution.
+func supportsResultMetadataID(version byte) bool {
+	return version > protoVersion4 && version != protoVersionDSE1
+}
+
+// supportsFailureReasonMap returns true if read and write failures of
+// protocol version list the reason of each failed node.
+func supportsFailureReasonMap(version byte) bool {
+	return version > protoVersion4 && version != protoVersionDSE1
+}
+
 func (p protoVersion) String() string {
 	dir := "REQ"
 	if p.response() {
@@ -120,6 +178,9 @@ const (
 	opAuthChallenge frameOp = 0x0E
 	opAuthResponse  frameOp = 0x0F
 	opAuthSuccess   frameOp = 0x10
+
+	// DSE protocol extensions
+	opReviseRequest frameOp = 0xFF
 )
 
 func (f frameOp) String() string {
@@ -156,6 +217,8 @@ func (f frameOp) String() string {
 		return "AUTH_RESPONSE"
 	case opAuthSuccess:
 		return "AUTH_SUCCESS"
+	case opReviseRequest:
+		return "REVISE_REQUEST"
 	default:
 		return fmt.Sprintf("UNKNOWN_OP_%d", f)
 	}
@@ -174,6 +237,9 @@ const (
 	flagHasMorePages    int = 0x02
 	flagNoMetaData      int = 0x04
 	flagMetaDataChanged int = 0x08
+	// DSE continuous paging
+	flagContinuousPaging   int = 0x40000000
+	flagLastContinuousPage int = -0x80000000
 
 	// query flags
 	flagValues                uint32 = 0x01
@@ -185,6 +251,7 @@ const (
 	flagWithNameValues        uint32 = 0x40
 	flagWithKeyspace          uint32 = 0x80
 	flagWithNowInSeconds      uint32 = 0x100
+	flagWithContinuousPaging  uint32 = 0x40000000
 
 	// prepare flags
 	flagWithPreparedKeyspace uint32 = 0x01
@@ -378,8 +445,26 @@ type framer struct {
 	readBuffer []byte
 
 	buf []byte
+	// bodyLen is the length of the frame body read into buf, used to give the
+	// offset of parse errors.
+	bodyLen int
+
+	// err is the first error encountered while parsing the frame body, reads
+	// return zero values once it is set.
+	err error
+	// malformed, if set, is called with err when the frame body turns out
+	// to be malformed.
+	malformed func(err error)
+	// limits, if set, limits the size of the decompressed body.
+	limits *responseLimits
 
 	customPayload map[string][]byte
+
+	// pooled is set for framers from getFramer, bufRef and decompressRef are
+	// the pooled buffers they hold.
+	pooled        bool
+	bufRef        *[]byte
+	decompressRef *[]byte
 }
 
 func newFramer(compressor Compressor, version byte) *framer {
@@ -388,6 +473,11 @@ func newFramer(compressor Compressor, version byte) *framer {
 		buf:        buf[:0],
 		readBuffer: buf,
 	}
+	f.setup(compressor, version)
+	return f
+}
+
+func (f *framer) setup(compressor Compressor, version byte) {
 	var flags byte
 	if compressor != nil {
 		flags |= flagCompress
@@ -410,8 +500,6 @@ func newFramer(compressor Compressor, version byte) *framer {
 
 	f.header = nil
 	f.traceID = nil
-
-	return f
 }
 
 type frame interface {
@@ -426,7 +514,7 @@ func readHeader(r io.Reader, p []byte) (head frameHeader, err error) {
 
 	version := p[0] & protoVersionMask
 
-	if version < protoVersion1 || version > protoVersion5 {
+	if version < protoVersion1 || (version > protoVersion5 && !isDSEVersion(version)) || version > protoVersionDSE2 {
 		return frameHeader{}, fmt.Errorf("gocql: unsupported protocol response version: %d", version)
 	}
 
@@ -492,7 +580,7 @@ func (f *framer) readFrame(r io.Reader, head *frameHeader) error {
 	if cap(f.readBuffer) >= head.length {
 		f.buf = f.readBuffer[:head.length]
 	} else {
-		f.readBuffer = make([]byte, head.length)
+		f.readBuffer = f.buffer(head.length)
 		f.buf = f.readBuffer
 	}
 
@@ -502,33 +590,74 @@ func (f *framer) readFrame(r io.Reader, head *frameHeader) error {
 		return fmt.Errorf("unable to read frame body: read %d/%d bytes: %v", n, head.length, err)
 	}
 
-	if f.proto < protoVersion5 && head.flags&flagCompress == flagCompress {
+	if !modernLayout(f.proto) && head.flags&flagCompress == flagCompress {
 		if f.compres == nil {
 			return NewErrProtocol("no compressor available with compressed frame body")
 		}
 
-		f.buf, err = f.compres.AppendDecompressedWithLength(nil, f.buf)
+		var dst []byte
+		if l, ok := f.compres.(decompressedLengther); ok && (f.limits != nil || f.pooled) {
+			if n, err := l.DecompressedLength(f.buf); err == nil {
+				if err := f.limits.checkDecompressed(head, n); err != nil {
+					return err
+				}
+				dst = f.decompressBuffer(n)
+			}
+		}
+
+		f.buf, err = f.compres.AppendDecompressedWithLength(dst, f.buf)
 		if err != nil {
 			return err
 		}
+		if err := f.limits.checkDecompressed(head, len(f.buf)); err != nil {
+			return err
+		}
 	}
 
 	f.header = head
+	f.bodyLen = len(f.buf)
+	f.err = nil
 	return nil
 }
 
-func (f *framer) parseFrame() (frame frame, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if _, ok := r.(runtime.Error); ok {
-				panic(r)
-			}
-			err = r.(error)
-		}
-	}()
+// fail records the first error encountered while parsing the frame body as
+// an ErrProtocol giving the offset it was found at. The rest of the body is
+// dropped so further reads fail fast.
+func (f *framer) fail(format string, args ...interface{}) {
+	if f.err != nil {
+		return
+	}
+
+	op := "unknown"
+	if f.header != nil {
+		op = f.header.op.String()
+	}
+	f.err = NewErrProtocol("gocql: malformed %s frame at offset %d: %s", op, f.bodyLen-len(f.buf), fmt.Sprintf(format, args...))
+	f.buf = f.buf[:0]
+
+	if f.malformed != nil {
+		f.malformed(f.err)
+	}
+}
 
+// checkCount validates a count of n elements read from the frame body, which
+// each take at least size bytes, against the remaining body. This guards
+// allocations and loops from corrupt counts.
+func (f *framer) checkCount(n, size int, what string) bool {
+	if f.err != nil {
+		return false
+	}
+	if n < 0 || n > len(f.buf)/size {
+		f.fail("invalid %s count %d with %d bytes left", what, n, len(f.buf))
+		return false
+	}
+	return true
+}
+
+func (f *framer) parseFrame() (frame frame, err error) {
 	if f.header.version.request() {
-		return nil, NewErrProtocol("got a request frame from server: %v", f.header.version)
+		f.fail("got a request frame from server: %v", f.header.version)
+		return nil, f.err
 	}
 
 	if f.header.flags&flagTracing == flagTracing {
@@ -562,10 +691,13 @@ func (f *framer) parseFrame() (frame frame, err error) {
 	case opEvent:
 		frame = f.parseEventFrame()
 	default:
-		return nil, NewErrProtocol("unknown op in frame header: %s", f.header.op)
+		f.fail("unknown op in frame header: %s", f.header.op)
 	}
 
-	return
+	if f.err != nil {
+		return nil, f.err
+	}
+	return frame, err
 }
 
 func (f *framer) parseErrorFrame() frame {
@@ -634,7 +766,7 @@ func (f *framer) parseErrorFrame() frame {
 		res.Consistency = f.readConsistency()
 		res.Received = f.readInt()
 		res.BlockFor = f.readInt()
-		if f.proto > protoVersion4 {
+		if supportsFailureReasonMap(f.proto) {
 			res.ErrorMap = f.readErrorMap()
 			res.NumFailures = len(res.ErrorMap)
 		} else {
@@ -650,7 +782,7 @@ func (f *framer) parseErrorFrame() frame {
 		res.Consistency = f.readConsistency()
 		res.Received = f.readInt()
 		res.BlockFor = f.readInt()
-		if f.proto > protoVersion4 {
+		if supportsFailureReasonMap(f.proto) {
 			res.ErrorMap = f.readErrorMap()
 			res.NumFailures = len(res.ErrorMap)
 		} else {
@@ -685,13 +817,18 @@ func (f *framer) parseErrorFrame() frame {
 		// TODO(zariel): we should have some distinct types for these errors
 		return errD
 	default:
-		panic(fmt.Errorf("unknown error code: 0x%x", errD.code))
+		f.fail("unknown error code: 0x%x", errD.code)
+		return errD
 	}
 }
 
 func (f *framer) readErrorMap() (errMap ErrorMap) {
 	errMap = make(ErrorMap)
 	numErrs := f.readInt()
+	// each entry is an inet address of at least 5 bytes and a short
+	if !f.checkCount(numErrs, 7, "error map") {
+		return
+	}
 	for i := 0; i < numErrs; i++ {
 		ip := f.readInetAdressOnly().String()
 		errMap[ip] = f.readShort()
@@ -746,7 +883,7 @@ func (f *framer) finish() error {
 		return ErrFrameTooBig
 	}
 
-	if f.proto < protoVersion5 && f.buf[1]&flagCompress == flagCompress {
+	if !modernLayout(f.proto) && f.buf[1]&flagCompress == flagCompress {
 		if f.compres == nil {
 			panic("compress flag set with no compressor")
 		}
@@ -831,13 +968,13 @@ func (w *writePrepareFrame) buildFrame(f *framer, streamID int) error {
 
 	var flags uint32 = 0
 	if w.keyspace != "" {
-		if f.proto > protoVersion4 {
+		if supportsKeyspace(f.proto) {
 			flags |= flagWithPreparedKeyspace
 		} else {
 			panic(fmt.Errorf("the keyspace can only be set with protocol 5 or higher"))
 		}
 	}
-	if f.proto > protoVersion4 {
+	if usesIntFlags(f.proto) {
 		f.writeUint(flags)
 	}
 	if w.keyspace != "" {
@@ -847,9 +984,20 @@ func (w *writePrepareFrame) buildFrame(f *framer, streamID int) error {
 	return f.finish()
 }
 
+// maxTypeInfoDepth is the maximum nesting of types read by readTypeInfo, it
+// keeps corrupt frames from exhausting the stack.
+const maxTypeInfoDepth = 256
+
 func (f *framer) readTypeInfo() TypeInfo {
+	return f.readNestedTypeInfo(0)
+}
+
+func (f *framer) readNestedTypeInfo(depth int) TypeInfo {
 	// TODO: factor this out so the same code paths can be used to parse custom
 	// types and other types, as much of the logic will be duplicated.
+	if depth > maxTypeInfoDepth {
+		f.fail("type nested deeper than %d levels", maxTypeInfoDepth)
+	}
 	id := f.readShort()
 
 	simple := NativeType{
@@ -866,54 +1014,74 @@ func (f *framer) readTypeInfo() TypeInfo {
 
 	switch simple.typ {
 	case TypeTuple:
-		n := f.readShort()
+		n := int(f.readShort())
+		if !f.checkCount(n, 2, "tuple element") {
+			return simple
+		}
+		// tuples are always frozen
 		tuple := TupleTypeInfo{
 			NativeType: simple,
 			Elems:      make([]TypeInfo, n),
+			Frozen:     true,
 		}
 
-		for i := 0; i < int(n); i++ {
-			tuple.Elems[i] = f.readTypeInfo()
+		for i := 0; i < n; i++ {
+			tuple.Elems[i] = f.readNestedTypeInfo(depth + 1)
 		}
 
 		return tuple
 
 	case TypeUDT:
+		// the metadata does not say whether a column is frozen, only that
+		// nested types are
 		udt := UDTTypeInfo{
 			NativeType: simple,
+			Frozen:     depth > 0,
 		}
 		udt.KeySpace = f.readString()
 		udt.Name = f.readString()
 
-		n := f.readShort()
+		n := int(f.readShort())
+		if !f.checkCount(n, 4, "UDT field") {
+			return simple
+		}
 		udt.Elements = make([]UDTField, n)
-		for i := 0; i < int(n); i++ {
+		for i := 0; i < n; i++ {
 			field := &udt.Elements[i]
 			field.Name = f.readString()
-			field.Type = f.readTypeInfo()
+			field.Type = f.readNestedTypeInfo(depth + 1)
 		}
 
 		return udt
 	case TypeMap, TypeList, TypeSet:
 		collection := CollectionType{
 			NativeType: simple,
+			Frozen:     depth > 0,
 		}
 
 		if simple.typ == TypeMap {
-			collection.Key = f.readTypeInfo()
+			collection.Key = f.readNestedTypeInfo(depth + 1)
 		}
 
-		collection.Elem = f.readTypeInfo()
+		collection.Elem = f.readNestedTypeInfo(depth + 1)
 
 		return collection
 	case TypeCustom:
 		if strings.HasPrefix(simple.custom, VECTOR_TYPE) {
 			spec := strings.TrimPrefix(simple.custom, VECTOR_TYPE)
+			if len(spec) < 2 {
+				f.fail("invalid vector type: %q", simple.custom)
+				return simple
+			}
 			spec = spec[1 : len(spec)-1] // remove parenthesis
 			idx := strings.LastIndex(spec, ",")
+			if idx < 0 {
+				f.fail("invalid vector type: %q", simple.custom)
+				return simple
+			}
 			typeStr := spec[:idx]
 			dimStr := spec[idx+1:]
-			subType := getCassandraLongType(strings.TrimSpace(typeStr), f.proto, nopLogger{})
+			subType := frozenType(getCassandraLongType(strings.TrimSpace(typeStr), f.proto, nopLogger{}))
 			dim, _ := strconv.Atoi(strings.TrimSpace(dimStr))
 			vector := VectorType{
 				NativeType: simple,
@@ -949,12 +1117,16 @@ func (f *framer) parsePreparedMetadata() preparedMetadata {
 	meta.flags = f.readInt()
 	meta.colCount = f.readInt()
 	if meta.colCount < 0 {
-		panic(fmt.Errorf("received negative column count: %d", meta.colCount))
+		f.fail("received negative column count: %d", meta.colCount)
+		return meta
 	}
 	meta.actualColCount = meta.colCount
 
 	if f.proto >= protoVersion4 {
 		pkeyCount := f.readInt()
+		if !f.checkCount(pkeyCount, 2, "partition key") {
+			return meta
+		}
 		pkeys := make([]int, pkeyCount)
 		for i := 0; i < pkeyCount; i++ {
 			pkeys[i] = int(f.readShort())
@@ -976,6 +1148,15 @@ func (f *framer) parsePreparedMetadata() preparedMetadata {
 		meta.table = f.readString()
 	}
 
+	// each column is at least a name and a type id
+	colSize := 4
+	if !globalSpec {
+		colSize += 4
+	}
+	if !f.checkCount(meta.colCount, colSize, "column") {
+		return meta
+	}
+
 	var cols []ColumnInfo
 	if meta.colCount < 1000 {
 		// preallocate columninfo to avoid excess copying
@@ -994,6 +1175,7 @@ func (f *framer) parsePreparedMetadata() preparedMetadata {
 	}
 
 	meta.columns = cols
+	meta.plans = make([]columnPlan, len(cols))
 
 	return meta
 }
@@ -1013,12 +1195,34 @@ type resultMetadata struct {
 	actualColCount int
 
 	newMetadataID []byte
+
+	// continuousPageNumber is the number of the page, starting at 1, when
+	// the rows are a page of a DSE continuous paging request.
+	continuousPageNumber int
+
+	// plans caches the marshal plans of columns, it is shared by copies of
+	// the metadata of prepared statements.
+	plans []columnPlan
+}
+
+// plan returns the marshal plan cache of column i, nil if there is none.
+func (r *resultMetadata) plan(i int) *columnPlan {
+	if i < len(r.plans) {
+		return &r.plans[i]
+	}
+	return nil
 }
 
 func (r *resultMetadata) morePages() bool {
 	return r.flags&flagHasMorePages == flagHasMorePages
 }
 
+// moreContinuousPages returns true if the rows are a continuous page which
+// is followed by other pages.
+func (r *resultMetadata) moreContinuousPages() bool {
+	return r.flags&flagContinuousPaging != 0 && r.flags&flagLastContinuousPage == 0
+}
+
 func (r *resultMetadata) noMetaData() bool {
 	return r.flags&flagNoMetaData == flagNoMetaData
 }
@@ -1046,13 +1250,43 @@ func (f *framer) readCol(col *ColumnInfo, meta *resultMetadata, globalSpec bool,
 	}
 }
 
+// lastContinuousPage returns true if the response read by f is the last one of
+// a DSE continuous paging request, which is the case of all responses but
+// continuous pages followed by other pages. The frame is not consumed.
+func (f *framer) lastContinuousPage() bool {
+	peek := framer{proto: f.proto, buf: f.buf}
+	if f.header.op != opResult {
+		return true
+	}
+
+	if f.header.flags&flagTracing == flagTracing {
+		peek.readTrace()
+	}
+	if f.header.flags&flagWarning == flagWarning {
+		peek.readStringList()
+	}
+	if f.header.flags&flagCustomPayload == flagCustomPayload {
+		peek.readBytesMap()
+	}
+	if peek.readInt() != resultKindRows {
+		return true
+	}
+
+	flags := peek.readInt()
+	if peek.err != nil {
+		return true
+	}
+	return flags&flagContinuousPaging == 0 || flags&flagLastContinuousPage != 0
+}
+
 func (f *framer) parseResultMetadata() resultMetadata {
 	var meta resultMetadata
 
 	meta.flags = f.readInt()
 	meta.colCount = f.readInt()
 	if meta.colCount < 0 {
-		panic(fmt.Errorf("received negative column count: %d", meta.colCount))
+		f.fail("received negative column count: %d", meta.colCount)
+		return meta
 	}
 	meta.actualColCount = meta.colCount
 
@@ -1060,10 +1294,14 @@ func (f *framer) parseResultMetadata() resultMetadata {
 		meta.pagingState = copyBytes(f.readBytes())
 	}
 
-	if f.proto > protoVersion4 && meta.flags&flagMetaDataChanged == flagMetaDataChanged {
+	if supportsResultMetadataID(f.proto) && meta.flags&flagMetaDataChanged == flagMetaDataChanged {
 		meta.newMetadataID = copyBytes(f.readShortBytes())
 	}
 
+	if isDSEVersion(f.proto) && meta.flags&flagContinuousPaging != 0 {
+		meta.continuousPageNumber = f.readInt()
+	}
+
 	if meta.noMetaData() {
 		return meta
 	}
@@ -1075,6 +1313,15 @@ func (f *framer) parseResultMetadata() resultMetadata {
 		table = f.readString()
 	}
 
+	// each column is at least a name and a type id
+	colSize := 4
+	if !globalSpec {
+		colSize += 4
+	}
+	if !f.checkCount(meta.colCount, colSize, "column") {
+		return meta
+	}
+
 	var cols []ColumnInfo
 	if meta.colCount < 1000 {
 		// preallocate columninfo to avoid excess copying
@@ -1094,6 +1341,7 @@ func (f *framer) parseResultMetadata() resultMetadata {
 	}
 
 	meta.columns = cols
+	meta.plans = make([]columnPlan, len(cols))
 
 	return meta
 }
@@ -1122,7 +1370,8 @@ func (f *framer) parseResultFrame() (frame, error) {
 		return f.parseResultSchemaChange(), nil
 	}
 
-	return nil, NewErrProtocol("unknown result kind: %x", kind)
+	f.fail("unknown result kind: %x", kind)
+	return nil, f.err
 }
 
 type resultRowsFrame struct {
@@ -1143,7 +1392,7 @@ func (f *framer) parseResultRows() frame {
 
 	result.numRows = f.readInt()
 	if result.numRows < 0 {
-		panic(fmt.Errorf("invalid row_count in result frame: %d", result.numRows))
+		f.fail("invalid row_count in result frame: %d", result.numRows)
 	}
 
 	return result
@@ -1180,7 +1429,7 @@ func (f *framer) parseResultPrepared() frame {
 		preparedID:  f.readShortBytes(),
 	}
 
-	if f.proto > protoVersion4 {
+	if supportsResultMetadataID(f.proto) {
 		frame.resultMetadataID = copyBytes(f.readShortBytes())
 	}
 
@@ -1322,7 +1571,8 @@ func (f *framer) parseResultSchemaChange() frame {
 
 			return frame
 		default:
-			panic(fmt.Errorf("gocql: unknown SCHEMA_CHANGE target: %q change: %q", target, change))
+			f.fail("unknown SCHEMA_CHANGE target: %q change: %q", target, change)
+			return nil
 		}
 	}
 
@@ -1424,7 +1674,8 @@ func (f *framer) parseEventFrame() frame {
 		// this should work for all versions
 		return f.parseResultSchemaChange()
 	default:
-		panic(fmt.Errorf("gocql: unknown event type: %q", eventType))
+		f.fail("unknown event type: %q", eventType)
+		return nil
 	}
 
 }
@@ -1469,6 +1720,17 @@ type queryParams struct {
 	// v5+
 	keyspace     string
 	nowInSeconds *int
+	// DSE
+	continuous *continuousPagingParams
+}
+
+// continuousPagingParams are the DSE continuous paging options of a request.
+type continuousPagingParams struct {
+	maxPages       int
+	pagesPerSecond int
+	// nextPages is the number of pages sent before the server waits for the
+	// client to ask for more, DSE_V2 and later.
+	nextPages int
 }
 
 func (q queryParams) String() string {
@@ -1515,20 +1777,27 @@ func (f *framer) writeQueryParams(opts *queryParams) {
 	}
 
 	if opts.keyspace != "" {
-		if f.proto < protoVersion5 {
+		if !supportsKeyspace(f.proto) {
 			panic(fmt.Errorf("the keyspace can only be set with protocol 5 or higher"))
 		}
 		flags |= flagWithKeyspace
 	}
 
 	if opts.nowInSeconds != nil {
-		if f.proto < protoVersion5 {
+		if !supportsNowInSeconds(f.proto) {
 			panic(fmt.Errorf("now_in_seconds can only be set with protocol 5 or higher"))
 		}
 		flags |= flagWithNowInSeconds
 	}
 
-	if f.proto > protoVersion4 {
+	if opts.continuous != nil {
+		if !isDSEVersion(f.proto) {
+			panic(fmt.Errorf("continuous paging can only be used with DSE protocol versions"))
+		}
+		flags |= flagWithContinuousPaging
+	}
+
+	if usesIntQueryFlags(f.proto) {
 		f.writeUint(flags)
 	} else {
 		f.writeByte(byte(flags))
@@ -1579,6 +1848,40 @@ func (f *framer) writeQueryParams(opts *queryParams) {
 	if opts.nowInSeconds != nil {
 		f.writeInt(int32(*opts.nowInSeconds))
 	}
+
+	if opts.continuous != nil {
+		f.writeInt(int32(opts.continuous.maxPages))
+		f.writeInt(int32(opts.continuous.pagesPerSecond))
+		if f.proto >= protoVersionDSE2 {
+			f.writeInt(int32(opts.continuous.nextPages))
+		}
+	}
+}
+
+const (
+	// DSE revision types of REVISE_REQUEST
+	reviseCancelContinuousPaging = 1
+	reviseMoreContinuousPages    = 2
+)
+
+// writeReviseRequestFrame revises the DSE continuous paging request running
+// on targetStream.
+type writeReviseRequestFrame struct {
+	revision     int32
+	targetStream int
+	// nextPages is the number of pages to send for
+	// reviseMoreContinuousPages, DSE_V2 and later.
+	nextPages int
+}
+
+func (w *writeReviseRequestFrame) buildFrame(f *framer, streamID int) error {
+	f.writeHeader(f.flags, opReviseRequest, streamID)
+	f.writeInt(w.revision)
+	f.writeInt(int32(w.targetStream))
+	if w.revision == reviseMoreContinuousPages {
+		f.writeInt(int32(w.nextPages))
+	}
+	return f.finish()
 }
 
 type writeQueryFrame struct {
@@ -1646,7 +1949,7 @@ func (f *framer) writeExecuteFrame(streamID int, preparedID, resultMetadataID []
 	f.writeCustomPayload(customPayload)
 	f.writeShortBytes(preparedID)
 
-	if f.proto > protoVersion4 {
+	if supportsResultMetadataID(f.proto) {
 		f.writeShortBytes(resultMetadataID)
 	}
 
@@ -1753,20 +2056,20 @@ func (f *framer) writeBatchFrame(streamID int, w *writeBatchFrame, customPayload
 	}
 
 	if w.keyspace != "" {
-		if f.proto < protoVersion5 {
+		if !supportsKeyspace(f.proto) {
 			panic(fmt.Errorf("the keyspace can only be set with protocol 5 or higher"))
 		}
 		flags |= flagWithKeyspace
 	}
 
 	if w.nowInSeconds != nil {
-		if f.proto < protoVersion5 {
+		if !supportsNowInSeconds(f.proto) {
 			panic(fmt.Errorf("now_in_seconds can only be set with protocol 5 or higher"))
 		}
 		flags |= flagWithNowInSeconds
 	}
 
-	if f.proto > protoVersion4 {
+	if usesIntFlags(f.proto) {
 		f.writeUint(flags)
 	} else {
 		f.writeByte(byte(flags))
@@ -1825,7 +2128,8 @@ func (f *framer) writeRegisterFrame(streamID int, w *writeRegisterFrame) error {
 
 func (f *framer) readByte() byte {
 	if len(f.buf) < 1 {
-		panic(fmt.Errorf("not enough bytes in buffer to read byte require 1 got: %d", len(f.buf)))
+		f.fail("not enough bytes in buffer to read byte require 1 got: %d", len(f.buf))
+		return 0
 	}
 
 	b := f.buf[0]
@@ -1835,7 +2139,8 @@ func (f *framer) readByte() byte {
 
 func (f *framer) readInt() (n int) {
 	if len(f.buf) < 4 {
-		panic(fmt.Errorf("not enough bytes in buffer to read int require 4 got: %d", len(f.buf)))
+		f.fail("not enough bytes in buffer to read int require 4 got: %d", len(f.buf))
+		return 0
 	}
 
 	n = int(int32(f.buf[0])<<24 | int32(f.buf[1])<<16 | int32(f.buf[2])<<8 | int32(f.buf[3]))
@@ -1845,7 +2150,8 @@ func (f *framer) readInt() (n int) {
 
 func (f *framer) readShort() (n uint16) {
 	if len(f.buf) < 2 {
-		panic(fmt.Errorf("not enough bytes in buffer to read short require 2 got: %d", len(f.buf)))
+		f.fail("not enough bytes in buffer to read short require 2 got: %d", len(f.buf))
+		return 0
 	}
 	n = uint16(f.buf[0])<<8 | uint16(f.buf[1])
 	f.buf = f.buf[2:]
@@ -1853,10 +2159,11 @@ func (f *framer) readShort() (n uint16) {
 }
 
 func (f *framer) readString() (s string) {
-	size := f.readShort()
+	size := int(f.readShort())
 
-	if len(f.buf) < int(size) {
-		panic(fmt.Errorf("not enough bytes in buffer to read string require %d got: %d", size, len(f.buf)))
+	if len(f.buf) < size {
+		f.fail("not enough bytes in buffer to read string require %d got: %d", size, len(f.buf))
+		return ""
 	}
 
 	s = string(f.buf[:size])
@@ -1867,8 +2174,12 @@ func (f *framer) readString() (s string) {
 func (f *framer) readLongString() (s string) {
 	size := f.readInt()
 
-	if len(f.buf) < size {
-		panic(fmt.Errorf("not enough bytes in buffer to read long string require %d got: %d", size, len(f.buf)))
+	if size < 0 {
+		f.fail("invalid long string length: %d", size)
+		return ""
+	} else if len(f.buf) < size {
+		f.fail("not enough bytes in buffer to read long string require %d got: %d", size, len(f.buf))
+		return ""
 	}
 
 	s = string(f.buf[:size])
@@ -1878,7 +2189,8 @@ func (f *framer) readLongString() (s string) {
 
 func (f *framer) readUUID() *UUID {
 	if len(f.buf) < 16 {
-		panic(fmt.Errorf("not enough bytes in buffer to read uuid require %d got: %d", 16, len(f.buf)))
+		f.fail("not enough bytes in buffer to read uuid require %d got: %d", 16, len(f.buf))
+		return &UUID{}
 	}
 
 	// TODO: how to handle this error, if it is a uuid, then sureley, problems?
@@ -1888,10 +2200,13 @@ func (f *framer) readUUID() *UUID {
 }
 
 func (f *framer) readStringList() []string {
-	size := f.readShort()
+	size := int(f.readShort())
+	if !f.checkCount(size, 2, "string list") {
+		return nil
+	}
 
 	l := make([]string, size)
-	for i := 0; i < int(size); i++ {
+	for i := 0; i < size; i++ {
 		l[i] = f.readString()
 	}
 
@@ -1900,12 +2215,15 @@ func (f *framer) readStringList() []string {
 
 func (f *framer) readBytesInternal() ([]byte, error) {
 	size := f.readInt()
-	if size < 0 {
+	if f.err != nil {
+		return nil, f.err
+	} else if size < 0 {
 		return nil, nil
 	}
 
 	if len(f.buf) < size {
-		return nil, fmt.Errorf("not enough bytes in buffer to read bytes require %d got: %d", size, len(f.buf))
+		f.fail("not enough bytes in buffer to read bytes require %d got: %d", size, len(f.buf))
+		return nil, f.err
 	}
 
 	l := f.buf[:size]
@@ -1915,18 +2233,15 @@ func (f *framer) readBytesInternal() ([]byte, error) {
 }
 
 func (f *framer) readBytes() []byte {
-	l, err := f.readBytesInternal()
-	if err != nil {
-		panic(err)
-	}
-
+	l, _ := f.readBytesInternal()
 	return l
 }
 
 func (f *framer) readShortBytes() []byte {
-	size := f.readShort()
-	if len(f.buf) < int(size) {
-		panic(fmt.Errorf("not enough bytes in buffer to read short bytes: require %d got %d", size, len(f.buf)))
+	size := int(f.readShort())
+	if len(f.buf) < size {
+		f.fail("not enough bytes in buffer to read short bytes: require %d got %d", size, len(f.buf))
+		return nil
 	}
 
 	l := f.buf[:size]
@@ -1936,19 +2251,19 @@ func (f *framer) readShortBytes() []byte {
 }
 
 func (f *framer) readInetAdressOnly() net.IP {
-	if len(f.buf) < 1 {
-		panic(fmt.Errorf("not enough bytes in buffer to read inet size require %d got: %d", 1, len(f.buf)))
+	size := int(f.readByte())
+	if f.err != nil {
+		return nil
 	}
 
-	size := f.buf[0]
-	f.buf = f.buf[1:]
-
 	if !(size == 4 || size == 16) {
-		panic(fmt.Errorf("invalid IP size: %d", size))
+		f.fail("invalid IP size: %d", size)
+		return nil
 	}
 
-	if len(f.buf) < 1 {
-		panic(fmt.Errorf("not enough bytes in buffer to read inet require %d got: %d", size, len(f.buf)))
+	if len(f.buf) < size {
+		f.fail("not enough bytes in buffer to read inet require %d got: %d", size, len(f.buf))
+		return nil
 	}
 
 	ip := make([]byte, size)
@@ -1966,10 +2281,13 @@ func (f *framer) readConsistency() Consistency {
 }
 
 func (f *framer) readBytesMap() map[string][]byte {
-	size := f.readShort()
+	size := int(f.readShort())
+	if !f.checkCount(size, 6, "bytes map") {
+		return nil
+	}
 	m := make(map[string][]byte, size)
 
-	for i := 0; i < int(size); i++ {
+	for i := 0; i < size; i++ {
 		k := f.readString()
 		v := f.readBytes()
 		m[k] = v
@@ -1979,10 +2297,13 @@ func (f *framer) readBytesMap() map[string][]byte {
 }
 
 func (f *framer) readStringMultiMap() map[string][]string {
-	size := f.readShort()
+	size := int(f.readShort())
+	if !f.checkCount(size, 4, "string multimap") {
+		return nil
+	}
 	m := make(map[string][]string, size)
 
-	for i := 0; i < int(size); i++ {
+	for i := 0; i < size; i++ {
 		k := f.readString()
 		v := f.readStringList()
 		m[k] = v
@@ -2128,8 +2449,8 @@ func (f *framer) writeBytesMap(m map[string][]byte) {
 
 func (f *framer) prepareModernLayout() error {
 	// Ensure protocol version is V5 or higher
-	if f.proto < protoVersion5 {
-		panic("Modern layout is not supported with version V4 or less")
+	if !modernLayout(f.proto) {
+		panic("Modern layout is not supported with version V4 or less, or DSE versions")
 	}
 
 	selfContained := true
@@ -0,0 +1,168 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"testing"
+)
+
+func fuzzSeedFrames(t testing.TB) [][]byte {
+	void := newFramer(nil, protoVersion4)
+	void.writeHeader(0, opResult, 1)
+	void.buf[0] |= protoDirectionMask
+	void.writeInt(resultKindVoid)
+
+	rows := newFramer(nil, protoVersion4)
+	rows.writeHeader(0, opResult, 2)
+	rows.buf[0] |= protoDirectionMask
+	rows.writeInt(resultKindRows)
+	rows.writeInt(int32(flagGlobalTableSpec))
+	rows.writeInt(2)
+	rows.writeString("ks")
+	rows.writeString("t")
+	rows.writeString("k")
+	rows.writeShort(uint16(TypeMap))
+	rows.writeShort(uint16(TypeVarchar))
+	rows.writeShort(uint16(TypeInt))
+	rows.writeString("v")
+	rows.writeShort(uint16(TypeTuple))
+	rows.writeShort(2)
+	rows.writeShort(uint16(TypeUUID))
+	rows.writeShort(uint16(TypeBlob))
+	rows.writeInt(1)
+	rows.writeBytes([]byte("a"))
+	rows.writeBytes(nil)
+
+	errFrame := newFramer(nil, protoVersion5)
+	errFrame.writeHeader(0, opError, 3)
+	errFrame.buf[0] |= protoDirectionMask
+	errFrame.writeInt(ErrCodeReadFailure)
+	errFrame.writeString("read failure")
+	errFrame.writeConsistency(Quorum)
+	errFrame.writeInt(1)
+	errFrame.writeInt(2)
+	errFrame.writeInt(1)
+	errFrame.writeByte(4)
+	errFrame.buf = append(errFrame.buf, 127, 0, 0, 1)
+	errFrame.writeShort(0)
+	errFrame.writeByte(0)
+
+	event := newFramer(nil, protoVersion4)
+	event.writeHeader(0, opEvent, -1)
+	event.buf[0] |= protoDirectionMask
+	event.writeString("STATUS_CHANGE")
+	event.writeString("UP")
+	event.writeByte(4)
+	event.buf = append(event.buf, 127, 0, 0, 1)
+	event.writeInt(9042)
+
+	var frames [][]byte
+	for _, f := range []*framer{void, rows, errFrame, event} {
+		if err := f.finish(); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, f.buf)
+	}
+
+	return frames
+}
+
+func FuzzParseFrame(f *testing.F) {
+	for _, frame := range fuzzSeedFrames(f) {
+		f.Add(frame)
+	}
+	f.Add([]byte("\x8200\b\x00\x00\x00\b0\x00\x00\x00\x040000"))
+	f.Add([]byte("\x83000\b\x00\x00\x00\x14\x00\x00\x00\x020000000000000000"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		head, err := readHeader(r, make([]byte, maxFrameHeaderSize))
+		if err != nil {
+			return
+		}
+
+		framer := newFramer(nil, head.version.version())
+		if err := framer.readFrame(r, &head); err != nil {
+			return
+		}
+
+		frame, err := framer.parseFrame()
+		if err != nil {
+			if _, ok := err.(ErrProtocol); !ok {
+				t.Fatalf("expected an ErrProtocol, got %T: %v", err, err)
+			}
+			return
+		}
+
+		if rows, ok := frame.(*resultRowsFrame); ok {
+			// the rows are read by Iter
+			for i := 0; i < rows.numRows*rows.meta.actualColCount; i++ {
+				if _, err := framer.readBytesInternal(); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+func FuzzReadTypeInfo(f *testing.F) {
+	f.Add([]byte{0x00, byte(TypeInt)})
+	f.Add([]byte{0x00, byte(TypeMap), 0x00, byte(TypeVarchar), 0x00, byte(TypeList), 0x00, byte(TypeInt)})
+	f.Add([]byte{0x00, byte(TypeUDT), 0x00, 0x02, 'k', 's', 0x00, 0x01, 'u', 0x00, 0x01, 0x00, 0x01, 'a', 0x00, byte(TypeText)})
+	vector := newFramer(nil, protoVersion4)
+	vector.writeShort(uint16(TypeCustom))
+	vector.writeString(VECTOR_TYPE + "(org.apache.cassandra.db.marshal.FloatType, 3)")
+	f.Add(vector.buf)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		framer := newFramer(nil, protoVersion4)
+		framer.buf = data
+		framer.bodyLen = len(data)
+		framer.readTypeInfo()
+	})
+}
+
+func FuzzReadSegment(f *testing.F) {
+	for _, frame := range fuzzSeedFrames(f) {
+		segment, err := newUncompressedSegment(frame, true)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(segment, false)
+
+		segment, err = newCompressedSegment(frame, true, testMockedCompressor{})
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(segment, true)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, compressed bool) {
+		r := bytes.NewReader(data)
+		if compressed {
+			readCompressedSegment(r, testMockedCompressor{})
+		} else {
+			readUncompressedSegment(r)
+		}
+	})
+}
@@ -132,6 +132,27 @@ func TestFrameReadTooLong(t *testing.T) {
 	}
 }
 
+func TestFrameReadTypeInfoFrozen(t *testing.T) {
+	framer := newFramer(nil, protoVersion4)
+	// map<text, frozen<list<int>>>
+	framer.writeShort(uint16(TypeMap))
+	framer.writeShort(uint16(TypeText))
+	framer.writeShort(uint16(TypeList))
+	framer.writeShort(uint16(TypeInt))
+
+	info := framer.readTypeInfo()
+	expected := CollectionType{
+		NativeType: NewNativeType(protoVersion4, TypeMap),
+		Key:        NewNativeType(protoVersion4, TypeText),
+		Elem: CollectionType{
+			NativeType: NewNativeType(protoVersion4, TypeList),
+			Elem:       NewNativeType(protoVersion4, TypeInt),
+			Frozen:     true,
+		},
+	}
+	assert.Equal(t, expected, info)
+}
+
 func Test_framer_writeExecuteFrame(t *testing.T) {
 	framer := newFramer(nil, protoVersion5)
 	nowInSeconds := 123
@@ -204,6 +225,119 @@ func Test_framer_writeBatchFrame(t *testing.T) {
 	assertDeepEqual(t, "nowInSeconds", nowInSeconds, framer.readInt())
 }
 
+func Test_framer_writeExecuteFrameDSEV1(t *testing.T) {
+	// DSE_V1 is based on v4, executions have no result metadata ID
+	framer := newFramer(nil, protoVersionDSE1)
+	params := queryParams{consistency: Quorum, pageSize: 100}
+	if err := framer.writeExecuteFrame(123, []byte{1, 2, 3}, []byte{4, 5, 6}, &params, &map[string][]byte{}); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeFrame(framer.buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, decoded.PreparedID)
+	assert.Nil(t, decoded.ResultMetadataID)
+	assert.Equal(t, Quorum, decoded.Consistency)
+	assert.Equal(t, 100, decoded.PageSize)
+
+	// skipping header
+	framer.buf = framer.buf[9:]
+
+	assert.Equal(t, []byte{1, 2, 3}, framer.readShortBytes())
+	assert.Equal(t, Quorum, Consistency(framer.readShort()))
+	assert.Equal(t, int(flagPageSize), framer.readInt())
+	assert.Equal(t, 100, framer.readInt())
+	assert.Empty(t, framer.buf)
+}
+
+func Test_framer_writePrepareFrameDSEV1(t *testing.T) {
+	framer := newFramer(nil, protoVersionDSE1)
+	req := &writePrepareFrame{statement: "SELECT * FROM ks.t"}
+	if err := req.buildFrame(framer, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeFrame(framer.buf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT * FROM ks.t"}, decoded.Statements)
+
+	// no flags follow the statement
+	framer.buf = framer.buf[9:]
+	assert.Equal(t, "SELECT * FROM ks.t", framer.readLongString())
+	assert.Empty(t, framer.buf)
+}
+
+func Test_framer_writeBatchFrameDSEV1(t *testing.T) {
+	framer := newFramer(nil, protoVersionDSE1)
+	req := &writeBatchFrame{consistency: Quorum, serialConsistency: LocalSerial}
+	if err := framer.writeBatchFrame(1, req, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeFrame(framer.buf)
+	require.NoError(t, err)
+	assert.Equal(t, LocalSerial, decoded.SerialConsistency)
+
+	// batches keep the byte flags of v4
+	framer.buf = framer.buf[9:]
+	assert.Equal(t, LoggedBatch, BatchType(framer.readByte()))
+	assert.Equal(t, 0, int(framer.readShort()))
+	assert.Equal(t, Quorum, Consistency(framer.readShort()))
+	assert.Equal(t, byte(flagWithSerialConsistency), framer.readByte())
+	assert.Equal(t, LocalSerial, Consistency(framer.readShort()))
+	assert.Empty(t, framer.buf)
+}
+
+func TestFrameParseDSEV1Responses(t *testing.T) {
+	parse := func(f *framer) frame {
+		t.Helper()
+		require.NoError(t, f.finish())
+		r := bytes.NewReader(f.buf)
+		head, err := readHeader(r, make([]byte, maxFrameHeaderSize))
+		require.NoError(t, err)
+		framer := newFramer(nil, head.version.version())
+		require.NoError(t, framer.readFrame(r, &head))
+		frame, err := framer.parseFrame()
+		require.NoError(t, err)
+		assert.Empty(t, framer.buf)
+		return frame
+	}
+
+	// prepared results have no result metadata ID
+	f := newFramer(nil, protoVersionDSE1)
+	f.writeHeader(0, opResult, 1)
+	f.buf[0] |= protoDirectionMask
+	f.writeInt(resultKindPrepared)
+	f.writeShortBytes([]byte{1, 2, 3})
+	// <metadata>: flags, column count and partition key count
+	f.writeInt(0)
+	f.writeInt(0)
+	f.writeInt(0)
+	// <result_metadata>
+	f.writeInt(int32(flagNoMetaData))
+	f.writeInt(0)
+	prepared, ok := parse(f).(*resultPreparedFrame)
+	require.True(t, ok)
+	assert.Equal(t, []byte{1, 2, 3}, prepared.preparedID)
+	assert.Nil(t, prepared.resultMetadataID)
+
+	// read failures have the number of failures rather than a reason map
+	f = newFramer(nil, protoVersionDSE1)
+	f.writeHeader(0, opError, 1)
+	f.buf[0] |= protoDirectionMask
+	f.writeInt(ErrCodeReadFailure)
+	f.writeString("read failed")
+	f.writeShort(uint16(Quorum))
+	f.writeInt(1)
+	f.writeInt(2)
+	f.writeInt(1)
+	f.writeByte(0)
+	failure, ok := parse(f).(*RequestErrReadFailure)
+	require.True(t, ok)
+	assert.Equal(t, 1, failure.NumFailures)
+	assert.Nil(t, failure.ErrorMap)
+}
+
 type testMockedCompressor struct {
 	// this is an error its methods should return
 	expectedError error
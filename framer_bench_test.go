@@ -25,6 +25,7 @@
 package gocql
 
 import (
+	"bytes"
 	"compress/gzip"
 	"io/ioutil"
 	"os"
@@ -70,3 +71,35 @@ func BenchmarkParseRowsFrame(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkReadFrame(b *testing.B) {
+	data, err := readGzipData("testdata/frames/bench_parse_result.gz")
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+
+	bench := func(b *testing.B, get func() *framer) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r.Reset(data)
+			head := frameHeader{
+				version: protoVersion4 | 0x80,
+				op:      opResult,
+				length:  len(data),
+			}
+			framer := get()
+			if err := framer.readFrame(r, &head); err != nil {
+				b.Fatal(err)
+			}
+			framer.release()
+		}
+	}
+
+	b.Run("newFramer", func(b *testing.B) {
+		bench(b, func() *framer { return newFramer(nil, protoVersion4) })
+	})
+	b.Run("getFramer", func(b *testing.B) {
+		bench(b, func() *framer { return getFramer(nil, protoVersion4) })
+	})
+}
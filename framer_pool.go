@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"sync"
+)
+
+// bufferClasses are the sizes of the pooled frame buffers. Requests and most
+// responses fit the smallest classes, larger buffers are only kept up to the
+// largest class so that a few big pages don't pin memory.
+var bufferClasses = [...]int{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+var bufferPools [len(bufferClasses)]sync.Pool
+
+// getBuffer returns a buffer of at least n bytes from the pools, or nil if n
+// is over the largest class.
+func getBuffer(n int) *[]byte {
+	for i, size := range bufferClasses {
+		if n > size {
+			continue
+		}
+		if b, ok := bufferPools[i].Get().(*[]byte); ok {
+			return b
+		}
+		b := make([]byte, size)
+		return &b
+	}
+	return nil
+}
+
+// putBuffer returns b to the pool of the largest class it can hold, buffers
+// over the largest class are dropped.
+func putBuffer(b *[]byte) {
+	n := cap(*b)
+	if n > bufferClasses[len(bufferClasses)-1] {
+		return
+	}
+	for i := len(bufferClasses) - 1; i >= 0; i-- {
+		if n >= bufferClasses[i] {
+			*b = (*b)[:n]
+			bufferPools[i].Put(b)
+			return
+		}
+	}
+}
+
+var framerPool = sync.Pool{
+	New: func() interface{} {
+		return new(framer)
+	},
+}
+
+// getFramer is newFramer for framers which are handed back with release once
+// nothing references their buffers anymore.
+func getFramer(compressor Compressor, version byte) *framer {
+	f := framerPool.Get().(*framer)
+	f.bufRef = getBuffer(defaultBufSize)
+	f.pooled = true
+	f.readBuffer = *f.bufRef
+	f.buf = f.readBuffer[:0]
+	f.setup(compressor, version)
+	return f
+}
+
+// buffer returns a buffer of n bytes for the frame body, pooled framers take
+// it from the pools and hand back the buffer they held.
+func (f *framer) buffer(n int) []byte {
+	if f.pooled {
+		if f.bufRef != nil {
+			putBuffer(f.bufRef)
+			f.bufRef = nil
+		}
+		if b := getBuffer(n); b != nil {
+			f.bufRef = b
+			return (*b)[:n]
+		}
+	}
+	return make([]byte, n)
+}
+
+// decompressBuffer returns the buffer to decompress a body of n bytes into,
+// nil to let the compressor allocate it.
+func (f *framer) decompressBuffer(n int) []byte {
+	if !f.pooled || f.decompressRef != nil {
+		return nil
+	}
+	if f.decompressRef = getBuffer(n); f.decompressRef == nil {
+		return nil
+	}
+	return (*f.decompressRef)[:0]
+}
+
+// release hands a framer from getFramer and its buffers back to the pools.
+// Nothing may reference the framer or slices of its body afterwards. It is a
+// no-op for framers from newFramer.
+func (f *framer) release() {
+	if f == nil || !f.pooled {
+		return
+	}
+
+	if f.header == nil && cap(f.buf) > cap(f.readBuffer) {
+		// the request outgrew its buffer while it was written, keep the
+		// bigger one
+		if f.bufRef != nil {
+			putBuffer(f.bufRef)
+		}
+		buf := f.buf
+		f.bufRef = &buf
+	}
+	if f.bufRef != nil {
+		putBuffer(f.bufRef)
+	}
+	if f.decompressRef != nil {
+		putBuffer(f.decompressRef)
+	}
+
+	*f = framer{}
+	framerPool.Put(f)
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetBufferClasses(t *testing.T) {
+	tests := []struct {
+		n    int
+		size int
+	}{
+		{0, 1 << 10},
+		{defaultBufSize, 1 << 10},
+		{1<<10 + 1, 4 << 10},
+		{100 << 10, 256 << 10},
+		{1 << 20, 1 << 20},
+	}
+	for _, test := range tests {
+		b := getBuffer(test.n)
+		if b == nil || len(*b) != test.size || cap(*b) != test.size {
+			t.Errorf("getBuffer(%d): expected a buffer of %d bytes", test.n, test.size)
+			continue
+		}
+		putBuffer(b)
+	}
+
+	if b := getBuffer(1<<20 + 1); b != nil {
+		t.Errorf("expected no buffer over the largest class, got %d bytes", cap(*b))
+	}
+}
+
+func TestPooledFramerReadFrame(t *testing.T) {
+	body := bytes.Repeat([]byte{0xab}, 5000)
+	head := frameHeader{version: protoVersion4 | 0x80, op: opResult, length: len(body)}
+
+	f := getFramer(nil, protoVersion4)
+	if err := f.readFrame(bytes.NewReader(body), &head); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.buf, body) {
+		t.Fatal("unexpected frame body")
+	}
+	if cap(f.readBuffer) != 16<<10 || f.bufRef == nil || &(*f.bufRef)[0] != &f.readBuffer[0] {
+		t.Fatalf("expected the body to be read into a pooled buffer of 16KiB, got %d bytes", cap(f.readBuffer))
+	}
+
+	f.release()
+	if f.pooled || f.bufRef != nil || f.readBuffer != nil || f.header != nil {
+		t.Fatal("expected release to reset the framer")
+	}
+	// releasing a framer which is not pooled does nothing
+	newFramer(nil, protoVersion4).release()
+}
+
+func TestPooledFramerRequestOutgrowsBuffer(t *testing.T) {
+	f := getFramer(nil, protoVersion4)
+	frame := &writeQueryFrame{statement: string(bytes.Repeat([]byte{'a'}, 3000))}
+	if err := frame.buildFrame(f, 1); err != nil {
+		t.Fatal(err)
+	}
+	if cap(f.buf) <= cap(f.readBuffer) {
+		t.Fatalf("expected the request to outgrow the buffer of %d bytes", cap(f.readBuffer))
+	}
+	f.release()
+	if f.bufRef != nil || f.buf != nil {
+		t.Fatal("expected release to reset the framer")
+	}
+}
@@ -0,0 +1,634 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Custom class names of the DataStax Enterprise geospatial types.
+const (
+	POINT_TYPE      = "org.apache.cassandra.db.marshal.PointType"
+	LINESTRING_TYPE = "org.apache.cassandra.db.marshal.LineStringType"
+	POLYGON_TYPE    = "org.apache.cassandra.db.marshal.PolygonType"
+)
+
+// Point is a value of the DSE PointType.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// LineString is a value of the DSE LineStringType, it has no points when
+// it is empty.
+type LineString struct {
+	Points []Point
+}
+
+// Polygon is a value of the DSE PolygonType. The first ring is the exterior
+// of the polygon, the others are its holes. Rings are closed, their last
+// point is their first point. It has no rings when it is empty.
+type Polygon struct {
+	Rings [][]Point
+}
+
+// geometry kinds of the well-known binary and text representations
+const (
+	wkbPoint      uint32 = 1
+	wkbLineString uint32 = 2
+	wkbPolygon    uint32 = 3
+)
+
+// geometryKind returns the geometry kind of the custom class, zero if it
+// is not a geospatial type.
+func geometryKind(class string) uint32 {
+	switch strings.TrimPrefix(class, apacheCassandraTypePrefix) {
+	case "PointType":
+		return wkbPoint
+	case "LineStringType":
+		return wkbLineString
+	case "PolygonType":
+		return wkbPolygon
+	}
+	return 0
+}
+
+func geometryName(kind uint32) string {
+	switch kind {
+	case wkbPoint:
+		return "POINT"
+	case wkbLineString:
+		return "LINESTRING"
+	case wkbPolygon:
+		return "POLYGON"
+	}
+	return "geometry " + strconv.FormatUint(uint64(kind), 10)
+}
+
+// geometryGoType returns the Go type of the geospatial type class, nil if
+// class is not a geospatial type.
+func geometryGoType(class string) reflect.Type {
+	switch geometryKind(class) {
+	case wkbPoint:
+		return reflect.TypeOf(Point{})
+	case wkbLineString:
+		return reflect.TypeOf(LineString{})
+	case wkbPolygon:
+		return reflect.TypeOf(Polygon{})
+	}
+	return nil
+}
+
+// WKB returns the little endian well-known binary representation of p.
+func (p Point) WKB() []byte {
+	buf := appendWKBHeader(make([]byte, 0, 21), wkbPoint)
+	return appendWKBPoint(buf, p)
+}
+
+// WKB returns the little endian well-known binary representation of l.
+func (l LineString) WKB() []byte {
+	buf := appendWKBHeader(make([]byte, 0, 9+16*len(l.Points)), wkbLineString)
+	return appendWKBPoints(buf, l.Points)
+}
+
+// WKB returns the little endian well-known binary representation of p.
+func (p Polygon) WKB() []byte {
+	buf := appendWKBHeader(make([]byte, 0, 64), wkbPolygon)
+	buf = appendUint32LE(buf, uint32(len(p.Rings)))
+	for _, ring := range p.Rings {
+		buf = appendWKBPoints(buf, ring)
+	}
+	return buf
+}
+
+func appendWKBHeader(buf []byte, kind uint32) []byte {
+	buf = append(buf, 1) // little endian
+	return appendUint32LE(buf, kind)
+}
+
+func appendUint32LE(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64LE(buf []byte, v uint64) []byte {
+	return appendUint32LE(appendUint32LE(buf, uint32(v)), uint32(v>>32))
+}
+
+func appendWKBPoint(buf []byte, p Point) []byte {
+	buf = appendUint64LE(buf, math.Float64bits(p.X))
+	return appendUint64LE(buf, math.Float64bits(p.Y))
+}
+
+func appendWKBPoints(buf []byte, points []Point) []byte {
+	buf = appendUint32LE(buf, uint32(len(points)))
+	for _, p := range points {
+		buf = appendWKBPoint(buf, p)
+	}
+	return buf
+}
+
+// ParsePointWKB parses the well-known binary representation of a point.
+func ParsePointWKB(data []byte) (Point, error) {
+	r, err := newWKBReader(data, wkbPoint)
+	if err != nil {
+		return Point{}, err
+	}
+	p := r.point()
+	return p, r.finish()
+}
+
+// ParseLineStringWKB parses the well-known binary representation of a
+// line string.
+func ParseLineStringWKB(data []byte) (LineString, error) {
+	r, err := newWKBReader(data, wkbLineString)
+	if err != nil {
+		return LineString{}, err
+	}
+	l := LineString{Points: r.points()}
+	return l, r.finish()
+}
+
+// ParsePolygonWKB parses the well-known binary representation of a
+// polygon.
+func ParsePolygonWKB(data []byte) (Polygon, error) {
+	r, err := newWKBReader(data, wkbPolygon)
+	if err != nil {
+		return Polygon{}, err
+	}
+	var p Polygon
+	if n := r.count(4); n > 0 {
+		p.Rings = make([][]Point, n)
+		for i := range p.Rings {
+			p.Rings[i] = r.points()
+		}
+	}
+	return p, r.finish()
+}
+
+// wkbReader reads well-known binary geometries, it records the first error
+// and reads zeros after it.
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func newWKBReader(data []byte, kind uint32) (*wkbReader, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("gocql: invalid %s WKB: %d bytes is too short", geometryName(kind), len(data))
+	}
+	r := &wkbReader{data: data[1:]}
+	switch data[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("gocql: invalid %s WKB: unknown byte order %d", geometryName(kind), data[0])
+	}
+	if got := r.uint32(); got != kind {
+		return nil, fmt.Errorf("gocql: invalid %s WKB: got a %s", geometryName(kind), geometryName(got))
+	}
+	return r, nil
+}
+
+func (r *wkbReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = fmt.Errorf("gocql: invalid WKB: unexpected end of data")
+		return 0
+	}
+	v := r.order.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+// count reads the number of items of at least size bytes which follow.
+func (r *wkbReader) count(size int) int {
+	n := r.uint32()
+	if r.err == nil && uint64(n)*uint64(size) > uint64(len(r.data)) {
+		r.err = fmt.Errorf("gocql: invalid WKB: %d items do not fit in %d bytes", n, len(r.data))
+		return 0
+	}
+	return int(n)
+}
+
+func (r *wkbReader) float64() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 8 {
+		r.err = fmt.Errorf("gocql: invalid WKB: unexpected end of data")
+		return 0
+	}
+	v := math.Float64frombits(r.order.Uint64(r.data))
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *wkbReader) point() Point {
+	return Point{X: r.float64(), Y: r.float64()}
+}
+
+func (r *wkbReader) points() []Point {
+	n := r.count(16)
+	if n == 0 {
+		return nil
+	}
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = r.point()
+	}
+	return points
+}
+
+func (r *wkbReader) finish() error {
+	if r.err == nil && len(r.data) > 0 {
+		r.err = fmt.Errorf("gocql: invalid WKB: %d trailing bytes", len(r.data))
+	}
+	return r.err
+}
+
+// String returns the well-known text representation of p, for example
+// "POINT (30 10)".
+func (p Point) String() string {
+	var b strings.Builder
+	b.WriteString("POINT (")
+	writeWKTPoint(&b, p)
+	b.WriteByte(')')
+	return b.String()
+}
+
+// String returns the well-known text representation of l, for example
+// "LINESTRING (30 10, 10 30, 40 40)".
+func (l LineString) String() string {
+	if len(l.Points) == 0 {
+		return "LINESTRING EMPTY"
+	}
+	var b strings.Builder
+	b.WriteString("LINESTRING ")
+	writeWKTPoints(&b, l.Points)
+	return b.String()
+}
+
+// String returns the well-known text representation of p, for example
+// "POLYGON ((35 10, 45 45, 15 40, 10 20, 35 10), (20 30, 35 35, 30 20, 20 30))".
+func (p Polygon) String() string {
+	if len(p.Rings) == 0 {
+		return "POLYGON EMPTY"
+	}
+	var b strings.Builder
+	b.WriteString("POLYGON (")
+	for i, ring := range p.Rings {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeWKTPoints(&b, ring)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func writeWKTPoint(b *strings.Builder, p Point) {
+	b.WriteString(strconv.FormatFloat(p.X, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(p.Y, 'f', -1, 64))
+}
+
+func writeWKTPoints(b *strings.Builder, points []Point) {
+	b.WriteByte('(')
+	for i, p := range points {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeWKTPoint(b, p)
+	}
+	b.WriteByte(')')
+}
+
+// ParsePointWKT parses the well-known text representation of a point, for
+// example "POINT (30 10)".
+func ParsePointWKT(s string) (Point, error) {
+	p := &wktParser{input: s}
+	if err := p.keyword(wkbPoint); err != nil {
+		return Point{}, err
+	}
+	if p.empty() {
+		return Point{}, p.errorf("empty points are not supported")
+	}
+	if err := p.expect('('); err != nil {
+		return Point{}, err
+	}
+	point, err := p.point()
+	if err != nil {
+		return Point{}, err
+	}
+	if err := p.expect(')'); err != nil {
+		return Point{}, err
+	}
+	return point, p.finish()
+}
+
+// ParseLineStringWKT parses the well-known text representation of a line
+// string, for example "LINESTRING (30 10, 10 30, 40 40)".
+func ParseLineStringWKT(s string) (LineString, error) {
+	p := &wktParser{input: s}
+	if err := p.keyword(wkbLineString); err != nil {
+		return LineString{}, err
+	}
+	var l LineString
+	if !p.empty() {
+		points, err := p.points()
+		if err != nil {
+			return LineString{}, err
+		}
+		l.Points = points
+	}
+	return l, p.finish()
+}
+
+// ParsePolygonWKT parses the well-known text representation of a polygon,
+// for example "POLYGON ((30 10, 40 40, 20 40, 10 20, 30 10))".
+func ParsePolygonWKT(s string) (Polygon, error) {
+	p := &wktParser{input: s}
+	if err := p.keyword(wkbPolygon); err != nil {
+		return Polygon{}, err
+	}
+	var poly Polygon
+	if !p.empty() {
+		if err := p.expect('('); err != nil {
+			return Polygon{}, err
+		}
+		for {
+			ring, err := p.points()
+			if err != nil {
+				return Polygon{}, err
+			}
+			poly.Rings = append(poly.Rings, ring)
+			if !p.consume(',') {
+				break
+			}
+		}
+		if err := p.expect(')'); err != nil {
+			return Polygon{}, err
+		}
+	}
+	return poly, p.finish()
+}
+
+// wktParser parses well-known text geometries:
+//
+//	GEOMETRY := KEYWORD ( "EMPTY" | "(" COORDS ")" )
+//	COORDS := NUMBER NUMBER | POINTS { "," POINTS }
+//	POINTS := "(" NUMBER NUMBER { "," NUMBER NUMBER } ")"
+type wktParser struct {
+	input string
+	pos   int
+}
+
+func (p *wktParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("gocql: invalid WKT %q at offset %d: %s", p.input, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *wktParser) skipWhitespace() int {
+	for p.pos < len(p.input) && (isWhitespaceChar(p.input[p.pos]) || p.input[p.pos] == '\r') {
+		p.pos++
+	}
+	return p.pos
+}
+
+// word reads the next word, words are case insensitive.
+func (p *wktParser) word() string {
+	start := p.skipWhitespace()
+	for p.pos < len(p.input) && isCQLIdentifierChar(p.input[p.pos]) {
+		p.pos++
+	}
+	return strings.ToUpper(p.input[start:p.pos])
+}
+
+func (p *wktParser) keyword(kind uint32) error {
+	start := p.skipWhitespace()
+	if word := p.word(); word != geometryName(kind) {
+		p.pos = start
+		return p.errorf("expected %s", geometryName(kind))
+	}
+	return nil
+}
+
+// empty consumes the EMPTY keyword if it is next.
+func (p *wktParser) empty() bool {
+	start := p.pos
+	if p.word() == "EMPTY" {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func (p *wktParser) consume(c byte) bool {
+	if p.skipWhitespace() < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) expect(c byte) error {
+	if !p.consume(c) {
+		if p.pos == len(p.input) {
+			return p.errorf("expected %q, got end of input", c)
+		}
+		return p.errorf("expected %q, got %q", c, p.input[p.pos])
+	}
+	return nil
+}
+
+func (p *wktParser) number() (float64, error) {
+	start := p.skipWhitespace()
+	for p.pos < len(p.input) && strings.IndexByte("0123456789+-.eE", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("expected a number")
+	}
+	return v, nil
+}
+
+func (p *wktParser) point() (Point, error) {
+	x, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	y, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{X: x, Y: y}, nil
+}
+
+func (p *wktParser) points() ([]Point, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var points []Point
+	for {
+		point, err := p.point()
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+		if !p.consume(',') {
+			break
+		}
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+func (p *wktParser) finish() error {
+	if p.skipWhitespace() < len(p.input) {
+		return p.errorf("unexpected %q after the geometry", p.input[p.pos:])
+	}
+	return nil
+}
+
+// marshalGeometry marshals value into the geospatial type of info, which
+// has the geometry kind.
+func marshalGeometry(info TypeInfo, kind uint32, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case unsetColumn:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		var g interface{ WKB() []byte }
+		var err error
+		switch kind {
+		case wkbPoint:
+			g, err = ParsePointWKT(v)
+		case wkbLineString:
+			g, err = ParseLineStringWKT(v)
+		case wkbPolygon:
+			g, err = ParsePolygonWKT(v)
+		}
+		if err != nil {
+			return nil, marshalErrorf("can not marshal %q into %s: %v", v, info, err)
+		}
+		return g.WKB(), nil
+	case Point:
+		if kind == wkbPoint {
+			return v.WKB(), nil
+		}
+	case LineString:
+		if kind == wkbLineString {
+			return v.WKB(), nil
+		}
+	case Polygon:
+		if kind == wkbPolygon {
+			return v.WKB(), nil
+		}
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	return nil, marshalErrorf("can not marshal %T into %s. Accepted types: Marshaler, %s, string, []byte, UnsetValue.", value, info, geometryGoType(info.Custom()))
+}
+
+// unmarshalGeometry unmarshals data of the geospatial type of info, which
+// has the geometry kind.
+func unmarshalGeometry(info TypeInfo, kind uint32, data []byte, value interface{}) error {
+	accepted := func() error {
+		return unmarshalErrorf("can not unmarshal %s into %T. Accepted types: Unmarshaler, *%s, *string, *[]byte.", info, value, geometryGoType(info.Custom()))
+	}
+
+	var err error
+	switch v := value.(type) {
+	case Unmarshaler:
+		return v.UnmarshalCQL(info, data)
+	case *[]byte:
+		if data != nil {
+			*v = append((*v)[:0], data...)
+		} else {
+			*v = nil
+		}
+		return nil
+	case *string:
+		if data == nil {
+			*v = ""
+			return nil
+		}
+		var g fmt.Stringer
+		switch kind {
+		case wkbPoint:
+			g, err = ParsePointWKB(data)
+		case wkbLineString:
+			g, err = ParseLineStringWKB(data)
+		case wkbPolygon:
+			g, err = ParsePolygonWKB(data)
+		}
+		if err == nil {
+			*v = g.String()
+		}
+	case *Point:
+		if kind != wkbPoint {
+			return accepted()
+		}
+		if data == nil {
+			*v = Point{}
+			return nil
+		}
+		*v, err = ParsePointWKB(data)
+	case *LineString:
+		if kind != wkbLineString {
+			return accepted()
+		}
+		if data == nil {
+			*v = LineString{}
+			return nil
+		}
+		*v, err = ParseLineStringWKB(data)
+	case *Polygon:
+		if kind != wkbPolygon {
+			return accepted()
+		}
+		if data == nil {
+			*v = Polygon{}
+			return nil
+		}
+		*v, err = ParsePolygonWKB(data)
+	default:
+		return accepted()
+	}
+	if err != nil {
+		return unmarshalErrorf("can not unmarshal %s into %T: %v", info, value, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+//go:build all || unit
+// +build all unit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGeometryWKT(t *testing.T) {
+	polygon := Polygon{Rings: [][]Point{
+		{{35, 10}, {45, 45}, {15, 40}, {10, 20}, {35, 10}},
+		{{20, 30}, {35, 35}, {30, 20}, {20, 30}},
+	}}
+
+	tests := []struct {
+		wkt   string
+		exp   string
+		parse func(string) (interface{}, error)
+		value interface{}
+	}{
+		{"POINT (30 10)", "", func(s string) (interface{}, error) { return ParsePointWKT(s) }, Point{30, 10}},
+		{" point(-1.5 2e3) ", "POINT (-1.5 2000)", func(s string) (interface{}, error) { return ParsePointWKT(s) }, Point{-1.5, 2000}},
+		{"LINESTRING (30 10, 10 30, 40 40)", "", func(s string) (interface{}, error) { return ParseLineStringWKT(s) }, LineString{Points: []Point{{30, 10}, {10, 30}, {40, 40}}}},
+		{"LineString Empty", "LINESTRING EMPTY", func(s string) (interface{}, error) { return ParseLineStringWKT(s) }, LineString{}},
+		{"POLYGON ((35 10, 45 45, 15 40, 10 20, 35 10), (20 30, 35 35, 30 20, 20 30))", "", func(s string) (interface{}, error) { return ParsePolygonWKT(s) }, polygon},
+		{"POLYGON EMPTY", "", func(s string) (interface{}, error) { return ParsePolygonWKT(s) }, Polygon{}},
+	}
+	for _, test := range tests {
+		got, err := test.parse(test.wkt)
+		if err != nil {
+			t.Errorf("%s: %v", test.wkt, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.value) {
+			t.Errorf("%s: expected %#v got %#v", test.wkt, test.value, got)
+		}
+		exp := test.exp
+		if exp == "" {
+			exp = test.wkt
+		}
+		if s := got.(interface{ String() string }).String(); s != exp {
+			t.Errorf("%s: expected to format as %s got %s", test.wkt, exp, s)
+		}
+	}
+
+	for _, wkt := range []string{"", "POINT", "POINT EMPTY", "POINT (1)", "POINT (1 2", "POINT (1 2) x", "LINESTRING (1 2)"} {
+		if _, err := ParsePointWKT(wkt); err == nil {
+			t.Errorf("%q: expected an error", wkt)
+		}
+	}
+	if _, err := ParseLineStringWKT("LINESTRING ((1 2))"); err == nil {
+		t.Error("expected an error for a line string with rings")
+	}
+	if _, err := ParsePolygonWKT("POLYGON (1 2, 3 4)"); err == nil {
+		t.Error("expected an error for a polygon without rings")
+	}
+}
+
+func TestGeometryWKB(t *testing.T) {
+	polygon := Polygon{Rings: [][]Point{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}
+	got, err := ParsePolygonWKB(polygon.WKB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, polygon) {
+		t.Fatalf("expected %v got %v", polygon, got)
+	}
+
+	// big endian
+	point, err := ParsePointWKB([]byte("\x00\x00\x00\x00\x01\x3f\xf0\x00\x00\x00\x00\x00\x00\x40\x00\x00\x00\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if point != (Point{1, 2}) {
+		t.Fatalf("expected POINT (1 2) got %v", point)
+	}
+
+	for _, data := range [][]byte{
+		nil,
+		[]byte("\x02\x01\x00\x00\x00"),
+		[]byte("\x01\x02\x00\x00\x00\x00\x00\x00\x00"),
+		[]byte("\x01\x01\x00\x00\x00\x00\x00\x00\x00"),
+		append(Point{1, 2}.WKB(), 0),
+	} {
+		if _, err := ParsePointWKB(data); err == nil {
+			t.Errorf("%x: expected an error", data)
+		}
+	}
+	if _, err := ParseLineStringWKB([]byte("\x01\x02\x00\x00\x00\xff\xff\xff\xff")); err == nil {
+		t.Error("expected an error for a line string with too many points")
+	}
+}
+
+func TestGeometryMarshal(t *testing.T) {
+	info := NewCustomType(protoVersion4, TypeCustom, POINT_TYPE)
+	if _, err := Marshal(info, LineString{}); err == nil {
+		t.Error("expected an error marshaling a line string into a point")
+	}
+	if _, err := Marshal(info, "LINESTRING EMPTY"); err == nil {
+		t.Error("expected an error marshaling a line string into a point")
+	}
+
+	var line LineString
+	if err := Unmarshal(info, Point{1, 2}.WKB(), &line); err == nil {
+		t.Error("expected an error unmarshaling a point into a line string")
+	}
+
+	v, err := info.NewWithError()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(info, Point{1, 2}.WKB(), v); err != nil {
+		t.Fatal(err)
+	}
+	if point, ok := v.(*Point); !ok || *point != (Point{1, 2}) {
+		t.Fatalf("expected *Point(1 2) got %#v", v)
+	}
+}
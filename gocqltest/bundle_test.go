@@ -0,0 +1,300 @@
+//go:build all || unit
+// +build all unit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// testCert is a certificate and its key in PEM.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// sniProxy routes TLS connections to the node whose host ID is the server
+// name.
+type sniProxy struct {
+	t        *testing.T
+	cluster  *Cluster
+	listener net.Listener
+
+	mu          sync.Mutex
+	serverNames map[string]int
+}
+
+func newSNIProxy(t *testing.T, cluster *Cluster, tlsConfig *tls.Config) *sniProxy {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &sniProxy{
+		t:           t,
+		cluster:     cluster,
+		listener:    listener,
+		serverNames: make(map[string]int),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go p.serve()
+	return p
+}
+
+func (p *sniProxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.route(conn.(*tls.Conn))
+	}
+}
+
+func (p *sniProxy) route(conn *tls.Conn) {
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		return
+	}
+
+	serverName := conn.ConnectionState().ServerName
+	p.mu.Lock()
+	p.serverNames[serverName]++
+	p.mu.Unlock()
+
+	for _, node := range p.cluster.Nodes() {
+		if node.HostID().String() != serverName {
+			continue
+		}
+		nodeConn, err := net.Dial("tcp", node.Addr())
+		if err != nil {
+			return
+		}
+		defer nodeConn.Close()
+
+		go func() {
+			io.Copy(nodeConn, conn)
+			nodeConn.Close()
+		}()
+		io.Copy(conn, nodeConn)
+		return
+	}
+}
+
+func (p *sniProxy) routed(serverName string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.serverNames[serverName] > 0
+}
+
+func newTestBundle(t *testing.T, config map[string]interface{}, ca, client *testCert) *gocql.SecureConnectBundle {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := map[string][]byte{
+		"ca.crt": ca.certPEM,
+		"cert":   client.certPEM,
+		"key":    client.keyPEM,
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["config.json"] = configJSON
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := gocql.ReadSecureConnectBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestSecureConnectBundle(t *testing.T) {
+	cluster := newTestCluster(t, Config{Nodes: 2})
+	cluster.When("SELECT id FROM ks.users").ReturnRows(
+		[]Column{{Keyspace: "ks", Table: "users", Name: "id", Type: gocql.NewNativeType(4, gocql.TypeInt)}},
+		[]interface{}{1})
+
+	notAfter := time.Now().Add(time.Hour)
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gocqltest CA"},
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+	server := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "proxy"},
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	proxy := newSNIProxy(t, cluster, &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate(t)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+
+	// a stand-in for the metadata service, reached with its own client
+	var contactPoints []string
+	for _, node := range cluster.Nodes() {
+		contactPoints = append(contactPoints, node.HostID().String())
+	}
+	metadata := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"version": 1,
+			"contact_info": map[string]interface{}{
+				"type":              "sni_proxy",
+				"local_dc":          defaultDataCenter,
+				"contact_points":    contactPoints[:1],
+				"sni_proxy_address": proxy.listener.Addr().String(),
+			},
+		})
+	}))
+	defer metadata.Close()
+
+	host, port, err := net.SplitHostPort(metadata.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+	bundle := newTestBundle(t, map[string]interface{}{
+		"host":           host,
+		"port":           portNum,
+		"keyspace":       "ks",
+		"caCertLocation": "./ca.crt",
+		"certLocation":   "./cert",
+		"keyLocation":    "./key",
+	}, ca, client)
+
+	cfg, err := bundle.NewCluster(context.Background(), metadata.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newTestSession(t, cfg)
+
+	var id int
+	if err := session.Query("SELECT id FROM ks.users").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatalf("expected id 1, got %d", id)
+	}
+
+	// all nodes are reached through the proxy by their host ID
+	for deadline := time.Now().Add(5 * time.Second); !proxy.routed(contactPoints[0]) || !proxy.routed(contactPoints[1]); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected connections routed to all nodes, got %v", proxy.serverNames)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	defaultClusterName    = "gocqltest"
+	defaultDataCenter     = "datacenter1"
+	defaultRack           = "rack1"
+	defaultReleaseVersion = "4.0.0"
+
+	minProtocolVersion = 3
+	maxProtocolVersion = 5
+
+	// protoVersionDSE1 is the first DataStax Enterprise protocol version,
+	// DSE nodes support DSE_V1 and DSE_V2 next to v3 and v4.
+	protoVersionDSE1 = 0x41
+
+	// bindAttempts is the number of times NewCluster looks for a port that
+	// is free on all node addresses.
+	bindAttempts = 10
+)
+
+// Config describes the cluster started by NewCluster. The zero value starts
+// a single node accepting protocol versions 3 to 5 without authentication.
+type Config struct {
+	// Nodes is the number of nodes in the cluster.
+	// Default: 1
+	Nodes int
+
+	// MinProtocolVersion and MaxProtocolVersion bound the native protocol
+	// versions accepted by the nodes, requests using other versions are
+	// rejected with a protocol error.
+	// Default: 3 and 5
+	MinProtocolVersion int
+	MaxProtocolVersion int
+
+	// Username and Password enable the PasswordAuthenticator on all nodes
+	// when Username is not empty.
+	Username string
+	Password string
+
+	// ClusterName, DataCenter, Rack and ReleaseVersion are reported in
+	// system.local and system.peers.
+	// Default: "gocqltest", "datacenter1", "rack1" and "4.0.0"
+	ClusterName    string
+	DataCenter     string
+	Rack           string
+	ReleaseVersion string
+
+	// DSEVersion makes the nodes report a DataStax Enterprise version in
+	// system.local and system.peers, authenticate with the DseAuthenticator
+	// and support proxy login and execution when not empty.
+	DSEVersion string
+}
+
+// Cluster is a set of fake nodes.
+type Cluster struct {
+	cfg           Config
+	port          int
+	schemaVersion gocql.UUID
+	nodes         []*Node
+	rules         ruleSet
+}
+
+// NewCluster starts the nodes described by cfg. The cluster must be closed
+// with Close once it's not needed anymore.
+func NewCluster(cfg Config) (*Cluster, error) {
+	if cfg.Nodes <= 0 {
+		cfg.Nodes = 1
+	}
+	if cfg.MinProtocolVersion == 0 {
+		cfg.MinProtocolVersion = minProtocolVersion
+	}
+	if cfg.MaxProtocolVersion == 0 {
+		cfg.MaxProtocolVersion = maxProtocolVersion
+	}
+	if cfg.MinProtocolVersion < minProtocolVersion || cfg.MaxProtocolVersion > maxProtocolVersion ||
+		cfg.MinProtocolVersion > cfg.MaxProtocolVersion {
+		return nil, fmt.Errorf("gocqltest: unsupported protocol versions %d to %d", cfg.MinProtocolVersion, cfg.MaxProtocolVersion)
+	}
+	if cfg.ClusterName == "" {
+		cfg.ClusterName = defaultClusterName
+	}
+	if cfg.DataCenter == "" {
+		cfg.DataCenter = defaultDataCenter
+	}
+	if cfg.Rack == "" {
+		cfg.Rack = defaultRack
+	}
+	if cfg.ReleaseVersion == "" {
+		cfg.ReleaseVersion = defaultReleaseVersion
+	}
+
+	c := &Cluster{
+		cfg:           cfg,
+		schemaVersion: gocql.TimeUUID(),
+	}
+
+	// spread the tokens evenly over the murmur3 token range
+	step := math.MaxUint64 / uint64(cfg.Nodes)
+	for i := 0; i < cfg.Nodes; i++ {
+		token := int64(1<<63 + uint64(i)*step)
+		c.nodes = append(c.nodes, &Node{
+			cluster: c,
+			ip:      net.IPv4(127, 0, 0, byte(i+1)),
+			hostID:  gocql.TimeUUID(),
+			tokens:  []string{strconv.FormatInt(token, 10)},
+		})
+	}
+
+	var err error
+	for i := 0; i < bindAttempts; i++ {
+		if err = c.listen(); err == nil {
+			return c, nil
+		}
+	}
+
+	return nil, err
+}
+
+// listen starts all nodes on a port picked by the first one.
+func (c *Cluster) listen() error {
+	c.port = 0
+	for _, node := range c.nodes {
+		if err := node.listen(); err != nil {
+			c.Close()
+			return err
+		}
+		if c.port == 0 {
+			c.port = node.listener.Addr().(*net.TCPAddr).Port
+		}
+	}
+
+	return nil
+}
+
+// Nodes returns the nodes of the cluster.
+func (c *Cluster) Nodes() []*Node {
+	return c.nodes
+}
+
+// Port returns the port shared by all nodes.
+func (c *Cluster) Port() int {
+	return c.port
+}
+
+// Hosts returns the addresses of all nodes as host:port pairs.
+func (c *Cluster) Hosts() []string {
+	hosts := make([]string, len(c.nodes))
+	for i, node := range c.nodes {
+		hosts[i] = node.Addr()
+	}
+	return hosts
+}
+
+// ClusterConfig returns a gocql.ClusterConfig connecting to the cluster.
+func (c *Cluster) ClusterConfig() *gocql.ClusterConfig {
+	cfg := gocql.NewCluster(c.Hosts()...)
+	cfg.Port = c.port
+	if c.cfg.Username != "" {
+		cfg.Authenticator = gocql.PasswordAuthenticator{
+			Username: c.cfg.Username,
+			Password: c.cfg.Password,
+		}
+	}
+	return cfg
+}
+
+// When adds a rule for statements equal to stmt on all nodes. Statements are
+// compared case insensitively with whitespace runs collapsed.
+//
+// Rules added to a node take precedence over the rules of the cluster, and
+// rules added earlier take precedence over rules added later.
+func (c *Cluster) When(stmt string) *Rule {
+	return c.rules.add(matchStatement(stmt))
+}
+
+// WhenMatch adds a rule for statements matching re on all nodes.
+func (c *Cluster) WhenMatch(re *regexp.Regexp) *Rule {
+	return c.rules.add(re.MatchString)
+}
+
+// PushEvent sends ev to the connections of all running nodes registered for
+// its type.
+func (c *Cluster) PushEvent(ev Event) {
+	for _, node := range c.nodes {
+		node.PushEvent(ev)
+	}
+}
+
+// Close stops all nodes.
+func (c *Cluster) Close() {
+	for _, node := range c.nodes {
+		node.stop()
+	}
+}
+
+// pushEventFrom sends ev through all running nodes except from.
+func (c *Cluster) pushEventFrom(from *Node, ev Event) {
+	for _, node := range c.nodes {
+		if node != from {
+			node.PushEvent(ev)
+		}
+	}
+}
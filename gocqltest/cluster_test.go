@@ -0,0 +1,644 @@
+//go:build all || unit
+// +build all unit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+func newTestCluster(t *testing.T, cfg Config) *Cluster {
+	t.Helper()
+
+	cluster, err := NewCluster(cfg)
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	return cluster
+}
+
+func newTestSession(t *testing.T, cfg *gocql.ClusterConfig) *gocql.Session {
+	t.Helper()
+
+	session, err := cfg.CreateSession()
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	t.Cleanup(session.Close)
+	return session
+}
+
+func TestClusterProtocolVersions(t *testing.T) {
+	for _, proto := range []int{3, 4, 5} {
+		t.Run(fmt.Sprintf("v%d", proto), func(t *testing.T) {
+			cluster := newTestCluster(t, Config{MaxProtocolVersion: proto})
+
+			cols := []Column{
+				{Keyspace: "ks", Table: "users", Name: "id", Type: gocql.NewNativeType(4, gocql.TypeInt)},
+				{Keyspace: "ks", Table: "users", Name: "name", Type: gocql.NewNativeType(4, gocql.TypeVarchar)},
+			}
+			var rows [][]interface{}
+			for i := 0; i < 5; i++ {
+				rows = append(rows, []interface{}{i, fmt.Sprintf("user%d", i)})
+			}
+			cluster.When("SELECT id, name FROM ks.users WHERE id > ?").
+				Params(cols[0]).
+				ReturnRows(cols, rows...)
+
+			// the driver discovers the protocol version
+			session := newTestSession(t, cluster.ClusterConfig())
+
+			iter := session.Query("SELECT id, name FROM ks.users WHERE id > ?", 0).PageSize(2).Iter()
+			var (
+				id   int
+				name string
+				n    int
+			)
+			for iter.Scan(&id, &name) {
+				if id != n || name != fmt.Sprintf("user%d", n) {
+					t.Fatalf("unexpected row %d: %d %q", n, id, name)
+				}
+				n++
+			}
+			if err := iter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if n != len(rows) {
+				t.Fatalf("expected %d rows, got %d", len(rows), n)
+			}
+		})
+	}
+}
+
+func TestClusterRuleError(t *testing.T) {
+	cluster := newTestCluster(t, Config{})
+	const stmt = "UPDATE ks.users SET name = 'bob' WHERE id = 1"
+	cluster.When(stmt).Times(1).ReturnError(Unavailable(gocql.Quorum, 2, 1))
+
+	session := newTestSession(t, cluster.ClusterConfig())
+
+	err := session.Query(stmt).Exec()
+	unavailable, ok := err.(*gocql.RequestErrUnavailable)
+	if !ok {
+		t.Fatalf("expected *gocql.RequestErrUnavailable, got %v", err)
+	}
+	if unavailable.Consistency != gocql.Quorum || unavailable.Required != 2 || unavailable.Alive != 1 {
+		t.Fatalf("unexpected error %+v", unavailable)
+	}
+
+	// the rule was used up
+	if err := session.Query(stmt).Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterRuleDelay(t *testing.T) {
+	cluster := newTestCluster(t, Config{})
+	cluster.When("UPDATE ks.users SET name = 'bob' WHERE id = 1").Delay(100 * time.Millisecond)
+
+	cfg := cluster.ClusterConfig()
+	cfg.Timeout = 20 * time.Millisecond
+	session := newTestSession(t, cfg)
+
+	if err := session.Query("UPDATE ks.users SET name = 'bob' WHERE id = 1").Exec(); err != gocql.ErrTimeoutNoResponse {
+		t.Fatalf("expected %v, got %v", gocql.ErrTimeoutNoResponse, err)
+	}
+}
+
+func TestClusterBatch(t *testing.T) {
+	cluster := newTestCluster(t, Config{})
+	cluster.When("INSERT INTO ks.users (id) VALUES (2)").ReturnError(WriteTimeout(gocql.One, 0, 1, "BATCH"))
+
+	session := newTestSession(t, cluster.ClusterConfig())
+
+	batch := session.NewBatch(gocql.LoggedBatch)
+	batch.Query("INSERT INTO ks.users (id) VALUES (1)")
+	batch.Query("INSERT INTO ks.users (id) VALUES (2)")
+	err := session.ExecuteBatch(batch)
+	if timeout, ok := err.(*gocql.RequestErrWriteTimeout); !ok || timeout.WriteType != "BATCH" {
+		t.Fatalf("expected BATCH write timeout, got %v", err)
+	}
+
+	var batched int
+	for _, req := range cluster.Nodes()[0].Requests() {
+		if req.Op == "BATCH" {
+			batched++
+		}
+	}
+	if batched != 2 {
+		t.Fatalf("expected 2 batched statements, got %d", batched)
+	}
+}
+
+func TestClusterAuthentication(t *testing.T) {
+	cluster := newTestCluster(t, Config{Username: "cassandra", Password: "secret"})
+
+	session := newTestSession(t, cluster.ClusterConfig())
+	if err := session.Query("UPDATE ks.users SET name = 'bob' WHERE id = 1").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := cluster.ClusterConfig()
+	cfg.Authenticator = gocql.PasswordAuthenticator{Username: "cassandra", Password: "wrong"}
+	if session, err := cfg.CreateSession(); err == nil {
+		session.Close()
+		t.Fatal("expected authentication to fail")
+	}
+}
+
+func TestClusterRouting(t *testing.T) {
+	cluster := newTestCluster(t, Config{Nodes: 3})
+	const stmt = "UPDATE ks.users SET name = 'bob' WHERE id = 1"
+
+	cfg := cluster.ClusterConfig()
+	cfg.PoolConfig.HostSelectionPolicy = gocql.RoundRobinHostPolicy()
+	cfg.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: 2}
+	session := newTestSession(t, cfg)
+
+	executed := func() map[string]int {
+		counts := make(map[string]int)
+		for _, node := range cluster.Nodes() {
+			for _, req := range node.Requests() {
+				if req.Statement == stmt {
+					counts[node.Addr()]++
+				}
+			}
+		}
+		return counts
+	}
+
+	for i := 0; i < 6; i++ {
+		if err := session.Query(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counts := executed(); len(counts) != 3 {
+		t.Fatalf("expected the statement to be executed on 3 nodes, got %v", counts)
+	}
+
+	// the driver fails over to the remaining nodes
+	down := cluster.Nodes()[2]
+	down.Stop()
+	before := executed()[down.Addr()]
+	for i := 0; i < 6; i++ {
+		if err := session.Query(stmt).Idempotent(true).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := executed()[down.Addr()]; after != before {
+		t.Fatalf("statement executed on stopped node %s", down.Addr())
+	}
+
+	if err := down.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if !down.IsUp() {
+		t.Fatal("node is not up after Start")
+	}
+}
+
+func TestClusterMixedProtocolVersions(t *testing.T) {
+	// the driver waits 10s before reconnecting to nodes with a minor release
+	// version below 2
+	cluster := newTestCluster(t, Config{Nodes: 2, ReleaseVersion: "3.11.0"})
+	old := cluster.Nodes()[1]
+	old.SetMaxProtocolVersion(4)
+
+	cols := []Column{{Keyspace: "ks", Table: "users", Name: "id", Type: gocql.NewNativeType(4, gocql.TypeInt)}}
+	const stmt = "SELECT id FROM ks.users WHERE id = ?"
+	cluster.When(stmt).Params(cols[0]).ReturnRows(cols, []interface{}{1})
+
+	cfg := cluster.ClusterConfig()
+	cfg.PoolConfig.HostSelectionPolicy = gocql.RoundRobinHostPolicy()
+	session := newTestSession(t, cfg)
+
+	versions := func() map[string]int {
+		versions := make(map[string]int)
+		for _, host := range session.GetHosts() {
+			if host.IsUp() {
+				versions[host.ConnectAddress().String()] = host.ProtocolVersion()
+			}
+		}
+		return versions
+	}
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out, host protocol versions %v", versions())
+			}
+		}
+	}
+	query := func() {
+		t.Helper()
+		for i := 0; i < 4; i++ {
+			var id int
+			if err := session.Query(stmt, 1).Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	waitFor(func() bool {
+		v := versions()
+		return v[cluster.Nodes()[0].IP().String()] == 5 && v[old.IP().String()] == 4
+	})
+	if v := session.ProtocolVersion(); v != 4 {
+		t.Fatalf("expected the session to use protocol v4, got v%d", v)
+	}
+	query()
+
+	// upgrade the node, the session moves up to v5 once the node was seen
+	// going down and coming back
+	old.SetMaxProtocolVersion(5)
+	old.Stop()
+	waitFor(func() bool {
+		return len(versions()) == 1
+	})
+	if err := old.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool {
+		return session.ProtocolVersion() == 5 && len(versions()) == 2
+	})
+	query()
+}
+
+func TestClusterDSEProtocolVersions(t *testing.T) {
+	// DSE nodes reject v5 and list their own, higher, protocol versions
+	cluster := newTestCluster(t, Config{DSEVersion: "6.8.0"})
+	session := newTestSession(t, cluster.ClusterConfig())
+
+	if v := session.ProtocolVersion(); v != 0x42 {
+		t.Fatalf("expected the session to use protocol DSE_V2, got %#x", v)
+	}
+	for _, host := range session.GetHosts() {
+		if v := host.ProtocolVersion(); v != 0x42 {
+			t.Fatalf("expected %s to use protocol DSE_V2, got %#x", host.ConnectAddress(), v)
+		}
+	}
+	if err := session.Query("SELECT release_version FROM system.local").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster.Nodes()[0].SetMaxProtocolVersion(4)
+	cluster.Nodes()[0].Stop()
+	if err := cluster.Nodes()[0].Start(); err != nil {
+		t.Fatal(err)
+	}
+	session = newTestSession(t, cluster.ClusterConfig())
+	if v := session.ProtocolVersion(); v != 0x41 {
+		t.Fatalf("expected the session to use protocol DSE_V1, got %#x", v)
+	}
+}
+
+func TestClusterSupportedOptions(t *testing.T) {
+	cluster := newTestCluster(t, Config{Nodes: 2})
+	old := cluster.Nodes()[1]
+	old.SetMaxProtocolVersion(4)
+
+	session := newTestSession(t, cluster.ClusterConfig())
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		connected := 0
+		for _, host := range session.GetHosts() {
+			if host.IsUp() && host.SupportedOptions() != nil {
+				connected++
+			}
+		}
+		if connected == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for connections to both nodes")
+		}
+	}
+
+	for _, host := range session.GetHosts() {
+		supported := host.SupportedOptions()
+		if supported.Product() != gocql.ProductCassandra {
+			t.Errorf("host %s: expected product %q, got %q", host.ConnectAddress(), gocql.ProductCassandra, supported.Product())
+		}
+		if got := supported.CQLVersions(); !reflect.DeepEqual(got, []string{"3.4.5"}) {
+			t.Errorf("host %s: unexpected CQL versions %v", host.ConnectAddress(), got)
+		}
+	}
+
+	supported := session.SupportedOptions()
+	if got := supported.ProtocolVersions(); !reflect.DeepEqual(got, []int{3, 4}) {
+		t.Fatalf("expected the protocol versions supported by both nodes, got %v", got)
+	}
+	if supported.SupportsCompression("snappy") {
+		t.Fatal("expected no supported compression")
+	}
+
+	cfg := cluster.ClusterConfig()
+	cfg.Compressor = gocql.SnappyCompressor{}
+	_, err := cfg.CreateSession()
+	var compErr *gocql.ErrCompressorNotSupported
+	if !errors.As(err, &compErr) {
+		t.Fatalf("expected ErrCompressorNotSupported, got %v", err)
+	}
+	if compErr.Compressor != "snappy" {
+		t.Fatalf("expected the snappy compressor in the error, got %q", compErr.Compressor)
+	}
+}
+
+func TestClusterCredentialsProvider(t *testing.T) {
+	cluster := newTestCluster(t, Config{Username: "cassandra", Password: "rotated"})
+
+	var fetches int32
+	provider := gocql.NewCachedCredentialsProvider(func(ctx context.Context) (gocql.Credentials, error) {
+		// the first fetch returns the credentials before the rotation
+		password := "rotated"
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			password = "stale"
+		}
+		return gocql.Credentials{Username: "cassandra", Password: password}, nil
+	}, 0)
+
+	cfg := cluster.ClusterConfig()
+	cfg.CredentialsProvider = provider
+	newTestSession(t, cfg)
+
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("expected the credentials to be fetched twice, got %d", n)
+	}
+
+	cfg = cluster.ClusterConfig()
+	cfg.CredentialsProvider = gocql.NewCachedCredentialsProvider(func(ctx context.Context) (gocql.Credentials, error) {
+		return gocql.Credentials{Username: "cassandra", Password: "wrong"}, nil
+	}, 0)
+	if _, err := cfg.CreateSession(); err == nil {
+		t.Fatal("expected the session to fail with wrong credentials")
+	}
+}
+
+// lastRequest returns the last request for stmt received by the single node
+// of cluster.
+func lastRequest(t *testing.T, cluster *Cluster, stmt string) Request {
+	t.Helper()
+	reqs := cluster.Nodes()[0].Requests()
+	for i := len(reqs) - 1; i >= 0; i-- {
+		if reqs[i].Statement == stmt {
+			return reqs[i]
+		}
+	}
+	t.Fatalf("no request for %q", stmt)
+	return Request{}
+}
+
+func TestClusterProxyAuthentication(t *testing.T) {
+	cluster := newTestCluster(t, Config{Username: "service", Password: "secret", DSEVersion: "6.8.0"})
+	const stmt = "INSERT INTO ks.t (id) VALUES (1)"
+
+	t.Run("login", func(t *testing.T) {
+		cfg := cluster.ClusterConfig()
+		cfg.Authenticator = gocql.ProxyAuthenticator{Username: "service", Password: "secret", AuthorizationID: "tenant"}
+		session := newTestSession(t, cfg)
+
+		if err := session.Query(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+		if role := lastRequest(t, cluster, stmt).Role; role != "tenant" {
+			t.Fatalf("expected the query to run as tenant, got %q", role)
+		}
+	})
+
+	t.Run("execute", func(t *testing.T) {
+		cfg := cluster.ClusterConfig()
+		cfg.Authenticator = gocql.ProxyAuthenticator{Username: "service", Password: "secret"}
+		session := newTestSession(t, cfg)
+
+		if err := session.Query(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+		if role := lastRequest(t, cluster, stmt).Role; role != "service" {
+			t.Fatalf("expected the query to run as service, got %q", role)
+		}
+
+		payload := map[string][]byte{"key": []byte("value")}
+		if err := session.Query(stmt).CustomPayload(payload).ExecuteAs("tenant").Exec(); err != nil {
+			t.Fatal(err)
+		}
+		req := lastRequest(t, cluster, stmt)
+		if req.Role != "tenant" || string(req.CustomPayload["key"]) != "value" {
+			t.Fatalf("expected the query to run as tenant with the custom payload, got %q and %v", req.Role, req.CustomPayload)
+		}
+		if len(payload) != 1 {
+			t.Fatalf("the custom payload of the query was modified: %v", payload)
+		}
+
+		batch := session.Batch(gocql.LoggedBatch).Query(stmt).ExecuteAs("other")
+		if err := session.ExecuteBatch(batch); err != nil {
+			t.Fatal(err)
+		}
+		if role := lastRequest(t, cluster, stmt).Role; role != "other" {
+			t.Fatalf("expected the batch to run as other, got %q", role)
+		}
+	})
+}
+
+func TestClusterProxyAuthenticationNotSupported(t *testing.T) {
+	cluster := newTestCluster(t, Config{Username: "service", Password: "secret"})
+
+	cfg := cluster.ClusterConfig()
+	cfg.Authenticator = gocql.ProxyAuthenticator{Username: "service", Password: "secret", AuthorizationID: "tenant"}
+	if _, err := cfg.CreateSession(); err == nil {
+		t.Fatal("expected proxy login to fail without the DseAuthenticator")
+	}
+
+	session := newTestSession(t, cluster.ClusterConfig())
+	err := session.Query("INSERT INTO ks.t (id) VALUES (1)").ExecuteAs("tenant").Exec()
+	if !errors.Is(err, gocql.ErrExecuteAsNotSupported) {
+		t.Fatalf("expected ErrExecuteAsNotSupported, got %v", err)
+	}
+	err = session.ExecuteBatch(session.Batch(gocql.LoggedBatch).Query("INSERT INTO ks.t (id) VALUES (1)").ExecuteAs("tenant"))
+	if !errors.Is(err, gocql.ErrExecuteAsNotSupported) {
+		t.Fatalf("expected ErrExecuteAsNotSupported, got %v", err)
+	}
+}
+
+func TestClusterProxyExecuteProtocolV3(t *testing.T) {
+	// the role is sent in the custom payload, which protocol v3 lacks
+	cluster := newTestCluster(t, Config{Username: "service", Password: "secret", DSEVersion: "6.8.0"})
+
+	cfg := cluster.ClusterConfig()
+	cfg.ProtoVersion = 3
+	cfg.Authenticator = gocql.ProxyAuthenticator{Username: "service", Password: "secret"}
+	session := newTestSession(t, cfg)
+
+	err := session.Query("INSERT INTO ks.t (id) VALUES (1)").ExecuteAs("tenant").Exec()
+	if !errors.Is(err, gocql.ErrExecuteAsNotSupported) {
+		t.Fatalf("expected ErrExecuteAsNotSupported, got %v", err)
+	}
+	err = session.ExecuteBatch(session.Batch(gocql.LoggedBatch).Query("INSERT INTO ks.t (id) VALUES (1)").ExecuteAs("tenant"))
+	if !errors.Is(err, gocql.ErrExecuteAsNotSupported) {
+		t.Fatalf("expected ErrExecuteAsNotSupported, got %v", err)
+	}
+}
+
+// plainHostDialer dials hosts without TLS.
+type plainHostDialer struct{}
+
+func (plainHostDialer) DialHost(ctx context.Context, host *gocql.HostInfo) (*gocql.DialedHost, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host.ConnectAddressAndPort())
+	if err != nil {
+		return nil, err
+	}
+	return &gocql.DialedHost{Conn: conn}, nil
+}
+
+func TestClusterMutualTLSAuthenticatorWithoutTLS(t *testing.T) {
+	cluster := newTestCluster(t, Config{Username: "cassandra", Password: "secret"})
+
+	cfg := cluster.ClusterConfig()
+	cfg.Authenticator = gocql.MutualTLSAuthenticator{}
+	if _, err := cfg.CreateSession(); !errors.Is(err, gocql.ErrMutualTLSRequired) {
+		t.Fatalf("expected ErrMutualTLSRequired without SslOpts, got %v", err)
+	}
+
+	cfg.HostDialer = plainHostDialer{}
+	_, err := cfg.CreateSession()
+	if err == nil || !strings.Contains(err.Error(), gocql.ErrMutualTLSRequired.Error()) {
+		t.Fatalf("expected ErrMutualTLSRequired on a plain connection, got %v", err)
+	}
+}
+
+// userID is a domain ID without Marshaler and Unmarshaler, stored as int.
+type userID struct {
+	n int32
+}
+
+func TestClusterCodecs(t *testing.T) {
+	cluster := newTestCluster(t, Config{})
+	intType := gocql.NewNativeType(4, gocql.TypeInt)
+	cols := []Column{
+		{Keyspace: "ks", Table: "users", Name: "id", Type: intType},
+		{Keyspace: "ks", Table: "users", Name: "friends", Type: gocql.CollectionType{NativeType: gocql.NewNativeType(4, gocql.TypeList), Elem: intType}},
+	}
+	const stmt = "SELECT id, friends FROM ks.users WHERE id = ?"
+	cluster.When(stmt).Params(cols[0]).ReturnRows(cols, []interface{}{7, []int{8, 9}})
+
+	codecs := gocql.NewCodecRegistry()
+	codecs.Register(gocql.TypeInt, reflect.TypeOf(userID{}), gocql.Codec{
+		Marshal: func(info gocql.TypeInfo, value interface{}) ([]byte, error) {
+			return gocql.Marshal(info, value.(userID).n)
+		},
+		Unmarshal: func(info gocql.TypeInfo, data []byte, value interface{}) error {
+			return gocql.Unmarshal(info, data, &value.(*userID).n)
+		},
+	})
+	cfg := cluster.ClusterConfig()
+	cfg.Codecs = codecs
+	session := newTestSession(t, cfg)
+
+	var (
+		id      userID
+		friends []userID
+	)
+	if err := session.Query(stmt, userID{7}).Scan(&id, &friends); err != nil {
+		t.Fatal(err)
+	}
+	if id != (userID{7}) || !reflect.DeepEqual(friends, []userID{{8}, {9}}) {
+		t.Fatalf("unexpected row %v %v", id, friends)
+	}
+	if values := lastRequest(t, cluster, stmt).Values; len(values) != 1 || !reflect.DeepEqual(values[0], []byte{0, 0, 0, 7}) {
+		t.Fatalf("expected the bound id to be marshaled with the codec, got %x", values)
+	}
+}
+
+// retainedBlob keeps the data it is unmarshaled from without copying it.
+type retainedBlob []byte
+
+func (b *retainedBlob) UnmarshalCQL(info gocql.TypeInfo, data []byte) error {
+	*b = data
+	return nil
+}
+
+func TestClusterBorrowBytes(t *testing.T) {
+	cluster := newTestCluster(t, Config{})
+	cols := []Column{
+		{Keyspace: "ks", Table: "blobs", Name: "id", Type: gocql.NewNativeType(4, gocql.TypeInt)},
+		{Keyspace: "ks", Table: "blobs", Name: "data", Type: gocql.NewNativeType(4, gocql.TypeBlob)},
+	}
+	var rows [][]interface{}
+	for i := 0; i < 20; i++ {
+		rows = append(rows, []interface{}{i, []byte(fmt.Sprintf("blob%d", i))})
+	}
+	const stmt = "SELECT id, data FROM ks.blobs"
+	cluster.When(stmt).ReturnRows(cols, rows...)
+
+	session := newTestSession(t, cluster.ClusterConfig())
+
+	// borrowed values are only valid until the next Scan
+	iter := session.Query(stmt).PageSize(3).Iter().BorrowBytes()
+	var (
+		id   int
+		data []byte
+		n    int
+	)
+	for iter.Scan(&id, &data) {
+		if id != n || string(data) != fmt.Sprintf("blob%d", n) {
+			t.Fatalf("unexpected row %d: %d %q", n, id, data)
+		}
+		n++
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(rows) {
+		t.Fatalf("expected %d rows, got %d", len(rows), n)
+	}
+
+	// values which keep the data they were unmarshaled from stay valid
+	// after the iteration and other queries
+	var retained []retainedBlob
+	iter = session.Query(stmt).PageSize(3).Iter()
+	var blob retainedBlob
+	for iter.Scan(&id, &blob) {
+		retained = append(retained, blob)
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := session.Query(stmt).PageSize(3).Iter().Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, blob := range retained {
+		if string(blob) != fmt.Sprintf("blob%d", i) {
+			t.Fatalf("retained value %d was overwritten: %q", i, blob)
+		}
+	}
+}
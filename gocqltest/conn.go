@@ -0,0 +1,747 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/gocql/gocql/internal/wire"
+)
+
+const (
+	opError         = 0x00
+	opStartup       = 0x01
+	opReady         = 0x02
+	opAuthenticate  = 0x03
+	opOptions       = 0x05
+	opSupported     = 0x06
+	opQuery         = 0x07
+	opResult        = 0x08
+	opPrepare       = 0x09
+	opExecute       = 0x0A
+	opRegister      = 0x0B
+	opEvent         = 0x0C
+	opBatch         = 0x0D
+	opAuthChallenge = 0x0E
+	opAuthResponse  = 0x0F
+	opAuthSuccess   = 0x10
+
+	resultKindVoid     = 1
+	resultKindRows     = 2
+	resultKindKeyspace = 3
+	resultKindPrepared = 4
+
+	flagCustomPayload = 0x04
+
+	flagHasMorePages = 0x02
+	flagNoMetaData   = 0x04
+
+	flagValues                = 0x01
+	flagSkipMetaData          = 0x02
+	flagPageSize              = 0x04
+	flagWithPagingState       = 0x08
+	flagWithSerialConsistency = 0x10
+	flagDefaultTimestamp      = 0x20
+	flagWithNameValues        = 0x40
+	flagWithKeyspace          = 0x80
+	flagWithNowInSeconds      = 0x100
+
+	flagWithPreparedKeyspace = 0x01
+
+	passwordAuthenticator = "org.apache.cassandra.auth.PasswordAuthenticator"
+	dseAuthenticator      = "com.datastax.bdp.cassandra.auth.DseAuthenticator"
+)
+
+// serverConn is a client connection to a node.
+type serverConn struct {
+	node *Node
+	conn net.Conn
+	done chan struct{}
+
+	closeOnce sync.Once
+
+	writeMu   sync.Mutex
+	segmented bool
+
+	mu       sync.Mutex
+	version  byte
+	keyspace string
+	events   map[string]bool
+	// role is the role the connection logged in as, saslStarted is set once
+	// the client picked the PLAIN mechanism of the DseAuthenticator.
+	role        string
+	saslStarted bool
+}
+
+func newServerConn(node *Node, conn net.Conn) *serverConn {
+	return &serverConn{
+		node:   node,
+		conn:   conn,
+		done:   make(chan struct{}),
+		events: make(map[string]bool),
+	}
+}
+
+func (c *serverConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *serverConn) serve() {
+	defer c.node.removeConn(c)
+	defer c.close()
+
+	var r io.Reader = c.conn
+	for {
+		f, err := wire.ReadFrame(r)
+		if err != nil {
+			return
+		}
+
+		head := f.Header()
+		version := head.Version & 0x7F
+		if !c.node.supportsProtocolVersion(version) {
+			// like Cassandra respond on stream 0 and close the connection,
+			// the driver parses the supported versions from the message
+			msg := fmt.Sprintf("Invalid or unsupported protocol version (%d); the lowest supported version is %d and the greatest is %d",
+				version, c.node.cluster.cfg.MinProtocolVersion, c.node.maxProtocolVersion())
+			if c.node.cluster.cfg.DSEVersion != "" {
+				msg = fmt.Sprintf("Invalid or unsupported protocol version (%d); supported versions are (%s)",
+					version, strings.Join(c.node.protocolVersions(), ", "))
+			}
+			c.sendError(version, 0, protocolError(msg))
+			return
+		}
+
+		c.mu.Lock()
+		if c.version == 0 {
+			c.version = version
+		}
+		c.mu.Unlock()
+
+		switch head.Op {
+		case opOptions, opStartup, opAuthResponse:
+			// the handshake is handled synchronously because protocol v5
+			// switches to segments once it completes
+			c.handle(f)
+		default:
+			go c.handle(f)
+		}
+
+		c.writeMu.Lock()
+		segmented := c.segmented
+		c.writeMu.Unlock()
+		if segmented {
+			if _, ok := r.(*segmentReader); !ok {
+				r = &segmentReader{r: c.conn}
+			}
+		}
+	}
+}
+
+func (c *serverConn) handle(f wire.Framer) {
+	head := f.Header()
+	version := head.Version & 0x7F
+
+	var payload map[string][]byte
+	if head.Flags&flagCustomPayload != 0 {
+		payload = f.ReadBytesMap()
+	}
+
+	switch head.Op {
+	case opOptions:
+		resp := c.newFrame(version, opSupported, head.Stream)
+		resp.WriteStringMultiMap(map[string][]string{
+			"CQL_VERSION":       {"3.4.5"},
+			"COMPRESSION":       {},
+			"PROTOCOL_VERSIONS": c.node.protocolVersions(),
+		})
+		c.send(resp)
+	case opStartup:
+		c.startup(f)
+	case opAuthResponse:
+		c.authenticate(f)
+	case opRegister:
+		events := f.ReadStringList()
+		if c.malformed(f) {
+			return
+		}
+		c.mu.Lock()
+		for _, event := range events {
+			c.events[event] = true
+		}
+		c.mu.Unlock()
+		c.send(c.newFrame(version, opReady, head.Stream))
+	case opQuery:
+		stmt := f.ReadLongString()
+		params := readQueryParams(f, version)
+		if c.malformed(f) {
+			return
+		}
+		c.execute(head.Stream, "QUERY", stmt, params, payload)
+	case opPrepare:
+		c.prepare(f)
+	case opExecute:
+		id := f.ReadShortBytes()
+		if v5Based(version) {
+			// <result_metadata_id>
+			f.ReadShortBytes()
+		}
+		params := readQueryParams(f, version)
+		if c.malformed(f) {
+			return
+		}
+
+		stmt, ok := c.node.preparedStatement(string(id))
+		if !ok {
+			c.sendError(version, head.Stream, unpreparedError(append([]byte(nil), id...)))
+			return
+		}
+		c.execute(head.Stream, "EXECUTE", stmt, params, payload)
+	case opBatch:
+		c.batch(f, payload)
+	default:
+		c.sendError(version, head.Stream, protocolError("unsupported operation "+opName(head.Op)))
+	}
+}
+
+// malformed responds with a protocol error if the body of f could not be
+// read.
+func (c *serverConn) malformed(f wire.Framer) bool {
+	err := f.Err()
+	if err == nil {
+		return false
+	}
+
+	head := f.Header()
+	c.sendError(head.Version&0x7F, head.Stream, protocolError(fmt.Sprintf("unable to decode %s frame: %v", opName(head.Op), err)))
+	return true
+}
+
+func (c *serverConn) startup(f wire.Framer) {
+	head := f.Header()
+	version := head.Version & 0x7F
+
+	opts := f.ReadStringMap()
+	if c.malformed(f) {
+		return
+	}
+	if compression := opts["COMPRESSION"]; compression != "" {
+		c.sendError(version, head.Stream, protocolError("unsupported compression "+compression))
+		return
+	}
+
+	var resp wire.Framer
+	if c.node.cluster.cfg.Username != "" {
+		resp = c.newFrame(version, opAuthenticate, head.Stream)
+		if c.node.cluster.cfg.DSEVersion != "" {
+			resp.WriteString(dseAuthenticator)
+		} else {
+			resp.WriteString(passwordAuthenticator)
+		}
+	} else {
+		resp = c.newFrame(version, opReady, head.Stream)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	resp.Send(c.conn, false)
+	// Protocol v5 frames are wrapped in segments once the handshake
+	// completes, DSE versions keep the legacy framing.
+	c.segmented = version > 4 && version < protoVersionDSE1
+}
+
+func (c *serverConn) authenticate(f wire.Framer) {
+	head := f.Header()
+	version := head.Version & 0x7F
+
+	// the token is "authzid\x00username\x00password"
+	data := f.ReadBytes()
+	if c.malformed(f) {
+		return
+	}
+	cfg := c.node.cluster.cfg
+
+	c.mu.Lock()
+	saslStarted := c.saslStarted
+	c.mu.Unlock()
+	if cfg.DSEVersion != "" && !saslStarted {
+		// the DseAuthenticator first expects the SASL mechanism
+		if string(data) != "PLAIN" {
+			c.sendError(version, head.Stream, credentialsError("Unsupported SASL mechanism "+string(data)))
+			return
+		}
+		c.mu.Lock()
+		c.saslStarted = true
+		c.mu.Unlock()
+
+		resp := c.newFrame(version, opAuthChallenge, head.Stream)
+		resp.WriteBytes([]byte("PLAIN-START"))
+		c.send(resp)
+		return
+	}
+
+	token := strings.SplitN(string(data), "\x00", 3)
+	if len(token) != 3 || token[1] != cfg.Username || token[2] != cfg.Password {
+		c.sendError(version, head.Stream, credentialsError("Provided username and/or password are incorrect"))
+		return
+	}
+
+	// only the DseAuthenticator supports logging in as another role
+	role := token[1]
+	if cfg.DSEVersion != "" && token[0] != "" {
+		role = token[0]
+	}
+	c.mu.Lock()
+	c.role = role
+	c.mu.Unlock()
+
+	resp := c.newFrame(version, opAuthSuccess, head.Stream)
+	resp.WriteBytes(nil)
+	c.send(resp)
+}
+
+func (c *serverConn) prepare(f wire.Framer) {
+	head := f.Header()
+	version := head.Version & 0x7F
+
+	stmt := f.ReadLongString()
+	if v5Based(version) {
+		if flags := f.ReadInt(); flags&flagWithPreparedKeyspace != 0 {
+			f.ReadString()
+		}
+	}
+	if c.malformed(f) {
+		return
+	}
+
+	sum := md5.Sum([]byte(normalizeStatement(stmt)))
+	id := sum[:]
+	c.node.prepare(string(id), stmt)
+
+	var res result
+	if rule := c.node.findRule(stmt, false); rule != nil {
+		res = rule.result()
+	}
+
+	resp := c.newFrame(version, opResult, head.Stream)
+	resp.WriteInt(resultKindPrepared)
+	resp.WriteShortBytes(id)
+	if v5Based(version) {
+		// <result_metadata_id>
+		resp.WriteShortBytes(id)
+	}
+
+	// <metadata>
+	resp.WriteInt(0)
+	resp.WriteInt(int32(len(res.params)))
+	if version > 3 {
+		// <pk_count>
+		resp.WriteInt(0)
+	}
+	c.writeColumns(resp, res.params)
+
+	// <result_metadata>
+	if len(res.columns) == 0 {
+		resp.WriteInt(flagNoMetaData)
+		resp.WriteInt(0)
+	} else {
+		resp.WriteInt(0)
+		resp.WriteInt(int32(len(res.columns)))
+		c.writeColumns(resp, res.columns)
+	}
+
+	c.send(resp)
+}
+
+func (c *serverConn) batch(f wire.Framer, payload map[string][]byte) {
+	head := f.Header()
+	version := head.Version & 0x7F
+
+	// <type>
+	f.ReadUint8()
+	n := int(f.ReadShort())
+	stmts := make([]string, n)
+	values := make([][][]byte, n)
+	for i := 0; i < n; i++ {
+		if kind := f.ReadUint8(); kind == 0 {
+			stmts[i] = f.ReadLongString()
+		} else {
+			id := f.ReadShortBytes()
+			if c.malformed(f) {
+				return
+			}
+			stmt, ok := c.node.preparedStatement(string(id))
+			if !ok {
+				c.sendError(version, head.Stream, unpreparedError(append([]byte(nil), id...)))
+				return
+			}
+			stmts[i] = stmt
+		}
+
+		values[i] = make([][]byte, f.ReadShort())
+		for j := range values[i] {
+			values[i][j] = copyBytes(f.ReadBytes())
+		}
+	}
+	cons := gocql.Consistency(f.ReadShort())
+	if c.malformed(f) {
+		return
+	}
+
+	var (
+		delay time.Duration
+		err   *Error
+	)
+	for i, stmt := range stmts {
+		c.node.record(Request{Op: "BATCH", Statement: stmt, Values: values[i], Consistency: cons,
+			CustomPayload: payload, Role: c.executeAs(payload)})
+
+		rule := c.node.findRule(stmt, true)
+		if rule == nil {
+			continue
+		}
+
+		res := rule.result()
+		if res.delay > delay {
+			delay = res.delay
+		}
+		if err == nil {
+			err = res.err
+		}
+	}
+
+	if !c.wait(delay) {
+		return
+	}
+	if err != nil {
+		c.sendError(version, head.Stream, err)
+		return
+	}
+
+	resp := c.newFrame(version, opResult, head.Stream)
+	resp.WriteInt(resultKindVoid)
+	c.send(resp)
+}
+
+func (c *serverConn) execute(stream int, op, stmt string, params queryParams, payload map[string][]byte) {
+	c.node.record(Request{Op: op, Statement: stmt, Values: params.values, Consistency: params.consistency,
+		CustomPayload: payload, Role: c.executeAs(payload)})
+
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+
+	rule := c.node.findRule(stmt, true)
+	if rule == nil {
+		c.send(c.builtinResult(version, stream, stmt, params))
+		return
+	}
+
+	res := rule.result()
+	if !c.wait(res.delay) {
+		return
+	}
+	if res.err != nil {
+		c.sendError(version, stream, res.err)
+		return
+	}
+	if res.columns == nil {
+		resp := c.newFrame(version, opResult, stream)
+		resp.WriteInt(resultKindVoid)
+		c.send(resp)
+		return
+	}
+
+	c.send(c.rowsResult(version, stream, res.columns, res.rows, params))
+}
+
+// builtinResult responds to statements without rules.
+func (c *serverConn) builtinResult(version byte, stream int, stmt string, params queryParams) wire.Framer {
+	normalized := normalizeStatement(stmt)
+	lower := strings.ToLower(normalized)
+
+	if strings.HasPrefix(lower, "use ") {
+		keyspace := strings.Trim(strings.TrimSpace(normalized[4:]), `"`)
+
+		c.mu.Lock()
+		c.keyspace = keyspace
+		c.mu.Unlock()
+
+		resp := c.newFrame(version, opResult, stream)
+		resp.WriteInt(resultKindKeyspace)
+		resp.WriteString(keyspace)
+		return resp
+	}
+
+	if table, ok := c.node.systemTable(lower); ok {
+		columns, rows := table.project(lower)
+		return c.rowsResult(version, stream, columns, rows, params)
+	}
+
+	resp := c.newFrame(version, opResult, stream)
+	resp.WriteInt(resultKindVoid)
+	return resp
+}
+
+func (c *serverConn) rowsResult(version byte, stream int, columns []Column, rows [][][]byte, params queryParams) wire.Framer {
+	// the paging state is the offset of the first row of the page
+	offset := 0
+	if len(params.pagingState) == 4 {
+		offset = int(params.pagingState[0])<<24 | int(params.pagingState[1])<<16 |
+			int(params.pagingState[2])<<8 | int(params.pagingState[3])
+	}
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	rows = rows[offset:]
+
+	var pagingState []byte
+	if params.pageSize > 0 && len(rows) > params.pageSize {
+		rows = rows[:params.pageSize]
+		next := offset + params.pageSize
+		pagingState = []byte{byte(next >> 24), byte(next >> 16), byte(next >> 8), byte(next)}
+	}
+
+	flags := 0
+	if pagingState != nil {
+		flags |= flagHasMorePages
+	}
+	if params.flags&flagSkipMetaData != 0 {
+		flags |= flagNoMetaData
+	}
+
+	resp := c.newFrame(version, opResult, stream)
+	resp.WriteInt(resultKindRows)
+	resp.WriteInt(int32(flags))
+	resp.WriteInt(int32(len(columns)))
+	if pagingState != nil {
+		resp.WriteBytes(pagingState)
+	}
+	if flags&flagNoMetaData == 0 {
+		c.writeColumns(resp, columns)
+	}
+
+	resp.WriteInt(int32(len(rows)))
+	for _, row := range rows {
+		for _, v := range row {
+			resp.WriteBytes(v)
+		}
+	}
+	return resp
+}
+
+func (c *serverConn) writeColumns(f wire.Framer, columns []Column) {
+	c.mu.Lock()
+	keyspace := c.keyspace
+	c.mu.Unlock()
+
+	for _, col := range columns {
+		if col.Keyspace != "" {
+			f.WriteString(col.Keyspace)
+		} else {
+			f.WriteString(keyspace)
+		}
+		f.WriteString(col.Table)
+		f.WriteString(col.Name)
+		writeType(f, col.Type)
+	}
+}
+
+// wait sleeps for d and returns false if the connection was closed meanwhile.
+func (c *serverConn) wait(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *serverConn) pushEvent(ev Event) {
+	c.mu.Lock()
+	registered := c.events[ev.eventType()]
+	version := c.version
+	c.mu.Unlock()
+
+	if !registered {
+		return
+	}
+
+	f := c.newFrame(version, opEvent, -1)
+	f.WriteString(ev.eventType())
+	ev.write(f)
+	c.send(f)
+}
+
+func (c *serverConn) newFrame(version, op byte, stream int) wire.Framer {
+	f := wire.NewFramer(version | 0x80)
+	f.WriteHeader(0, op, stream)
+	return f
+}
+
+func (c *serverConn) sendError(version byte, stream int, err *Error) {
+	f := c.newFrame(version, opError, stream)
+	err.write(f)
+	c.send(f)
+}
+
+func (c *serverConn) send(f wire.Framer) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := f.Send(c.conn, c.segmented); err != nil {
+		c.close()
+	}
+}
+
+// segmentReader reads the payload of protocol v5 segments.
+type segmentReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (s *segmentReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		payload, _, err := wire.ReadSegment(s.r)
+		if err != nil {
+			return 0, err
+		}
+		s.buf = payload
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+type queryParams struct {
+	consistency gocql.Consistency
+	flags       uint32
+	values      [][]byte
+	pageSize    int
+	pagingState []byte
+}
+
+// v5Based returns true if requests of protocol version have prepare flags,
+// the keyspace and the result metadata ID of prepared statements, which
+// DSE_V1 lacks as it is based on v4.
+func v5Based(version byte) bool {
+	return version > 4 && version != protoVersionDSE1
+}
+
+func readQueryParams(f wire.Framer, version byte) queryParams {
+	var p queryParams
+	p.consistency = gocql.Consistency(f.ReadShort())
+	// DSE_V1 has int flags for continuous paging
+	if version > 4 {
+		p.flags = uint32(f.ReadInt())
+	} else {
+		p.flags = uint32(f.ReadUint8())
+	}
+
+	if p.flags&flagValues != 0 {
+		p.values = make([][]byte, f.ReadShort())
+		for i := range p.values {
+			if p.flags&flagWithNameValues != 0 {
+				f.ReadString()
+			}
+			p.values[i] = copyBytes(f.ReadBytes())
+		}
+	}
+	if p.flags&flagPageSize != 0 {
+		p.pageSize = f.ReadInt()
+	}
+	if p.flags&flagWithPagingState != 0 {
+		p.pagingState = copyBytes(f.ReadBytes())
+	}
+	if p.flags&flagWithSerialConsistency != 0 {
+		f.ReadShort()
+	}
+	if p.flags&flagDefaultTimestamp != 0 {
+		f.ReadLong()
+	}
+	if v5Based(version) && p.flags&flagWithKeyspace != 0 {
+		f.ReadString()
+	}
+	if version > 4 && version < protoVersionDSE1 && p.flags&flagWithNowInSeconds != 0 {
+		f.ReadInt()
+	}
+
+	return p
+}
+
+func copyBytes(p []byte) []byte {
+	if p == nil {
+		return nil
+	}
+	return append([]byte{}, p...)
+}
+
+func opName(op byte) string {
+	switch op {
+	case opStartup:
+		return "STARTUP"
+	case opOptions:
+		return "OPTIONS"
+	case opQuery:
+		return "QUERY"
+	case opPrepare:
+		return "PREPARE"
+	case opExecute:
+		return "EXECUTE"
+	case opRegister:
+		return "REGISTER"
+	case opBatch:
+		return "BATCH"
+	case opAuthResponse:
+		return "AUTH_RESPONSE"
+	default:
+		return fmt.Sprintf("UNKNOWN_OP_%d", op)
+	}
+}
+
+// executeAs returns the role a request with the custom payload is executed
+// as, the ProxyExecute role of DSE nodes or the role of the connection.
+func (c *serverConn) executeAs(payload map[string][]byte) string {
+	if role, ok := payload["ProxyExecute"]; ok && c.node.cluster.cfg.DSEVersion != "" {
+		return string(role)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.role
+}
@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gocqltest runs in-process fake Cassandra clusters for unit tests.
+//
+// The nodes of a Cluster speak native protocol versions 3 to 5 using the
+// frame codec of package gocql, serve system.local and system.peers so that
+// the driver discovers the whole cluster, and answer other statements from
+// scripted rules:
+//
+//	cluster, err := gocqltest.NewCluster(gocqltest.Config{Nodes: 3})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer cluster.Close()
+//
+//	cluster.When("SELECT name FROM ks.users WHERE id = ?").
+//		Params(gocqltest.Column{Name: "id", Type: gocql.NewNativeType(4, gocql.TypeInt)}).
+//		ReturnRows([]gocqltest.Column{{Name: "name", Type: gocql.NewNativeType(4, gocql.TypeVarchar)}},
+//			[]interface{}{"alice"})
+//	cluster.Nodes()[1].When("INSERT INTO ks.users (id, name) VALUES (1, 'bob')").
+//		ReturnError(gocqltest.WriteTimeout(gocql.Quorum, 1, 2, "SIMPLE"))
+//
+//	session, err := cluster.ClusterConfig().CreateSession()
+//
+// Statements that match no rule succeed with a void result.
+//
+// The nodes of a cluster listen on consecutive loopback addresses starting at
+// 127.0.0.1 and share a single port, multi-node clusters therefore need an
+// operating system routing the whole 127.0.0.0/8 block to the loopback
+// interface, like Linux does.
+package gocqltest
@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+	"github.com/gocql/gocql/internal/wire"
+)
+
+// Error is an error response scripted with Rule.ReturnError.
+type Error struct {
+	Code    int
+	Message string
+
+	// body writes the code specific fields following the message.
+	body func(f wire.Framer)
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gocqltest: error 0x%04x: %s", e.Code, e.Message)
+}
+
+func (e *Error) write(f wire.Framer) {
+	f.WriteInt(int32(e.Code))
+	f.WriteString(e.Message)
+	if e.body != nil {
+		e.body(f)
+	}
+}
+
+// ServerError returns an unexpected server error.
+func ServerError(msg string) *Error {
+	return &Error{Code: gocql.ErrCodeServer, Message: msg}
+}
+
+// Overloaded returns an overloaded coordinator error.
+func Overloaded() *Error {
+	return &Error{Code: gocql.ErrCodeOverloaded, Message: "Server is in overloaded state. Cannot process more requests."}
+}
+
+// IsBootstrapping returns a bootstrapping coordinator error.
+func IsBootstrapping() *Error {
+	return &Error{Code: gocql.ErrCodeBootstrapping, Message: "Cannot process the request, the node is bootstrapping"}
+}
+
+// Unavailable returns an unavailable error for a request needing required
+// replicas while only alive are.
+func Unavailable(cons gocql.Consistency, required, alive int) *Error {
+	return &Error{
+		Code:    gocql.ErrCodeUnavailable,
+		Message: "Cannot achieve consistency level " + cons.String(),
+		body: func(f wire.Framer) {
+			f.WriteShort(uint16(cons))
+			f.WriteInt(int32(required))
+			f.WriteInt(int32(alive))
+		},
+	}
+}
+
+// ReadTimeout returns a read timeout error.
+func ReadTimeout(cons gocql.Consistency, received, blockFor int, dataPresent bool) *Error {
+	return &Error{
+		Code:    gocql.ErrCodeReadTimeout,
+		Message: fmt.Sprintf("Operation timed out - received only %d responses.", received),
+		body: func(f wire.Framer) {
+			f.WriteShort(uint16(cons))
+			f.WriteInt(int32(received))
+			f.WriteInt(int32(blockFor))
+			if dataPresent {
+				f.WriteUint8(1)
+			} else {
+				f.WriteUint8(0)
+			}
+		},
+	}
+}
+
+// WriteTimeout returns a write timeout error, writeType is one of the write
+// types of the native protocol such as SIMPLE, BATCH or CAS.
+func WriteTimeout(cons gocql.Consistency, received, blockFor int, writeType string) *Error {
+	return &Error{
+		Code:    gocql.ErrCodeWriteTimeout,
+		Message: fmt.Sprintf("Operation timed out - received only %d responses.", received),
+		body: func(f wire.Framer) {
+			f.WriteShort(uint16(cons))
+			f.WriteInt(int32(received))
+			f.WriteInt(int32(blockFor))
+			f.WriteString(writeType)
+		},
+	}
+}
+
+// SyntaxError returns a syntax error.
+func SyntaxError(msg string) *Error {
+	return &Error{Code: gocql.ErrCodeSyntax, Message: msg}
+}
+
+// Unauthorized returns an unauthorized error.
+func Unauthorized(msg string) *Error {
+	return &Error{Code: gocql.ErrCodeUnauthorized, Message: msg}
+}
+
+// Invalid returns an invalid query error.
+func Invalid(msg string) *Error {
+	return &Error{Code: gocql.ErrCodeInvalid, Message: msg}
+}
+
+// AlreadyExists returns an error for an attempt to create an existing
+// keyspace or table, table is empty for keyspaces.
+func AlreadyExists(keyspace, table string) *Error {
+	msg := "Cannot add existing keyspace " + keyspace
+	if table != "" {
+		msg = "Cannot add already existing table " + table + " to keyspace " + keyspace
+	}
+
+	return &Error{
+		Code:    gocql.ErrCodeAlreadyExists,
+		Message: msg,
+		body: func(f wire.Framer) {
+			f.WriteString(keyspace)
+			f.WriteString(table)
+		},
+	}
+}
+
+func protocolError(msg string) *Error {
+	return &Error{Code: gocql.ErrCodeProtocol, Message: msg}
+}
+
+func credentialsError(msg string) *Error {
+	return &Error{Code: gocql.ErrCodeCredentials, Message: msg}
+}
+
+func unpreparedError(id []byte) *Error {
+	return &Error{
+		Code:    gocql.ErrCodeUnprepared,
+		Message: fmt.Sprintf("Prepared query with ID %x not found", id),
+		body: func(f wire.Framer) {
+			f.WriteShortBytes(id)
+		},
+	}
+}
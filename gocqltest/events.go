@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"net"
+
+	"github.com/gocql/gocql/internal/wire"
+)
+
+// Event is a server event pushed to the connections registered for its type.
+type Event interface {
+	// eventType returns the type connections register for.
+	eventType() string
+	write(f wire.Framer)
+}
+
+// TopologyChange is a TOPOLOGY_CHANGE event, Change is either NEW_NODE or
+// REMOVED_NODE.
+type TopologyChange struct {
+	Change string
+	Addr   net.IP
+	Port   int
+}
+
+func (e TopologyChange) eventType() string {
+	return "TOPOLOGY_CHANGE"
+}
+
+func (e TopologyChange) write(f wire.Framer) {
+	f.WriteString(e.Change)
+	f.WriteInet(e.Addr, e.Port)
+}
+
+// StatusChange is a STATUS_CHANGE event, Change is either UP or DOWN.
+type StatusChange struct {
+	Change string
+	Addr   net.IP
+	Port   int
+}
+
+func (e StatusChange) eventType() string {
+	return "STATUS_CHANGE"
+}
+
+func (e StatusChange) write(f wire.Framer) {
+	f.WriteString(e.Change)
+	f.WriteInet(e.Addr, e.Port)
+}
+
+// SchemaChange is a SCHEMA_CHANGE event, Change is one of CREATED, UPDATED
+// and DROPPED and Target one of KEYSPACE, TABLE and TYPE. Name is ignored for
+// keyspaces.
+type SchemaChange struct {
+	Change   string
+	Target   string
+	Keyspace string
+	Name     string
+}
+
+func (e SchemaChange) eventType() string {
+	return "SCHEMA_CHANGE"
+}
+
+func (e SchemaChange) write(f wire.Framer) {
+	f.WriteString(e.Change)
+	f.WriteString(e.Target)
+	f.WriteString(e.Keyspace)
+	if e.Target != "KEYSPACE" {
+		f.WriteString(e.Name)
+	}
+}
@@ -0,0 +1,288 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// Request is a statement executed by a node.
+type Request struct {
+	// Op is the frame that carried the statement, one of QUERY, EXECUTE and
+	// BATCH.
+	Op          string
+	Statement   string
+	Values      [][]byte
+	Consistency gocql.Consistency
+	// CustomPayload is the custom payload of the request, if any.
+	CustomPayload map[string][]byte
+	// Role is the role the request is executed as, empty without
+	// authentication.
+	Role string
+}
+
+// Node is a single node of a Cluster.
+type Node struct {
+	cluster *Cluster
+	ip      net.IP
+	hostID  gocql.UUID
+	tokens  []string
+	rules   ruleSet
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*serverConn]struct{}
+	prepared map[string]string
+	requests []Request
+	// maxProtocol overrides Config.MaxProtocolVersion when not zero.
+	maxProtocol int
+}
+
+// Addr returns the address of the node as a host:port pair.
+func (n *Node) Addr() string {
+	return net.JoinHostPort(n.ip.String(), strconv.Itoa(n.cluster.port))
+}
+
+// IP returns the address the node listens on.
+func (n *Node) IP() net.IP {
+	return n.ip
+}
+
+// HostID returns the host ID of the node.
+func (n *Node) HostID() gocql.UUID {
+	return n.hostID
+}
+
+// When adds a rule for statements equal to stmt executed by this node.
+func (n *Node) When(stmt string) *Rule {
+	return n.rules.add(matchStatement(stmt))
+}
+
+// WhenMatch adds a rule for statements matching re executed by this node.
+func (n *Node) WhenMatch(re *regexp.Regexp) *Rule {
+	return n.rules.add(re.MatchString)
+}
+
+// Requests returns the statements executed by the node so far, including the
+// queries of the driver control connection.
+func (n *Node) Requests() []Request {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Request(nil), n.requests...)
+}
+
+// SetMaxProtocolVersion changes the highest protocol version accepted by the
+// node, which allows simulating a cluster running different versions. Open
+// connections are not affected, restart the node to simulate an upgrade.
+func (n *Node) SetMaxProtocolVersion(version int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.maxProtocol = version
+}
+
+func (n *Node) maxProtocolVersion() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.maxProtocol != 0 {
+		return n.maxProtocol
+	}
+	return n.cluster.cfg.MaxProtocolVersion
+}
+
+// protocolVersions returns the protocol versions supported by the node in the
+// "<version>/<name>" format Cassandra uses to list them. Like DataStax
+// Enterprise, DSE nodes only support v5 as a beta version and add their own
+// DSE_V1 and DSE_V2 versions on top of v4 and v5.
+func (n *Node) protocolVersions() []string {
+	cfg := n.cluster.cfg
+	max := n.maxProtocolVersion()
+
+	var versions []string
+	for v := cfg.MinProtocolVersion; v <= max; v++ {
+		if v > 4 && cfg.DSEVersion != "" {
+			versions = append(versions, fmt.Sprintf("%d/v%d-beta", v, v))
+			continue
+		}
+		versions = append(versions, fmt.Sprintf("%d/v%d", v, v))
+	}
+	if cfg.DSEVersion != "" {
+		for v := 4; v <= max; v++ {
+			versions = append(versions, fmt.Sprintf("%d/dse_v%d", protoVersionDSE1+v-4, v-3))
+		}
+	}
+	return versions
+}
+
+// supportsProtocolVersion returns true if the node accepts requests using
+// version.
+func (n *Node) supportsProtocolVersion(version byte) bool {
+	cfg := n.cluster.cfg
+	if cfg.DSEVersion != "" && version >= protoVersionDSE1 {
+		return int(version-protoVersionDSE1)+4 <= n.maxProtocolVersion()
+	}
+	if cfg.DSEVersion != "" && version > 4 {
+		return false
+	}
+	return int(version) >= cfg.MinProtocolVersion && int(version) <= n.maxProtocolVersion()
+}
+
+// IsUp returns true if the node accepts connections.
+func (n *Node) IsUp() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.listener != nil
+}
+
+// Stop closes the listener and all connections of the node and sends a DOWN
+// status change event through the other nodes.
+func (n *Node) Stop() {
+	if n.stop() {
+		n.cluster.pushEventFrom(n, StatusChange{Change: "DOWN", Addr: n.ip, Port: n.cluster.port})
+	}
+}
+
+// Start restarts a stopped node on its address and sends an UP status change
+// event through the other nodes. Statements prepared before the node was
+// stopped are forgotten.
+func (n *Node) Start() error {
+	if n.IsUp() {
+		return nil
+	}
+	if err := n.listen(); err != nil {
+		return err
+	}
+
+	n.cluster.pushEventFrom(n, StatusChange{Change: "UP", Addr: n.ip, Port: n.cluster.port})
+	return nil
+}
+
+// PushEvent sends ev to all connections of the node registered for its type.
+func (n *Node) PushEvent(ev Event) {
+	n.mu.Lock()
+	conns := make([]*serverConn, 0, len(n.conns))
+	for conn := range n.conns {
+		conns = append(conns, conn)
+	}
+	n.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.pushEvent(ev)
+	}
+}
+
+func (n *Node) listen() error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(n.ip.String(), strconv.Itoa(n.cluster.port)))
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.listener = listener
+	n.conns = make(map[*serverConn]struct{})
+	n.prepared = make(map[string]string)
+	n.mu.Unlock()
+
+	go n.serve(listener)
+	return nil
+}
+
+func (n *Node) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := newServerConn(n, conn)
+
+		n.mu.Lock()
+		if n.listener != listener {
+			n.mu.Unlock()
+			conn.Close()
+			return
+		}
+		n.conns[c] = struct{}{}
+		n.mu.Unlock()
+
+		go c.serve()
+	}
+}
+
+// stop closes the listener and all connections of the node and returns false
+// if it was not running.
+func (n *Node) stop() bool {
+	n.mu.Lock()
+	listener := n.listener
+	conns := n.conns
+	n.listener = nil
+	n.conns = nil
+	n.mu.Unlock()
+
+	if listener == nil {
+		return false
+	}
+
+	listener.Close()
+	for conn := range conns {
+		conn.close()
+	}
+	return true
+}
+
+func (n *Node) removeConn(c *serverConn) {
+	n.mu.Lock()
+	delete(n.conns, c)
+	n.mu.Unlock()
+}
+
+func (n *Node) record(req Request) {
+	n.mu.Lock()
+	n.requests = append(n.requests, req)
+	n.mu.Unlock()
+}
+
+func (n *Node) prepare(id, stmt string) {
+	n.mu.Lock()
+	if n.prepared != nil {
+		n.prepared[id] = stmt
+	}
+	n.mu.Unlock()
+}
+
+func (n *Node) preparedStatement(id string) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	stmt, ok := n.prepared[id]
+	return stmt, ok
+}
+
+// findRule returns the first rule of the node or of the cluster matching
+// stmt, consuming one of its uses if consume is true.
+func (n *Node) findRule(stmt string, consume bool) *Rule {
+	if rule := n.rules.find(stmt, consume); rule != nil {
+		return rule
+	}
+	return n.cluster.rules.find(stmt, consume)
+}
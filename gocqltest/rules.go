@@ -0,0 +1,194 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Column describes a result column or a bind marker of a statement.
+type Column struct {
+	Keyspace string
+	Table    string
+	Name     string
+	Type     gocql.TypeInfo
+}
+
+// Rule scripts the response to the statements it matches. A rule without a
+// result responds with a void result.
+type Rule struct {
+	match func(stmt string) bool
+
+	mu      sync.Mutex
+	times   int
+	delay   time.Duration
+	params  []Column
+	columns []Column
+	rows    [][][]byte
+	err     *Error
+}
+
+// Params sets the bind markers returned when the statement is prepared. The
+// driver refuses to execute prepared statements with values that don't match
+// the bind markers, rules for statements with values must declare them.
+func (r *Rule) Params(params ...Column) *Rule {
+	r.mu.Lock()
+	r.params = params
+	r.mu.Unlock()
+	return r
+}
+
+// ReturnRows responds with rows of the given columns. Result pages are split
+// according to the page size of the request.
+//
+// ReturnRows panics if a value can't be marshalled to the type of its column.
+func (r *Rule) ReturnRows(columns []Column, rows ...[]interface{}) *Rule {
+	encoded := make([][][]byte, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			panic(fmt.Sprintf("gocqltest: row %d has %d values for %d columns", i, len(row), len(columns)))
+		}
+
+		encoded[i] = make([][]byte, len(row))
+		for j, v := range row {
+			b, err := gocql.Marshal(columns[j].Type, v)
+			if err != nil {
+				panic(fmt.Sprintf("gocqltest: unable to marshal column %q of row %d: %v", columns[j].Name, i, err))
+			}
+			encoded[i][j] = b
+		}
+	}
+
+	r.mu.Lock()
+	r.columns = columns
+	r.rows = encoded
+	r.err = nil
+	r.mu.Unlock()
+	return r
+}
+
+// ReturnError responds with err.
+func (r *Rule) ReturnError(err *Error) *Rule {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+	return r
+}
+
+// Delay delays the response by d.
+func (r *Rule) Delay(d time.Duration) *Rule {
+	r.mu.Lock()
+	r.delay = d
+	r.mu.Unlock()
+	return r
+}
+
+// Times limits the rule to the next n executions of matching statements, n
+// must be positive.
+// Preparing a statement doesn't count as an execution.
+func (r *Rule) Times(n int) *Rule {
+	r.mu.Lock()
+	r.times = n
+	r.mu.Unlock()
+	return r
+}
+
+// result is a snapshot of the response scripted by a rule.
+type result struct {
+	delay   time.Duration
+	params  []Column
+	columns []Column
+	rows    [][][]byte
+	err     *Error
+}
+
+func (r *Rule) result() result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return result{
+		delay:   r.delay,
+		params:  r.params,
+		columns: r.columns,
+		rows:    r.rows,
+		err:     r.err,
+	}
+}
+
+// use returns false if the rule was limited with Times and has no uses left,
+// otherwise it consumes one use if consume is true.
+func (r *Rule) use(consume bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.times < 0:
+		return false
+	case r.times == 0:
+		return true
+	case consume:
+		r.times--
+		if r.times == 0 {
+			r.times = -1
+		}
+	}
+	return true
+}
+
+type ruleSet struct {
+	mu    sync.Mutex
+	rules []*Rule
+}
+
+func (s *ruleSet) add(match func(stmt string) bool) *Rule {
+	rule := &Rule{match: match}
+
+	s.mu.Lock()
+	s.rules = append(s.rules, rule)
+	s.mu.Unlock()
+	return rule
+}
+
+func (s *ruleSet) find(stmt string, consume bool) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rule := range s.rules {
+		if rule.match(stmt) && rule.use(consume) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func normalizeStatement(stmt string) string {
+	stmt = strings.TrimRight(strings.TrimSpace(stmt), "; \t\n")
+	return strings.Join(strings.Fields(stmt), " ")
+}
+
+func matchStatement(stmt string) func(string) bool {
+	want := normalizeStatement(stmt)
+	return func(got string) bool {
+		return strings.EqualFold(normalizeStatement(got), want)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+const murmur3Partitioner = "org.apache.cassandra.dht.Murmur3Partitioner"
+
+var (
+	textType   = gocql.NewNativeType(4, gocql.TypeVarchar)
+	intType    = gocql.NewNativeType(4, gocql.TypeInt)
+	inetType   = gocql.NewNativeType(4, gocql.TypeInet)
+	uuidType   = gocql.NewNativeType(4, gocql.TypeUUID)
+	tokensType = gocql.CollectionType{
+		NativeType: gocql.NewNativeType(4, gocql.TypeSet),
+		Elem:       textType,
+	}
+)
+
+// systemTable is the content of a system table served by the nodes.
+type systemTable struct {
+	columns []Column
+	rows    [][]interface{}
+}
+
+// withDSEVersion adds the dse_version column to the rows of the nodes if
+// version is not empty.
+func (t systemTable) withDSEVersion(version string) systemTable {
+	if version == "" {
+		return t
+	}
+
+	t.columns = append(t.columns, Column{Name: "dse_version", Type: textType})
+	for i := range t.rows {
+		t.rows[i] = append(t.rows[i], version)
+	}
+	return t
+}
+
+// systemTable returns the system table selected by stmt, a normalized
+// statement in lower case.
+func (n *Node) systemTable(stmt string) (systemTable, bool) {
+	if !strings.HasPrefix(stmt, "select ") {
+		return systemTable{}, false
+	}
+	i := strings.Index(stmt, " from ")
+	if i < 0 {
+		return systemTable{}, false
+	}
+	name := strings.Fields(stmt[i+len(" from "):])[0]
+
+	c := n.cluster
+	switch name {
+	case "system.local":
+		return systemTable{
+			columns: []Column{
+				{Name: "key", Type: textType},
+				{Name: "broadcast_address", Type: inetType},
+				{Name: "cluster_name", Type: textType},
+				{Name: "cql_version", Type: textType},
+				{Name: "data_center", Type: textType},
+				{Name: "host_id", Type: uuidType},
+				{Name: "listen_address", Type: inetType},
+				{Name: "native_protocol_version", Type: textType},
+				{Name: "partitioner", Type: textType},
+				{Name: "rack", Type: textType},
+				{Name: "release_version", Type: textType},
+				{Name: "rpc_address", Type: inetType},
+				{Name: "schema_version", Type: uuidType},
+				{Name: "tokens", Type: tokensType},
+			},
+			rows: [][]interface{}{{
+				"local", n.ip, c.cfg.ClusterName, "3.4.5", c.cfg.DataCenter, n.hostID, n.ip,
+				strconv.Itoa(n.maxProtocolVersion()), murmur3Partitioner, c.cfg.Rack,
+				c.cfg.ReleaseVersion, n.ip, c.schemaVersion, n.tokens,
+			}},
+		}.withDSEVersion(c.cfg.DSEVersion), true
+	case "system.peers":
+		table := systemTable{
+			columns: []Column{
+				{Name: "peer", Type: inetType},
+				{Name: "data_center", Type: textType},
+				{Name: "host_id", Type: uuidType},
+				{Name: "rack", Type: textType},
+				{Name: "release_version", Type: textType},
+				{Name: "rpc_address", Type: inetType},
+				{Name: "schema_version", Type: uuidType},
+				{Name: "tokens", Type: tokensType},
+			},
+		}
+		for _, peer := range c.nodes {
+			if peer != n {
+				table.rows = append(table.rows, []interface{}{
+					peer.ip, c.cfg.DataCenter, peer.hostID, c.cfg.Rack, c.cfg.ReleaseVersion,
+					peer.ip, c.schemaVersion, peer.tokens,
+				})
+			}
+		}
+		return table.withDSEVersion(c.cfg.DSEVersion), true
+	case "system.peers_v2":
+		table := systemTable{
+			columns: []Column{
+				{Name: "peer", Type: inetType},
+				{Name: "peer_port", Type: intType},
+				{Name: "data_center", Type: textType},
+				{Name: "host_id", Type: uuidType},
+				{Name: "native_address", Type: inetType},
+				{Name: "native_port", Type: intType},
+				{Name: "rack", Type: textType},
+				{Name: "release_version", Type: textType},
+				{Name: "schema_version", Type: uuidType},
+				{Name: "tokens", Type: tokensType},
+			},
+		}
+		for _, peer := range c.nodes {
+			if peer != n {
+				table.rows = append(table.rows, []interface{}{
+					peer.ip, c.port, c.cfg.DataCenter, peer.hostID, peer.ip, c.port, c.cfg.Rack,
+					c.cfg.ReleaseVersion, c.schemaVersion, peer.tokens,
+				})
+			}
+		}
+		return table.withDSEVersion(c.cfg.DSEVersion), true
+	}
+
+	// other system tables are empty
+	if strings.HasPrefix(name, "system.") || strings.HasPrefix(name, "system_schema.") ||
+		strings.HasPrefix(name, "system_traces.") {
+		return systemTable{}, true
+	}
+
+	return systemTable{}, false
+}
+
+// project returns the columns and encoded rows of the table selected by stmt,
+// a normalized statement in lower case.
+func (t systemTable) project(stmt string) ([]Column, [][][]byte) {
+	selection := strings.TrimSpace(stmt[len("select "):strings.Index(stmt, " from ")])
+
+	indexes := make([]int, 0, len(t.columns))
+	if selection == "*" {
+		for i := range t.columns {
+			indexes = append(indexes, i)
+		}
+	} else {
+		for _, name := range strings.Split(selection, ",") {
+			name = strings.TrimSpace(name)
+			for i, col := range t.columns {
+				if col.Name == name {
+					indexes = append(indexes, i)
+				}
+			}
+		}
+	}
+
+	columns := make([]Column, len(indexes))
+	for i, idx := range indexes {
+		columns[i] = t.columns[idx]
+	}
+
+	rows := make([][][]byte, len(t.rows))
+	for i, row := range t.rows {
+		rows[i] = make([][]byte, len(indexes))
+		for j, idx := range indexes {
+			// the values of system tables always match their types
+			rows[i][j], _ = gocql.Marshal(t.columns[idx].Type, row[idx])
+		}
+	}
+
+	return columns, rows
+}
@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocqltest
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/gocql/gocql/internal/wire"
+)
+
+// writeType writes the [option] describing t.
+func writeType(f wire.Framer, t gocql.TypeInfo) {
+	f.WriteShort(uint16(t.Type()))
+
+	switch t.Type() {
+	case gocql.TypeCustom:
+		f.WriteString(t.Custom())
+	case gocql.TypeList, gocql.TypeSet:
+		writeType(f, t.(gocql.CollectionType).Elem)
+	case gocql.TypeMap:
+		collection := t.(gocql.CollectionType)
+		writeType(f, collection.Key)
+		writeType(f, collection.Elem)
+	case gocql.TypeTuple:
+		tuple := t.(gocql.TupleTypeInfo)
+		f.WriteShort(uint16(len(tuple.Elems)))
+		for _, elem := range tuple.Elems {
+			writeType(f, elem)
+		}
+	case gocql.TypeUDT:
+		udt := t.(gocql.UDTTypeInfo)
+		f.WriteString(udt.KeySpace)
+		f.WriteString(udt.Name)
+		f.WriteShort(uint16(len(udt.Elements)))
+		for _, elem := range udt.Elements {
+			f.WriteString(elem.Name)
+			writeType(f, elem.Type)
+		}
+	}
+}
@@ -25,12 +25,10 @@
 package gocql
 
 import (
-	"encoding/hex"
 	"fmt"
 	"math/big"
 	"net"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
@@ -98,6 +96,11 @@ func goType(t TypeInfo) (reflect.Type, error) {
 		return reflect.TypeOf(*new(time.Time)), nil
 	case TypeDuration:
 		return reflect.TypeOf(*new(Duration)), nil
+	case TypeCustom:
+		if typ := geometryGoType(t.Custom()); typ != nil {
+			return typ, nil
+		}
+		return nil, fmt.Errorf("cannot create Go type for unknown CQL type %s", t)
 	default:
 		return nil, fmt.Errorf("cannot create Go type for unknown CQL type %s", t)
 	}
@@ -164,183 +167,24 @@ func getCassandraBaseType(name string) Type {
 	}
 }
 
-// TODO: Cover with unit tests.
 // Parses long Java-style type definition to internal data structures.
 func getCassandraLongType(name string, protoVer byte, logger StdLogger) TypeInfo {
-	if strings.HasPrefix(name, SET_TYPE) {
-		return CollectionType{
-			NativeType: NewNativeType(protoVer, TypeSet),
-			Elem:       getCassandraLongType(unwrapCompositeTypeDefinition(name, SET_TYPE, '('), protoVer, logger),
-		}
-	} else if strings.HasPrefix(name, LIST_TYPE) {
-		return CollectionType{
-			NativeType: NewNativeType(protoVer, TypeList),
-			Elem:       getCassandraLongType(unwrapCompositeTypeDefinition(name, LIST_TYPE, '('), protoVer, logger),
-		}
-	} else if strings.HasPrefix(name, MAP_TYPE) {
-		names := splitJavaCompositeTypes(name, MAP_TYPE)
-		if len(names) != 2 {
-			logger.Printf("gocql: error parsing map type, it has %d subelements, expecting 2\n", len(names))
-			return NewNativeType(protoVer, TypeCustom)
-		}
-		return CollectionType{
-			NativeType: NewNativeType(protoVer, TypeMap),
-			Key:        getCassandraLongType(names[0], protoVer, logger),
-			Elem:       getCassandraLongType(names[1], protoVer, logger),
-		}
-	} else if strings.HasPrefix(name, TUPLE_TYPE) {
-		names := splitJavaCompositeTypes(name, TUPLE_TYPE)
-		types := make([]TypeInfo, len(names))
-
-		for i, name := range names {
-			types[i] = getCassandraLongType(name, protoVer, logger)
-		}
-
-		return TupleTypeInfo{
-			NativeType: NewNativeType(protoVer, TypeTuple),
-			Elems:      types,
-		}
-	} else if strings.HasPrefix(name, UDT_TYPE) {
-		names := splitJavaCompositeTypes(name, UDT_TYPE)
-		fields := make([]UDTField, len(names)-2)
-
-		for i := 2; i < len(names); i++ {
-			spec := strings.Split(names[i], ":")
-			fieldName, _ := hex.DecodeString(spec[0])
-			fields[i-2] = UDTField{
-				Name: string(fieldName),
-				Type: getCassandraLongType(spec[1], protoVer, logger),
-			}
-		}
-
-		udtName, _ := hex.DecodeString(names[1])
-		return UDTTypeInfo{
-			NativeType: NewNativeType(protoVer, TypeUDT),
-			KeySpace:   names[0],
-			Name:       string(udtName),
-			Elements:   fields,
-		}
-	} else if strings.HasPrefix(name, VECTOR_TYPE) {
-		names := splitJavaCompositeTypes(name, VECTOR_TYPE)
-		subType := getCassandraLongType(strings.TrimSpace(names[0]), protoVer, logger)
-		dim, err := strconv.Atoi(strings.TrimSpace(names[1]))
-		if err != nil {
-			logger.Printf("gocql: error parsing vector dimensions: %v\n", err)
-			return NewNativeType(protoVer, TypeCustom)
-		}
-
-		return VectorType{
-			NativeType: NewCustomType(protoVer, TypeCustom, VECTOR_TYPE),
-			SubType:    subType,
-			Dimensions: dim,
-		}
-	} else {
-		// basic type
-		return NativeType{
-			proto: protoVer,
-			typ:   getApacheCassandraType(name),
-		}
+	info, err := ParseCQLTypeClass(name, protoVer)
+	if err != nil {
+		logger.Printf("%v\n", err)
+		return NewCustomType(protoVer, TypeCustom, name)
 	}
+	return info
 }
 
 // Parses short CQL type representation (e.g. map<text, text>) to internal data structures.
 func getCassandraType(name string, protoVer byte, logger StdLogger) TypeInfo {
-	if strings.HasPrefix(name, "frozen<") {
-		return getCassandraType(unwrapCompositeTypeDefinition(name, "frozen", '<'), protoVer, logger)
-	} else if strings.HasPrefix(name, "set<") {
-		return CollectionType{
-			NativeType: NewNativeType(protoVer, TypeSet),
-			Elem:       getCassandraType(unwrapCompositeTypeDefinition(name, "set", '<'), protoVer, logger),
-		}
-	} else if strings.HasPrefix(name, "list<") {
-		return CollectionType{
-			NativeType: NewNativeType(protoVer, TypeList),
-			Elem:       getCassandraType(unwrapCompositeTypeDefinition(name, "list", '<'), protoVer, logger),
-		}
-	} else if strings.HasPrefix(name, "map<") {
-		names := splitCQLCompositeTypes(name, "map")
-		if len(names) != 2 {
-			logger.Printf("Error parsing map type, it has %d subelements, expecting 2\n", len(names))
-			return NewNativeType(protoVer, TypeCustom)
-		}
-		return CollectionType{
-			NativeType: NewNativeType(protoVer, TypeMap),
-			Key:        getCassandraType(names[0], protoVer, logger),
-			Elem:       getCassandraType(names[1], protoVer, logger),
-		}
-	} else if strings.HasPrefix(name, "tuple<") {
-		names := splitCQLCompositeTypes(name, "tuple")
-		types := make([]TypeInfo, len(names))
-
-		for i, name := range names {
-			types[i] = getCassandraType(name, protoVer, logger)
-		}
-
-		return TupleTypeInfo{
-			NativeType: NewNativeType(protoVer, TypeTuple),
-			Elems:      types,
-		}
-	} else if strings.HasPrefix(name, "vector<") {
-		names := splitCQLCompositeTypes(name, "vector")
-		subType := getCassandraType(strings.TrimSpace(names[0]), protoVer, logger)
-		dim, _ := strconv.Atoi(strings.TrimSpace(names[1]))
-
-		return VectorType{
-			NativeType: NewCustomType(protoVer, TypeCustom, VECTOR_TYPE),
-			SubType:    subType,
-			Dimensions: dim,
-		}
-	} else {
-		return NativeType{
-			proto: protoVer,
-			typ:   getCassandraBaseType(name),
-		}
-	}
-}
-
-func splitCQLCompositeTypes(name string, typeName string) []string {
-	return splitCompositeTypes(name, typeName, '<', '>')
-}
-
-func splitJavaCompositeTypes(name string, typeName string) []string {
-	return splitCompositeTypes(name, typeName, '(', ')')
-}
-
-func unwrapCompositeTypeDefinition(name string, typeName string, typeOpen int32) string {
-	return strings.TrimPrefix(name[:len(name)-1], typeName+string(typeOpen))
-}
-
-func splitCompositeTypes(name string, typeName string, typeOpen int32, typeClose int32) []string {
-	def := unwrapCompositeTypeDefinition(name, typeName, typeOpen)
-	if !strings.Contains(def, string(typeOpen)) {
-		parts := strings.Split(def, ",")
-		for i := range parts {
-			parts[i] = strings.TrimSpace(parts[i])
-		}
-		return parts
-	}
-	var parts []string
-	lessCount := 0
-	segment := ""
-	for _, char := range def {
-		if char == ',' && lessCount == 0 {
-			if segment != "" {
-				parts = append(parts, strings.TrimSpace(segment))
-			}
-			segment = ""
-			continue
-		}
-		segment += string(char)
-		if char == typeOpen {
-			lessCount++
-		} else if char == typeClose {
-			lessCount--
-		}
-	}
-	if segment != "" {
-		parts = append(parts, strings.TrimSpace(segment))
+	info, err := ParseCQLType(name, protoVer)
+	if err != nil {
+		logger.Printf("%v\n", err)
+		return NewNativeType(protoVer, TypeCustom)
 	}
-	return parts
+	return info
 }
 
 func getApacheCassandraType(class string) Type {
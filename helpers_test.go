@@ -75,6 +75,7 @@ func TestGetCassandraType(t *testing.T) {
 		{
 			"tuple<int, int, text>", TupleTypeInfo{
 				NativeType: NativeType{typ: TypeTuple},
+				Frozen:     true,
 
 				Elems: []TypeInfo{
 					NativeType{typ: TypeInt},
@@ -86,12 +87,15 @@ func TestGetCassandraType(t *testing.T) {
 		{
 			"frozen<map<text, frozen<list<frozen<tuple<int, int>>>>>>", CollectionType{
 				NativeType: NativeType{typ: TypeMap},
+				Frozen:     true,
 
 				Key: NativeType{typ: TypeText},
 				Elem: CollectionType{
 					NativeType: NativeType{typ: TypeList},
+					Frozen:     true,
 					Elem: TupleTypeInfo{
 						NativeType: NativeType{typ: TypeTuple},
+						Frozen:     true,
 
 						Elems: []TypeInfo{
 							NativeType{typ: TypeInt},
@@ -105,15 +109,19 @@ func TestGetCassandraType(t *testing.T) {
 			"frozen<tuple<frozen<tuple<text, frozen<list<frozen<tuple<int, int>>>>>>, frozen<tuple<text, frozen<list<frozen<tuple<int, int>>>>>>,  frozen<map<text, frozen<list<frozen<tuple<int, int>>>>>>>>",
 			TupleTypeInfo{
 				NativeType: NativeType{typ: TypeTuple},
+				Frozen:     true,
 				Elems: []TypeInfo{
 					TupleTypeInfo{
 						NativeType: NativeType{typ: TypeTuple},
+						Frozen:     true,
 						Elems: []TypeInfo{
 							NativeType{typ: TypeText},
 							CollectionType{
 								NativeType: NativeType{typ: TypeList},
+								Frozen:     true,
 								Elem: TupleTypeInfo{
 									NativeType: NativeType{typ: TypeTuple},
+									Frozen:     true,
 									Elems: []TypeInfo{
 										NativeType{typ: TypeInt},
 										NativeType{typ: TypeInt},
@@ -124,12 +132,15 @@ func TestGetCassandraType(t *testing.T) {
 					},
 					TupleTypeInfo{
 						NativeType: NativeType{typ: TypeTuple},
+						Frozen:     true,
 						Elems: []TypeInfo{
 							NativeType{typ: TypeText},
 							CollectionType{
 								NativeType: NativeType{typ: TypeList},
+								Frozen:     true,
 								Elem: TupleTypeInfo{
 									NativeType: NativeType{typ: TypeTuple},
+									Frozen:     true,
 									Elems: []TypeInfo{
 										NativeType{typ: TypeInt},
 										NativeType{typ: TypeInt},
@@ -140,11 +151,14 @@ func TestGetCassandraType(t *testing.T) {
 					},
 					CollectionType{
 						NativeType: NativeType{typ: TypeMap},
+						Frozen:     true,
 						Key:        NativeType{typ: TypeText},
 						Elem: CollectionType{
 							NativeType: NativeType{typ: TypeList},
+							Frozen:     true,
 							Elem: TupleTypeInfo{
 								NativeType: NativeType{typ: TypeTuple},
+								Frozen:     true,
 								Elems: []TypeInfo{
 									NativeType{typ: TypeInt},
 									NativeType{typ: TypeInt},
@@ -158,10 +172,12 @@ func TestGetCassandraType(t *testing.T) {
 		{
 			"frozen<tuple<frozen<tuple<int, int>>, int, frozen<tuple<int, int>>>>", TupleTypeInfo{
 				NativeType: NativeType{typ: TypeTuple},
+				Frozen:     true,
 
 				Elems: []TypeInfo{
 					TupleTypeInfo{
 						NativeType: NativeType{typ: TypeTuple},
+						Frozen:     true,
 
 						Elems: []TypeInfo{
 							NativeType{typ: TypeInt},
@@ -171,6 +187,7 @@ func TestGetCassandraType(t *testing.T) {
 					NativeType{typ: TypeInt},
 					TupleTypeInfo{
 						NativeType: NativeType{typ: TypeTuple},
+						Frozen:     true,
 
 						Elems: []TypeInfo{
 							NativeType{typ: TypeInt},
@@ -183,9 +200,11 @@ func TestGetCassandraType(t *testing.T) {
 		{
 			"frozen<map<frozen<tuple<int, int>>, int>>", CollectionType{
 				NativeType: NativeType{typ: TypeMap},
+				Frozen:     true,
 
 				Key: TupleTypeInfo{
 					NativeType: NativeType{typ: TypeTuple},
+					Frozen:     true,
 
 					Elems: []TypeInfo{
 						NativeType{typ: TypeInt},
@@ -258,6 +277,7 @@ func TestGetCassandraType(t *testing.T) {
 				},
 				SubType: CollectionType{
 					NativeType: NativeType{typ: TypeMap},
+					Frozen:     true,
 					Key:        NativeType{typ: TypeUUID},
 					Elem:       NativeType{typ: TypeTimestamp},
 				},
@@ -272,6 +292,7 @@ func TestGetCassandraType(t *testing.T) {
 				},
 				SubType: TupleTypeInfo{
 					NativeType: NativeType{typ: TypeTuple},
+					Frozen:     true,
 					Elems: []TypeInfo{
 						NativeType{typ: TypeInt},
 						NativeType{typ: TypeFloat},
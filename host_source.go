@@ -179,6 +179,8 @@ type HostInfo struct {
 	state            nodeState
 	schemaVersion    string
 	tokens           []string
+	protoVersion     int
+	supported        SupportedOptions
 }
 
 // NewHostInfo creates HostInfo with provided connectAddress and port.
@@ -347,6 +349,36 @@ func (h *HostInfo) Version() cassVersion {
 	return h.version
 }
 
+// ProtocolVersion returns the native protocol version negotiated with the
+// host, or 0 if the session uses a fixed version or is not connected to the
+// host.
+func (h *HostInfo) ProtocolVersion() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.protoVersion
+}
+
+func (h *HostInfo) setProtocolVersion(version int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.protoVersion = version
+}
+
+// SupportedOptions returns the options the host returned in its SUPPORTED
+// response when the last connection to it was opened, or nil if the driver
+// did not connect to the host yet.
+func (h *HostInfo) SupportedOptions() SupportedOptions {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.supported
+}
+
+func (h *HostInfo) setSupportedOptions(supported SupportedOptions) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.supported = supported
+}
+
 func (h *HostInfo) State() nodeState {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
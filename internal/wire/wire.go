@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package wire gives the other packages of this module access to the native
+// protocol frame codec implemented by package gocql.
+//
+// The codec is registered when package gocql is initialised, importing gocql
+// is enough to make the functions in this package usable.
+package wire
+
+import (
+	"io"
+	"net"
+)
+
+// Header is the header of a single native protocol frame.
+type Header struct {
+	// Version is the protocol version including the direction bit.
+	Version byte
+	Flags   byte
+	Stream  int
+	Op      byte
+	Length  int
+}
+
+// Framer reads and writes the body of a single native protocol frame.
+//
+// The read methods return zero values once the body turns out to be
+// malformed, callers are expected to check Err once done reading.
+type Framer interface {
+	Header() Header
+	// Err returns the first error encountered while reading the body.
+	Err() error
+
+	ReadUint8() uint8
+	ReadShort() uint16
+	ReadInt() int
+	ReadLong() int64
+	ReadString() string
+	ReadLongString() string
+	ReadBytes() []byte
+	ReadShortBytes() []byte
+	ReadStringList() []string
+	ReadStringMap() map[string]string
+	ReadBytesMap() map[string][]byte
+
+	// WriteHeader resets the framer and writes a frame header for the version
+	// the framer was created with.
+	WriteHeader(flags, op byte, stream int)
+	WriteUint8(b uint8)
+	WriteShort(n uint16)
+	WriteInt(n int32)
+	WriteLong(n int64)
+	WriteString(s string)
+	WriteLongString(s string)
+	WriteBytes(p []byte)
+	WriteShortBytes(p []byte)
+	WriteStringList(l []string)
+	WriteStringMap(m map[string]string)
+	WriteStringMultiMap(m map[string][]string)
+	WriteInet(ip net.IP, port int)
+
+	// Send finishes the frame and writes it to w, wrapped in protocol v5
+	// segments if segmented is true.
+	Send(w io.Writer, segmented bool) error
+}
+
+var (
+	// NewFramer returns a Framer writing frames with the given version byte,
+	// including the direction bit.
+	NewFramer func(version byte) Framer
+
+	// ReadFrame reads a single frame of any supported protocol version from r.
+	ReadFrame func(r io.Reader) (Framer, error)
+
+	// ReadSegment reads a single uncompressed protocol v5 segment from r and
+	// returns its payload.
+	ReadSegment func(r io.Reader) (payload []byte, selfContained bool, err error)
+)
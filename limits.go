@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"fmt"
+)
+
+// ErrResponseTooLarge is returned when a response exceeds one of the limits
+// set with ClusterConfig.MaxResponseBodySize, MaxDecompressedBodySize or
+// MaxPageBytes. The response is discarded, the connection it was received on
+// stays usable.
+type ErrResponseTooLarge struct {
+	// Limit is the exceeded limit, either "body", "decompressed" or "page".
+	Limit string
+	// Size is the size of the response body in bytes.
+	Size int
+	// Max is the value of the exceeded limit.
+	Max int
+}
+
+func (e *ErrResponseTooLarge) Error() string {
+	return fmt.Sprintf("gocql: response %s size of %d bytes exceeds the limit of %d bytes", e.Limit, e.Size, e.Max)
+}
+
+// responseLimits are the limits on the size of responses of a connection, a
+// nil *responseLimits has no limits.
+type responseLimits struct {
+	body         int
+	decompressed int
+	page         int
+}
+
+func newResponseLimits(cfg *ClusterConfig) *responseLimits {
+	if cfg.MaxResponseBodySize <= 0 && cfg.MaxDecompressedBodySize <= 0 && cfg.MaxPageBytes <= 0 {
+		return nil
+	}
+	return &responseLimits{
+		body:         cfg.MaxResponseBodySize,
+		decompressed: cfg.MaxDecompressedBodySize,
+		page:         cfg.MaxPageBytes,
+	}
+}
+
+// checkLength checks the body length of the frame with header head before the
+// body is read.
+func (l *responseLimits) checkLength(head *frameHeader) error {
+	if l == nil {
+		return nil
+	}
+	if l.body > 0 && head.length > l.body {
+		return &ErrResponseTooLarge{Limit: "body", Size: head.length, Max: l.body}
+	}
+	// protocol v5 compresses the segments carrying frames, not the frames
+	if modernLayout(head.version.version()) || head.flags&flagCompress == 0 {
+		return l.checkDecompressed(head, head.length)
+	}
+	return nil
+}
+
+// checkDecompressed checks the size of the decompressed body of the frame
+// with header head.
+func (l *responseLimits) checkDecompressed(head *frameHeader, size int) error {
+	if l == nil {
+		return nil
+	}
+	if l.decompressed > 0 && size > l.decompressed {
+		return &ErrResponseTooLarge{Limit: "decompressed", Size: size, Max: l.decompressed}
+	}
+	if l.page > 0 && head.op == opResult && size > l.page {
+		return &ErrResponseTooLarge{Limit: "page", Size: size, Max: l.page}
+	}
+	return nil
+}
+
+// decompressedLengther is implemented by compressors which can tell the
+// decompressed length of a protocol v4 frame body without decompressing it.
+type decompressedLengther interface {
+	DecompressedLength(src []byte) (int, error)
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"testing"
+)
+
+// lengthlessCompressor hides the DecompressedLength method of the compressor.
+type lengthlessCompressor struct {
+	Compressor
+}
+
+func TestFramerReadFrameDecompressedLimit(t *testing.T) {
+	for _, compressor := range []Compressor{SnappyCompressor{}, lengthlessCompressor{SnappyCompressor{}}} {
+		f := newFramer(compressor, protoVersion4)
+		f.writeHeader(f.flags, opResult, 1)
+		f.buf[0] |= protoDirectionMask
+		f.writeInt(resultKindVoid)
+		f.buf = append(f.buf, make([]byte, 4096)...)
+		if err := f.finish(); err != nil {
+			t.Fatal(err)
+		}
+
+		r := bytes.NewReader(f.buf)
+		head, err := readHeader(r, make([]byte, maxFrameHeaderSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (&responseLimits{decompressed: 1024}).checkLength(&head); err != nil {
+			t.Fatalf("%T: the compressed length should not be checked against the decompressed limit: %v", compressor, err)
+		}
+
+		framer := newFramer(compressor, protoVersion4)
+		framer.limits = &responseLimits{decompressed: 1024}
+		err = framer.readFrame(r, &head)
+		tooLarge, ok := err.(*ErrResponseTooLarge)
+		if !ok {
+			t.Fatalf("%T: expected *ErrResponseTooLarge, got %T: %v", compressor, err, err)
+		}
+		if tooLarge.Limit != "decompressed" || tooLarge.Size != 4100 || tooLarge.Max != 1024 {
+			t.Errorf("%T: unexpected error %+v", compressor, tooLarge)
+		}
+	}
+}
+
+func TestResponseLimitsPage(t *testing.T) {
+	limits := &responseLimits{page: 1024}
+	result := &frameHeader{version: protoVersion4 | protoDirectionMask, op: opResult, length: 2048}
+	if err, ok := limits.checkLength(result).(*ErrResponseTooLarge); !ok || err.Limit != "page" {
+		t.Errorf("expected the page limit to apply to RESULT frames, got %v", err)
+	}
+
+	supported := &frameHeader{version: protoVersion4 | protoDirectionMask, op: opSupported, length: 2048}
+	if err := limits.checkLength(supported); err != nil {
+		t.Errorf("expected the page limit to only apply to RESULT frames, got %v", err)
+	}
+
+	if err := (*responseLimits)(nil).checkLength(result); err != nil {
+		t.Errorf("expected no limits, got %v", err)
+	}
+}
@@ -80,6 +80,15 @@ func (s LZ4Compressor) AppendDecompressedWithLength(dst, src []byte) ([]byte, er
 
 }
 
+// DecompressedLength returns the decompressed length of a block compressed
+// with AppendCompressedWithLength.
+func (s LZ4Compressor) DecompressedLength(src []byte) (int, error) {
+	if len(src) < dataLengthSize {
+		return 0, fmt.Errorf("cassandra lz4 block size should be >4, got=%d", len(src))
+	}
+	return int(binary.BigEndian.Uint32(src[:dataLengthSize])), nil
+}
+
 func (s LZ4Compressor) AppendCompressed(dst, src []byte) ([]byte, error) {
 	maxLength := lz4.CompressBlockBound(len(src))
 	oldDstLen := len(dst)
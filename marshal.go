@@ -110,20 +110,36 @@ type Unmarshaler interface {
 //	duration                    | time.Duration      |
 //	duration                    | gocql.Duration     |
 //	duration                    | string             | parsed with time.ParseDuration
+//	PointType                   | gocql.Point        | DSE geospatial types
+//	LineStringType              | gocql.LineString   |
+//	PolygonType                 | gocql.Polygon      |
+//	geospatial types            | string             | well-known text, for example "POINT (1 2)"
+//	geospatial types            | []byte             | well-known binary
+//
+// Codecs registered with RegisterCodec take precedence over the conversions above.
 //
 // The marshal/unmarshal error provides a list of supported types when an unsupported type is attempted.
 func Marshal(info TypeInfo, value interface{}) ([]byte, error) {
+	return marshalCQL(nil, info, value)
+}
+
+// marshalCQL is Marshal consulting codecs before the global codecs.
+func marshalCQL(codecs *CodecRegistry, info TypeInfo, value interface{}) ([]byte, error) {
 	if info.Version() < protoVersion1 {
 		panic("protocol version not set")
 	}
 
+	if marshal := codecs.marshaler(info, value); marshal != nil {
+		return marshal(info, value)
+	}
+
 	if valueRef := reflect.ValueOf(value); valueRef.Kind() == reflect.Ptr {
 		if valueRef.IsNil() {
 			return nil, nil
 		} else if v, ok := value.(Marshaler); ok {
 			return v.MarshalCQL(info)
 		} else {
-			return Marshal(info, valueRef.Elem().Interface())
+			return marshalCQL(codecs, info, valueRef.Elem().Interface())
 		}
 	}
 
@@ -155,9 +171,9 @@ func Marshal(info TypeInfo, value interface{}) ([]byte, error) {
 	case TypeTimestamp:
 		return marshalTimestamp(info, value)
 	case TypeList, TypeSet:
-		return marshalList(info, value)
+		return marshalList(codecs, info, value)
 	case TypeMap:
-		return marshalMap(info, value)
+		return marshalMap(codecs, info, value)
 	case TypeUUID, TypeTimeUUID:
 		return marshalUUID(info, value)
 	case TypeVarint:
@@ -165,16 +181,19 @@ func Marshal(info TypeInfo, value interface{}) ([]byte, error) {
 	case TypeInet:
 		return marshalInet(info, value)
 	case TypeTuple:
-		return marshalTuple(info, value)
+		return marshalTuple(codecs, info, value)
 	case TypeUDT:
-		return marshalUDT(info, value)
+		return marshalUDT(codecs, info, value)
 	case TypeDate:
 		return marshalDate(info, value)
 	case TypeDuration:
 		return marshalDuration(info, value)
 	case TypeCustom:
 		if vector, ok := info.(VectorType); ok {
-			return marshalVector(vector, value)
+			return marshalVector(codecs, vector, value)
+		}
+		if kind := geometryKind(info.Custom()); kind != 0 {
+			return marshalGeometry(info, kind, value)
 		}
 	}
 
@@ -228,13 +247,29 @@ func Marshal(info TypeInfo, value interface{}) ([]byte, error) {
 //	date                                    | *time.Time              | time of beginning of the day (in UTC)
 //	date                                    | *string                 | formatted with 2006-01-02 format
 //	duration                                | *gocql.Duration         |
+//	PointType                               | *gocql.Point            | DSE geospatial types
+//	LineStringType                          | *gocql.LineString       |
+//	PolygonType                             | *gocql.Polygon          |
+//	geospatial types                        | *string                 | well-known text, for example "POINT (1 2)"
+//	geospatial types                        | *[]byte                 | well-known binary
+//
+// Codecs registered with RegisterCodec take precedence over the conversions above.
 func Unmarshal(info TypeInfo, data []byte, value interface{}) error {
+	return unmarshalCQL(nil, info, data, value)
+}
+
+// unmarshalCQL is Unmarshal consulting codecs before the global codecs.
+func unmarshalCQL(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
+	if unmarshal := codecs.unmarshaler(info, value); unmarshal != nil {
+		return unmarshal(info, data, value)
+	}
+
 	if v, ok := value.(Unmarshaler); ok {
 		return v.UnmarshalCQL(info, data)
 	}
 
 	if isNullableValue(value) {
-		return unmarshalNullable(info, data, value)
+		return unmarshalNullable(codecs, info, data, value)
 	}
 
 	switch info.Type() {
@@ -263,9 +298,9 @@ func Unmarshal(info TypeInfo, data []byte, value interface{}) error {
 	case TypeTimestamp:
 		return unmarshalTimestamp(info, data, value)
 	case TypeList, TypeSet:
-		return unmarshalList(info, data, value)
+		return unmarshalList(codecs, info, data, value)
 	case TypeMap:
-		return unmarshalMap(info, data, value)
+		return unmarshalMap(codecs, info, data, value)
 	case TypeTimeUUID:
 		return unmarshalTimeUUID(info, data, value)
 	case TypeUUID:
@@ -273,16 +308,19 @@ func Unmarshal(info TypeInfo, data []byte, value interface{}) error {
 	case TypeInet:
 		return unmarshalInet(info, data, value)
 	case TypeTuple:
-		return unmarshalTuple(info, data, value)
+		return unmarshalTuple(codecs, info, data, value)
 	case TypeUDT:
-		return unmarshalUDT(info, data, value)
+		return unmarshalUDT(codecs, info, data, value)
 	case TypeDate:
 		return unmarshalDate(info, data, value)
 	case TypeDuration:
 		return unmarshalDuration(info, data, value)
 	case TypeCustom:
 		if vector, ok := info.(VectorType); ok {
-			return unmarshalVector(vector, data, value)
+			return unmarshalVector(codecs, vector, data, value)
+		}
+		if kind := geometryKind(info.Custom()); kind != 0 {
+			return unmarshalGeometry(info, kind, data, value)
 		}
 	}
 
@@ -304,7 +342,7 @@ func isNullData(info TypeInfo, data []byte) bool {
 	return data == nil
 }
 
-func unmarshalNullable(info TypeInfo, data []byte, value interface{}) error {
+func unmarshalNullable(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
 	valueRef := reflect.ValueOf(value)
 
 	if isNullData(info, data) {
@@ -315,7 +353,7 @@ func unmarshalNullable(info TypeInfo, data []byte, value interface{}) error {
 
 	newValue := reflect.New(valueRef.Type().Elem().Elem())
 	valueRef.Elem().Set(newValue)
-	return Unmarshal(info, data, newValue.Interface())
+	return unmarshalCQL(codecs, info, data, newValue.Interface())
 }
 
 func marshalVarchar(info TypeInfo, value interface{}) ([]byte, error) {
@@ -1587,7 +1625,7 @@ func writeCollectionSize(info CollectionType, n int, buf *bytes.Buffer) error {
 	return nil
 }
 
-func marshalList(info TypeInfo, value interface{}) ([]byte, error) {
+func marshalList(codecs *CodecRegistry, info TypeInfo, value interface{}) ([]byte, error) {
 	listInfo, ok := info.(CollectionType)
 	if !ok {
 		return nil, marshalErrorf("marshal: can not marshal non collection type into list")
@@ -1616,7 +1654,7 @@ func marshalList(info TypeInfo, value interface{}) ([]byte, error) {
 		}
 
 		for i := 0; i < n; i++ {
-			item, err := Marshal(listInfo.Elem, rv.Index(i).Interface())
+			item, err := marshalCQL(codecs, listInfo.Elem, rv.Index(i).Interface())
 			if err != nil {
 				return nil, err
 			}
@@ -1639,7 +1677,7 @@ func marshalList(info TypeInfo, value interface{}) ([]byte, error) {
 			for i := 0; i < len(keys); i++ {
 				keys[i] = rkeys[i].Interface()
 			}
-			return marshalList(listInfo, keys)
+			return marshalList(codecs, listInfo, keys)
 		}
 	}
 	return nil, marshalErrorf("can not marshal %T into %s. Accepted types: slice, array, map[]struct.", value, info)
@@ -1662,7 +1700,7 @@ func readCollectionSize(info CollectionType, data []byte) (size, read int, err e
 	return
 }
 
-func unmarshalList(info TypeInfo, data []byte, value interface{}) error {
+func unmarshalList(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
 	listInfo, ok := info.(CollectionType)
 	if !ok {
 		return unmarshalErrorf("unmarshal: can not unmarshal none collection type into list")
@@ -1715,7 +1753,7 @@ func unmarshalList(info TypeInfo, data []byte, value interface{}) error {
 				unmarshalData = data[:m]
 				data = data[m:]
 			}
-			if err := Unmarshal(listInfo.Elem, unmarshalData, rv.Index(i).Addr().Interface()); err != nil {
+			if err := unmarshalCQL(codecs, listInfo.Elem, unmarshalData, rv.Index(i).Addr().Interface()); err != nil {
 				return err
 			}
 		}
@@ -1724,7 +1762,7 @@ func unmarshalList(info TypeInfo, data []byte, value interface{}) error {
 	return unmarshalErrorf("can not unmarshal %s into %T. Accepted types: *slice, *array.", info, value)
 }
 
-func marshalVector(info VectorType, value interface{}) ([]byte, error) {
+func marshalVector(codecs *CodecRegistry, info VectorType, value interface{}) ([]byte, error) {
 	if value == nil {
 		return nil, nil
 	} else if _, ok := value.(unsetColumn); ok {
@@ -1747,7 +1785,7 @@ func marshalVector(info VectorType, value interface{}) ([]byte, error) {
 		}
 
 		for i := 0; i < n; i++ {
-			item, err := Marshal(info.SubType, rv.Index(i).Interface())
+			item, err := marshalCQL(codecs, info.SubType, rv.Index(i).Interface())
 			if err != nil {
 				return nil, err
 			}
@@ -1761,7 +1799,7 @@ func marshalVector(info VectorType, value interface{}) ([]byte, error) {
 	return nil, marshalErrorf("can not marshal %T into %s. Accepted types: slice, array.", value, info)
 }
 
-func unmarshalVector(info VectorType, data []byte, value interface{}) error {
+func unmarshalVector(codecs *CodecRegistry, info VectorType, data []byte, value interface{}) error {
 	rv := reflect.ValueOf(value)
 	if rv.Kind() != reflect.Ptr {
 		return unmarshalErrorf("can not unmarshal into non-pointer %T", value)
@@ -1810,7 +1848,7 @@ func unmarshalVector(info VectorType, data []byte, value interface{}) error {
 				unmarshalData = data[:elemSize]
 				data = data[elemSize:]
 			}
-			err := Unmarshal(info.SubType, unmarshalData, rv.Index(i).Addr().Interface())
+			err := unmarshalCQL(codecs, info.SubType, unmarshalData, rv.Index(i).Addr().Interface())
 			if err != nil {
 				return unmarshalErrorf("failed to unmarshal %s into %T: %s", info.SubType, unmarshalData, err.Error())
 			}
@@ -1883,7 +1921,7 @@ func computeUnsignedVIntSize(v uint64) int {
 	return (639 - lead0*9) >> 6
 }
 
-func marshalMap(info TypeInfo, value interface{}) ([]byte, error) {
+func marshalMap(codecs *CodecRegistry, info TypeInfo, value interface{}) ([]byte, error) {
 	mapInfo, ok := info.(CollectionType)
 	if !ok {
 		return nil, marshalErrorf("marshal: can not marshal none collection type into map")
@@ -1915,7 +1953,7 @@ func marshalMap(info TypeInfo, value interface{}) ([]byte, error) {
 
 	keys := rv.MapKeys()
 	for _, key := range keys {
-		item, err := Marshal(mapInfo.Key, key.Interface())
+		item, err := marshalCQL(codecs, mapInfo.Key, key.Interface())
 		if err != nil {
 			return nil, err
 		}
@@ -1929,7 +1967,7 @@ func marshalMap(info TypeInfo, value interface{}) ([]byte, error) {
 		}
 		buf.Write(item)
 
-		item, err = Marshal(mapInfo.Elem, rv.MapIndex(key).Interface())
+		item, err = marshalCQL(codecs, mapInfo.Elem, rv.MapIndex(key).Interface())
 		if err != nil {
 			return nil, err
 		}
@@ -1946,7 +1984,7 @@ func marshalMap(info TypeInfo, value interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func unmarshalMap(info TypeInfo, data []byte, value interface{}) error {
+func unmarshalMap(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
 	mapInfo, ok := info.(CollectionType)
 	if !ok {
 		return unmarshalErrorf("unmarshal: can not unmarshal none collection type into map")
@@ -1990,7 +2028,7 @@ func unmarshalMap(info TypeInfo, data []byte, value interface{}) error {
 			unmarshalData = data[:m]
 			data = data[m:]
 		}
-		if err := Unmarshal(mapInfo.Key, unmarshalData, key.Interface()); err != nil {
+		if err := unmarshalCQL(codecs, mapInfo.Key, unmarshalData, key.Interface()); err != nil {
 			return err
 		}
 
@@ -2010,7 +2048,7 @@ func unmarshalMap(info TypeInfo, data []byte, value interface{}) error {
 			unmarshalData = data[:m]
 			data = data[m:]
 		}
-		if err := Unmarshal(mapInfo.Elem, unmarshalData, val.Interface()); err != nil {
+		if err := unmarshalCQL(codecs, mapInfo.Elem, unmarshalData, val.Interface()); err != nil {
 			return err
 		}
 
@@ -2174,7 +2212,7 @@ func unmarshalInet(info TypeInfo, data []byte, value interface{}) error {
 	return unmarshalErrorf("cannot unmarshal %s into %T. Accepted types: Unmarshaler, *net.IP, *string.", info, value)
 }
 
-func marshalTuple(info TypeInfo, value interface{}) ([]byte, error) {
+func marshalTuple(codecs *CodecRegistry, info TypeInfo, value interface{}) ([]byte, error) {
 	tuple := info.(TupleTypeInfo)
 	switch v := value.(type) {
 	case unsetColumn:
@@ -2191,7 +2229,7 @@ func marshalTuple(info TypeInfo, value interface{}) ([]byte, error) {
 				continue
 			}
 
-			data, err := Marshal(tuple.Elems[i], elem)
+			data, err := marshalCQL(codecs, tuple.Elems[i], elem)
 			if err != nil {
 				return nil, err
 			}
@@ -2223,7 +2261,7 @@ func marshalTuple(info TypeInfo, value interface{}) ([]byte, error) {
 				continue
 			}
 
-			data, err := Marshal(elem, field.Interface())
+			data, err := marshalCQL(codecs, elem, field.Interface())
 			if err != nil {
 				return nil, err
 			}
@@ -2249,7 +2287,7 @@ func marshalTuple(info TypeInfo, value interface{}) ([]byte, error) {
 				continue
 			}
 
-			data, err := Marshal(elem, item.Interface())
+			data, err := marshalCQL(codecs, elem, item.Interface())
 			if err != nil {
 				return nil, err
 			}
@@ -2278,7 +2316,7 @@ func readBytes(p []byte) ([]byte, []byte) {
 // currently only support unmarshal into a list of values, this makes it possible
 // to support tuples without changing the query API. In the future this can be extend
 // to allow unmarshalling into custom tuple types.
-func unmarshalTuple(info TypeInfo, data []byte, value interface{}) error {
+func unmarshalTuple(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
 	if v, ok := value.(Unmarshaler); ok {
 		return v.UnmarshalCQL(info, data)
 	}
@@ -2292,7 +2330,7 @@ func unmarshalTuple(info TypeInfo, data []byte, value interface{}) error {
 			if len(data) >= 4 {
 				p, data = readBytes(data)
 			}
-			err := Unmarshal(elem, p, v[i])
+			err := unmarshalCQL(codecs, elem, p, v[i])
 			if err != nil {
 				return err
 			}
@@ -2322,11 +2360,18 @@ func unmarshalTuple(info TypeInfo, data []byte, value interface{}) error {
 				p, data = readBytes(data)
 			}
 
+			if dst := rv.Field(i).Addr().Interface(); codecs.unmarshaler(elem, dst) != nil {
+				if err := unmarshalCQL(codecs, elem, p, dst); err != nil {
+					return err
+				}
+				continue
+			}
+
 			v, err := elem.NewWithError()
 			if err != nil {
 				return err
 			}
-			if err := Unmarshal(elem, p, v); err != nil {
+			if err := unmarshalCQL(codecs, elem, p, v); err != nil {
 				return err
 			}
 
@@ -2359,11 +2404,18 @@ func unmarshalTuple(info TypeInfo, data []byte, value interface{}) error {
 				p, data = readBytes(data)
 			}
 
+			if dst := rv.Index(i).Addr().Interface(); codecs.unmarshaler(elem, dst) != nil {
+				if err := unmarshalCQL(codecs, elem, p, dst); err != nil {
+					return err
+				}
+				continue
+			}
+
 			v, err := elem.NewWithError()
 			if err != nil {
 				return err
 			}
-			if err := Unmarshal(elem, p, v); err != nil {
+			if err := unmarshalCQL(codecs, elem, p, v); err != nil {
 				return err
 			}
 
@@ -2404,7 +2456,7 @@ type UDTUnmarshaler interface {
 	UnmarshalUDT(name string, info TypeInfo, data []byte) error
 }
 
-func marshalUDT(info TypeInfo, value interface{}) ([]byte, error) {
+func marshalUDT(codecs *CodecRegistry, info TypeInfo, value interface{}) ([]byte, error) {
 	udt := info.(UDTTypeInfo)
 
 	switch v := value.(type) {
@@ -2433,7 +2485,7 @@ func marshalUDT(info TypeInfo, value interface{}) ([]byte, error) {
 
 			if ok {
 				var err error
-				data, err = Marshal(e.Type, val)
+				data, err = marshalCQL(codecs, e.Type, val)
 				if err != nil {
 					return nil, err
 				}
@@ -2477,7 +2529,7 @@ func marshalUDT(info TypeInfo, value interface{}) ([]byte, error) {
 		var data []byte
 		if f.IsValid() && f.CanInterface() {
 			var err error
-			data, err = Marshal(e.Type, f.Interface())
+			data, err = marshalCQL(codecs, e.Type, f.Interface())
 			if err != nil {
 				return nil, err
 			}
@@ -2489,7 +2541,7 @@ func marshalUDT(info TypeInfo, value interface{}) ([]byte, error) {
 	return buf, nil
 }
 
-func unmarshalUDT(info TypeInfo, data []byte, value interface{}) error {
+func unmarshalUDT(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
 	switch v := value.(type) {
 	case Unmarshaler:
 		return v.UnmarshalCQL(info, data)
@@ -2550,7 +2602,7 @@ func unmarshalUDT(info TypeInfo, data []byte, value interface{}) error {
 			var p []byte
 			p, data = readBytes(data)
 
-			if err := Unmarshal(e.Type, p, val.Interface()); err != nil {
+			if err := unmarshalCQL(codecs, e.Type, p, val.Interface()); err != nil {
 				return err
 			}
 
@@ -2615,7 +2667,7 @@ func unmarshalUDT(info TypeInfo, data []byte, value interface{}) error {
 		}
 
 		fk := f.Addr().Interface()
-		if err := Unmarshal(e.Type, p, fk); err != nil {
+		if err := unmarshalCQL(codecs, e.Type, p, fk); err != nil {
 			return err
 		}
 	}
@@ -2683,6 +2735,10 @@ type CollectionType struct {
 	NativeType
 	Key  TypeInfo // only used for TypeMap
 	Elem TypeInfo // only used for TypeMap, TypeList and TypeSet
+	// Frozen is set for collections declared as frozen<...> or nested in
+	// another type. Result metadata does not say whether a column is frozen,
+	// there only collections nested in other types are marked.
+	Frozen bool
 }
 
 type VectorType struct {
@@ -2715,6 +2771,8 @@ func (c CollectionType) String() string {
 type TupleTypeInfo struct {
 	NativeType
 	Elems []TypeInfo
+	// Frozen is always set by the driver as tuples are always frozen.
+	Frozen bool
 }
 
 func (t TupleTypeInfo) String() string {
@@ -2746,6 +2804,9 @@ type UDTTypeInfo struct {
 	KeySpace string
 	Name     string
 	Elements []UDTField
+	// Frozen is set for user-defined types declared as frozen<...> or nested
+	// in another type, see CollectionType.Frozen for result metadata.
+	Frozen bool
 }
 
 func (u UDTTypeInfo) NewWithError() (interface{}, error) {
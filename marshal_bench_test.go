@@ -0,0 +1,176 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"testing"
+	"time"
+)
+
+type benchUser struct {
+	Name    string            `cql:"name"`
+	Email   string            `cql:"email"`
+	Age     int               `cql:"age"`
+	Created time.Time         `cql:"created"`
+	Tags    []string          `cql:"tags"`
+	Props   map[string]string `cql:"props"`
+}
+
+// benchRow returns the types and values of a wide row of scalar, collection,
+// tuple and user-defined type columns.
+func benchRow() ([]TypeInfo, []interface{}) {
+	native := func(typ Type) NativeType { return NativeType{proto: protoVersion4, typ: typ} }
+	list := CollectionType{NativeType: native(TypeList), Elem: native(TypeText)}
+	textMap := CollectionType{NativeType: native(TypeMap), Key: native(TypeText), Elem: native(TypeText)}
+	user := UDTTypeInfo{
+		NativeType: native(TypeUDT),
+		KeySpace:   "ks",
+		Name:       "user",
+		Elements: []UDTField{
+			{Name: "name", Type: native(TypeText)},
+			{Name: "email", Type: native(TypeText)},
+			{Name: "age", Type: native(TypeInt)},
+			{Name: "created", Type: native(TypeTimestamp)},
+			{Name: "tags", Type: list},
+			{Name: "props", Type: textMap},
+		},
+	}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	u := benchUser{
+		Name:    "alice",
+		Email:   "alice@example.com",
+		Age:     42,
+		Created: created,
+		Tags:    []string{"a", "b", "c"},
+		Props:   map[string]string{"k": "v"},
+	}
+
+	types := []TypeInfo{
+		native(TypeUUID),
+		native(TypeInt),
+		native(TypeBigInt),
+		native(TypeText),
+		native(TypeTimestamp),
+		native(TypeDouble),
+		list,
+		textMap,
+		CollectionType{NativeType: native(TypeSet), Elem: native(TypeInt)},
+		TupleTypeInfo{NativeType: native(TypeTuple), Elems: []TypeInfo{native(TypeText), native(TypeInt)}},
+		CollectionType{NativeType: native(TypeList), Elem: user},
+		VectorType{NativeType: NativeType{proto: protoVersion4, typ: TypeCustom, custom: "org.apache.cassandra.db.marshal.VectorType"}, SubType: native(TypeFloat), Dimensions: 8},
+	}
+	values := []interface{}{
+		TimeUUID(),
+		42,
+		int64(1) << 40,
+		"some text value",
+		created,
+		3.14,
+		[]string{"a", "b", "c", "d"},
+		map[string]string{"a": "1", "b": "2"},
+		[]int{1, 2, 3, 4, 5},
+		struct {
+			A string
+			B int
+		}{"x", 1},
+		[]benchUser{u, u},
+		[]float32{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	return types, values
+}
+
+func BenchmarkMarshalRow(b *testing.B) {
+	types, values := benchRow()
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, value := range values {
+				if _, err := Marshal(types[j], value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("plan", func(b *testing.B) {
+		plans := make([]columnPlan, len(types))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, value := range values {
+				if _, err := plans[j].marshal(nil, types[j], value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkUnmarshalRow(b *testing.B) {
+	types, values := benchRow()
+	data := make([][]byte, len(values))
+	for i, value := range values {
+		var err error
+		if data[i], err = Marshal(types[i], value); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var (
+		id    UUID
+		n     int
+		big   int64
+		text  string
+		ts    time.Time
+		f     float64
+		list  []string
+		m     map[string]string
+		set   []int
+		tuple struct {
+			A string
+			B int
+		}
+		users  []benchUser
+		vector []float32
+	)
+	dest := []interface{}{&id, &n, &big, &text, &ts, &f, &list, &m, &set, &tuple, &users, &vector}
+
+	b.Run("Unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, p := range data {
+				if err := Unmarshal(types[j], p, dest[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("plan", func(b *testing.B) {
+		plans := make([]columnPlan, len(types))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, p := range data {
+				if err := plans[j].unmarshal(nil, types[j], p, dest[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
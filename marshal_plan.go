@@ -0,0 +1,979 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sync/atomic"
+)
+
+var (
+	marshalerType      = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType    = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	udtMarshalerType   = reflect.TypeOf((*UDTMarshaler)(nil)).Elem()
+	udtUnmarshalerType = reflect.TypeOf((*UDTUnmarshaler)(nil)).Elem()
+	interfaceSliceType = reflect.TypeOf([]interface{}(nil))
+	unsetColumnType    = reflect.TypeOf(unsetColumn{})
+)
+
+// encodePlan marshals values of one Go type into one CQL type. The type
+// switches, struct tag lookups and codec lookups of Marshal are done once
+// when the plan is compiled. Values the plan has no special handling for are
+// marshaled with leaf, which is the built-in function for the CQL type or
+// Marshal itself.
+type encodePlan struct {
+	info TypeInfo
+	leaf func(info TypeInfo, value interface{}) ([]byte, error)
+	enc  func(rv reflect.Value) ([]byte, error)
+}
+
+func (p *encodePlan) encode(value interface{}) ([]byte, error) {
+	if p.enc == nil {
+		return p.leaf(p.info, value)
+	}
+	return p.enc(reflect.ValueOf(value))
+}
+
+func (p *encodePlan) encodeValue(rv reflect.Value) ([]byte, error) {
+	if p.enc == nil {
+		return p.leaf(p.info, rv.Interface())
+	}
+	return p.enc(rv)
+}
+
+// decodePlan unmarshals one CQL type into pointers to one Go type, it is the
+// counterpart of encodePlan for Unmarshal. dec is called with the addressable
+// value pointed to. borrows is set when data may be handed to code outside
+// the driver, codecs and Unmarshalers, which can retain it.
+type decodePlan struct {
+	info    TypeInfo
+	leaf    func(info TypeInfo, data []byte, value interface{}) error
+	dec     func(data []byte, rv reflect.Value) error
+	borrows bool
+}
+
+func (p *decodePlan) decode(data []byte, value interface{}) error {
+	if p.dec == nil {
+		return p.leaf(p.info, data, value)
+	}
+	return p.dec(data, reflect.ValueOf(value).Elem())
+}
+
+func (p *decodePlan) decodeValue(data []byte, rv reflect.Value) error {
+	if p.dec == nil {
+		return p.leaf(p.info, data, rv.Addr().Interface())
+	}
+	return p.dec(data, rv)
+}
+
+// builtinMarshaler returns the function Marshal uses for the scalar CQL
+// type of info, nil for other types.
+func builtinMarshaler(info TypeInfo) func(TypeInfo, interface{}) ([]byte, error) {
+	switch info.Type() {
+	case TypeVarchar, TypeAscii, TypeBlob, TypeText:
+		return marshalVarchar
+	case TypeBoolean:
+		return marshalBool
+	case TypeTinyInt:
+		return marshalTinyInt
+	case TypeSmallInt:
+		return marshalSmallInt
+	case TypeInt:
+		return marshalInt
+	case TypeBigInt, TypeCounter:
+		return marshalBigInt
+	case TypeFloat:
+		return marshalFloat
+	case TypeDouble:
+		return marshalDouble
+	case TypeDecimal:
+		return marshalDecimal
+	case TypeTime:
+		return marshalTime
+	case TypeTimestamp:
+		return marshalTimestamp
+	case TypeUUID, TypeTimeUUID:
+		return marshalUUID
+	case TypeVarint:
+		return marshalVarint
+	case TypeInet:
+		return marshalInet
+	case TypeDate:
+		return marshalDate
+	case TypeDuration:
+		return marshalDuration
+	}
+	return nil
+}
+
+// builtinUnmarshaler returns the function Unmarshal uses for the scalar CQL
+// type of info, nil for other types.
+func builtinUnmarshaler(info TypeInfo) func(TypeInfo, []byte, interface{}) error {
+	switch info.Type() {
+	case TypeVarchar, TypeAscii, TypeBlob, TypeText:
+		return unmarshalVarchar
+	case TypeBoolean:
+		return unmarshalBool
+	case TypeInt:
+		return unmarshalInt
+	case TypeBigInt, TypeCounter:
+		return unmarshalBigInt
+	case TypeVarint:
+		return unmarshalVarint
+	case TypeSmallInt:
+		return unmarshalSmallInt
+	case TypeTinyInt:
+		return unmarshalTinyInt
+	case TypeFloat:
+		return unmarshalFloat
+	case TypeDouble:
+		return unmarshalDouble
+	case TypeDecimal:
+		return unmarshalDecimal
+	case TypeTime:
+		return unmarshalTime
+	case TypeTimestamp:
+		return unmarshalTimestamp
+	case TypeTimeUUID:
+		return unmarshalTimeUUID
+	case TypeUUID:
+		return unmarshalUUID
+	case TypeInet:
+		return unmarshalInet
+	case TypeDate:
+		return unmarshalDate
+	case TypeDuration:
+		return unmarshalDuration
+	}
+	return nil
+}
+
+// compileEncodePlan returns the plan marshaling values of t into info, it
+// behaves as marshalCQL(codecs, info, value).
+func compileEncodePlan(codecs *CodecRegistry, info TypeInfo, t reflect.Type) *encodePlan {
+	p := &encodePlan{info: info, leaf: func(info TypeInfo, value interface{}) ([]byte, error) {
+		return marshalCQL(codecs, info, value)
+	}}
+	if info.Version() < protoVersion1 || t.Kind() == reflect.Interface || t == unsetColumnType || codecs.marshalerFor(info, t) != nil {
+		return p
+	}
+
+	if t.Kind() == reflect.Ptr {
+		if t.Implements(marshalerType) {
+			p.enc = func(rv reflect.Value) ([]byte, error) {
+				if rv.IsNil() {
+					return nil, nil
+				}
+				return rv.Interface().(Marshaler).MarshalCQL(info)
+			}
+			return p
+		}
+		elem := compileEncodePlan(codecs, info, t.Elem())
+		p.enc = func(rv reflect.Value) ([]byte, error) {
+			if rv.IsNil() {
+				return nil, nil
+			}
+			return elem.encodeValue(rv.Elem())
+		}
+		return p
+	}
+
+	if t.Implements(marshalerType) {
+		p.leaf = func(info TypeInfo, value interface{}) ([]byte, error) {
+			return value.(Marshaler).MarshalCQL(info)
+		}
+		return p
+	}
+	if leaf := builtinMarshaler(info); leaf != nil {
+		p.leaf = leaf
+		p.enc = compileScalarEncoder(info, t)
+		return p
+	}
+
+	switch info := info.(type) {
+	case CollectionType:
+		if info.Type() == TypeMap {
+			p.enc = compileMapEncoder(codecs, info, t)
+		} else if info.Type() == TypeList || info.Type() == TypeSet {
+			p.enc = compileListEncoder(codecs, info, t)
+		}
+	case VectorType:
+		p.enc = compileVectorEncoder(codecs, info, t)
+	case TupleTypeInfo:
+		p.enc = compileTupleEncoder(codecs, info, t)
+	case UDTTypeInfo:
+		p.enc = compileUDTEncoder(codecs, info, t)
+	}
+	return p
+}
+
+// compileScalarEncoder returns an encoder for the common Go kinds of scalar
+// CQL types which avoids boxing values, nil for other kinds. It encodes as
+// the built-in marshal function of the CQL type.
+func compileScalarEncoder(info TypeInfo, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	k := t.Kind()
+	signed := k == reflect.Int || k == reflect.Int64 || k == reflect.Int32 || k == reflect.Int16 || k == reflect.Int8
+
+	switch info.Type() {
+	case TypeVarchar, TypeAscii, TypeBlob, TypeText:
+		if k == reflect.String {
+			return func(rv reflect.Value) ([]byte, error) {
+				return []byte(rv.String()), nil
+			}
+		} else if k == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return func(rv reflect.Value) ([]byte, error) {
+				return rv.Bytes(), nil
+			}
+		}
+	case TypeBoolean:
+		if k == reflect.Bool {
+			return func(rv reflect.Value) ([]byte, error) {
+				return encBool(rv.Bool()), nil
+			}
+		}
+	case TypeInt:
+		if signed {
+			return func(rv reflect.Value) ([]byte, error) {
+				v := rv.Int()
+				if v > math.MaxInt32 || v < math.MinInt32 {
+					return nil, marshalErrorf("marshal int: value %d out of range", v)
+				}
+				return encInt(int32(v)), nil
+			}
+		}
+	case TypeBigInt, TypeCounter:
+		if signed {
+			return func(rv reflect.Value) ([]byte, error) {
+				return encBigInt(rv.Int()), nil
+			}
+		}
+	case TypeFloat:
+		if k == reflect.Float32 {
+			return func(rv reflect.Value) ([]byte, error) {
+				return encInt(int32(math.Float32bits(float32(rv.Float())))), nil
+			}
+		}
+	case TypeDouble:
+		if k == reflect.Float64 {
+			return func(rv reflect.Value) ([]byte, error) {
+				return encBigInt(int64(math.Float64bits(rv.Float()))), nil
+			}
+		}
+	case TypeTimestamp:
+		if k == reflect.Int64 {
+			return func(rv reflect.Value) ([]byte, error) {
+				return encBigInt(rv.Int()), nil
+			}
+		}
+	}
+	return nil
+}
+
+// writeCollectionItem writes item prefixed with its size, null items are
+// written with size -1 for supported protocols.
+func writeCollectionItem(info CollectionType, item []byte, buf *bytes.Buffer) error {
+	itemLen := len(item)
+	if item == nil && info.proto > protoVersion2 {
+		itemLen = -1
+	}
+	if err := writeCollectionSize(info, itemLen, buf); err != nil {
+		return err
+	}
+	buf.Write(item)
+	return nil
+}
+
+func compileListEncoder(codecs *CodecRegistry, info CollectionType, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := compileEncodePlan(codecs, info.Elem, t.Elem())
+		return func(rv reflect.Value) ([]byte, error) {
+			if rv.Kind() == reflect.Slice && rv.IsNil() {
+				return nil, nil
+			}
+
+			buf := &bytes.Buffer{}
+			n := rv.Len()
+			if err := writeCollectionSize(info, n, buf); err != nil {
+				return nil, err
+			}
+			for i := 0; i < n; i++ {
+				item, err := elem.encodeValue(rv.Index(i))
+				if err != nil {
+					return nil, err
+				}
+				if err := writeCollectionItem(info, item, buf); err != nil {
+					return nil, err
+				}
+			}
+			return buf.Bytes(), nil
+		}
+	case reflect.Map:
+		if t.Elem().Kind() != reflect.Struct || t.Elem().NumField() != 0 {
+			return nil
+		}
+		key := compileEncodePlan(codecs, info.Elem, t.Key())
+		return func(rv reflect.Value) ([]byte, error) {
+			buf := &bytes.Buffer{}
+			keys := rv.MapKeys()
+			if err := writeCollectionSize(info, len(keys), buf); err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				item, err := key.encodeValue(k)
+				if err != nil {
+					return nil, err
+				}
+				if err := writeCollectionItem(info, item, buf); err != nil {
+					return nil, err
+				}
+			}
+			return buf.Bytes(), nil
+		}
+	}
+	return nil
+}
+
+func compileMapEncoder(codecs *CodecRegistry, info CollectionType, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	if t.Kind() != reflect.Map {
+		return nil
+	}
+	key := compileEncodePlan(codecs, info.Key, t.Key())
+	elem := compileEncodePlan(codecs, info.Elem, t.Elem())
+
+	return func(rv reflect.Value) ([]byte, error) {
+		if rv.IsNil() {
+			return nil, nil
+		}
+
+		buf := &bytes.Buffer{}
+		if err := writeCollectionSize(info, rv.Len(), buf); err != nil {
+			return nil, err
+		}
+		for _, k := range rv.MapKeys() {
+			item, err := key.encodeValue(k)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeCollectionItem(info, item, buf); err != nil {
+				return nil, err
+			}
+
+			item, err = elem.encodeValue(rv.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			if err := writeCollectionItem(info, item, buf); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+func compileVectorEncoder(codecs *CodecRegistry, info VectorType, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil
+	}
+	elem := compileEncodePlan(codecs, info.SubType, t.Elem())
+	variableLength := isVectorVariableLengthType(info.SubType)
+
+	return func(rv reflect.Value) ([]byte, error) {
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+
+		n := rv.Len()
+		if n != info.Dimensions {
+			return nil, marshalErrorf("expected vector with %d dimensions, received %d", info.Dimensions, n)
+		}
+		buf := &bytes.Buffer{}
+		for i := 0; i < n; i++ {
+			item, err := elem.encodeValue(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if variableLength {
+				writeUnsignedVInt(buf, uint64(len(item)))
+			}
+			buf.Write(item)
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+func compileTupleEncoder(codecs *CodecRegistry, info TupleTypeInfo, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	var item func(rv reflect.Value, i int) reflect.Value
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.NumField() != len(info.Elems) || hasUnexportedField(t) {
+			return nil
+		}
+		item = reflect.Value.Field
+	case reflect.Slice, reflect.Array:
+		// []interface{} has its own handling of nil elements
+		if t == interfaceSliceType || (t.Kind() == reflect.Array && t.Len() != len(info.Elems)) {
+			return nil
+		}
+		item = reflect.Value.Index
+	default:
+		return nil
+	}
+
+	elems := make([]*encodePlan, len(info.Elems))
+	for i, elem := range info.Elems {
+		if t.Kind() == reflect.Struct {
+			elems[i] = compileEncodePlan(codecs, elem, t.Field(i).Type)
+		} else {
+			elems[i] = compileEncodePlan(codecs, elem, t.Elem())
+		}
+	}
+
+	return func(rv reflect.Value) ([]byte, error) {
+		if rv.Kind() == reflect.Slice && rv.Len() != len(elems) {
+			return marshalCQL(codecs, info, rv.Interface())
+		}
+
+		var buf []byte
+		for i, elem := range elems {
+			v := item(rv, i)
+			if v.Kind() == reflect.Ptr && v.IsNil() {
+				buf = appendInt(buf, int32(-1))
+				continue
+			}
+
+			data, err := elem.encodeValue(v)
+			if err != nil {
+				return nil, err
+			}
+			buf = appendInt(buf, int32(len(data)))
+			buf = append(buf, data...)
+		}
+		return buf, nil
+	}
+}
+
+// udtFields returns the index of the struct field of t for each element of
+// info, nil if the element has no field. Fields are looked up as in
+// marshalUDT and unmarshalUDT: by cql tag, then by name.
+func udtFields(info UDTTypeInfo, t reflect.Type) [][]int {
+	tags := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("cql"); tag != "" {
+			tags[tag] = []int{i}
+		}
+	}
+
+	fields := make([][]int, len(info.Elements))
+	for i, e := range info.Elements {
+		if index, ok := tags[e.Name]; ok {
+			fields[i] = index
+		} else if sf, ok := t.FieldByName(e.Name); ok {
+			fields[i] = sf.Index
+		}
+	}
+	return fields
+}
+
+func compileUDTEncoder(codecs *CodecRegistry, info UDTTypeInfo, t reflect.Type) func(reflect.Value) ([]byte, error) {
+	if t.Kind() != reflect.Struct || t.Implements(udtMarshalerType) {
+		return nil
+	}
+
+	fields := udtFields(info, t)
+	elems := make([]*encodePlan, len(info.Elements))
+	for i, e := range info.Elements {
+		if fields[i] != nil {
+			elems[i] = compileEncodePlan(codecs, e.Type, t.FieldByIndex(fields[i]).Type)
+		}
+	}
+
+	return func(rv reflect.Value) ([]byte, error) {
+		var buf []byte
+		for i, elem := range elems {
+			var data []byte
+			if elem != nil {
+				if f := rv.FieldByIndex(fields[i]); f.CanInterface() {
+					var err error
+					data, err = elem.encodeValue(f)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			buf = appendBytes(buf, data)
+		}
+		return buf, nil
+	}
+}
+
+// compileDecodePlan returns the plan unmarshaling info into pointers to t, it
+// behaves as unmarshalCQL(codecs, info, data, value).
+func compileDecodePlan(codecs *CodecRegistry, info TypeInfo, t reflect.Type) *decodePlan {
+	p := &decodePlan{info: info, leaf: func(info TypeInfo, data []byte, value interface{}) error {
+		return unmarshalCQL(codecs, info, data, value)
+	}, borrows: true}
+	if codecs.unmarshalerFor(info, t) != nil {
+		return p
+	}
+
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		p.leaf = func(info TypeInfo, data []byte, value interface{}) error {
+			return value.(Unmarshaler).UnmarshalCQL(info, data)
+		}
+		return p
+	}
+
+	if t.Kind() == reflect.Ptr {
+		elem := compileDecodePlan(codecs, info, t.Elem())
+		p.dec = func(data []byte, rv reflect.Value) error {
+			if data == nil {
+				rv.Set(reflect.Zero(t))
+				return nil
+			}
+			v := reflect.New(t.Elem())
+			rv.Set(v)
+			return elem.decodeValue(data, v.Elem())
+		}
+		p.borrows = elem.borrows
+		return p
+	}
+
+	if leaf := builtinUnmarshaler(info); leaf != nil {
+		p.leaf = leaf
+		p.borrows = false
+		return p
+	}
+
+	var borrows bool
+	switch info := info.(type) {
+	case CollectionType:
+		if info.Type() == TypeMap {
+			p.dec, borrows = compileMapDecoder(codecs, info, t)
+		} else if info.Type() == TypeList || info.Type() == TypeSet {
+			p.dec, borrows = compileListDecoder(codecs, info, t)
+		}
+	case VectorType:
+		p.dec, borrows = compileVectorDecoder(codecs, info, t)
+	case TupleTypeInfo:
+		p.dec, borrows = compileTupleDecoder(codecs, info, t)
+	case UDTTypeInfo:
+		p.dec, borrows = compileUDTDecoder(codecs, info, t)
+	}
+	if p.dec != nil {
+		p.borrows = borrows
+	}
+	return p
+}
+
+// readCollectionItem returns the next item of data and the remaining data,
+// the item is nil if it is null.
+func readCollectionItem(info CollectionType, data []byte, eof string) (item, rest []byte, err error) {
+	m, p, err := readCollectionSize(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	data = data[p:]
+	if m < 0 {
+		return nil, data, nil
+	}
+	if len(data) < m {
+		return nil, nil, unmarshalErrorf(eof)
+	}
+	return data[:m], data[m:], nil
+}
+
+func compileListDecoder(codecs *CodecRegistry, info CollectionType, t reflect.Type) (func([]byte, reflect.Value) error, bool) {
+	k := t.Kind()
+	if k != reflect.Slice && k != reflect.Array {
+		return nil, false
+	}
+	elem := compileDecodePlan(codecs, info.Elem, t.Elem())
+
+	return func(data []byte, rv reflect.Value) error {
+		if data == nil {
+			if k == reflect.Array {
+				return unmarshalErrorf("unmarshal list: can not store nil in array value")
+			}
+			if rv.IsNil() {
+				return nil
+			}
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		n, p, err := readCollectionSize(info, data)
+		if err != nil {
+			return err
+		}
+		data = data[p:]
+		if k == reflect.Array {
+			if rv.Len() != n {
+				return unmarshalErrorf("unmarshal list: array with wrong size")
+			}
+		} else {
+			rv.Set(reflect.MakeSlice(t, n, n))
+		}
+		for i := 0; i < n; i++ {
+			var item []byte
+			item, data, err = readCollectionItem(info, data, "unmarshal list: unexpected eof")
+			if err != nil {
+				return err
+			}
+			if err := elem.decodeValue(item, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, elem.borrows
+}
+
+func compileMapDecoder(codecs *CodecRegistry, info CollectionType, t reflect.Type) (func([]byte, reflect.Value) error, bool) {
+	if t.Kind() != reflect.Map {
+		return nil, false
+	}
+	keyType, elemType := t.Key(), t.Elem()
+	key := compileDecodePlan(codecs, info.Key, keyType)
+	elem := compileDecodePlan(codecs, info.Elem, elemType)
+
+	return func(data []byte, rv reflect.Value) error {
+		if data == nil {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		n, p, err := readCollectionSize(info, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return unmarshalErrorf("negative map size %d", n)
+		}
+		rv.Set(reflect.MakeMapWithSize(t, n))
+		data = data[p:]
+		for i := 0; i < n; i++ {
+			var item []byte
+			item, data, err = readCollectionItem(info, data, "unmarshal map: unexpected eof")
+			if err != nil {
+				return err
+			}
+			k := reflect.New(keyType).Elem()
+			if err := key.decodeValue(item, k); err != nil {
+				return err
+			}
+
+			item, data, err = readCollectionItem(info, data, "unmarshal map: unexpected eof")
+			if err != nil {
+				return err
+			}
+			v := reflect.New(elemType).Elem()
+			if err := elem.decodeValue(item, v); err != nil {
+				return err
+			}
+
+			rv.SetMapIndex(k, v)
+		}
+		return nil
+	}, key.borrows || elem.borrows
+}
+
+func compileVectorDecoder(codecs *CodecRegistry, info VectorType, t reflect.Type) (func([]byte, reflect.Value) error, bool) {
+	k := t.Kind()
+	if k != reflect.Slice && k != reflect.Array {
+		return nil, false
+	}
+	elem := compileDecodePlan(codecs, info.SubType, t.Elem())
+	variableLength := isVectorVariableLengthType(info.SubType)
+
+	return func(data []byte, rv reflect.Value) error {
+		if data == nil {
+			if k == reflect.Array {
+				return unmarshalErrorf("unmarshal vector: can not store nil in array value")
+			}
+			if rv.IsNil() {
+				return nil
+			}
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		if k == reflect.Array {
+			if rv.Len() != info.Dimensions {
+				return unmarshalErrorf("unmarshal vector: array of size %d cannot store vector of %d dimensions", rv.Len(), info.Dimensions)
+			}
+		} else {
+			rv.Set(reflect.MakeSlice(t, info.Dimensions, info.Dimensions))
+		}
+		elemSize := len(data) / info.Dimensions
+		for i := 0; i < info.Dimensions; i++ {
+			if variableLength {
+				m, p, err := readUnsignedVInt(data)
+				if err != nil {
+					return err
+				}
+				elemSize = int(m)
+				data = data[p:]
+			}
+			var item []byte
+			if elemSize >= 0 {
+				if len(data) < elemSize {
+					return unmarshalErrorf("unmarshal vector: unexpected eof")
+				}
+				item = data[:elemSize]
+				data = data[elemSize:]
+			}
+			if err := elem.decodeValue(item, rv.Index(i)); err != nil {
+				return unmarshalErrorf("failed to unmarshal %s into %T: %s", info.SubType, item, err.Error())
+			}
+		}
+		return nil
+	}, elem.borrows
+}
+
+// tupleElemDecoder unmarshals one tuple element into a struct field or
+// slice element the way unmarshalTuple does: into a new value of the Go type
+// of the element which is then stored, unless a codec is registered for the
+// type of the destination.
+type tupleElemDecoder struct {
+	direct  *decodePlan
+	natural reflect.Type
+	plan    *decodePlan
+}
+
+func compileTupleElemDecoder(codecs *CodecRegistry, info TypeInfo, dst reflect.Type) (tupleElemDecoder, bool) {
+	if codecs.unmarshalerFor(info, dst) != nil {
+		return tupleElemDecoder{direct: compileDecodePlan(codecs, info, dst)}, true
+	}
+	v, err := info.NewWithError()
+	if err != nil {
+		return tupleElemDecoder{}, false
+	}
+	natural := reflect.TypeOf(v).Elem()
+	return tupleElemDecoder{natural: natural, plan: compileDecodePlan(codecs, info, natural)}, true
+}
+
+func (d tupleElemDecoder) borrows() bool {
+	if d.direct != nil {
+		return d.direct.borrows
+	}
+	return d.plan.borrows
+}
+
+func (d tupleElemDecoder) decode(data []byte, dst reflect.Value) error {
+	if d.direct != nil {
+		return d.direct.decodeValue(data, dst)
+	}
+
+	v := reflect.New(d.natural)
+	if err := d.plan.decodeValue(data, v.Elem()); err != nil {
+		return err
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if data != nil {
+			dst.Set(v)
+		} else {
+			dst.Set(reflect.Zero(v.Type()))
+		}
+	default:
+		dst.Set(v.Elem())
+	}
+	return nil
+}
+
+func compileTupleDecoder(codecs *CodecRegistry, info TupleTypeInfo, t reflect.Type) (func([]byte, reflect.Value) error, bool) {
+	k := t.Kind()
+	switch k {
+	case reflect.Struct:
+		if t.NumField() != len(info.Elems) || hasUnexportedField(t) {
+			return nil, false
+		}
+	case reflect.Slice, reflect.Array:
+		if k == reflect.Array && t.Len() != len(info.Elems) {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	var borrows bool
+	elems := make([]tupleElemDecoder, len(info.Elems))
+	for i, elem := range info.Elems {
+		var dst reflect.Type
+		if k == reflect.Struct {
+			dst = t.Field(i).Type
+		} else {
+			dst = t.Elem()
+		}
+
+		var ok bool
+		if elems[i], ok = compileTupleElemDecoder(codecs, elem, dst); !ok {
+			return nil, false
+		}
+		borrows = borrows || elems[i].borrows()
+	}
+
+	return func(data []byte, rv reflect.Value) error {
+		if k == reflect.Slice {
+			rv.Set(reflect.MakeSlice(t, len(elems), len(elems)))
+		}
+		for i, elem := range elems {
+			var p []byte
+			if len(data) >= 4 {
+				p, data = readBytes(data)
+			}
+
+			dst := rv.Index
+			if k == reflect.Struct {
+				dst = rv.Field
+			}
+			if err := elem.decode(p, dst(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, borrows
+}
+
+func compileUDTDecoder(codecs *CodecRegistry, info UDTTypeInfo, t reflect.Type) (func([]byte, reflect.Value) error, bool) {
+	if t.Kind() != reflect.Struct || reflect.PtrTo(t).Implements(udtUnmarshalerType) {
+		return nil, false
+	}
+
+	var borrows bool
+	fields := udtFields(info, t)
+	elems := make([]*decodePlan, len(info.Elements))
+	for i, e := range info.Elements {
+		if fields[i] == nil {
+			continue
+		}
+		sf := t.FieldByIndex(fields[i])
+		if sf.PkgPath != "" {
+			// unexported fields are rejected by unmarshalUDT
+			return nil, false
+		}
+		elems[i] = compileDecodePlan(codecs, e.Type, sf.Type)
+		borrows = borrows || elems[i].borrows
+	}
+
+	return func(data []byte, rv reflect.Value) error {
+		if len(data) == 0 {
+			if rv.CanSet() {
+				rv.Set(reflect.Zero(t))
+			}
+			return nil
+		}
+
+		for i, elem := range elems {
+			if len(data) == 0 {
+				return nil
+			}
+			if len(data) < 4 {
+				return unmarshalErrorf("can not unmarshal %s: field [%d]%s: unexpected eof", info, i, info.Elements[i].Name)
+			}
+
+			var p []byte
+			p, data = readBytes(data)
+			if elem == nil {
+				// skip fields which exist in the UDT but not in the struct
+				continue
+			}
+			if err := elem.decodeValue(p, rv.FieldByIndex(fields[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, borrows
+}
+
+func hasUnexportedField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// codecGeneration is incremented whenever a codec is registered or removed
+// in any CodecRegistry, invalidating compiled plans.
+var codecGeneration uint32
+
+// columnPlan caches the plans of a column for the Go type of the last value
+// marshaled into or unmarshaled from it.
+type columnPlan struct {
+	enc atomic.Value // *cachedPlan
+	dec atomic.Value // *cachedPlan
+}
+
+type cachedPlan struct {
+	goType reflect.Type
+	codecs *CodecRegistry
+	gen    uint32
+	enc    *encodePlan
+	dec    *decodePlan
+}
+
+func (c *cachedPlan) valid(goType reflect.Type, codecs *CodecRegistry, gen uint32) bool {
+	return c != nil && c.goType == goType && c.codecs == codecs && c.gen == gen
+}
+
+// marshal is marshalCQL(codecs, info, value) using the cached plan, c may
+// be nil.
+func (c *columnPlan) marshal(codecs *CodecRegistry, info TypeInfo, value interface{}) ([]byte, error) {
+	if c == nil || value == nil {
+		return marshalCQL(codecs, info, value)
+	}
+
+	goType := reflect.TypeOf(value)
+	gen := atomic.LoadUint32(&codecGeneration)
+	plan, _ := c.enc.Load().(*cachedPlan)
+	if !plan.valid(goType, codecs, gen) {
+		plan = &cachedPlan{goType: goType, codecs: codecs, gen: gen, enc: compileEncodePlan(codecs, info, goType)}
+		c.enc.Store(plan)
+	}
+	return plan.enc.encode(value)
+}
+
+// unmarshal is unmarshalCQL(codecs, info, data, value) using the cached
+// plan, c may be nil.
+func (c *columnPlan) unmarshal(codecs *CodecRegistry, info TypeInfo, data []byte, value interface{}) error {
+	goType := reflect.TypeOf(value)
+	if c == nil || goType == nil || goType.Kind() != reflect.Ptr || reflect.ValueOf(value).IsNil() {
+		return unmarshalCQL(codecs, info, data, value)
+	}
+
+	gen := atomic.LoadUint32(&codecGeneration)
+	plan, _ := c.dec.Load().(*cachedPlan)
+	if !plan.valid(goType, codecs, gen) {
+		plan = &cachedPlan{goType: goType, codecs: codecs, gen: gen, dec: compileDecodePlan(codecs, info, goType.Elem())}
+		c.dec.Store(plan)
+	}
+	return plan.dec.decode(data, value)
+}
+
+// borrows reports whether unmarshaling data into value may have retained it,
+// it is true unless value was unmarshaled with a cached plan which copies
+// data. c may be nil.
+func (c *columnPlan) borrows(codecs *CodecRegistry, value interface{}) bool {
+	if c == nil {
+		return true
+	}
+	plan, _ := c.dec.Load().(*cachedPlan)
+	return !plan.valid(reflect.TypeOf(value), codecs, atomic.LoadUint32(&codecGeneration)) || plan.dec.borrows
+}
@@ -0,0 +1,258 @@
+//go:build all || unit
+// +build all unit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalPlanEncode(t *testing.T) {
+	for i, test := range marshalTests {
+		var plan columnPlan
+		// the second round uses the cached plan
+		for round := 0; round < 2; round++ {
+			data, err := plan.marshal(nil, test.Info, test.Value)
+			if test.MarshalError != nil {
+				if err != test.MarshalError {
+					t.Errorf("marshalTest[%d] (%v=>%T): returned error %#v, want %#v", i, test.Info, test.Value, err, test.MarshalError)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("marshalTest[%d] (%v=>%T): %v", i, test.Info, test.Value, err)
+				continue
+			}
+			if !bytes.Equal(data, test.Data) {
+				t.Errorf("marshalTest[%d] (%v=>%T): expected %q, got %q", i, test.Info, test.Value, test.Data, data)
+			}
+		}
+	}
+}
+
+func TestMarshalPlanDecode(t *testing.T) {
+	type decodeTest struct {
+		Info           TypeInfo
+		Data           []byte
+		Value          interface{}
+		UnmarshalError error
+		// byValue passes Value instead of a pointer to a new value when
+		// UnmarshalError is set, as TestMarshal_Decode does.
+		byValue bool
+	}
+	tests := make([]decodeTest, 0, len(marshalTests)+len(unmarshalTests))
+	for _, test := range marshalTests {
+		tests = append(tests, decodeTest{test.Info, test.Data, test.Value, test.UnmarshalError, true})
+	}
+	for _, test := range unmarshalTests {
+		tests = append(tests, decodeTest{test.Info, test.Data, test.Value, test.UnmarshalError, false})
+	}
+
+	for i, test := range tests {
+		var plan columnPlan
+		// the second round uses the cached plan
+		for round := 0; round < 2; round++ {
+			if test.UnmarshalError != nil {
+				dest, expectedDest := test.Value, test.Value
+				if !test.byValue {
+					dest = reflect.New(reflect.TypeOf(test.Value)).Interface()
+					expectedDest = reflect.New(reflect.TypeOf(test.Value)).Interface()
+				}
+				err := plan.unmarshal(nil, test.Info, test.Data, dest)
+				if expected := Unmarshal(test.Info, test.Data, expectedDest); !reflect.DeepEqual(err, expected) {
+					t.Errorf("decodeTest[%d] (%v=>%T): returned error %#v, want %#v", i, test.Info, test.Value, err, expected)
+				}
+				continue
+			}
+
+			v := reflect.New(reflect.TypeOf(test.Value))
+			if err := plan.unmarshal(nil, test.Info, test.Data, v.Interface()); err != nil {
+				t.Errorf("decodeTest[%d] (%v=>%T): %v", i, test.Info, test.Value, err)
+				continue
+			}
+			if !reflect.DeepEqual(v.Elem().Interface(), test.Value) {
+				t.Errorf("decodeTest[%d] (%v=>%T): expected %#v, got %#v", i, test.Info, test.Value, test.Value, v.Elem().Interface())
+			}
+		}
+	}
+}
+
+type planTestItem struct {
+	Name  string   `cql:"name"`
+	Price *int64   `cql:"price"`
+	Tags  []string `cql:"tags"`
+	Extra int
+}
+
+func TestMarshalPlanNested(t *testing.T) {
+	text := NativeType{proto: protoVersion4, typ: TypeText}
+	bigint := NativeType{proto: protoVersion4, typ: TypeBigInt}
+	item := UDTTypeInfo{
+		NativeType: NativeType{proto: protoVersion4, typ: TypeUDT},
+		Name:       "item",
+		Elements: []UDTField{
+			{Name: "name", Type: text},
+			{Name: "price", Type: bigint},
+			{Name: "tags", Type: CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeList}, Elem: text}},
+			{Name: "missing", Type: text},
+		},
+	}
+	price := int64(100)
+
+	tests := []struct {
+		name  string
+		info  TypeInfo
+		value interface{}
+	}{
+		{
+			name:  "map of lists of udts",
+			info:  CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeMap}, Key: text, Elem: CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeList}, Elem: item}},
+			value: map[string][]planTestItem{"a": {{Name: "x", Price: &price, Tags: []string{"t"}}, {Name: "y"}}, "b": nil},
+		},
+		{
+			name:  "set from map keys",
+			info:  CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeSet}, Elem: text},
+			value: map[string]struct{}{"a": {}},
+		},
+		{
+			name: "tuple struct",
+			info: TupleTypeInfo{NativeType: NativeType{proto: protoVersion4, typ: TypeTuple}, Elems: []TypeInfo{text, bigint, item.Elements[2].Type}},
+			value: struct {
+				A string
+				B *int64
+				C []string
+			}{"a", nil, []string{"x"}},
+		},
+		{
+			name:  "tuple slice",
+			info:  TupleTypeInfo{NativeType: NativeType{proto: protoVersion4, typ: TypeTuple}, Elems: []TypeInfo{text, text}},
+			value: []string{"a", "b"},
+		},
+		{
+			name:  "vector of lists",
+			info:  VectorType{NativeType: NativeType{proto: protoVersion4, typ: TypeCustom, custom: "org.apache.cassandra.db.marshal.VectorType"}, SubType: CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeList}, Elem: bigint}, Dimensions: 2},
+			value: [][]int64{{1, 2}, {3}},
+		},
+		{
+			name:  "pointer to udt",
+			info:  item,
+			value: &planTestItem{Name: "x", Price: &price},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var plan columnPlan
+			expected, err := Marshal(test.info, test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := plan.marshal(nil, test.info, test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// map iteration order is random, compare single entry maps only
+			if reflect.TypeOf(test.value).Kind() != reflect.Map && !bytes.Equal(data, expected) {
+				t.Fatalf("expected %x, got %x", expected, data)
+			}
+
+			got := reflect.New(reflect.TypeOf(test.value))
+			err = plan.unmarshal(nil, test.info, data, got.Interface())
+			want := reflect.New(reflect.TypeOf(test.value))
+			if expected := Unmarshal(test.info, data, want.Interface()); !reflect.DeepEqual(err, expected) {
+				t.Fatalf("returned error %v, want %v", err, expected)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), want.Elem().Interface()) {
+				t.Fatalf("expected %#v, got %#v", want.Elem().Interface(), got.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestMarshalPlanCodecs(t *testing.T) {
+	bigint := NativeType{proto: protoVersion4, typ: TypeBigInt}
+	list := CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeList}, Elem: bigint}
+	var plan columnPlan
+
+	if _, err := plan.marshal(nil, list, []testMoney{{1}}); err == nil {
+		t.Fatal("expected an error without codec")
+	}
+
+	RegisterCodec(TypeBigInt, testMoneyType, testMoneyCodec)
+	defer globalCodecs.Unregister(TypeBigInt, testMoneyType)
+
+	// registering the codec invalidates the plan
+	data, err := plan.marshal(nil, list, []testMoney{{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []testMoney
+	if err := plan.unmarshal(nil, list, data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []testMoney{{1}}) {
+		t.Fatalf("expected [{1}], got %v", got)
+	}
+}
+
+func TestMarshalPlanBorrows(t *testing.T) {
+	bigint := NativeType{proto: protoVersion4, typ: TypeBigInt}
+	text := NativeType{proto: protoVersion4, typ: TypeVarchar}
+	list := CollectionType{NativeType: NativeType{proto: protoVersion4, typ: TypeList}, Elem: bigint}
+	codecs := NewCodecRegistry()
+	codecs.Register(TypeBigInt, testMoneyType, testMoneyCodec)
+
+	tests := []struct {
+		info    TypeInfo
+		t       reflect.Type
+		borrows bool
+	}{
+		{text, reflect.TypeOf([]byte(nil)), false},
+		{text, reflect.TypeOf(""), false},
+		{text, reflect.TypeOf((*string)(nil)), false},
+		{text, reflect.TypeOf(CustomString("")), true},
+		{list, reflect.TypeOf([]int64(nil)), false},
+		{list, reflect.TypeOf([]*int64(nil)), false},
+		{list, reflect.TypeOf([]testMoney(nil)), true},
+		{bigint, testMoneyType, true},
+		// Unmarshal handles types the plan can't compile
+		{list, reflect.TypeOf(map[string]int(nil)), true},
+	}
+	for _, test := range tests {
+		if plan := compileDecodePlan(codecs, test.info, test.t); plan.borrows != test.borrows {
+			t.Errorf("%s into %v: expected borrows %v", test.info, test.t, test.borrows)
+		}
+	}
+
+	var plan columnPlan
+	var got []int64
+	if !plan.borrows(nil, &got) {
+		t.Fatal("expected values without cached plan to borrow")
+	}
+	if err := plan.unmarshal(nil, list, []byte{0, 0, 0, 0}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if plan.borrows(nil, &got) {
+		t.Fatal("expected the cached plan not to borrow")
+	}
+}
@@ -404,6 +404,36 @@ var marshalTests = []struct {
 		nil,
 		nil,
 	},
+	{
+		NativeType{proto: 4, typ: TypeCustom, custom: POINT_TYPE},
+		[]byte("\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\xf0\x3f\x00\x00\x00\x00\x00\x00\x00\x40"),
+		Point{X: 1, Y: 2},
+		nil,
+		nil,
+	},
+	{
+		NativeType{proto: 4, typ: TypeCustom, custom: POINT_TYPE},
+		[]byte("\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\xf0\x3f\x00\x00\x00\x00\x00\x00\x00\x40"),
+		"POINT (1 2)",
+		nil,
+		nil,
+	},
+	{
+		NativeType{proto: 4, typ: TypeCustom, custom: LINESTRING_TYPE},
+		[]byte("\x01\x02\x00\x00\x00\x02\x00\x00\x00" +
+			"\x00\x00\x00\x00\x00\x00\xf0\x3f\x00\x00\x00\x00\x00\x00\x00\x40" +
+			"\x00\x00\x00\x00\x00\x00\x08\x40\x00\x00\x00\x00\x00\x00\x10\x40"),
+		LineString{Points: []Point{{1, 2}, {3, 4}}},
+		nil,
+		nil,
+	},
+	{
+		NativeType{proto: 4, typ: TypeCustom, custom: POLYGON_TYPE},
+		[]byte("\x01\x03\x00\x00\x00\x00\x00\x00\x00"),
+		Polygon{},
+		nil,
+		nil,
+	},
 	{
 		CollectionType{
 			NativeType: NativeType{proto: 2, typ: TypeList},
@@ -2081,7 +2111,7 @@ func TestUnmarshalTuple(t *testing.T) {
 }
 
 func TestMarshalUDTMap(t *testing.T) {
-	typeInfo := UDTTypeInfo{NativeType{proto: 3, typ: TypeUDT}, "", "xyz", []UDTField{
+	typeInfo := UDTTypeInfo{NativeType: NativeType{proto: 3, typ: TypeUDT}, Name: "xyz", Elements: []UDTField{
 		{Name: "x", Type: NativeType{proto: 3, typ: TypeInt}},
 		{Name: "y", Type: NativeType{proto: 3, typ: TypeInt}},
 		{Name: "z", Type: NativeType{proto: 3, typ: TypeInt}},
@@ -2136,7 +2166,7 @@ func TestMarshalUDTMap(t *testing.T) {
 }
 
 func TestMarshalUDTStruct(t *testing.T) {
-	typeInfo := UDTTypeInfo{NativeType{proto: 3, typ: TypeUDT}, "", "xyz", []UDTField{
+	typeInfo := UDTTypeInfo{NativeType: NativeType{proto: 3, typ: TypeUDT}, Name: "xyz", Elements: []UDTField{
 		{Name: "x", Type: NativeType{proto: 3, typ: TypeInt}},
 		{Name: "y", Type: NativeType{proto: 3, typ: TypeInt}},
 		{Name: "z", Type: NativeType{proto: 3, typ: TypeInt}},
@@ -305,7 +305,7 @@ func (s *schemaDescriber) refreshSchema(keyspaceName string) error {
 	}
 
 	// organize the schema data
-	compileMetadata(s.session.cfg.ProtoVersion, keyspace, tables, columns, functions, aggregates, userTypes,
+	compileMetadata(s.session.ProtocolVersion(), keyspace, tables, columns, functions, aggregates, userTypes,
 		materializedViews, s.session.logger)
 
 	// update the cache
@@ -647,7 +647,7 @@ func getTableMetadata(session *Session, keyspaceName string) ([]TableMetadata, e
 			}
 			return r
 		}
-	} else if session.cfg.ProtoVersion == protoVersion1 {
+	} else if session.ProtocolVersion() == protoVersion1 {
 		// we have key aliases
 		stmt = `
 		SELECT
@@ -910,7 +910,7 @@ func getColumnMetadata(session *Session, keyspaceName string) ([]ColumnMetadata,
 	)
 
 	// Deal with differences in protocol versions
-	if session.cfg.ProtoVersion == 1 {
+	if session.ProtocolVersion() == 1 {
 		columns, err = session.scanColumnMetadataV1(keyspaceName)
 	} else if session.useSystemSchema { // Cassandra 3.x+
 		columns, err = session.scanColumnMetadataSystem(keyspaceName)
@@ -933,7 +933,8 @@ func getTypeInfo(t string, protoVer byte, logger StdLogger) TypeInfo {
 }
 
 func getUserTypeMetadata(session *Session, keyspaceName string) ([]UserTypeMetadata, error) {
-	if session.cfg.ProtoVersion == protoVersion1 {
+	proto := session.ProtocolVersion()
+	if proto == protoVersion1 {
 		return nil, nil
 	}
 	var tableName string
@@ -965,7 +966,7 @@ func getUserTypeMetadata(session *Session, keyspaceName string) ([]UserTypeMetad
 		}
 		uType.FieldTypes = make([]TypeInfo, len(argumentTypes))
 		for i, argumentType := range argumentTypes {
-			uType.FieldTypes[i] = getTypeInfo(argumentType, byte(session.cfg.ProtoVersion), session.logger)
+			uType.FieldTypes[i] = getTypeInfo(argumentType, byte(proto), session.logger)
 		}
 		uTypes = append(uTypes, uType)
 	}
@@ -1179,7 +1180,8 @@ func getMaterializedViewsMetadata(session *Session, keyspaceName string) ([]Mate
 }
 
 func getFunctionsMetadata(session *Session, keyspaceName string) ([]FunctionMetadata, error) {
-	if session.cfg.ProtoVersion == protoVersion1 || !session.hasAggregatesAndFunctions {
+	proto := session.ProtocolVersion()
+	if proto == protoVersion1 || !session.hasAggregatesAndFunctions {
 		return nil, nil
 	}
 	var tableName string
@@ -1218,10 +1220,10 @@ func getFunctionsMetadata(session *Session, keyspaceName string) ([]FunctionMeta
 		if err != nil {
 			return nil, err
 		}
-		function.ReturnType = getTypeInfo(returnType, byte(session.cfg.ProtoVersion), session.logger)
+		function.ReturnType = getTypeInfo(returnType, byte(proto), session.logger)
 		function.ArgumentTypes = make([]TypeInfo, len(argumentTypes))
 		for i, argumentType := range argumentTypes {
-			function.ArgumentTypes[i] = getTypeInfo(argumentType, byte(session.cfg.ProtoVersion), session.logger)
+			function.ArgumentTypes[i] = getTypeInfo(argumentType, byte(proto), session.logger)
 		}
 		functions = append(functions, function)
 	}
@@ -1234,7 +1236,8 @@ func getFunctionsMetadata(session *Session, keyspaceName string) ([]FunctionMeta
 }
 
 func getAggregatesMetadata(session *Session, keyspaceName string) ([]AggregateMetadata, error) {
-	if session.cfg.ProtoVersion == protoVersion1 || !session.hasAggregatesAndFunctions {
+	proto := session.ProtocolVersion()
+	if proto == protoVersion1 || !session.hasAggregatesAndFunctions {
 		return nil, nil
 	}
 	var tableName string
@@ -1275,11 +1278,11 @@ func getAggregatesMetadata(session *Session, keyspaceName string) ([]AggregateMe
 		if err != nil {
 			return nil, err
 		}
-		aggregate.ReturnType = getTypeInfo(returnType, byte(session.cfg.ProtoVersion), session.logger)
-		aggregate.StateType = getTypeInfo(stateType, byte(session.cfg.ProtoVersion), session.logger)
+		aggregate.ReturnType = getTypeInfo(returnType, byte(proto), session.logger)
+		aggregate.StateType = getTypeInfo(stateType, byte(proto), session.logger)
 		aggregate.ArgumentTypes = make([]TypeInfo, len(argumentTypes))
 		for i, argumentType := range argumentTypes {
-			aggregate.ArgumentTypes[i] = getTypeInfo(argumentType, byte(session.cfg.ProtoVersion), session.logger)
+			aggregate.ArgumentTypes[i] = getTypeInfo(argumentType, byte(proto), session.logger)
 		}
 		aggregates = append(aggregates, aggregate)
 	}
@@ -1323,6 +1326,7 @@ const (
 	UDT_TYPE        = "org.apache.cassandra.db.marshal.UserType"
 	TUPLE_TYPE      = "org.apache.cassandra.db.marshal.TupleType"
 	VECTOR_TYPE     = "org.apache.cassandra.db.marshal.VectorType"
+	FROZEN_TYPE     = "org.apache.cassandra.db.marshal.FrozenType"
 )
 
 // represents a class specification in the type def AST
@@ -1385,7 +1389,7 @@ func (t *typeParser) parse() typeParserResult {
 				} else {
 					name = string(decoded)
 				}
-				collections[name] = param.class.asTypeInfo()
+				collections[name] = param.class.asTypeInfo(t.logger)
 			}
 		}
 
@@ -1398,7 +1402,7 @@ func (t *typeParser) parse() typeParserResult {
 			if reversed[i] {
 				class = class.params[0].class
 			}
-			types[i] = class.asTypeInfo()
+			types[i] = class.asTypeInfo(t.logger)
 		}
 
 		return typeParserResult{
@@ -1414,7 +1418,7 @@ func (t *typeParser) parse() typeParserResult {
 		if reversed {
 			class = class.params[0].class
 		}
-		typeInfo := class.asTypeInfo()
+		typeInfo := class.asTypeInfo(t.logger)
 
 		return typeParserResult{
 			isComposite: false,
@@ -1424,45 +1428,13 @@ func (t *typeParser) parse() typeParserResult {
 	}
 }
 
-func (class *typeParserClassNode) asTypeInfo() TypeInfo {
-	if strings.HasPrefix(class.name, LIST_TYPE) {
-		elem := class.params[0].class.asTypeInfo()
-		return CollectionType{
-			NativeType: NativeType{
-				typ:   TypeList,
-				proto: class.proto,
-			},
-			Elem: elem,
-		}
-	}
-	if strings.HasPrefix(class.name, SET_TYPE) {
-		elem := class.params[0].class.asTypeInfo()
-		return CollectionType{
-			NativeType: NativeType{
-				typ:   TypeSet,
-				proto: class.proto,
-			},
-			Elem: elem,
-		}
-	}
-	if strings.HasPrefix(class.name, MAP_TYPE) {
-		key := class.params[0].class.asTypeInfo()
-		elem := class.params[1].class.asTypeInfo()
-		return CollectionType{
-			NativeType: NativeType{
-				typ:   TypeMap,
-				proto: class.proto,
-			},
-			Key:  key,
-			Elem: elem,
-		}
-	}
-
-	// must be a simple type or custom type
-	info := NativeType{typ: getApacheCassandraType(class.name), proto: class.proto}
-	if info.typ == TypeCustom {
-		// add the entire class definition
-		info.custom = class.input
+// asTypeInfo returns the TypeInfo of the class, see ParseCQLTypeClass. Invalid
+// classes are logged and treated as custom types.
+func (class *typeParserClassNode) asTypeInfo(logger StdLogger) TypeInfo {
+	info, err := class.typeInfo()
+	if err != nil {
+		logger.Printf("gocql: unable to parse type class %q, treating it as a custom type: %v\n", class.input, err)
+		return NewCustomType(class.proto, TypeCustom, class.input)
 	}
 	return info
 }
@@ -1512,7 +1484,7 @@ func (t *typeParser) parseParamNodes() (params []typeParserParamNode, ok bool) {
 
 	t.skipWhitespace()
 
-	for t.input[t.index] != ')' {
+	for t.index < len(t.input) && t.input[t.index] != ')' {
 		// look for a named param, but if no colon, then we want to backup
 		backupIndex := t.index
 
@@ -1527,7 +1499,7 @@ func (t *typeParser) parseParamNodes() (params []typeParserParamNode, ok bool) {
 
 		t.skipWhitespace()
 
-		if t.input[t.index] == ':' {
+		if t.index < len(t.input) && t.input[t.index] == ':' {
 			// there is a name for this parameter
 
 			// consume the ':'
@@ -1560,7 +1532,7 @@ func (t *typeParser) parseParamNodes() (params []typeParserParamNode, ok bool) {
 
 		t.skipWhitespace()
 
-		if t.input[t.index] == ',' {
+		if t.index < len(t.input) && t.input[t.index] == ',' {
 			// consume the comma
 			t.index++
 
@@ -1568,6 +1540,11 @@ func (t *typeParser) parseParamNodes() (params []typeParserParamNode, ok bool) {
 		}
 	}
 
+	if t.index == len(t.input) {
+		// unterminated params
+		return nil, false
+	}
+
 	// consume the ')'
 	t.index++
 
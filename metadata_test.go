@@ -625,6 +625,21 @@ func TestTypeParser(t *testing.T) {
 		},
 	)
 
+	// frozen list of sets
+	assertParseNonCompositeType(
+		t,
+		"org.apache.cassandra.db.marshal.FrozenType(org.apache.cassandra.db.marshal.ListType(org.apache.cassandra.db.marshal.SetType(org.apache.cassandra.db.marshal.Int32Type)))",
+		assertTypeInfo{
+			Type:   TypeList,
+			Frozen: true,
+			Elem: &assertTypeInfo{
+				Type:   TypeSet,
+				Frozen: true,
+				Elem:   &assertTypeInfo{Type: TypeInt},
+			},
+		},
+	)
+
 	// map
 	assertParseNonCompositeType(
 		t,
@@ -650,6 +665,13 @@ func TestTypeParser(t *testing.T) {
 		assertTypeInfo{Type: TypeCustom, Custom: "org.apache.cassandra.db.marshal.DynamicCompositeType(u=>org.apache.cassandra.db.marshal.UUIDType,d=>org.apache.cassandra.db.marshal.DateType,t=>org.apache.cassandra.db.marshal.TimeUUIDType,b=>org.apache.cassandra.db.marshal.BytesType,s=>org.apache.cassandra.db.marshal.UTF8Type,B=>org.apache.cassandra.db.marshal.BooleanType,a=>org.apache.cassandra.db.marshal.AsciiType,l=>org.apache.cassandra.db.marshal.LongType,i=>org.apache.cassandra.db.marshal.IntegerType,x=>org.apache.cassandra.db.marshal.LexicalUUIDType)"},
 	)
 
+	// invalid classes are treated as custom types
+	assertParseNonCompositeType(
+		t,
+		"org.apache.cassandra.db.marshal.ListType(org.apache.cassandra.db.marshal.UTF8Type,org.apache.cassandra.db.marshal.Int32Type)",
+		assertTypeInfo{Type: TypeCustom, Custom: "org.apache.cassandra.db.marshal.ListType(org.apache.cassandra.db.marshal.UTF8Type,org.apache.cassandra.db.marshal.Int32Type)"},
+	)
+
 	// composite defs
 	assertParseCompositeType(
 		t,
@@ -691,6 +713,7 @@ type assertTypeInfo struct {
 	Elem     *assertTypeInfo
 	Key      *assertTypeInfo
 	Custom   string
+	Frozen   bool
 }
 
 // Helper function for asserting that the type parser returns the expected
@@ -809,6 +832,9 @@ func assertParseNonCompositeTypes(
 		}
 
 		collection, _ := typeActual.(CollectionType)
+		if collection.Frozen != typeExpected.Frozen {
+			t.Errorf("%s: Expected to parse Frozen %v but was %v", context, typeExpected.Frozen, collection.Frozen)
+		}
 		// check the elem
 		if typeExpected.Elem != nil {
 			if collection.Elem == nil {
@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+const (
+	mutualTLSAuthenticator                     = "org.apache.cassandra.auth.MutualTlsAuthenticator"
+	mutualTLSWithPasswordFallbackAuthenticator = "org.apache.cassandra.auth.MutualTlsWithPasswordFallbackAuthenticator"
+)
+
+// ErrMutualTLSRequired is returned when MutualTLSAuthenticator is used without
+// TLS, or without a client certificate, as no identity could be presented.
+var ErrMutualTLSRequired = errors.New("gocql: MutualTLSAuthenticator requires TLS with a client certificate")
+
+// MutualTLSAuthenticator authenticates with the identity of the client
+// certificate configured in SslOptions, with the MutualTlsAuthenticator of
+// Cassandra 5 and later. No password is exchanged.
+//
+// Connections which are not TLS fail with ErrMutualTLSRequired, and so does
+// creating a session whose SslOpts have no client certificate when HostDialer
+// is not set.
+type MutualTLSAuthenticator struct {
+	// AllowedAuthenticators are the server authenticators accepted.
+	// Default: MutualTlsAuthenticator and MutualTlsWithPasswordFallbackAuthenticator
+	AllowedAuthenticators []string
+}
+
+func (m MutualTLSAuthenticator) Challenge(req []byte) ([]byte, Authenticator, error) {
+	allowed := m.AllowedAuthenticators
+	if len(allowed) == 0 {
+		allowed = []string{mutualTLSAuthenticator, mutualTLSWithPasswordFallbackAuthenticator}
+	}
+	if !approve(string(req), allowed) {
+		return nil, nil, fmt.Errorf("unexpected authenticator %q", req)
+	}
+
+	// the server authenticates the certificate of the connection, the
+	// response is ignored
+	return []byte{}, nil, nil
+}
+
+func (m MutualTLSAuthenticator) Success(data []byte) error {
+	return nil
+}
+
+// requiresTLS reports whether auth authenticates with the TLS identity of
+// the connection.
+func requiresTLS(auth Authenticator) bool {
+	switch auth.(type) {
+	case MutualTLSAuthenticator, *MutualTLSAuthenticator:
+		return true
+	}
+	return false
+}
+
+// checkMutualTLS fails fast when the authenticator of cfg requires a client
+// certificate which the default dialer won't present.
+func checkMutualTLS(cfg *ClusterConfig) error {
+	if !requiresTLS(cfg.Authenticator) || cfg.HostDialer != nil {
+		return nil
+	}
+
+	opts := cfg.SslOpts
+	if opts == nil {
+		return ErrMutualTLSRequired
+	}
+	if opts.CertPath != "" {
+		return nil
+	}
+	if opts.Config != nil && (len(opts.Config.Certificates) > 0 || opts.Config.GetClientCertificate != nil) {
+		return nil
+	}
+	return ErrMutualTLSRequired
+}
+
+// isTLSConn reports whether conn is a TLS connection.
+func isTLSConn(conn interface{}) bool {
+	_, ok := conn.(interface{ ConnectionState() tls.ConnectionState })
+	return ok
+}
@@ -0,0 +1,68 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+)
+
+func TestMutualTLSAuthenticatorChallenge(t *testing.T) {
+	auth := MutualTLSAuthenticator{}
+	for _, class := range []string{mutualTLSAuthenticator, mutualTLSWithPasswordFallbackAuthenticator} {
+		resp, challenger, err := auth.Challenge([]byte(class))
+		if err != nil {
+			t.Fatalf("%s: %v", class, err)
+		}
+		if resp == nil || len(resp) != 0 || challenger != nil {
+			t.Fatalf("%s: expected an empty response, got %q", class, resp)
+		}
+	}
+
+	if _, _, err := auth.Challenge([]byte("org.apache.cassandra.auth.PasswordAuthenticator")); err == nil {
+		t.Fatal("expected the PasswordAuthenticator to be rejected")
+	}
+}
+
+func TestCheckMutualTLS(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ClusterConfig
+		ok   bool
+	}{
+		{"other authenticator", ClusterConfig{Authenticator: PasswordAuthenticator{}}, true},
+		{"no TLS", ClusterConfig{Authenticator: MutualTLSAuthenticator{}}, false},
+		{"no client certificate", ClusterConfig{Authenticator: &MutualTLSAuthenticator{}, SslOpts: &SslOptions{CaPath: "ca.crt"}}, false},
+		{"certificate files", ClusterConfig{Authenticator: MutualTLSAuthenticator{}, SslOpts: &SslOptions{CertPath: "cert", KeyPath: "key"}}, true},
+		{"certificate config", ClusterConfig{Authenticator: MutualTLSAuthenticator{}, SslOpts: &SslOptions{Config: &tls.Config{Certificates: []tls.Certificate{{}}}}}, true},
+		{"host dialer", ClusterConfig{Authenticator: MutualTLSAuthenticator{}, HostDialer: &defaultHostDialer{}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkMutualTLS(&test.cfg)
+			if test.ok && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			} else if !test.ok && !errors.Is(err, ErrMutualTLSRequired) {
+				t.Fatalf("expected ErrMutualTLSRequired, got %v", err)
+			}
+		})
+	}
+}
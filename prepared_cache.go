@@ -72,9 +72,11 @@ func (p *preparedLRU) execIfMissing(key string, fn func(lru *lru.Cache) *infligh
 	return fn(p.lru), false
 }
 
-func (p *preparedLRU) keyFor(hostID, keyspace, statement string) string {
+func (p *preparedLRU) keyFor(version byte, hostID, keyspace, statement string) string {
 	// TODO: we should just use a struct for the key in the map
-	return hostID + keyspace + statement
+	// the statement is prepared per protocol version as a connection to the host
+	// might have negotiated a different version, only v5 returns result metadata ids.
+	return hostID + string([]byte{version}) + keyspace + statement
 }
 
 func (p *preparedLRU) evictPreparedID(key string, id []byte) {
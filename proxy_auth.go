@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// dseAuthenticator is the authenticator of DSE nodes, which supports
+	// proxy login and execution.
+	dseAuthenticator = "com.datastax.bdp.cassandra.auth.DseAuthenticator"
+
+	// proxyExecuteKey is the custom payload key of the role a request is
+	// executed as.
+	proxyExecuteKey = "ProxyExecute"
+)
+
+// ErrExecuteAsNotSupported is returned by queries and batches using ExecuteAs
+// on hosts which don't support proxy execution, or over protocol v3 which has
+// no custom payloads. Other servers would ignore the role and execute the
+// request as the authenticated role.
+var ErrExecuteAsNotSupported = errors.New("gocql: executing as another role is only supported by DSE hosts over protocol v4 or higher")
+
+// ProxyAuthenticator authenticates with Username and Password and, if
+// AuthorizationID is set, logs in as the role AuthorizationID (proxy login).
+// The authenticated role must be granted the PROXY.LOGIN permission on it.
+//
+// Proxy login requires the DseAuthenticator, authenticating with another
+// server authenticator fails instead of silently logging in as Username. To
+// authenticate as one role and execute requests as another one, see
+// Query.ExecuteAs and Batch.ExecuteAs.
+type ProxyAuthenticator struct {
+	Username        string
+	Password        string
+	AuthorizationID string
+	// Setting this to nil or empty will allow authenticating with any authenticator
+	// provided by the server.
+	AllowedAuthenticators []string
+}
+
+func (p ProxyAuthenticator) Challenge(req []byte) ([]byte, Authenticator, error) {
+	class := string(req)
+	if !approve(class, p.AllowedAuthenticators) {
+		return nil, nil, fmt.Errorf("unexpected authenticator %q", req)
+	}
+
+	if class != dseAuthenticator {
+		if p.AuthorizationID != "" {
+			return nil, nil, fmt.Errorf("gocql: proxy login requires the DseAuthenticator, the server uses %q", class)
+		}
+		return p.token(), nil, nil
+	}
+
+	// select the PLAIN SASL mechanism, the server then asks for the token
+	return []byte("PLAIN"), proxyAuthenticatorChallenge{p}, nil
+}
+
+func (p ProxyAuthenticator) Success(data []byte) error {
+	return nil
+}
+
+// token returns the SASL PLAIN token "authzid\x00username\x00password".
+func (p ProxyAuthenticator) token() []byte {
+	resp := make([]byte, 0, 2+len(p.AuthorizationID)+len(p.Username)+len(p.Password))
+	resp = append(resp, p.AuthorizationID...)
+	resp = append(resp, 0)
+	resp = append(resp, p.Username...)
+	resp = append(resp, 0)
+	resp = append(resp, p.Password...)
+	return resp
+}
+
+// proxyAuthenticatorChallenge answers the challenge of the DseAuthenticator.
+type proxyAuthenticatorChallenge struct {
+	auth ProxyAuthenticator
+}
+
+func (c proxyAuthenticatorChallenge) Challenge(req []byte) ([]byte, Authenticator, error) {
+	if string(req) != "PLAIN-START" {
+		return nil, nil, fmt.Errorf("gocql: unexpected challenge %q from the DseAuthenticator", req)
+	}
+	return c.auth.token(), nil, nil
+}
+
+func (c proxyAuthenticatorChallenge) Success(data []byte) error {
+	return nil
+}
+
+// supportsProxyExecute returns true if requests to host using protocol
+// version can be executed as another role, the role is sent in the custom
+// payload which requires protocol v4.
+func supportsProxyExecute(host *HostInfo, version byte) bool {
+	if host == nil || version < protoVersion4 {
+		return false
+	}
+	return host.DSEVersion() != "" || host.SupportedOptions().Product() == ProductDSE
+}
+
+// withExecuteAs returns payload with the role to execute the request as, a
+// copy of payload if role is set.
+func withExecuteAs(payload map[string][]byte, role string) map[string][]byte {
+	if role == "" {
+		return payload
+	}
+
+	res := make(map[string][]byte, len(payload)+1)
+	for k, v := range payload {
+		res[k] = v
+	}
+	res[proxyExecuteKey] = []byte(role)
+	return res
+}
@@ -34,14 +34,18 @@ type ExecutableQuery interface {
 	borrowForExecution()    // Used to ensure that the query stays alive for lifetime of a particular execution goroutine.
 	releaseAfterExecution() // Used when a goroutine finishes its execution attempts, either with ok result or an error.
 	execute(ctx context.Context, conn *Conn) *Iter
-	attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo)
+	attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo, throttled time.Duration)
 	retryPolicy() RetryPolicy
+	rateLimiter() RateLimiter
 	speculativeExecutionPolicy() SpeculativeExecutionPolicy
 	GetRoutingKey() ([]byte, error)
 	Keyspace() string
 	Table() string
 	IsIdempotent() bool
 	GetHostID() string
+	GetTag() string
+	pagingStateVersion() int
+	priority() Priority
 
 	withContext(context.Context) ExecutableQuery
 
@@ -53,16 +57,33 @@ type queryExecutor struct {
 	policy HostSelectionPolicy
 }
 
-func (q *queryExecutor) attemptQuery(ctx context.Context, qry ExecutableQuery, conn *Conn) *Iter {
+func (q *queryExecutor) attemptQuery(ctx context.Context, qry ExecutableQuery, conn *Conn, throttled time.Duration) *Iter {
 	start := time.Now()
 	iter := qry.execute(ctx, conn)
 	end := time.Now()
 
-	qry.attempt(q.pool.keyspace, end, start, iter, conn.host)
+	qry.attempt(q.pool.keyspace, end, start, iter, conn.host, throttled)
 
 	return iter
 }
 
+// throttle waits for the rate limiter of qry to allow an attempt on host and
+// returns the time spent waiting.
+func (q *queryExecutor) throttle(ctx context.Context, qry ExecutableQuery, host *HostInfo) (time.Duration, error) {
+	limiter := qry.rateLimiter()
+	if limiter == nil {
+		return 0, nil
+	}
+
+	start := time.Now()
+	err := limiter.Wait(ctx, RateLimitRequest{
+		Keyspace: qry.Keyspace(),
+		Tag:      qry.GetTag(),
+		Host:     host,
+	})
+	return time.Since(start), err
+}
+
 func (q *queryExecutor) speculate(ctx context.Context, qry ExecutableQuery, sp SpeculativeExecutionPolicy,
 	hostIter NextHost, results chan *Iter) *Iter {
 	ticker := time.NewTicker(sp.Delay())
@@ -83,6 +104,17 @@ func (q *queryExecutor) speculate(ctx context.Context, qry ExecutableQuery, sp S
 	return nil
 }
 
+// pagingStateCompatible returns true if a paging state returned by a
+// connection using protocol version from can be used on one using version to.
+// Paging states are serialized differently before protocol v4, a zero version
+// is unknown and compatible with any other.
+func pagingStateCompatible(from, to int) bool {
+	if from == 0 || to == 0 {
+		return true
+	}
+	return (from >= protoVersion4) == (to >= protoVersion4)
+}
+
 func (q *queryExecutor) executeQuery(qry ExecutableQuery) (*Iter, error) {
 	var hostIter NextHost
 
@@ -161,18 +193,38 @@ func (q *queryExecutor) do(ctx context.Context, qry ExecutableQuery, hostIter Ne
 		}
 
 		pool, ok := q.pool.getPool(host)
-		if !ok {
+		if !ok || !pagingStateCompatible(qry.pagingStateVersion(), host.ProtocolVersion()) {
+			selectedHost = hostIter()
+			continue
+		}
+
+		if err := pool.admit(ctx, qry.priority()); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return &Iter{err: ctxErr}
+			}
+			// the host is overloaded, try the next one
+			lastErr = err
 			selectedHost = hostIter()
 			continue
 		}
 
 		conn := pool.Pick()
 		if conn == nil {
+			pool.release()
 			selectedHost = hostIter()
 			continue
 		}
 
-		iter = q.attemptQuery(ctx, qry, conn)
+		// the rate limiter is only consulted once the attempt is sure to be
+		// sent, so hosts rejecting it don't use up tokens
+		throttled, err := q.throttle(ctx, qry, host)
+		if err != nil {
+			pool.release()
+			return &Iter{err: err}
+		}
+
+		iter = q.attemptQuery(ctx, qry, conn, throttled)
+		pool.release()
 		iter.host = selectedHost.Info()
 		// Update host
 		switch iter.err {
@@ -182,6 +234,11 @@ func (q *queryExecutor) do(ctx context.Context, qry ExecutableQuery, hostIter Ne
 			selectedHost.Mark(nil)
 			return iter
 		default:
+			if _, ok := iter.err.(*ErrResponseTooLarge); ok {
+				// the response is fine, other hosts would return the same
+				selectedHost.Mark(nil)
+				return iter
+			}
 			selectedHost.Mark(iter.err)
 		}
 
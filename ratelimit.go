@@ -0,0 +1,190 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by TokenBucketRateLimiter when a request can't be
+// allowed before the deadline of its context.
+var ErrRateLimited = errors.New("gocql: request rate limited, no token available before the context deadline")
+
+// RateLimitRequest describes a single attempt of a query or batch that is
+// subject to rate limiting.
+type RateLimitRequest struct {
+	// Keyspace is the keyspace of the query or batch.
+	Keyspace string
+	// Tag is the tag set with Query.Tag or Batch.Tag.
+	Tag string
+	// Host is the host the attempt is sent to.
+	Host *HostInfo
+}
+
+// RateLimiter limits the rate at which queries and batches are sent to the cluster.
+// It is consulted before every attempt once the host admitted it, so retries and
+// speculative executions are rate limited as well.
+//
+// A RateLimiter can be set for all queries with ClusterConfig.RateLimiter, or
+// for a single query with Query.RateLimiter or Batch.RateLimiter.
+type RateLimiter interface {
+	// Wait blocks until the attempt described by req is allowed to be sent, or
+	// returns an error if it can't be allowed before ctx is done.
+	Wait(ctx context.Context, req RateLimitRequest) error
+}
+
+// RateLimitKeyFunc selects the token bucket used for a request.
+type RateLimitKeyFunc func(req RateLimitRequest) string
+
+// RateLimitByKeyspace rate limits requests separately for each keyspace.
+func RateLimitByKeyspace(req RateLimitRequest) string {
+	return req.Keyspace
+}
+
+// RateLimitByTag rate limits requests separately for each query tag.
+func RateLimitByTag(req RateLimitRequest) string {
+	return req.Tag
+}
+
+// RateLimitByHost rate limits requests separately for each host.
+func RateLimitByHost(req RateLimitRequest) string {
+	if req.Host == nil {
+		return ""
+	}
+	return req.Host.HostID()
+}
+
+// TokenBucketRateLimiter is a RateLimiter which keeps a token bucket for each key
+// returned by its RateLimitKeyFunc. Every attempt takes a token from its bucket and
+// waits until one is available if the bucket is empty. Wait returns ErrRateLimited
+// without taking a token if none is available before the deadline of the context.
+type TokenBucketRateLimiter struct {
+	rate  float64
+	burst float64
+	key   RateLimitKeyFunc
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewTokenBucketRateLimiter creates a TokenBucketRateLimiter which allows rate
+// requests per second with bursts of up to burst requests for each key returned
+// by key. If key is nil, all requests share a single bucket.
+func NewTokenBucketRateLimiter(rate float64, burst int, key RateLimitKeyFunc) *TokenBucketRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucketRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		key:     key,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Wait implements RateLimiter.
+func (t *TokenBucketRateLimiter) Wait(ctx context.Context, req RateLimitRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var key string
+	if t.key != nil {
+		key = t.key(req)
+	}
+
+	delay, ok := t.reserve(ctx, key, time.Now())
+	if !ok {
+		return ErrRateLimited
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		t.cancel(key)
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token from the bucket of key and returns how long the caller
+// has to wait before using it. It reports false without taking a token if the
+// wait would exceed the deadline of ctx.
+func (t *TokenBucketRateLimiter) reserve(ctx context.Context, key string, now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: t.burst, last: now}
+		t.buckets[key] = b
+	}
+
+	// refill the bucket
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * t.rate
+		if b.tokens > t.burst {
+			b.tokens = t.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if t.rate <= 0 {
+		return 0, false
+	}
+
+	delay := time.Duration((1 - b.tokens) / t.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		return 0, false
+	}
+
+	b.tokens--
+	return delay, true
+}
+
+// cancel returns a token which was reserved but not used to the bucket of key.
+func (t *TokenBucketRateLimiter) cancel(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if b, ok := t.buckets[key]; ok {
+		b.tokens++
+		if b.tokens > t.burst {
+			b.tokens = t.burst
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRateLimiterBurst(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(1, 3, nil)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if delay, ok := limiter.reserve(context.Background(), "", now); !ok || delay != 0 {
+			t.Fatalf("request %d: expected no delay, got %v %v", i, delay, ok)
+		}
+	}
+
+	delay, ok := limiter.reserve(context.Background(), "", now)
+	if !ok || delay != time.Second {
+		t.Fatalf("expected delay of %v, got %v %v", time.Second, delay, ok)
+	}
+
+	// the bucket is refilled over time
+	delay, ok = limiter.reserve(context.Background(), "", now.Add(3*time.Second))
+	if !ok || delay != 0 {
+		t.Fatalf("expected no delay after refill, got %v %v", delay, ok)
+	}
+}
+
+func TestTokenBucketRateLimiterKeys(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(1, 1, RateLimitByKeyspace)
+	ctx := context.Background()
+
+	if err := limiter.Wait(ctx, RateLimitRequest{Keyspace: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Wait(ctx, RateLimitRequest{Keyspace: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tag := RateLimitByTag(RateLimitRequest{Tag: "batch-job"}); tag != "batch-job" {
+		t.Fatalf("expected key %q, got %q", "batch-job", tag)
+	}
+	host := &HostInfo{hostId: "host-1"}
+	if key := RateLimitByHost(RateLimitRequest{Host: host}); key != "host-1" {
+		t.Fatalf("expected key %q, got %q", "host-1", key)
+	}
+}
+
+func TestTokenBucketRateLimiterDeadline(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(1, 1, nil)
+	if err := limiter.Wait(context.Background(), RateLimitRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, RateLimitRequest{}); err != ErrRateLimited {
+		t.Fatalf("expected %v, got %v", ErrRateLimited, err)
+	}
+
+	// the rejected request must not have consumed a token
+	if delay, ok := limiter.reserve(context.Background(), "", time.Now()); !ok || delay > time.Second {
+		t.Fatalf("expected delay of at most %v, got %v %v", time.Second, delay, ok)
+	}
+
+	// a context done while waiting returns its own error and the token
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := limiter.Wait(ctx, RateLimitRequest{}); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if delay, ok := limiter.reserve(context.Background(), "", time.Now()); !ok || delay > 2*time.Second {
+		t.Fatalf("expected delay of at most %v, got %v %v", 2*time.Second, delay, ok)
+	}
+}
@@ -0,0 +1,410 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FrameDirection tells whether a recorded frame was sent or received.
+type FrameDirection uint8
+
+const (
+	FrameSent FrameDirection = iota + 1
+	FrameReceived
+)
+
+func (d FrameDirection) String() string {
+	switch d {
+	case FrameSent:
+		return "SENT"
+	case FrameReceived:
+		return "RECEIVED"
+	default:
+		return fmt.Sprintf("UNKNOWN_DIRECTION_%d", uint8(d))
+	}
+}
+
+// RecordedFrame is a frame sent or received by a connection.
+type RecordedFrame struct {
+	Time      time.Time
+	Direction FrameDirection
+	// Host is the address of the connection the frame was sent or received on.
+	Host string
+	// Stream is the stream ID of the frame.
+	Stream int
+	// Segmented is true if the frame was carried in protocol v5 segments.
+	Segmented bool
+	// Frame is the frame, header included, with an uncompressed body.
+	Frame []byte
+}
+
+// FrameRecorder is the interface implemented by frame recorders, see
+// ClusterConfig.FrameRecorder.
+//
+// Experimental, this interface and use may change
+type FrameRecorder interface {
+	// RecordFrame gets called for every frame sent and received by the
+	// connections of a session. The recorder owns frame.Frame.
+	RecordFrame(frame RecordedFrame)
+}
+
+func (c *Conn) recordsFrames() bool {
+	return c.recorder != nil || c.decodeObserver != nil
+}
+
+// record passes frame to the frame observer, decoded, and the recorder.
+func (c *Conn) record(frame RecordedFrame) {
+	if c.decodeObserver != nil {
+		decoded, err := DecodeFrame(frame.Frame)
+		c.decodeObserver.ObserveFrame(c.ctx, ObservedFrame{
+			Direction: frame.Direction,
+			Time:      frame.Time,
+			Host:      c.host,
+			Frame:     decoded,
+			Err:       err,
+		})
+	}
+	if c.recorder != nil {
+		c.recorder.RecordFrame(frame)
+	}
+}
+
+// recordSent records the request req sent on stream. The frame is built
+// again, uncompressed and with bound values redacted if configured.
+func (c *Conn) recordSent(req frameBuilder, flags byte, stream int, segmented bool) {
+	if c.session.cfg.RedactRecordedValues {
+		req = redactFrame(req)
+	}
+	if auth, ok := req.(*writeAuthResponseFrame); ok {
+		// never record credentials
+		req = &writeAuthResponseFrame{data: make([]byte, len(auth.data))}
+	}
+
+	framer := newFramer(nil, c.version)
+	framer.flags = flags &^ flagCompress
+	if err := req.buildFrame(framer, stream); err != nil {
+		return
+	}
+
+	c.record(RecordedFrame{
+		Time:      time.Now(),
+		Direction: FrameSent,
+		Host:      c.addr,
+		Stream:    stream,
+		Segmented: segmented,
+		Frame:     framer.buf,
+	})
+}
+
+// recordReceived records the frame with header head and the uncompressed
+// body.
+func (c *Conn) recordReceived(head *frameHeader, body []byte, segmented bool) {
+	framer := newFramer(nil, head.version.version())
+	framer.writeHeader(head.flags&^flagCompress, head.op, head.stream)
+	framer.buf[0] = byte(head.version)
+	framer.buf = append(framer.buf, body...)
+	framer.setLength(len(body))
+
+	c.record(RecordedFrame{
+		Time:      time.Now(),
+		Direction: FrameReceived,
+		Host:      c.addr,
+		Stream:    head.stream,
+		Segmented: segmented,
+		Frame:     framer.buf,
+	})
+}
+
+// redactFrame returns a copy of req with all bound values replaced by zero
+// bytes of the same length.
+func redactFrame(req frameBuilder) frameBuilder {
+	redact := func(values []queryValues) []queryValues {
+		redacted := make([]queryValues, len(values))
+		for i, v := range values {
+			redacted[i] = v
+			if v.value != nil {
+				redacted[i].value = make([]byte, len(v.value))
+			}
+		}
+		return redacted
+	}
+
+	switch req := req.(type) {
+	case *writeQueryFrame:
+		redacted := *req
+		redacted.params.values = redact(req.params.values)
+		return &redacted
+	case *writeExecuteFrame:
+		redacted := *req
+		redacted.params.values = redact(req.params.values)
+		return &redacted
+	case *writeBatchFrame:
+		redacted := *req
+		redacted.statements = make([]batchStatment, len(req.statements))
+		for i, stmt := range req.statements {
+			redacted.statements[i] = stmt
+			redacted.statements[i].values = redact(stmt.values)
+		}
+		return &redacted
+	}
+	return req
+}
+
+const (
+	frameCaptureMagic   = "GOCQLCAP"
+	frameCaptureVersion = 1
+
+	// record flags
+	captureFlagSent      byte = 0x01
+	captureFlagSegmented byte = 0x02
+)
+
+// FrameCaptureWriter is a FrameRecorder writing frames to a capture file
+// which can be read with FrameCaptureReader.
+//
+// A capture file starts with the magic "GOCQLCAP" followed by a format
+// version byte. Each frame is then stored as a flags byte, the varint
+// difference in nanoseconds from the time of the previous frame, the
+// uvarint length prefixed host and the uvarint length prefixed frame.
+type FrameCaptureWriter struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	last int64
+	err  error
+}
+
+// NewFrameCaptureWriter writes the capture file header to w and returns a
+// writer for the frames. Flush must be called once recording is done.
+func NewFrameCaptureWriter(w io.Writer) (*FrameCaptureWriter, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(frameCaptureMagic)
+	if err := bw.WriteByte(frameCaptureVersion); err != nil {
+		return nil, err
+	}
+
+	return &FrameCaptureWriter{w: bw}, nil
+}
+
+// RecordFrame implements FrameRecorder. Write errors are returned by Flush.
+func (c *FrameCaptureWriter) RecordFrame(frame RecordedFrame) {
+	var flags byte
+	if frame.Direction == FrameSent {
+		flags |= captureFlagSent
+	}
+	if frame.Segmented {
+		flags |= captureFlagSegmented
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	now := frame.Time.UnixNano()
+	c.w.WriteByte(flags)
+	c.w.Write(buf[:binary.PutVarint(buf[:], now-c.last)])
+	c.w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(frame.Host)))])
+	c.w.WriteString(frame.Host)
+	c.w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(frame.Frame)))])
+	_, c.err = c.w.Write(frame.Frame)
+	c.last = now
+}
+
+// Flush writes buffered frames to the underlying writer and returns the
+// first error encountered while writing the capture.
+func (c *FrameCaptureWriter) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	c.err = c.w.Flush()
+	return c.err
+}
+
+// FrameCaptureReader reads the frames of a capture file written by
+// FrameCaptureWriter.
+type FrameCaptureReader struct {
+	r    *bufio.Reader
+	last int64
+}
+
+// NewFrameCaptureReader reads the capture file header from r.
+func NewFrameCaptureReader(r io.Reader) (*FrameCaptureReader, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(frameCaptureMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("gocql: unable to read frame capture header: %w", err)
+	}
+	if string(header[:len(frameCaptureMagic)]) != frameCaptureMagic {
+		return nil, errors.New("gocql: not a frame capture file")
+	}
+	if v := header[len(frameCaptureMagic)]; v != frameCaptureVersion {
+		return nil, fmt.Errorf("gocql: unsupported frame capture version %d", v)
+	}
+
+	return &FrameCaptureReader{r: br}, nil
+}
+
+// Next returns the next frame of the capture, or io.EOF at its end.
+func (c *FrameCaptureReader) Next() (RecordedFrame, error) {
+	flags, err := c.r.ReadByte()
+	if err != nil {
+		return RecordedFrame{}, err
+	}
+
+	delta, err := binary.ReadVarint(c.r)
+	if err != nil {
+		return RecordedFrame{}, unexpectedEOF(err)
+	}
+	host, err := c.readBytes()
+	if err != nil {
+		return RecordedFrame{}, err
+	}
+	frame, err := c.readBytes()
+	if err != nil {
+		return RecordedFrame{}, err
+	}
+
+	head, err := readHeader(bytes.NewReader(frame), make([]byte, maxFrameHeaderSize))
+	if err != nil {
+		return RecordedFrame{}, fmt.Errorf("gocql: invalid frame in capture: %w", err)
+	}
+
+	c.last += delta
+	direction := FrameReceived
+	if flags&captureFlagSent != 0 {
+		direction = FrameSent
+	}
+
+	return RecordedFrame{
+		Time:      time.Unix(0, c.last),
+		Direction: direction,
+		Host:      string(host),
+		Stream:    head.stream,
+		Segmented: flags&captureFlagSegmented != 0,
+		Frame:     frame,
+	}, nil
+}
+
+func (c *FrameCaptureReader) readBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if n > maxFrameSize {
+		return nil, ErrFrameTooBig
+	}
+
+	p := make([]byte, n)
+	if _, err := io.ReadFull(c.r, p); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return p, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReplayedFrame is a frame of a capture decoded by ReplayFrameCapture.
+type ReplayedFrame struct {
+	RecordedFrame
+
+	// Header is the decoded frame header.
+	Header string
+	// Decoded is the received frame as parsed by the driver, it is empty for
+	// sent frames.
+	Decoded string
+	// Err is the error returned when parsing a received frame.
+	Err error
+}
+
+// ReplayFrameCapture reads the capture file from r and calls fn with every
+// frame in order. Received frames are parsed by the same code the connections
+// use to parse responses, which makes captures usable as regression tests.
+//
+// ReplayFrameCapture stops and returns the error returned by fn.
+func ReplayFrameCapture(r io.Reader, fn func(frame ReplayedFrame) error) error {
+	capture, err := NewFrameCaptureReader(r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		recorded, err := capture.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(replayFrame(recorded)); err != nil {
+			return err
+		}
+	}
+}
+
+func replayFrame(recorded RecordedFrame) ReplayedFrame {
+	replayed := ReplayedFrame{RecordedFrame: recorded}
+
+	r := bytes.NewReader(recorded.Frame)
+	head, err := readHeader(r, make([]byte, maxFrameHeaderSize))
+	if err != nil {
+		replayed.Err = err
+		return replayed
+	}
+	replayed.Header = head.String()
+
+	if recorded.Direction != FrameReceived {
+		return replayed
+	}
+
+	framer := newFramer(nil, head.version.version())
+	if err := framer.readFrame(r, &head); err != nil {
+		replayed.Err = err
+		return replayed
+	}
+
+	frame, err := framer.parseFrame()
+	if err != nil {
+		replayed.Err = err
+		return replayed
+	}
+
+	replayed.Decoded = fmt.Sprint(frame)
+	return replayed
+}
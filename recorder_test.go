@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameCaptureRoundTrip(t *testing.T) {
+	request := newFramer(nil, protoVersion4)
+	if err := request.writeQueryFrame(3, "SELECT * FROM ks.t", &queryParams{consistency: One}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	response := newFramer(nil, protoVersion4)
+	response.writeHeader(0, opResult, 3)
+	response.buf[0] |= protoDirectionMask
+	response.writeInt(resultKindVoid)
+	if err := response.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(0, 1700000000123456789)
+	recorded := []RecordedFrame{
+		{Time: start, Direction: FrameSent, Host: "127.0.0.1:9042", Stream: 3, Frame: request.buf},
+		{Time: start.Add(time.Millisecond), Direction: FrameReceived, Host: "127.0.0.1:9042", Stream: 3, Segmented: true, Frame: response.buf},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewFrameCaptureWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range recorded {
+		w.RecordFrame(frame)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var replayed []ReplayedFrame
+	err = ReplayFrameCapture(bytes.NewReader(buf.Bytes()), func(frame ReplayedFrame) error {
+		replayed = append(replayed, frame)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replayed) != len(recorded) {
+		t.Fatalf("expected %d frames, got %d", len(recorded), len(replayed))
+	}
+	for i, frame := range replayed {
+		exp := recorded[i]
+		if !frame.Time.Equal(exp.Time) || frame.Direction != exp.Direction || frame.Host != exp.Host ||
+			frame.Stream != exp.Stream || frame.Segmented != exp.Segmented || !bytes.Equal(frame.Frame, exp.Frame) {
+			t.Errorf("frame %d: expected %+v, got %+v", i, exp, frame.RecordedFrame)
+		}
+		if frame.Err != nil {
+			t.Errorf("frame %d: %v", i, frame.Err)
+		}
+	}
+
+	if !strings.Contains(replayed[0].Header, "op=QUERY") || replayed[0].Decoded != "" {
+		t.Errorf("unexpected sent frame header=%q decoded=%q", replayed[0].Header, replayed[0].Decoded)
+	}
+	if replayed[1].Decoded != "[result_void]" {
+		t.Errorf("expected received frame to decode to %q, got %q", "[result_void]", replayed[1].Decoded)
+	}
+}
+
+func TestFrameCaptureReaderInvalid(t *testing.T) {
+	if _, err := NewFrameCaptureReader(strings.NewReader("NOTACAPTURE")); err == nil {
+		t.Fatal("expected an error for an invalid header")
+	}
+
+	// truncated frame
+	capture := frameCaptureMagic + string([]byte{frameCaptureVersion, captureFlagSent, 0, 0, 10, 1})
+	r, err := NewFrameCaptureReader(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestRedactFrame(t *testing.T) {
+	secret := []byte("secret")
+	query := &writeQueryFrame{
+		statement: "INSERT INTO ks.t (k, v) VALUES (?, ?)",
+		params:    queryParams{values: []queryValues{{value: secret}, {value: nil}}},
+	}
+	batch := &writeBatchFrame{
+		statements: []batchStatment{{statement: "INSERT", values: []queryValues{{value: secret}}}},
+	}
+
+	redactedQuery := redactFrame(query).(*writeQueryFrame)
+	if v := redactedQuery.params.values[0].value; !bytes.Equal(v, make([]byte, len(secret))) {
+		t.Errorf("query value was not redacted: %q", v)
+	}
+	if redactedQuery.params.values[1].value != nil {
+		t.Error("null query value was not kept")
+	}
+
+	redactedBatch := redactFrame(batch).(*writeBatchFrame)
+	if v := redactedBatch.statements[0].values[0].value; !bytes.Equal(v, make([]byte, len(secret))) {
+		t.Errorf("batch value was not redacted: %q", v)
+	}
+
+	// the original frames are untouched
+	if !bytes.Equal(query.params.values[0].value, secret) || !bytes.Equal(batch.statements[0].values[0].value, secret) {
+		t.Error("redactFrame modified the original frame")
+	}
+}
@@ -0,0 +1,331 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+)
+
+// SecureConnectBundle is a secure connect bundle of a cluster served behind an
+// SNI proxy, such as DataStax Astra. The bundle is a zip archive containing a
+// config.json file with the address of the metadata service of the cluster,
+// and the CA, certificate and key used for TLS with the metadata service and
+// the proxy.
+type SecureConnectBundle struct {
+	// Host and Port are the address of the metadata service.
+	Host string
+	Port int
+	// Keyspace and LocalDC are the default keyspace and the local datacenter,
+	// if set by the bundle.
+	Keyspace string
+	LocalDC  string
+	// Username and Password are the credentials, if set by the bundle.
+	Username string
+	Password string
+	// TLSConfig trusts the CA of the bundle and presents its certificate.
+	TLSConfig *tls.Config
+}
+
+// secureConnectBundleConfig is the config.json file of a bundle.
+type secureConnectBundleConfig struct {
+	Host           string `json:"host"`
+	Port           int    `json:"port"`
+	Keyspace       string `json:"keyspace"`
+	LocalDC        string `json:"localDC"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	CACertLocation string `json:"caCertLocation"`
+	CertLocation   string `json:"certLocation"`
+	KeyLocation    string `json:"keyLocation"`
+}
+
+// LoadSecureConnectBundle reads the secure connect bundle at the path.
+func LoadSecureConnectBundle(path string) (*SecureConnectBundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: unable to open secure connect bundle: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("gocql: unable to open secure connect bundle: %w", err)
+	}
+	return ReadSecureConnectBundle(f, info.Size())
+}
+
+// ReadSecureConnectBundle reads a secure connect bundle from the zip archive
+// r of size bytes.
+func ReadSecureConnectBundle(r io.ReaderAt, size int64) (*SecureConnectBundle, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: unable to read secure connect bundle: %w", err)
+	}
+
+	readFile := func(name, def string) ([]byte, error) {
+		if name == "" {
+			name = def
+		}
+		f, err := archive.Open(path.Clean(name))
+		if err != nil {
+			return nil, fmt.Errorf("gocql: unable to read %s from secure connect bundle: %w", name, err)
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	data, err := readFile("config.json", "")
+	if err != nil {
+		return nil, err
+	}
+	var cfg secureConnectBundleConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("gocql: unable to parse config.json of secure connect bundle: %w", err)
+	}
+	if cfg.Host == "" || cfg.Port == 0 {
+		return nil, errors.New("gocql: secure connect bundle has no metadata service host and port")
+	}
+
+	caPEM, err := readFile(cfg.CACertLocation, "ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := readFile(cfg.CertLocation, "cert")
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := readFile(cfg.KeyLocation, "key")
+	if err != nil {
+		return nil, err
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("gocql: secure connect bundle has no valid CA certificate")
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: unable to load the certificate of secure connect bundle: %w", err)
+	}
+
+	return &SecureConnectBundle{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Keyspace: cfg.Keyspace,
+		LocalDC:  cfg.LocalDC,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		TLSConfig: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: []tls.Certificate{cert},
+			ServerName:   cfg.Host,
+		},
+	}, nil
+}
+
+// sniMetadata is the response of the metadata service.
+type sniMetadata struct {
+	ContactInfo struct {
+		Type            string   `json:"type"`
+		LocalDC         string   `json:"local_dc"`
+		ContactPoints   []string `json:"contact_points"`
+		SNIProxyAddress string   `json:"sni_proxy_address"`
+	} `json:"contact_info"`
+}
+
+// fetchMetadata reads the proxy address and contact points from the metadata
+// service of the bundle with client, or a client using TLSConfig if nil.
+func (b *SecureConnectBundle) fetchMetadata(ctx context.Context, client *http.Client) (*sniMetadata, error) {
+	if client == nil {
+		client = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: b.TLSConfig},
+		}
+	}
+
+	url := "https://" + net.JoinHostPort(b.Host, strconv.Itoa(b.Port)) + "/metadata"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: unable to read cluster metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gocql: unable to read cluster metadata: %s", resp.Status)
+	}
+
+	var metadata sniMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("gocql: unable to parse cluster metadata: %w", err)
+	}
+	if metadata.ContactInfo.SNIProxyAddress == "" || len(metadata.ContactInfo.ContactPoints) == 0 {
+		return nil, errors.New("gocql: cluster metadata has no SNI proxy address or contact points")
+	}
+	return &metadata, nil
+}
+
+// NewCluster reads the proxy address and contact points from the metadata
+// service and returns a ClusterConfig connecting to all hosts through the
+// proxy. The metadata service is reached with client, or a client using
+// TLSConfig if nil.
+//
+// The ClusterConfig uses an SNI HostDialer, an AddressTranslator mapping all
+// hosts to the proxy and a token aware policy preferring the local
+// datacenter. The keyspace and credentials of the bundle are set if present.
+func (b *SecureConnectBundle) NewCluster(ctx context.Context, client *http.Client) (*ClusterConfig, error) {
+	metadata, err := b.fetchMetadata(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyAddr := metadata.ContactInfo.SNIProxyAddress
+	proxyHost, portStr, err := net.SplitHostPort(proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: invalid SNI proxy address %q: %w", proxyAddr, err)
+	}
+	proxyPort, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: invalid SNI proxy address %q: %w", proxyAddr, err)
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", proxyHost)
+	if err != nil {
+		return nil, fmt.Errorf("gocql: unable to resolve SNI proxy address %q: %w", proxyAddr, err)
+	}
+	proxyIP := ips[0]
+
+	cfg := NewCluster(proxyAddr)
+	cfg.HostDialer = NewSNIHostDialer(proxyAddr, b.TLSConfig, metadata.ContactInfo.ContactPoints, nil)
+	// hosts are reached through the proxy, their addresses only have to be
+	// valid
+	cfg.AddressTranslator = AddressTranslatorFunc(func(addr net.IP, port int) (net.IP, int) {
+		return proxyIP, proxyPort
+	})
+
+	localDC := metadata.ContactInfo.LocalDC
+	if localDC == "" {
+		localDC = b.LocalDC
+	}
+	if localDC != "" {
+		cfg.PoolConfig.HostSelectionPolicy = TokenAwareHostPolicy(DCAwareRoundRobinPolicy(localDC))
+	}
+	cfg.Keyspace = b.Keyspace
+	if b.Username != "" {
+		cfg.Authenticator = PasswordAuthenticator{
+			Username: b.Username,
+			Password: b.Password,
+		}
+	}
+	return cfg, nil
+}
+
+// NewClusterFromBundle loads the secure connect bundle at the path and
+// returns a ClusterConfig connecting to its cluster, see
+// SecureConnectBundle.NewCluster.
+func NewClusterFromBundle(path string) (*ClusterConfig, error) {
+	bundle, err := LoadSecureConnectBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.NewCluster(context.Background(), nil)
+}
+
+// sniHostDialer dials all hosts through an SNI proxy.
+type sniHostDialer struct {
+	proxyAddr     string
+	tlsConfig     *tls.Config
+	contactPoints []string
+	dialer        Dialer
+}
+
+// NewSNIHostDialer returns a HostDialer connecting to all hosts through the
+// SNI proxy at proxyAddr, routing to each host with the server name set to
+// its host ID. Hosts without a host ID, such as the initial contact points,
+// are routed to one of contactPoints, a list of host IDs.
+//
+// The proxy certificate is verified against the root CAs of tlsConfig
+// without checking its host name, which is a host ID. Dialer is used to
+// connect to the proxy, or a net.Dialer if nil.
+func NewSNIHostDialer(proxyAddr string, tlsConfig *tls.Config, contactPoints []string, dialer Dialer) HostDialer {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	return &sniHostDialer{
+		proxyAddr:     proxyAddr,
+		tlsConfig:     tlsConfig,
+		contactPoints: contactPoints,
+		dialer:        dialer,
+	}
+}
+
+func (d *sniHostDialer) DialHost(ctx context.Context, host *HostInfo) (*DialedHost, error) {
+	serverName := host.HostID()
+	if serverName == "" {
+		if len(d.contactPoints) == 0 {
+			return nil, errors.New("gocql: no host ID to route the connection through the SNI proxy")
+		}
+		serverName = d.contactPoints[rand.Intn(len(d.contactPoints))]
+	}
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := d.tlsConfig.Clone()
+	tlsConfig.ServerName = serverName
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		return verifyChain(state, d.tlsConfig.RootCAs)
+	}
+	return WrapTLS(ctx, conn, d.proxyAddr, tlsConfig)
+}
+
+// verifyChain verifies the peer certificates of state against roots without
+// checking the host name.
+func verifyChain(state tls.ConnectionState, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("gocql: no certificate presented by the SNI proxy")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
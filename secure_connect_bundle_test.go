@@ -0,0 +1,377 @@
+//go:build all || unit
+// +build all unit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// bundleTestCerts are the CA, proxy and client certificates of a bundle.
+type bundleTestCerts struct {
+	proxy         *tls.Certificate
+	caCert        *x509.Certificate
+	caPEM         []byte
+	clientCertPEM []byte
+	clientKeyPEM  []byte
+}
+
+func newBundleTestCert(t *testing.T, tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func newBundleTestCerts(t *testing.T) *bundleTestCerts {
+	t.Helper()
+
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey, caPEM, _ := newBundleTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gocql test CA"},
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	_, _, proxyCertPEM, proxyKeyPEM := newBundleTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "proxy"},
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}, ca, caKey)
+	_, _, clientCertPEM, clientKeyPEM := newBundleTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	proxy, err := tls.X509KeyPair(proxyCertPEM, proxyKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &bundleTestCerts{
+		proxy:         &proxy,
+		caCert:        ca,
+		caPEM:         caPEM,
+		clientCertPEM: clientCertPEM,
+		clientKeyPEM:  clientKeyPEM,
+	}
+}
+
+// newTestBundleArchive returns a bundle zip archive with the files, the CA
+// and client certificates are added under their default names unless set.
+func newTestBundleArchive(t *testing.T, config map[string]interface{}, certs *bundleTestCerts, files map[string][]byte) []byte {
+	t.Helper()
+
+	if files == nil {
+		files = make(map[string][]byte)
+	}
+	for name, data := range map[string][]byte{
+		"ca.crt": certs.caPEM,
+		"cert":   certs.clientCertPEM,
+		"key":    certs.clientKeyPEM,
+	} {
+		if _, ok := files[name]; !ok {
+			files[name] = data
+		}
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["config.json"] = configJSON
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readTestBundle(archive []byte) (*SecureConnectBundle, error) {
+	return ReadSecureConnectBundle(bytes.NewReader(archive), int64(len(archive)))
+}
+
+func TestReadSecureConnectBundle(t *testing.T) {
+	certs := newBundleTestCerts(t)
+
+	bundle, err := readTestBundle(newTestBundleArchive(t, map[string]interface{}{
+		"host":           "metadata.example.com",
+		"port":           29080,
+		"keyspace":       "ks",
+		"localDC":        "dc1",
+		"username":       "user",
+		"password":       "secret",
+		"caCertLocation": "./root.crt",
+	}, certs, map[string][]byte{"root.crt": certs.caPEM, "ca.crt": []byte("not a certificate")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Host != "metadata.example.com" || bundle.Port != 29080 {
+		t.Errorf("expected metadata.example.com:29080, got %s:%d", bundle.Host, bundle.Port)
+	}
+	if bundle.Keyspace != "ks" || bundle.LocalDC != "dc1" {
+		t.Errorf("expected keyspace ks and local DC dc1, got %q and %q", bundle.Keyspace, bundle.LocalDC)
+	}
+	if bundle.Username != "user" || bundle.Password != "secret" {
+		t.Errorf("expected the bundle credentials, got %q and %q", bundle.Username, bundle.Password)
+	}
+	if bundle.TLSConfig.ServerName != "metadata.example.com" {
+		t.Errorf("expected server name metadata.example.com, got %q", bundle.TLSConfig.ServerName)
+	}
+	if len(bundle.TLSConfig.Certificates) != 1 {
+		t.Errorf("expected the client certificate, got %d certificates", len(bundle.TLSConfig.Certificates))
+	}
+	if _, err := certs.caCert.Verify(x509.VerifyOptions{Roots: bundle.TLSConfig.RootCAs}); err != nil {
+		t.Errorf("expected the bundle to trust its CA: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		files  map[string][]byte
+	}{
+		{"no host", map[string]interface{}{"port": 1}, nil},
+		{"no port", map[string]interface{}{"host": "127.0.0.1"}, nil},
+		{"missing CA", map[string]interface{}{"host": "127.0.0.1", "port": 1, "caCertLocation": "missing.crt"}, nil},
+		{"invalid CA", map[string]interface{}{"host": "127.0.0.1", "port": 1}, map[string][]byte{"ca.crt": []byte("not a certificate")}},
+		{"invalid key", map[string]interface{}{"host": "127.0.0.1", "port": 1}, map[string][]byte{"key": certs.caPEM}},
+	}
+	for _, test := range tests {
+		if _, err := readTestBundle(newTestBundleArchive(t, test.config, certs, test.files)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+	if _, err := readTestBundle([]byte("not a zip archive")); err == nil {
+		t.Error("expected an error reading an invalid archive")
+	}
+}
+
+func TestSecureConnectBundleNewCluster(t *testing.T) {
+	certs := newBundleTestCerts(t)
+
+	var metadata map[string]interface{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(metadata)
+	}))
+	defer srv.Close()
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+	bundle, err := readTestBundle(newTestBundleArchive(t, map[string]interface{}{
+		"host":     host,
+		"port":     portNum,
+		"keyspace": "ks",
+		"localDC":  "bundle-dc",
+		"username": "user",
+		"password": "secret",
+	}, certs, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata = map[string]interface{}{
+		"version": 1,
+		"contact_info": map[string]interface{}{
+			"type":              "sni_proxy",
+			"local_dc":          "dc1",
+			"contact_points":    []string{"d5b6b2e1-2c8a-4a57-9b5c-6e3c4f3a9a01"},
+			"sni_proxy_address": "127.0.0.1:29042",
+		},
+	}
+	cfg, err := bundle.NewCluster(context.Background(), srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Hosts) != 1 || cfg.Hosts[0] != "127.0.0.1:29042" {
+		t.Errorf("expected the proxy as the only host, got %v", cfg.Hosts)
+	}
+	if _, ok := cfg.HostDialer.(*sniHostDialer); !ok {
+		t.Errorf("expected an SNI host dialer, got %T", cfg.HostDialer)
+	}
+	if ip, port := cfg.AddressTranslator.Translate(net.IPv4(10, 0, 0, 1), 9042); !ip.Equal(net.IPv4(127, 0, 0, 1)) || port != 29042 {
+		t.Errorf("expected hosts to be translated to the proxy, got %v:%d", ip, port)
+	}
+	if cfg.PoolConfig.HostSelectionPolicy == nil {
+		t.Error("expected a host selection policy for the local datacenter")
+	}
+	if cfg.Keyspace != "ks" {
+		t.Errorf("expected keyspace ks, got %q", cfg.Keyspace)
+	}
+	if auth, ok := cfg.Authenticator.(PasswordAuthenticator); !ok || auth.Username != "user" || auth.Password != "secret" {
+		t.Errorf("expected the bundle credentials, got %#v", cfg.Authenticator)
+	}
+
+	metadata = map[string]interface{}{
+		"contact_info": map[string]interface{}{"sni_proxy_address": "127.0.0.1:29042"},
+	}
+	if _, err := bundle.NewCluster(context.Background(), srv.Client()); err == nil {
+		t.Error("expected an error for metadata without contact points")
+	}
+}
+
+func TestSNIHostDialer(t *testing.T) {
+	certs := newBundleTestCerts(t)
+	bundle, err := readTestBundle(newTestBundleArchive(t, map[string]interface{}{"host": "127.0.0.1", "port": 1}, certs, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(certs.caCert)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{*certs.proxy},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	serverNames := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				serverNames <- tlsConn.ConnectionState().ServerName
+			}
+			conn.Close()
+		}
+	}()
+
+	const contactPoint = "d5b6b2e1-2c8a-4a57-9b5c-6e3c4f3a9a01"
+	dialer := NewSNIHostDialer(listener.Addr().String(), bundle.TLSConfig, []string{contactPoint}, nil)
+	dial := func(host *HostInfo) string {
+		t.Helper()
+		dialed, err := dialer.DialHost(context.Background(), host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dialed.Conn.Close()
+		select {
+		case name := <-serverNames:
+			return name
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the proxy handshake")
+			return ""
+		}
+	}
+
+	host := &HostInfo{hostId: "0c0a7b1e-5f1d-4b8e-8d52-0f8f2d3e7c11"}
+	if name := dial(host); name != host.HostID() {
+		t.Errorf("expected the host to be routed by its host ID, got %q", name)
+	}
+	if name := dial(&HostInfo{}); name != contactPoint {
+		t.Errorf("expected a host without host ID to be routed to a contact point, got %q", name)
+	}
+
+	noContactPoints := NewSNIHostDialer(listener.Addr().String(), bundle.TLSConfig, nil, nil)
+	if _, err := noContactPoints.DialHost(context.Background(), &HostInfo{}); err == nil {
+		t.Error("expected an error routing a host without host ID or contact points")
+	}
+
+	// the proxy certificate must be signed by the CA of the bundle
+	otherCerts := newBundleTestCerts(t)
+	untrusted, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{*otherCerts.proxy},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer untrusted.Close()
+	go func() {
+		for {
+			conn, err := untrusted.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	dialer = NewSNIHostDialer(untrusted.Addr().String(), bundle.TLSConfig, []string{contactPoint}, nil)
+	if _, err := dialer.DialHost(context.Background(), host); err == nil {
+		t.Fatal("expected the proxy certificate to be rejected")
+	}
+}
@@ -32,6 +32,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -62,10 +64,12 @@ type Session struct {
 	connectObserver     ConnectObserver
 	frameObserver       FrameHeaderObserver
 	streamObserver      StreamObserver
+	codecs              *CodecRegistry
 	hostSource          *ringDescriber
 	ringRefresher       *refreshDebouncer
 	stmtsLRU            *preparedLRU
 
+	// connCfg is protected by mu, use connConfig() to read it.
 	connCfg *ConnConfig
 
 	executor *queryExecutor
@@ -76,6 +80,8 @@ type Session struct {
 	metadata clusterMetadata
 
 	mu sync.RWMutex
+	// reconfigureMu serializes calls to Reconfigure.
+	reconfigureMu sync.Mutex
 
 	control *controlConn
 
@@ -98,10 +104,24 @@ type Session struct {
 	isClosed bool
 	// isClosing bool is true once Session.Close is started.
 	isClosing bool
+	// isShuttingDown is true once Session.Shutdown is started.
+	isShuttingDown bool
 	// isInitialized is true once Session.init succeeds.
 	// you can use initialized() to read the value.
 	isInitialized bool
 
+	// inFlightMu protects inFlight, drained and isDrained.
+	inFlightMu sync.Mutex
+	// inFlight is the number of queries, batches and page fetches being
+	// executed, so that Shutdown can wait for them. Requests are only
+	// started while holding sessionStateMu.
+	inFlight int
+	// drained is closed once inFlight drops to zero after Shutdown started.
+	drained chan struct{}
+	// isDrained is true once Shutdown stopped waiting for in-flight requests,
+	// page fetches are rejected from then on.
+	isDrained bool
+
 	logger StdLogger
 }
 
@@ -191,12 +211,13 @@ func NewSession(cfg ClusterConfig) (*Session, error) {
 	s.connectObserver = cfg.ConnectObserver
 	s.frameObserver = cfg.FrameHeaderObserver
 	s.streamObserver = cfg.StreamObserver
+	s.codecs = cfg.Codecs
 
 	//Check the TLS Config before trying to connect to anything external
 	connCfg, err := connConfig(&s.cfg)
 	if err != nil {
 		//TODO: Return a typed error
-		return nil, fmt.Errorf("gocql: unable to create session: %v", err)
+		return nil, fmt.Errorf("gocql: unable to create session: %w", err)
 	}
 	s.connCfg = connCfg
 
@@ -208,7 +229,7 @@ func NewSession(cfg ClusterConfig) (*Session, error) {
 			return nil, ErrNoConnectionsStarted
 		} else {
 			// TODO(zariel): dont wrap this error in fmt.Errorf, return a typed error
-			return nil, fmt.Errorf("gocql: unable to create session: %v", err)
+			return nil, fmt.Errorf("gocql: unable to create session: %w", err)
 		}
 	}
 
@@ -227,7 +248,7 @@ func (s *Session) init() error {
 		if s.cfg.ProtoVersion == 0 {
 			proto, err := s.control.discoverProtocol(hosts)
 			if err != nil {
-				return fmt.Errorf("unable to discover protocol version: %v", err)
+				return fmt.Errorf("unable to discover protocol version: %w", err)
 			} else if proto == 0 {
 				return errors.New("unable to discovery protocol version")
 			}
@@ -451,6 +472,198 @@ func (s *Session) SetTrace(trace Tracer) {
 	s.mu.Unlock()
 }
 
+// reconfigurableFields lists the ClusterConfig fields that can be changed
+// with Session.Reconfigure.
+var reconfigurableFields = map[string]struct{}{
+	"Consistency":                {},
+	"SerialConsistency":          {},
+	"PageSize":                   {},
+	"RetryPolicy":                {},
+	"SpeculativeExecutionPolicy": {},
+	"DefaultTimestamp":           {},
+	"DefaultIdempotence":         {},
+	"RateLimiter":                {},
+	"QueryObserver":              {},
+	"BatchObserver":              {},
+	"ConnectObserver":            {},
+	"Timeout":                    {},
+	"WriteTimeout":               {},
+	"ConnectTimeout":             {},
+	"NumConns":                   {},
+}
+
+// Reconfigure changes the configuration of a live session. The function fn is
+// called with a copy of the current configuration and can modify it, the changes
+// are validated and then applied atomically, so queries see either the old or the
+// new configuration. If fn changes a field which can't be changed at runtime, or
+// the new configuration is invalid, an error is returned and nothing is applied.
+//
+// The following fields can be changed at runtime:
+//
+//   - Consistency, SerialConsistency, PageSize, DefaultTimestamp and DefaultIdempotence
+//     apply to queries and batches created after Reconfigure returns.
+//   - RetryPolicy, RateLimiter and SpeculativeExecutionPolicy apply to queries
+//     and batches created after Reconfigure returns.
+//   - QueryObserver, BatchObserver and ConnectObserver.
+//   - Timeout applies to existing and new connections, WriteTimeout and
+//     ConnectTimeout apply to new connections only.
+//   - NumConns resizes the connection pool of every host. Connections that are no
+//     longer needed are closed once their in-flight requests finish.
+func (s *Session) Reconfigure(fn func(*ClusterConfig)) error {
+	s.reconfigureMu.Lock()
+	defer s.reconfigureMu.Unlock()
+
+	if s.Closed() {
+		return ErrSessionClosed
+	}
+
+	s.mu.RLock()
+	old := s.cfg
+	// values changed with SetConsistency and SetPageSize take precedence
+	old.Consistency = s.cons
+	old.PageSize = s.pageSize
+	s.mu.RUnlock()
+
+	cfg := old
+	fn(&cfg)
+
+	if err := checkReconfigurable(&old, &cfg); err != nil {
+		return err
+	}
+	if err := validateRuntimeConfig(&cfg); err != nil {
+		return err
+	}
+
+	connCfg := *s.connConfig()
+	connCfg.Timeout = cfg.Timeout
+	connCfg.ConnectTimeout = cfg.ConnectTimeout
+	connCfg.WriteTimeout = cfg.WriteTimeout
+
+	s.mu.Lock()
+	// only assign the fields that are read while holding s.mu, other fields
+	// of s.cfg are read without locking.
+	s.cfg.Consistency = cfg.Consistency
+	s.cfg.SerialConsistency = cfg.SerialConsistency
+	s.cfg.PageSize = cfg.PageSize
+	s.cfg.RetryPolicy = cfg.RetryPolicy
+	s.cfg.RateLimiter = cfg.RateLimiter
+	s.cfg.SpeculativeExecutionPolicy = cfg.SpeculativeExecutionPolicy
+	s.cfg.DefaultTimestamp = cfg.DefaultTimestamp
+	s.cfg.DefaultIdempotence = cfg.DefaultIdempotence
+	s.cfg.QueryObserver = cfg.QueryObserver
+	s.cfg.BatchObserver = cfg.BatchObserver
+	s.cfg.ConnectObserver = cfg.ConnectObserver
+	s.cfg.Timeout = cfg.Timeout
+	s.cfg.WriteTimeout = cfg.WriteTimeout
+	s.cfg.ConnectTimeout = cfg.ConnectTimeout
+	s.cfg.NumConns = cfg.NumConns
+	s.cons = cfg.Consistency
+	s.pageSize = cfg.PageSize
+	s.queryObserver = cfg.QueryObserver
+	s.batchObserver = cfg.BatchObserver
+	s.connectObserver = cfg.ConnectObserver
+	s.connCfg = &connCfg
+	s.mu.Unlock()
+
+	if cfg.Timeout != old.Timeout {
+		s.pool.setTimeout(cfg.Timeout)
+		if s.control != nil {
+			if ch := s.control.getConn(); ch != nil {
+				ch.conn.r.SetTimeout(cfg.Timeout)
+			}
+		}
+	}
+
+	if cfg.NumConns != old.NumConns {
+		s.pool.setNumConns(cfg.NumConns)
+	}
+
+	return nil
+}
+
+// checkReconfigurable returns an error listing the fields that differ between old
+// and cfg and that can't be changed at runtime.
+func checkReconfigurable(old, cfg *ClusterConfig) error {
+	oldVal := reflect.ValueOf(old).Elem()
+	newVal := reflect.ValueOf(cfg).Elem()
+	typ := oldVal.Type()
+
+	var changed []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if _, ok := reconfigurableFields[field.Name]; ok {
+			continue
+		}
+
+		if !sameConfigValue(oldVal.Field(i), newVal.Field(i)) {
+			changed = append(changed, field.Name)
+		}
+	}
+
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("gocql: unable to reconfigure session: %s can't be changed at runtime", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// sameConfigValue reports whether the config field values a and b are the
+// same. Funcs are only deeply equal if they are nil, so funcs, including
+// the dynamic values of interfaces such as AddressTranslatorFunc, are
+// compared by pointer and pointers by identity.
+func sameConfigValue(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		a, b = a.Elem(), b.Elem()
+		if a.Type() != b.Type() {
+			return false
+		}
+		if a.Kind() == reflect.Ptr {
+			return a.Pointer() == b.Pointer()
+		}
+	}
+	if a.Kind() == reflect.Func {
+		return a.Pointer() == b.Pointer()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// validateRuntimeConfig validates the fields of cfg that can be changed at runtime.
+func validateRuntimeConfig(cfg *ClusterConfig) error {
+	if cfg.NumConns < 1 {
+		return fmt.Errorf("gocql: unable to reconfigure session: NumConns must be at least 1, got %d", cfg.NumConns)
+	}
+	if cfg.Timeout < 0 || cfg.ConnectTimeout < 0 || cfg.WriteTimeout < 0 {
+		return errors.New("gocql: unable to reconfigure session: timeouts must not be negative")
+	}
+	if cfg.SerialConsistency > 0 && !cfg.SerialConsistency.isSerial() {
+		return fmt.Errorf("gocql: unable to reconfigure session: SerialConsistency must be SERIAL or LOCAL_SERIAL, got %v", cfg.SerialConsistency)
+	}
+	return nil
+}
+
+// connConfig returns the configuration used to establish new connections.
+func (s *Session) connConfig() *ConnConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connCfg
+}
+
+// speculativeExecutionPolicy returns the default speculative execution policy,
+// it must be called while holding s.mu.
+func (s *Session) speculativeExecutionPolicy() SpeculativeExecutionPolicy {
+	if s.cfg.SpeculativeExecutionPolicy == nil {
+		return &NonSpeculativeExecution{}
+	}
+	return s.cfg.SpeculativeExecutionPolicy
+}
+
 // Query generates a new query object for interacting with the database.
 // Further details of the query may be tweaked using the resulting query
 // value before the query is executed. Query is automatically prepared
@@ -528,6 +741,125 @@ func (s *Session) Close() {
 	s.sessionStateMu.Unlock()
 }
 
+// Shutdown gracefully closes the session. New queries and batches are rejected
+// with ErrSessionShuttingDown right away, while queries and batches already being
+// executed, including their page prefetches, are allowed to finish.
+// Once they are done or ctx expires, page fetches are rejected with
+// ErrSessionShuttingDown as well and the event handlers, the control connection
+// and the connection pools are stopped in this order and the session becomes
+// unusable, like after Close.
+//
+// Shutdown returns ctx.Err() if ctx expired before all in-flight requests finished.
+func (s *Session) Shutdown(ctx context.Context) error {
+	s.sessionStateMu.Lock()
+	if s.isClosing || s.isShuttingDown {
+		s.sessionStateMu.Unlock()
+		return nil
+	}
+	s.isShuttingDown = true
+	s.sessionStateMu.Unlock()
+
+	// requests admitted before isShuttingDown was set are counted by now
+	drained := make(chan struct{})
+	s.inFlightMu.Lock()
+	if s.inFlight == 0 {
+		close(drained)
+		s.isDrained = true
+	} else {
+		s.drained = drained
+	}
+	s.inFlightMu.Unlock()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.inFlightMu.Lock()
+	s.isDrained = true
+	s.drained = nil
+	s.inFlightMu.Unlock()
+
+	s.sessionStateMu.Lock()
+	if s.isClosing {
+		// Close was called while waiting for in-flight requests
+		s.sessionStateMu.Unlock()
+		return err
+	}
+	s.isClosing = true
+	s.sessionStateMu.Unlock()
+
+	if s.nodeEvents != nil {
+		s.nodeEvents.stop()
+	}
+
+	if s.schemaEvents != nil {
+		s.schemaEvents.stop()
+	}
+
+	if s.ringRefresher != nil {
+		s.ringRefresher.stop()
+	}
+
+	if s.control != nil {
+		s.control.close()
+	}
+
+	if s.pool != nil {
+		s.pool.Close()
+	}
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	s.sessionStateMu.Lock()
+	s.isClosed = true
+	s.sessionStateMu.Unlock()
+
+	return err
+}
+
+// startRequest registers a new query or batch execution with the session.
+// Fetching the next page of an already executing query is allowed while the
+// session is shutting down until all in-flight requests are done, new requests
+// are not.
+func (s *Session) startRequest(nextPage bool) error {
+	s.sessionStateMu.RLock()
+	defer s.sessionStateMu.RUnlock()
+
+	if s.isClosed {
+		return ErrSessionClosed
+	} else if s.isShuttingDown && !nextPage {
+		return ErrSessionShuttingDown
+	}
+
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+
+	if s.isDrained {
+		return ErrSessionShuttingDown
+	}
+	s.inFlight++
+	return nil
+}
+
+// finishRequest unregisters a query or batch execution started with
+// startRequest.
+func (s *Session) finishRequest() {
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+
+	s.inFlight--
+	if s.inFlight == 0 && s.drained != nil {
+		close(s.drained)
+		s.drained = nil
+		s.isDrained = true
+	}
+}
+
 func (s *Session) Closed() bool {
 	s.sessionStateMu.RLock()
 	closed := s.isClosed
@@ -543,10 +875,17 @@ func (s *Session) initialized() bool {
 }
 
 func (s *Session) executeQuery(qry *Query) (it *Iter) {
+	return s.executeQueryPage(qry, false)
+}
+
+// executeQueryPage executes qry, nextPage is true if qry fetches the next page
+// of a query that is already being executed.
+func (s *Session) executeQueryPage(qry *Query, nextPage bool) *Iter {
 	// fail fast
-	if s.Closed() {
-		return &Iter{err: ErrSessionClosed}
+	if err := s.startRequest(nextPage); err != nil {
+		return &Iter{err: err}
 	}
+	defer s.finishRequest()
 
 	iter, err := s.executor.executeQuery(qry)
 	if err != nil {
@@ -756,9 +1095,10 @@ func (b *Batch) Exec() error {
 
 func (s *Session) executeBatch(batch *Batch) *Iter {
 	// fail fast
-	if s.Closed() {
-		return &Iter{err: ErrSessionClosed}
+	if err := s.startRequest(false); err != nil {
+		return &Iter{err: err}
 	}
+	defer s.finishRequest()
 
 	// Prevent the execution of the batch if greater than the limit
 	// Currently batches have a limit of 65536 queries.
@@ -936,6 +1276,8 @@ type Query struct {
 	pageSize              int
 	routingKey            []byte
 	pageState             []byte
+	pageStateVersion      int
+	continuous            *ContinuousPagingOptions
 	prefetch              float64
 	trace                 Tracer
 	observer              QueryObserver
@@ -972,6 +1314,11 @@ type Query struct {
 
 	keyspace          string
 	nowInSecondsValue *int
+	executeAs         string
+
+	prio    Priority
+	tag     string
+	limiter RateLimiter
 }
 
 type queryRoutingInfo struct {
@@ -997,8 +1344,8 @@ func (q *Query) defaultsFromSession() {
 	q.defaultTimestamp = s.cfg.DefaultTimestamp
 	q.idempotent = s.cfg.DefaultIdempotence
 	q.metrics = &queryMetrics{m: make(map[string]*hostMetrics)}
-
-	q.spec = &NonSpeculativeExecution{}
+	q.spec = s.speculativeExecutionPolicy()
+	q.limiter = s.cfg.RateLimiter
 	s.mu.RUnlock()
 }
 
@@ -1147,22 +1494,24 @@ func (q *Query) execute(ctx context.Context, conn *Conn) *Iter {
 	return conn.executeQuery(ctx, q)
 }
 
-func (q *Query) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo) {
+func (q *Query) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo, throttled time.Duration) {
 	latency := end.Sub(start)
 	attempt, metricsForHost := q.metrics.attempt(1, latency, host, q.observer != nil)
 
 	if q.observer != nil {
 		q.observer.ObserveQuery(q.Context(), ObservedQuery{
-			Keyspace:  keyspace,
-			Statement: q.stmt,
-			Values:    q.values,
-			Start:     start,
-			End:       end,
-			Rows:      iter.numRows,
-			Host:      host,
-			Metrics:   metricsForHost,
-			Err:       iter.err,
-			Attempt:   attempt,
+			Keyspace:     keyspace,
+			Statement:    q.stmt,
+			Values:       q.values,
+			Start:        start,
+			End:          end,
+			Rows:         iter.numRows,
+			Host:         host,
+			Metrics:      metricsForHost,
+			Err:          iter.err,
+			Attempt:      attempt,
+			Throttled:    throttled,
+			ResponseSize: iter.responseSize(),
 		})
 	}
 }
@@ -1224,7 +1573,7 @@ func (q *Query) GetRoutingKey() ([]byte, error) {
 		q.routingInfo.table = routingKeyInfo.table
 		q.routingInfo.mu.Unlock()
 	}
-	return createRoutingKey(routingKeyInfo, q.values)
+	return createRoutingKey(q.session.codecs, routingKeyInfo, q.values)
 }
 
 func (q *Query) shouldPrepare() bool {
@@ -1295,6 +1644,7 @@ func (q *Query) Idempotent(value bool) *Query {
 func (q *Query) Bind(v ...interface{}) *Query {
 	q.values = v
 	q.pageState = nil
+	q.pageStateVersion = 0
 	return q
 }
 
@@ -1316,10 +1666,20 @@ func (q *Query) SerialConsistency(cons Consistency) *Query {
 // must be used for all subsequent pages.
 func (q *Query) PageState(state []byte) *Query {
 	q.pageState = state
+	q.pageStateVersion = 0
 	q.disableAutoPage = true
 	return q
 }
 
+// ContinuousPaging enables DSE continuous paging for the query, the pages of
+// PageSize rows are then streamed by the node without a request per page.
+// Connections which do not use a DSE protocol version fall back to regular
+// paging, see ContinuousPagingOptions.
+func (q *Query) ContinuousPaging(opts ContinuousPagingOptions) *Query {
+	q.continuous = &opts
+	return q
+}
+
 // NoSkipMetadata will override the internal result metadata cache so that the driver does not
 // send skip_metadata for queries, this means that the result will always contain
 // the metadata to parse the rows and will not reuse the metadata from the prepared
@@ -1485,6 +1845,47 @@ func (q *Query) GetHostID() string {
 	return q.hostID
 }
 
+// pagingStateVersion returns the protocol version of the connection which
+// returned the paging state of the query, 0 if unknown.
+func (q *Query) pagingStateVersion() int {
+	return q.pageStateVersion
+}
+
+// Priority sets the priority class of the query. When the number of requests in
+// flight to a host is limited with PoolConfig.MaxInFlightPerHost, requests with
+// a higher priority are admitted first. The default is PriorityNormal.
+func (q *Query) Priority(p Priority) *Query {
+	q.prio = p
+	return q
+}
+
+func (q *Query) priority() Priority {
+	return q.prio
+}
+
+// Tag sets a tag on the query which is passed to the RateLimiter, it can be used
+// to rate limit groups of statements separately, see RateLimitByTag.
+func (q *Query) Tag(tag string) *Query {
+	q.tag = tag
+	return q
+}
+
+// GetTag returns the tag of the query.
+func (q *Query) GetTag() string {
+	return q.tag
+}
+
+// RateLimiter sets the rate limiter to use for the query, overriding
+// ClusterConfig.RateLimiter. A nil RateLimiter disables rate limiting.
+func (q *Query) RateLimiter(r RateLimiter) *Query {
+	q.limiter = r
+	return q
+}
+
+func (q *Query) rateLimiter() RateLimiter {
+	return q.limiter
+}
+
 // SetKeyspace will enable keyspace flag on the query.
 // It allows to specify the keyspace that the query should be executed in
 //
@@ -1503,6 +1904,17 @@ func (q *Query) WithNowInSeconds(now int) *Query {
 	return q
 }
 
+// ExecuteAs executes the query as role instead of the authenticated role,
+// which must be granted the PROXY.EXECUTE permission on it. The role is sent
+// in the custom payload of the query.
+//
+// Only supported by DSE over protocol v4 or higher, the query fails with
+// ErrExecuteAsNotSupported on other hosts and over protocol v3.
+func (q *Query) ExecuteAs(role string) *Query {
+	q.executeAs = role
+	return q
+}
+
 // Iter represents an iterator that can be used to iterate over all rows that
 // were returned by a query. The iterator might send additional queries to the
 // database during the iteration if paging was enabled.
@@ -1516,6 +1928,12 @@ type Iter struct {
 
 	framer *framer
 	closed int32
+	codecs *CodecRegistry
+
+	// borrow is set by BorrowBytes, retained is set once a column may have
+	// been unmarshaled into a value which references the framer body.
+	borrow   bool
+	retained bool
 }
 
 // Host returns the host which the query was sent to.
@@ -1561,7 +1979,9 @@ func (is *iterScanner) Next() bool {
 
 	if iter.pos >= iter.numRows {
 		if iter.next != nil {
+			iter.releaseFramer()
 			is.iter = iter.next.fetch()
+			is.iter.borrow = iter.borrow
 			return is.Next()
 		}
 		return false
@@ -1581,11 +2001,23 @@ func (is *iterScanner) Next() bool {
 	return true
 }
 
-func scanColumn(p []byte, col ColumnInfo, dest []interface{}) (int, error) {
+func (iter *Iter) scanColumn(j int, p []byte, dest []interface{}) (int, error) {
 	if dest[0] == nil {
 		return 1, nil
 	}
 
+	col := iter.meta.columns[j]
+	if iter.borrow {
+		if v, ok := dest[0].(*[]byte); ok {
+			switch col.TypeInfo.Type() {
+			case TypeBlob, TypeText, TypeVarchar, TypeAscii:
+				*v = p
+				return 1, nil
+			}
+		}
+	}
+
+	codecs := iter.codecs
 	if col.TypeInfo.Type() == TypeTuple {
 		// this will panic, actually a bug, please report
 		tuple := col.TypeInfo.(TupleTypeInfo)
@@ -1593,12 +2025,18 @@ func scanColumn(p []byte, col ColumnInfo, dest []interface{}) (int, error) {
 		count := len(tuple.Elems)
 		// here we pass in a slice of the struct which has the number number of
 		// values as elements in the tuple
-		if err := Unmarshal(col.TypeInfo, p, dest[:count]); err != nil {
+		iter.retained = true
+		if err := unmarshalCQL(codecs, col.TypeInfo, p, dest[:count]); err != nil {
 			return 0, err
 		}
 		return count, nil
 	} else {
-		if err := Unmarshal(col.TypeInfo, p, dest[0]); err != nil {
+		plan := iter.meta.plan(j)
+		err := plan.unmarshal(codecs, col.TypeInfo, p, dest[0])
+		if !iter.retained {
+			iter.retained = plan.borrows(codecs, dest[0])
+		}
+		if err != nil {
 			return 0, err
 		}
 		return 1, nil
@@ -1621,9 +2059,9 @@ func (is *iterScanner) Scan(dest ...interface{}) error {
 	// slices of dest
 	i := 0
 	var err error
-	for _, col := range iter.meta.columns {
+	for j := range iter.meta.columns {
 		var n int
-		n, err = scanColumn(is.cols[i], col, dest[i:])
+		n, err = iter.scanColumn(j, is.cols[j], dest[i:])
 		if err != nil {
 			break
 		}
@@ -1652,6 +2090,18 @@ func (iter *Iter) Scanner() Scanner {
 	return &iterScanner{iter: iter, cols: make([][]byte, len(iter.meta.columns))}
 }
 
+// responseSize returns the size of the decompressed response body of the
+// iter, see ObservedQuery.ResponseSize.
+func (iter *Iter) responseSize() int {
+	if iter.framer != nil {
+		return iter.framer.bodyLen
+	}
+	if err, ok := iter.err.(*ErrResponseTooLarge); ok {
+		return err.Size
+	}
+	return 0
+}
+
 func (iter *Iter) readColumn() ([]byte, error) {
 	return iter.framer.readBytesInternal()
 }
@@ -1671,7 +2121,10 @@ func (iter *Iter) Scan(dest ...interface{}) bool {
 
 	if iter.pos >= iter.numRows {
 		if iter.next != nil {
+			borrow := iter.borrow
+			iter.releaseFramer()
 			*iter = *iter.next.fetch()
+			iter.borrow = borrow
 			return iter.Scan(dest...)
 		}
 		return false
@@ -1691,14 +2144,14 @@ func (iter *Iter) Scan(dest ...interface{}) bool {
 	// i is the current position in dest, could posible replace it and just use
 	// slices of dest
 	i := 0
-	for _, col := range iter.meta.columns {
+	for j := range iter.meta.columns {
 		colBytes, err := iter.readColumn()
 		if err != nil {
 			iter.err = err
 			return false
 		}
 
-		n, err := scanColumn(colBytes, col, dest[i:])
+		n, err := iter.scanColumn(j, colBytes, dest[i:])
 		if err != nil {
 			iter.err = err
 			return false
@@ -1738,14 +2191,38 @@ func (iter *Iter) Warnings() []string {
 // the query or the iteration.
 func (iter *Iter) Close() error {
 	if atomic.CompareAndSwapInt32(&iter.closed, 0, 1) {
-		if iter.framer != nil {
-			iter.framer = nil
+		iter.releaseFramer()
+		if iter.next != nil && iter.next.pager != nil {
+			// stop streaming the pages which will not be read
+			iter.next.pager.cancel()
 		}
 	}
 
 	return iter.err
 }
 
+// BorrowBytes makes Scan store blob, text, varchar and ascii columns scanned
+// into *[]byte as slices of the response buffer instead of copies. Borrowed
+// slices are only valid until the next call of Scan, Scanner.Next or Close,
+// afterwards the buffer is reused for other responses. Copy them to keep
+// them longer.
+func (iter *Iter) BorrowBytes() *Iter {
+	iter.borrow = true
+	return iter
+}
+
+// releaseFramer drops the framer of the current page, its buffers go back
+// to the pools unless a value scanned from the page, a custom payload or an
+// error might still reference them.
+func (iter *Iter) releaseFramer() {
+	framer := iter.framer
+	iter.framer = nil
+	if framer == nil || iter.retained || iter.err != nil || framer.customPayload != nil {
+		return
+	}
+	framer.release()
+}
+
 // WillSwitchPage detects if iterator reached end of current page
 // and the next page is available.
 func (iter *Iter) WillSwitchPage() bool {
@@ -1783,6 +2260,9 @@ type nextIter struct {
 	oncea sync.Once
 	once  sync.Once
 	next  *Iter
+
+	// pager streams the pages of continuous paging queries.
+	pager *continuousPager
 }
 
 func (n *nextIter) fetchAsync() {
@@ -1793,12 +2273,17 @@ func (n *nextIter) fetchAsync() {
 
 func (n *nextIter) fetch() *Iter {
 	n.once.Do(func() {
+		if n.pager != nil {
+			n.next = n.pager.nextPage()
+			return
+		}
+
 		// if the query was specifically run on a connection then re-use that
 		// connection when fetching the next results
 		if n.qry.conn != nil {
 			n.next = n.qry.conn.executeQuery(n.qry.Context(), n.qry)
 		} else {
-			n.next = n.qry.session.executeQuery(n.qry)
+			n.next = n.qry.session.executeQueryPage(n.qry, true)
 		}
 	})
 	return n.next
@@ -1823,6 +2308,10 @@ type Batch struct {
 	keyspace              string
 	metrics               *queryMetrics
 	nowInSeconds          *int
+	executeAs             string
+	prio                  Priority
+	tag                   string
+	limiter               RateLimiter
 
 	// routingInfo is a pointer because Query can be copied and copyable struct can't hold a mutex.
 	routingInfo *queryRoutingInfo
@@ -1849,7 +2338,8 @@ func (s *Session) Batch(typ BatchType) *Batch {
 		defaultTimestamp: s.cfg.DefaultTimestamp,
 		keyspace:         s.cfg.Keyspace,
 		metrics:          &queryMetrics{m: make(map[string]*hostMetrics)},
-		spec:             &NonSpeculativeExecution{},
+		spec:             s.speculativeExecutionPolicy(),
+		limiter:          s.cfg.RateLimiter,
 		routingInfo:      &queryRoutingInfo{},
 	}
 
@@ -2022,7 +2512,7 @@ func (b *Batch) WithTimestamp(timestamp int64) *Batch {
 	return b
 }
 
-func (b *Batch) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo) {
+func (b *Batch) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo, throttled time.Duration) {
 	latency := end.Sub(start)
 	attempt, metricsForHost := b.metrics.attempt(1, latency, host, b.observer != nil)
 
@@ -2045,10 +2535,11 @@ func (b *Batch) attempt(keyspace string, end, start time.Time, iter *Iter, host
 		Start:      start,
 		End:        end,
 		// Rows not used in batch observations // TODO - might be able to support it when using BatchCAS
-		Host:    host,
-		Metrics: metricsForHost,
-		Err:     iter.err,
-		Attempt: attempt,
+		Host:      host,
+		Metrics:   metricsForHost,
+		Err:       iter.err,
+		Attempt:   attempt,
+		Throttled: throttled,
 	})
 }
 
@@ -2072,17 +2563,18 @@ func (b *Batch) GetRoutingKey() ([]byte, error) {
 		return nil, err
 	}
 
-	return createRoutingKey(routingKeyInfo, entry.Args)
+	return createRoutingKey(b.session.codecs, routingKeyInfo, entry.Args)
 }
 
-func createRoutingKey(routingKeyInfo *routingKeyInfo, values []interface{}) ([]byte, error) {
+func createRoutingKey(codecs *CodecRegistry, routingKeyInfo *routingKeyInfo, values []interface{}) ([]byte, error) {
 	if routingKeyInfo == nil {
 		return nil, nil
 	}
 
 	if len(routingKeyInfo.indexes) == 1 {
 		// single column routing key
-		routingKey, err := Marshal(
+		routingKey, err := marshalCQL(
+			codecs,
 			routingKeyInfo.types[0],
 			values[routingKeyInfo.indexes[0]],
 		)
@@ -2095,7 +2587,8 @@ func createRoutingKey(routingKeyInfo *routingKeyInfo, values []interface{}) ([]b
 	// composite routing key
 	buf := bytes.NewBuffer(make([]byte, 0, 256))
 	for i := range routingKeyInfo.indexes {
-		encoded, err := Marshal(
+		encoded, err := marshalCQL(
+			codecs,
 			routingKeyInfo.types[i],
 			values[routingKeyInfo.indexes[i]],
 		)
@@ -2127,6 +2620,45 @@ func (b *Batch) GetHostID() string {
 	return ""
 }
 
+func (b *Batch) pagingStateVersion() int {
+	return 0
+}
+
+// Priority sets the priority class of the batch. When the number of requests in
+// flight to a host is limited with PoolConfig.MaxInFlightPerHost, requests with
+// a higher priority are admitted first. The default is PriorityNormal.
+func (b *Batch) Priority(p Priority) *Batch {
+	b.prio = p
+	return b
+}
+
+func (b *Batch) priority() Priority {
+	return b.prio
+}
+
+// Tag sets a tag on the batch which is passed to the RateLimiter, it can be used
+// to rate limit groups of statements separately, see RateLimitByTag.
+func (b *Batch) Tag(tag string) *Batch {
+	b.tag = tag
+	return b
+}
+
+// GetTag returns the tag of the batch.
+func (b *Batch) GetTag() string {
+	return b.tag
+}
+
+// RateLimiter sets the rate limiter to use for the batch, overriding
+// ClusterConfig.RateLimiter. A nil RateLimiter disables rate limiting.
+func (b *Batch) RateLimiter(r RateLimiter) *Batch {
+	b.limiter = r
+	return b
+}
+
+func (b *Batch) rateLimiter() RateLimiter {
+	return b.limiter
+}
+
 // SetKeyspace will enable keyspace flag on the query.
 // It allows to specify the keyspace that the query should be executed in
 //
@@ -2145,6 +2677,13 @@ func (b *Batch) WithNowInSeconds(now int) *Batch {
 	return b
 }
 
+// ExecuteAs executes the batch as role instead of the authenticated role,
+// see Query.ExecuteAs.
+func (b *Batch) ExecuteAs(role string) *Batch {
+	b.executeAs = role
+	return b
+}
+
 type BatchType byte
 
 const (
@@ -2277,6 +2816,48 @@ func (t *traceWriter) Trace(traceId []byte) {
 	}
 }
 
+// ProtocolVersion returns the native protocol version supported by all the
+// hosts the session is connected to. When ClusterConfig.ProtoVersion is not
+// set the version is negotiated with each host, the session then moves up to
+// a newer version once every host supports it.
+func (s *Session) ProtocolVersion() int {
+	version := 0
+	for _, host := range s.ring.allHosts() {
+		if !host.IsUp() {
+			continue
+		}
+		if v := host.ProtocolVersion(); v != 0 && (version == 0 || v < version) {
+			version = v
+		}
+	}
+	if version == 0 {
+		return s.cfg.ProtoVersion
+	}
+	return version
+}
+
+// SupportedOptions returns the SUPPORTED options shared by all the hosts the
+// session is connected to, each option lists the values supported by every
+// host. Nil is returned if the session is not connected to any host.
+func (s *Session) SupportedOptions() SupportedOptions {
+	var supported SupportedOptions
+	for _, host := range s.ring.allHosts() {
+		if !host.IsUp() {
+			continue
+		}
+		hostSupported := host.SupportedOptions()
+		if hostSupported == nil {
+			continue
+		}
+		if supported == nil {
+			supported = hostSupported.intersect(hostSupported)
+		} else {
+			supported = supported.intersect(hostSupported)
+		}
+	}
+	return supported
+}
+
 // GetHosts return a list of hosts in the ring the driver knows of.
 func (s *Session) GetHosts() []*HostInfo {
 	return s.ring.allHosts()
@@ -2311,6 +2892,15 @@ type ObservedQuery struct {
 	// Attempt is the index of attempt at executing this query.
 	// The first attempt is number zero and any retries have non-zero attempt number.
 	Attempt int
+
+	// Throttled is the time the attempt waited for the RateLimiter before it was sent.
+	Throttled time.Duration
+
+	// ResponseSize is the size in bytes of the decompressed body of the response, or
+	// the size reported by *ErrResponseTooLarge. It is 0 for error responses.
+	// Compare it with ClusterConfig.MaxPageBytes and MaxDecompressedBodySize to tell
+	// how close to the limits responses are.
+	ResponseSize int
 }
 
 // QueryObserver is the interface implemented by query observers / stat collectors.
@@ -2348,6 +2938,9 @@ type ObservedBatch struct {
 	// Attempt is the index of attempt at executing this query.
 	// The first attempt is number zero and any retries have non-zero attempt number.
 	Attempt int
+
+	// Throttled is the time the attempt waited for the RateLimiter before it was sent.
+	Throttled time.Duration
 }
 
 // BatchObserver is the interface implemented by batch observers / stat collectors.
@@ -2393,6 +2986,7 @@ var (
 	ErrTooManyStmts         = errors.New("too many statements")
 	ErrUseStmt              = errors.New("use statements aren't supported. Please see https://github.com/apache/cassandra-gocql-driver for explanation.")
 	ErrSessionClosed        = errors.New("session has been closed")
+	ErrSessionShuttingDown  = errors.New("session is shutting down")
 	ErrNoConnections        = errors.New("gocql: no hosts available in the pool")
 	ErrNoKeyspace           = errors.New("no keyspace provided")
 	ErrKeyspaceDoesNotExist = errors.New("keyspace does not exist")
@@ -28,8 +28,13 @@
 package gocql
 
 import (
+	"bytes"
 	"context"
+	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestAsyncSessionInit(t *testing.T) {
@@ -58,3 +63,567 @@ func TestAsyncSessionInit(t *testing.T) {
 		t.Fatalf("unexpected error from void")
 	}
 }
+
+func TestSessionShutdown(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		recvHook: func(f *framer) {
+			if f.header.op == opQuery {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- db.Query("slow").Exec()
+	}()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if err := <-slowErr; err != nil {
+		t.Fatalf("in-flight query failed: %v", err)
+	}
+	if !db.Closed() {
+		t.Fatal("session is not closed after Shutdown")
+	}
+	if err := db.Query("void").Exec(); err != ErrSessionClosed {
+		t.Fatalf("expected %v, got %v", ErrSessionClosed, err)
+	}
+}
+
+func TestSessionShutdownDuringPageFetch(t *testing.T) {
+	received := make(chan struct{}, 2)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		recvHook: func(f *framer) {
+			if f.header.op == opQuery {
+				received <- struct{}{}
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- db.Query("slow").Exec()
+	}()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- db.Shutdown(ctx)
+	}()
+	for {
+		db.sessionStateMu.RLock()
+		shuttingDown := db.isShuttingDown
+		db.sessionStateMu.RUnlock()
+		if shuttingDown {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// fetching the next page of a query is still allowed, Shutdown waits for
+	// it even when the query which started the shutdown wait is done
+	pageErr := make(chan error, 1)
+	go func() {
+		pageErr <- db.executeQueryPage(db.Query("slow"), true).Close()
+	}()
+	<-received
+
+	if err := <-slowErr; err != nil {
+		t.Fatalf("in-flight query failed: %v", err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case err := <-pageErr:
+		if err != nil {
+			t.Fatalf("page fetch failed: %v", err)
+		}
+	default:
+		t.Fatal("Shutdown returned before the page fetch finished")
+	}
+}
+
+func TestSessionPageFetchAfterDrain(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	// a query is in flight while Shutdown waits for it
+	if err := db.startRequest(false); err != nil {
+		t.Fatal(err)
+	}
+	db.sessionStateMu.Lock()
+	db.isShuttingDown = true
+	db.sessionStateMu.Unlock()
+	db.inFlightMu.Lock()
+	db.drained = make(chan struct{})
+	db.inFlightMu.Unlock()
+
+	if err := db.startRequest(true); err != nil {
+		t.Fatalf("expected page fetches to be allowed while draining, got %v", err)
+	}
+	db.finishRequest()
+	db.finishRequest()
+
+	// the drain completed, Shutdown is closing the session
+	if err := db.startRequest(true); err != ErrSessionShuttingDown {
+		t.Fatalf("expected %v, got %v", ErrSessionShuttingDown, err)
+	}
+	db.inFlightMu.Lock()
+	inFlight := db.inFlight
+	db.inFlightMu.Unlock()
+	if inFlight != 0 {
+		t.Fatalf("expected no request in flight, got %d", inFlight)
+	}
+}
+
+func TestSessionShutdownContextExpired(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		recvHook: func(f *framer) {
+			if f.header.op == opQuery {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	go db.Query("timeout").Exec()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- db.Shutdown(ctx)
+	}()
+
+	// wait for the shutdown to start, new queries must be rejected from now on
+	for {
+		db.sessionStateMu.RLock()
+		shuttingDown := db.isShuttingDown
+		db.sessionStateMu.RUnlock()
+		if shuttingDown {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := db.Query("void").Exec(); err != ErrSessionShuttingDown && err != ErrSessionClosed {
+		t.Fatalf("expected %v, got %v", ErrSessionShuttingDown, err)
+	}
+
+	if err := <-shutdownErr; err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if !db.Closed() {
+		t.Fatal("session is not closed after Shutdown")
+	}
+}
+
+func TestSessionReconfigure(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+	waitForPoolSize(t, pool, 1)
+
+	retry := &SimpleRetryPolicy{NumRetries: 3}
+	spec := &SimpleSpeculativeExecution{NumAttempts: 1, TimeoutDelay: time.Second}
+	err = db.Reconfigure(func(cfg *ClusterConfig) {
+		cfg.Consistency = LocalOne
+		cfg.RetryPolicy = retry
+		cfg.SpeculativeExecutionPolicy = spec
+		cfg.Timeout = 5 * time.Second
+		cfg.NumConns = 3
+	})
+	if err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+
+	qry := db.Query("void")
+	if qry.GetConsistency() != LocalOne {
+		t.Fatalf("expected consistency %v, got %v", LocalOne, qry.GetConsistency())
+	}
+	if qry.retryPolicy() != retry {
+		t.Fatalf("expected retry policy %v, got %v", retry, qry.retryPolicy())
+	}
+	if qry.speculativeExecutionPolicy() != spec {
+		t.Fatalf("expected speculative execution policy %v, got %v", spec, qry.speculativeExecutionPolicy())
+	}
+	if err := qry.Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForPoolSize(t, pool, 3)
+	pool.mu.RLock()
+	for _, conn := range pool.conns {
+		if timeout := conn.r.GetTimeout(); timeout != 5*time.Second {
+			t.Errorf("expected connection timeout %v, got %v", 5*time.Second, timeout)
+		}
+	}
+	pool.mu.RUnlock()
+
+	if err := db.Reconfigure(func(cfg *ClusterConfig) { cfg.NumConns = 1 }); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	waitForPoolSize(t, pool, 1)
+}
+
+func TestSessionReconfigureFuncFields(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.AddressTranslator = AddressTranslatorFunc(func(addr net.IP, port int) (net.IP, int) {
+		return addr, port
+	})
+	cluster.HostFilter = HostFilterFunc(func(host *HostInfo) bool { return true })
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Reconfigure(func(cfg *ClusterConfig) { cfg.NumConns = 3 }); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+
+	err = db.Reconfigure(func(cfg *ClusterConfig) {
+		cfg.HostFilter = HostFilterFunc(func(host *HostInfo) bool { return false })
+	})
+	if err == nil || !strings.Contains(err.Error(), "HostFilter can't be changed at runtime") {
+		t.Fatalf("expected HostFilter to be rejected, got %v", err)
+	}
+}
+
+func TestSessionReconfigureRejected(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name   string
+		fn     func(cfg *ClusterConfig)
+		errMsg string
+	}{
+		{
+			name: "static fields",
+			fn: func(cfg *ClusterConfig) {
+				cfg.Consistency = One
+				cfg.Keyspace = "other"
+				cfg.Port = 1234
+				cfg.AuthProvider = func(h *HostInfo) (Authenticator, error) { return nil, nil }
+			},
+			errMsg: "gocql: unable to reconfigure session: AuthProvider, Keyspace, Port can't be changed at runtime",
+		},
+		{
+			name:   "invalid NumConns",
+			fn:     func(cfg *ClusterConfig) { cfg.NumConns = 0 },
+			errMsg: "gocql: unable to reconfigure session: NumConns must be at least 1, got 0",
+		},
+		{
+			name:   "invalid SerialConsistency",
+			fn:     func(cfg *ClusterConfig) { cfg.SerialConsistency = Quorum },
+			errMsg: "gocql: unable to reconfigure session: SerialConsistency must be SERIAL or LOCAL_SERIAL, got QUORUM",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := db.Reconfigure(test.fn)
+			if err == nil || err.Error() != test.errMsg {
+				t.Fatalf("expected error %q, got %v", test.errMsg, err)
+			}
+			if cons := db.Query("void").GetConsistency(); cons != Quorum {
+				t.Fatalf("configuration was partially applied, consistency is %v", cons)
+			}
+		})
+	}
+}
+
+func TestSessionMaxInFlightPerHost(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		recvHook: func(f *framer) {
+			if f.header.op == opQuery {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.PoolConfig.MaxInFlightPerHost = 1
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- db.Query("slow").Exec()
+	}()
+	<-received
+
+	err = db.Query("void").Priority(PriorityHigh).Exec()
+	if overloaded, ok := err.(*ErrOverloaded); !ok {
+		t.Fatalf("expected *ErrOverloaded, got %v", err)
+	} else if overloaded.Priority != PriorityHigh {
+		t.Fatalf("expected priority %v, got %v", PriorityHigh, overloaded.Priority)
+	}
+
+	if err := <-slowErr; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type countingRateLimiter struct {
+	mu    sync.Mutex
+	waits int
+}
+
+func (c *countingRateLimiter) Wait(ctx context.Context, req RateLimitRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits++
+	return nil
+}
+
+func (c *countingRateLimiter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waits
+}
+
+func TestSessionRateLimitAfterAdmission(t *testing.T) {
+	received := make(chan struct{}, 1)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		recvHook: func(f *framer) {
+			if f.header.op == opQuery {
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	limiter := &countingRateLimiter{}
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.PoolConfig.MaxInFlightPerHost = 1
+	cluster.RateLimiter = limiter
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- db.Query("slow").Exec()
+	}()
+	<-received
+
+	// the host rejects the query, it must not be rate limited
+	err = db.Query("void").Exec()
+	if _, ok := err.(*ErrOverloaded); !ok {
+		t.Fatalf("expected *ErrOverloaded, got %v", err)
+	}
+	if n := limiter.count(); n != 1 {
+		t.Fatalf("expected the rate limiter to be consulted once, got %d", n)
+	}
+
+	if err := <-slowErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionFrameRecorder(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	var buf bytes.Buffer
+	capture, err := NewFrameCaptureWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.FrameRecorder = capture
+	cluster.RedactRecordedValues = true
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+
+	if err := db.Query("void", "secret-value").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := capture.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		queryStream = -1
+		replied     bool
+	)
+	err = ReplayFrameCapture(&buf, func(frame ReplayedFrame) error {
+		if frame.Err != nil {
+			t.Errorf("unable to decode %s frame %s: %v", frame.Direction, frame.Header, frame.Err)
+		}
+		if bytes.Contains(frame.Frame, []byte("secret-value")) {
+			t.Errorf("bound value was recorded in %s", frame.Header)
+		}
+
+		switch {
+		case frame.Direction == FrameSent && strings.Contains(frame.Header, "op=QUERY"):
+			queryStream = frame.Stream
+		case frame.Direction == FrameReceived && frame.Stream == queryStream:
+			replied = frame.Decoded == "[result_void]"
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if queryStream < 0 || !replied {
+		t.Fatalf("expected the query and its void result to be recorded")
+	}
+}
+
+type collectingFrameObserver struct {
+	mu     sync.Mutex
+	frames []ObservedFrame
+}
+
+func (o *collectingFrameObserver) ObserveFrame(ctx context.Context, frame ObservedFrame) {
+	o.mu.Lock()
+	o.frames = append(o.frames, frame)
+	o.mu.Unlock()
+}
+
+func TestSessionFrameObserver(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	observer := &collectingFrameObserver{}
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.FrameObserver = observer
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+
+	if err := db.Query("void").Consistency(LocalQuorum).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+
+	queryStream := -1
+	var replied bool
+	for _, frame := range observer.frames {
+		if frame.Err != nil {
+			t.Errorf("unable to decode %s frame: %v", frame.Direction, frame.Err)
+			continue
+		}
+		if frame.Host == nil {
+			t.Errorf("%s frame %s has no host", frame.Direction, frame.Frame.Opcode)
+		}
+		switch {
+		case frame.Direction == FrameSent && frame.Frame.Opcode == "QUERY" && frame.Frame.Statements[0] == "void":
+			if frame.Frame.Consistency != LocalQuorum {
+				t.Errorf("expected consistency %v, got %v", LocalQuorum, frame.Frame.Consistency)
+			}
+			queryStream = frame.Frame.Stream
+		case frame.Direction == FrameReceived && frame.Frame.Stream == queryStream:
+			replied = frame.Frame.ResultKind == "VOID"
+		}
+	}
+
+	if queryStream < 0 || !replied {
+		t.Fatalf("expected the query and its void result to be observed")
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SigV4Credentials are AWS credentials used to sign the authentication
+// challenge of SigV4Authenticator.
+type SigV4Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+	// Expires is when temporary credentials expire, zero if they don't.
+	Expires time.Time
+}
+
+// SigV4CredentialsProvider provides the credentials of SigV4Authenticator,
+// it is called for every authentication handshake.
+type SigV4CredentialsProvider interface {
+	Retrieve(ctx context.Context) (SigV4Credentials, error)
+}
+
+// SigV4CredentialsFunc is a SigV4CredentialsProvider calling the function.
+type SigV4CredentialsFunc func(ctx context.Context) (SigV4Credentials, error)
+
+func (fn SigV4CredentialsFunc) Retrieve(ctx context.Context) (SigV4Credentials, error) {
+	return fn(ctx)
+}
+
+// StaticSigV4Credentials returns a SigV4CredentialsProvider always providing
+// creds.
+func StaticSigV4Credentials(creds SigV4Credentials) SigV4CredentialsProvider {
+	return SigV4CredentialsFunc(func(ctx context.Context) (SigV4Credentials, error) {
+		return creds, nil
+	})
+}
+
+// NewRefreshingSigV4Credentials returns a SigV4CredentialsProvider caching
+// the credentials returned by fetch until window before they expire.
+// Credentials without expiry are cached forever.
+func NewRefreshingSigV4Credentials(fetch func(ctx context.Context) (SigV4Credentials, error), window time.Duration) SigV4CredentialsProvider {
+	return &refreshingSigV4Credentials{fetch: fetch, window: window}
+}
+
+type refreshingSigV4Credentials struct {
+	fetch  func(ctx context.Context) (SigV4Credentials, error)
+	window time.Duration
+
+	mu     sync.Mutex
+	creds  SigV4Credentials
+	cached bool
+}
+
+func (p *refreshingSigV4Credentials) Retrieve(ctx context.Context) (SigV4Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cached && (p.creds.Expires.IsZero() || time.Now().Before(p.creds.Expires.Add(-p.window))) {
+		return p.creds, nil
+	}
+
+	creds, err := p.fetch(ctx)
+	if err != nil {
+		return SigV4Credentials{}, fmt.Errorf("gocql: unable to fetch SigV4 credentials: %w", err)
+	}
+	p.creds = creds
+	p.cached = true
+	return creds, nil
+}
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "cassandra"
+	// sigV4InitialResponse selects the SigV4 mechanism, the server responds
+	// with a nonce challenge.
+	sigV4InitialResponse = "SigV4\x00\x00"
+	// sigV4Timeout bounds the time spent retrieving credentials.
+	sigV4Timeout = 10 * time.Second
+)
+
+// SigV4Authenticator authenticates with AWS signature version 4, as Amazon
+// Keyspaces and compatible services do. The server sends a nonce which is
+// signed with the credentials of Credentials for the service "cassandra"
+// in Region.
+type SigV4Authenticator struct {
+	Region      string
+	Credentials SigV4CredentialsProvider
+	// Setting this to nil or empty will allow authenticating with any authenticator
+	// provided by the server.
+	AllowedAuthenticators []string
+
+	// now returns the signing time, time.Now if nil.
+	now func() time.Time
+}
+
+func (a SigV4Authenticator) Challenge(req []byte) ([]byte, Authenticator, error) {
+	if !approve(string(req), a.AllowedAuthenticators) {
+		return nil, nil, fmt.Errorf("unexpected authenticator %q", req)
+	}
+	if a.Region == "" || a.Credentials == nil {
+		return nil, nil, errors.New("gocql: SigV4Authenticator requires a Region and Credentials")
+	}
+	return []byte(sigV4InitialResponse), sigV4Challenge{a}, nil
+}
+
+func (a SigV4Authenticator) Success(data []byte) error {
+	return nil
+}
+
+// sigV4Challenge answers the nonce challenge.
+type sigV4Challenge struct {
+	auth SigV4Authenticator
+}
+
+func (c sigV4Challenge) Challenge(req []byte) ([]byte, Authenticator, error) {
+	nonce, err := sigV4Nonce(string(req))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sigV4Timeout)
+	defer cancel()
+	creds, err := c.auth.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now
+	if c.auth.now != nil {
+		now = c.auth.now
+	}
+	return signSigV4(creds, c.auth.Region, nonce, now().UTC()), nil, nil
+}
+
+func (c sigV4Challenge) Success(data []byte) error {
+	return nil
+}
+
+// sigV4Nonce returns the nonce of the challenge "nonce=<nonce>[,...]".
+func sigV4Nonce(challenge string) (string, error) {
+	for _, field := range strings.Split(challenge, ",") {
+		if nonce := strings.TrimPrefix(field, "nonce="); nonce != field {
+			return nonce, nil
+		}
+	}
+	return "", fmt.Errorf("gocql: no nonce in SigV4 challenge %q", challenge)
+}
+
+// signSigV4 returns the response to the nonce challenge signed at t.
+func signSigV4(creds SigV4Credentials, region, nonce string, t time.Time) []byte {
+	amzDate := t.Format("2006-01-02T15:04:05.000Z")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", t.Format("20060102"), region, sigV4Service)
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex(sigV4CanonicalRequest(creds.AccessKeyID, scope, amzDate, nonce)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, sigV4Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	resp := fmt.Sprintf("signature=%s,access_key=%s,amzdate=%s", signature, creds.AccessKeyID, amzDate)
+	if creds.SessionToken != "" {
+		resp += ",session_token=" + creds.SessionToken
+	}
+	return []byte(resp)
+}
+
+// sigV4CanonicalRequest returns the canonical request signed for the nonce.
+func sigV4CanonicalRequest(accessKeyID, scope, amzDate, nonce string) string {
+	query := []string{
+		"X-Amz-Algorithm=" + sigV4Algorithm,
+		"X-Amz-Credential=" + accessKeyID + "%2F" + url.QueryEscape(scope),
+		"X-Amz-Date=" + url.QueryEscape(amzDate),
+		"X-Amz-Expires=900",
+	}
+	sort.Strings(query)
+
+	return strings.Join([]string{
+		"PUT",
+		"/authenticate",
+		strings.Join(query, "&"),
+		"host:" + sigV4Service,
+		"",
+		"host",
+		sha256Hex(nonce),
+	}, "\n")
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
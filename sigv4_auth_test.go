@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignSigV4(t *testing.T) {
+	// reference vector of the AWS SigV4 authentication plugins
+	creds := SigV4Credentials{AccessKeyID: "UserID-1", SecretAccessKey: "UserSecretKey-1"}
+	signedAt := time.Date(2020, 6, 9, 22, 41, 51, 0, time.UTC)
+
+	got := string(signSigV4(creds, "us-west-2", "91703fdc2ef562e19fbdab0f58e42fe5", signedAt))
+	expected := "signature=7f3691c18a81b8ce7457699effbfae5b09b4e0714ab38c1292dbdf082c9ddd87,access_key=UserID-1,amzdate=2020-06-09T22:41:51.000Z"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	creds.SessionToken = "token"
+	if got := string(signSigV4(creds, "us-west-2", "91703fdc2ef562e19fbdab0f58e42fe5", signedAt)); got != expected+",session_token=token" {
+		t.Fatalf("expected the session token to be appended, got %q", got)
+	}
+}
+
+// fakeSigV4Server verifies the SigV4 handshake like the server does.
+type fakeSigV4Server struct {
+	region  string
+	nonce   string
+	secrets map[string]string
+	now     time.Time
+}
+
+func (s fakeSigV4Server) verify(resp []byte) error {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(string(resp), ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed field %q", field)
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	secret, ok := s.secrets[fields["access_key"]]
+	if !ok {
+		return fmt.Errorf("unknown access key %q", fields["access_key"])
+	}
+	signedAt, err := time.Parse("2006-01-02T15:04:05.000Z", fields["amzdate"])
+	if err != nil {
+		return err
+	}
+	if d := s.now.Sub(signedAt); d < -15*time.Minute || d > 15*time.Minute {
+		return fmt.Errorf("signature date %v is too far from %v", signedAt, s.now)
+	}
+
+	creds := SigV4Credentials{AccessKeyID: fields["access_key"], SecretAccessKey: secret, SessionToken: fields["session_token"]}
+	expected := signSigV4(creds, s.region, s.nonce, signedAt)
+	if !hmac.Equal(expected, resp) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (s fakeSigV4Server) handshake(auth Authenticator) error {
+	resp, challenger, err := auth.Challenge([]byte("com.amazonaws.cassandra.auth.SigV4Authenticator"))
+	if err != nil {
+		return err
+	}
+	if string(resp) != sigV4InitialResponse {
+		return fmt.Errorf("unexpected initial response %q", resp)
+	}
+	if challenger == nil {
+		return errors.New("expected a nonce challenger")
+	}
+	resp, _, err = challenger.Challenge([]byte("nonce=" + s.nonce))
+	if err != nil {
+		return err
+	}
+	return s.verify(resp)
+}
+
+func TestSigV4Authenticator(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := fakeSigV4Server{
+		region:  "eu-west-1",
+		nonce:   "0f4d2a9b7c1e",
+		secrets: map[string]string{"AKID": "secret"},
+		now:     now,
+	}
+	clock := func() time.Time { return now }
+
+	auth := SigV4Authenticator{
+		Region:      "eu-west-1",
+		Credentials: StaticSigV4Credentials(SigV4Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}),
+		now:         clock,
+	}
+	if err := server.handshake(auth); err != nil {
+		t.Fatalf("expected the handshake to succeed: %v", err)
+	}
+
+	auth.Credentials = StaticSigV4Credentials(SigV4Credentials{AccessKeyID: "AKID", SecretAccessKey: "wrong"})
+	if err := server.handshake(auth); err == nil {
+		t.Fatal("expected a wrong secret to be rejected")
+	}
+
+	auth.Credentials = StaticSigV4Credentials(SigV4Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	auth.Region = "us-east-1"
+	if err := server.handshake(auth); err == nil {
+		t.Fatal("expected a wrong region to be rejected")
+	}
+
+	auth.Region = "eu-west-1"
+	auth.now = func() time.Time { return now.Add(-time.Hour) }
+	if err := server.handshake(auth); err == nil {
+		t.Fatal("expected a stale signature to be rejected")
+	}
+}
+
+func TestSigV4AuthenticatorErrors(t *testing.T) {
+	if _, _, err := (SigV4Authenticator{Region: "eu-west-1"}).Challenge(nil); err == nil {
+		t.Fatal("expected an error without credentials")
+	}
+
+	auth := SigV4Authenticator{
+		Region:                "eu-west-1",
+		Credentials:           StaticSigV4Credentials(SigV4Credentials{}),
+		AllowedAuthenticators: []string{"com.amazonaws.cassandra.auth.SigV4Authenticator"},
+	}
+	if _, _, err := auth.Challenge([]byte("org.apache.cassandra.auth.PasswordAuthenticator")); err == nil {
+		t.Fatal("expected a disallowed authenticator to be rejected")
+	}
+
+	_, challenger, err := auth.Challenge([]byte("com.amazonaws.cassandra.auth.SigV4Authenticator"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := challenger.Challenge([]byte("salt=abc")); err == nil {
+		t.Fatal("expected an error for a challenge without nonce")
+	}
+
+	fetchErr := errors.New("no credentials")
+	auth.Credentials = SigV4CredentialsFunc(func(ctx context.Context) (SigV4Credentials, error) {
+		return SigV4Credentials{}, fetchErr
+	})
+	_, challenger, _ = auth.Challenge([]byte("com.amazonaws.cassandra.auth.SigV4Authenticator"))
+	if _, _, err := challenger.Challenge([]byte("nonce=abc")); !errors.Is(err, fetchErr) {
+		t.Fatalf("expected the provider error, got %v", err)
+	}
+}
+
+func TestRefreshingSigV4Credentials(t *testing.T) {
+	fetches := 0
+	expires := time.Now().Add(time.Hour)
+	provider := NewRefreshingSigV4Credentials(func(ctx context.Context) (SigV4Credentials, error) {
+		fetches++
+		return SigV4Credentials{AccessKeyID: fmt.Sprint("AKID", fetches), Expires: expires}, nil
+	}, 5*time.Minute)
+	ctx := context.Background()
+
+	first, err := provider.Retrieve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := provider.Retrieve(ctx); again != first || fetches != 1 {
+		t.Fatalf("expected the cached credentials, got %v after %d fetches", again, fetches)
+	}
+
+	// the cached credentials are within the refresh window
+	expires = time.Now().Add(time.Minute)
+	provider.(*refreshingSigV4Credentials).creds.Expires = expires
+	refreshed, err := provider.Retrieve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AccessKeyID != "AKID2" || fetches != 2 {
+		t.Fatalf("expected refreshed credentials, got %v after %d fetches", refreshed, fetches)
+	}
+	if again, _ := provider.Retrieve(ctx); again.AccessKeyID != "AKID3" || fetches != 3 {
+		t.Fatalf("expected credentials expiring within the window to be refetched, got %v", again)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Server products detected from the SUPPORTED options of a host, see
+// SupportedOptions.Product.
+const (
+	ProductCassandra = "CASSANDRA"
+	ProductDSE       = "DSE"
+	ProductScyllaDB  = "SCYLLADB"
+)
+
+// SupportedOptions are the options a host returns in its SUPPORTED response
+// to the OPTIONS request of each connection, such as its CQL versions
+// (CQL_VERSION), compression algorithms (COMPRESSION) and protocol versions
+// (PROTOCOL_VERSIONS). The map must not be modified.
+type SupportedOptions map[string][]string
+
+// CQLVersions returns the CQL versions supported by the host.
+func (o SupportedOptions) CQLVersions() []string {
+	return o["CQL_VERSION"]
+}
+
+// Compression returns the names of the compression algorithms supported by
+// the host, to be matched against Compressor.Name.
+func (o SupportedOptions) Compression() []string {
+	return o["COMPRESSION"]
+}
+
+// SupportsCompression returns true if the host supports the compression
+// algorithm name.
+func (o SupportedOptions) SupportsCompression(name string) bool {
+	for _, comp := range o.Compression() {
+		if comp == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ProtocolVersions returns the native protocol versions supported by the
+// host, including beta versions, in increasing order. Hosts before Cassandra
+// 4.0 do not list their protocol versions.
+func (o SupportedOptions) ProtocolVersions() []int {
+	var versions []int
+	for _, version := range o["PROTOCOL_VERSIONS"] {
+		// versions are listed as <number>/<name>, e.g. 5/v5-beta
+		number, _, _ := strings.Cut(version, "/")
+		v, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Product returns the server product of the host, the PRODUCT_TYPE option if
+// the host sets it, or one of ProductCassandra, ProductDSE and
+// ProductScyllaDB. An empty string is returned if the options are unknown.
+func (o SupportedOptions) Product() string {
+	if o == nil {
+		return ""
+	}
+	if product := o["PRODUCT_TYPE"]; len(product) > 0 {
+		return product[0]
+	}
+	for name := range o {
+		if strings.HasPrefix(name, "SCYLLA_") {
+			return ProductScyllaDB
+		}
+	}
+	for _, version := range o.ProtocolVersions() {
+		if isDSEVersion(byte(version)) {
+			return ProductDSE
+		}
+	}
+	return ProductCassandra
+}
+
+// ErrCompressorNotSupported is returned when connecting to a host which does
+// not support the compression algorithm of ClusterConfig.Compressor.
+type ErrCompressorNotSupported struct {
+	// Addr is the address of the host.
+	Addr string
+	// Compressor is the name of the configured compressor.
+	Compressor string
+	// Supported are the compression algorithms supported by the host.
+	Supported []string
+}
+
+func (e *ErrCompressorNotSupported) Error() string {
+	return fmt.Sprintf("gocql: compressor %q is not supported by host %s, supported compression algorithms: %v", e.Compressor, e.Addr, e.Supported)
+}
+
+// intersect returns the option values supported by both o and other, options
+// with no common value are left out.
+func (o SupportedOptions) intersect(other SupportedOptions) SupportedOptions {
+	res := make(SupportedOptions, len(o))
+	for name, values := range o {
+		for _, v := range values {
+			for _, otherV := range other[name] {
+				if v == otherV {
+					res[name] = append(res[name], v)
+					break
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSupportedOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		supported SupportedOptions
+		product   string
+		versions  []int
+	}{
+		{"unknown", nil, "", nil},
+		{"cassandra 3", SupportedOptions{"CQL_VERSION": {"3.4.4"}, "COMPRESSION": {"snappy", "lz4"}}, ProductCassandra, nil},
+		{"cassandra 4", SupportedOptions{"PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5-beta"}}, ProductCassandra, []int{3, 4, 5}},
+		{"dse", SupportedOptions{"PROTOCOL_VERSIONS": {"66/dse_v2", "65/dse_v1", "4/v4"}}, ProductDSE, []int{4, 65, 66}},
+		{"scylladb", SupportedOptions{"SCYLLA_SHARD": {"0"}, "PROTOCOL_VERSIONS": {"4/v4"}}, ProductScyllaDB, []int{4}},
+		{"product type", SupportedOptions{"PRODUCT_TYPE": {"DATASTAX_APOLLO"}}, "DATASTAX_APOLLO", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.supported.Product(); got != test.product {
+				t.Errorf("expected product %q, got %q", test.product, got)
+			}
+			if got := test.supported.ProtocolVersions(); !reflect.DeepEqual(got, test.versions) {
+				t.Errorf("expected protocol versions %v, got %v", test.versions, got)
+			}
+		})
+	}
+}
+
+func TestSupportedOptionsIntersect(t *testing.T) {
+	a := SupportedOptions{"COMPRESSION": {"snappy", "lz4"}, "CQL_VERSION": {"3.4.5"}}
+	b := SupportedOptions{"COMPRESSION": {"lz4"}, "CQL_VERSION": {"3.4.4"}}
+
+	got := a.intersect(b)
+	if !reflect.DeepEqual(got, SupportedOptions{"COMPRESSION": {"lz4"}}) {
+		t.Fatalf("unexpected intersection %v", got)
+	}
+	if !got.SupportsCompression("lz4") || got.SupportsCompression("snappy") {
+		t.Fatalf("unexpected compression support %v", got.Compression())
+	}
+}
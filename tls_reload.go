@@ -0,0 +1,171 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsConfigSource provides the TLS config of new connections. When
+// SslOptions.ReloadInterval is set, the CA, certificate and key files are
+// checked for changes at most once per interval and reloaded, connections
+// established before keep their config.
+type tlsConfigSource struct {
+	opts   *SslOptions
+	logger StdLogger
+
+	mu        sync.Mutex
+	config    *tls.Config
+	expiry    time.Time
+	files     []fileStamp
+	lastCheck time.Time
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newTLSConfigSource(opts *SslOptions, logger StdLogger) (*tlsConfigSource, error) {
+	s := &tlsConfigSource{
+		opts:   opts,
+		logger: logger,
+	}
+
+	// stamp the files before reading them so a change in between is not
+	// missed
+	s.files = s.stampFiles()
+	config, err := setupTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	s.config = config
+	s.expiry = certificateExpiry(config)
+	s.lastCheck = time.Now()
+	return s, nil
+}
+
+// get returns the TLS config for a new connection and the expiry of its
+// client certificate, zero if there is none.
+func (s *tlsConfigSource) get() (*tls.Config, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReloadInterval > 0 && time.Since(s.lastCheck) >= s.opts.ReloadInterval {
+		s.lastCheck = time.Now()
+		s.reload()
+	}
+	return s.config, s.expiry
+}
+
+// reload reads the files again if they changed, s.mu must be held. The
+// previous config is kept if the files can't be read.
+func (s *tlsConfigSource) reload() {
+	files := s.stampFiles()
+	changed := false
+	for i := range files {
+		if files[i] != s.files[i] {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+
+	config, err := setupTLSConfig(s.opts)
+	if err != nil {
+		// the files might be in the middle of being replaced, try again
+		// at the next check
+		s.logger.Printf("gocql: unable to reload TLS certificates: %v\n", err)
+		return
+	}
+
+	s.config = config
+	s.expiry = certificateExpiry(config)
+	s.files = files
+	if gocqlDebug {
+		s.logger.Printf("gocql: reloaded TLS certificates, client certificate expires at %v\n", s.expiry)
+	}
+}
+
+// certRenewed reports whether a client certificate expiring later than
+// expiry was loaded, so replacing a connection using the certificate
+// expiring at expiry renews its certificate.
+func (s *tlsConfigSource) certRenewed(expiry time.Time) bool {
+	_, current := s.get()
+	return current.After(expiry)
+}
+
+func (s *tlsConfigSource) stampFiles() []fileStamp {
+	paths := []string{s.opts.CaPath, s.opts.CertPath, s.opts.KeyPath}
+	stamps := make([]fileStamp, len(paths))
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// certificateExpiry returns when the first client certificate of config
+// expires, or zero if there is none.
+func certificateExpiry(config *tls.Config) time.Time {
+	if len(config.Certificates) == 0 || len(config.Certificates[0].Certificate) == 0 {
+		return time.Time{}
+	}
+
+	leaf := config.Certificates[0].Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(config.Certificates[0].Certificate[0]); err != nil {
+			return time.Time{}
+		}
+	}
+	return leaf.NotAfter
+}
+
+// certRecycleTime returns when a connection established at now using a
+// client certificate expiring at expiry is replaced, at a random time during
+// the first half of the recycle period so connections are not all replaced
+// at once. It returns zero if the connection is not recycled, which includes
+// certificates already inside the recycle period: a new connection would
+// use the same certificate, see certRenewed.
+func certRecycleTime(expiry time.Time, recycleBefore time.Duration, now time.Time) time.Time {
+	if expiry.IsZero() || recycleBefore <= 0 {
+		return time.Time{}
+	}
+	at := expiry.Add(-recycleBefore)
+	if !at.After(now) {
+		return time.Time{}
+	}
+	if jitter := int64(recycleBefore / 2); jitter > 0 {
+		at = at.Add(time.Duration(rand.Int63n(jitter)))
+	}
+	return at
+}
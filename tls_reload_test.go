@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func copyTestFile(t *testing.T, from, to string) {
+	t.Helper()
+	data, err := os.ReadFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(to, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// make the change visible on file systems with a coarse mtime
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(to, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSConfigSourceReload(t *testing.T) {
+	dir := t.TempDir()
+	opts := &SslOptions{
+		CaPath:         filepath.Join(dir, "ca.crt"),
+		CertPath:       filepath.Join(dir, "client.crt"),
+		KeyPath:        filepath.Join(dir, "client.key"),
+		ReloadInterval: time.Millisecond,
+	}
+	copyTestFile(t, "testdata/pki/ca.crt", opts.CaPath)
+	copyTestFile(t, "testdata/pki/gocql.crt", opts.CertPath)
+	copyTestFile(t, "testdata/pki/gocql.key", opts.KeyPath)
+
+	logger := &testLogger{}
+	source, err := newTLSConfigSource(opts, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, expiry := source.get()
+	if expiry.IsZero() {
+		t.Fatal("expected the expiry of the client certificate")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if config, _ := source.get(); config != first {
+		t.Fatal("expected the same config while the files are unchanged")
+	}
+
+	// a partially written key is not loaded
+	if err := os.WriteFile(opts.KeyPath, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if config, _ := source.get(); config != first {
+		t.Fatal("expected the previous config to be kept when the files are invalid")
+	}
+	if !strings.Contains(logger.String(), "unable to reload TLS certificates") {
+		t.Fatalf("expected the reload error to be logged, got %q", logger.String())
+	}
+
+	copyTestFile(t, "testdata/pki/cassandra.crt", opts.CertPath)
+	copyTestFile(t, "testdata/pki/cassandra.key", opts.KeyPath)
+	time.Sleep(2 * time.Millisecond)
+	config, _ := source.get()
+	if config == first {
+		t.Fatal("expected the config to be reloaded")
+	}
+	if bytes.Equal(config.Certificates[0].Certificate[0], first.Certificates[0].Certificate[0]) {
+		t.Fatal("expected the rotated client certificate")
+	}
+}
+
+func TestTLSConfigSourceNoReload(t *testing.T) {
+	dir := t.TempDir()
+	opts := &SslOptions{
+		CertPath: filepath.Join(dir, "client.crt"),
+		KeyPath:  filepath.Join(dir, "client.key"),
+	}
+	copyTestFile(t, "testdata/pki/gocql.crt", opts.CertPath)
+	copyTestFile(t, "testdata/pki/gocql.key", opts.KeyPath)
+
+	source, err := newTLSConfigSource(opts, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := source.get()
+
+	copyTestFile(t, "testdata/pki/cassandra.crt", opts.CertPath)
+	copyTestFile(t, "testdata/pki/cassandra.key", opts.KeyPath)
+	if config, _ := source.get(); config != first {
+		t.Fatal("expected the files to be read once without ReloadInterval")
+	}
+}
+
+func TestCertRecycleTime(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	if !certRecycleTime(time.Time{}, time.Minute, time.Now()).IsZero() {
+		t.Fatal("expected no recycling without a client certificate")
+	}
+	if !certRecycleTime(expiry, 0, time.Now()).IsZero() {
+		t.Fatal("expected no recycling when disabled")
+	}
+	if !certRecycleTime(expiry, 2*time.Hour, time.Now()).IsZero() {
+		t.Fatal("expected no recycling of a certificate already inside the recycle period")
+	}
+
+	for i := 0; i < 100; i++ {
+		at := certRecycleTime(expiry, 10*time.Minute, time.Now())
+		if at.Before(expiry.Add(-10*time.Minute)) || !at.Before(expiry.Add(-5*time.Minute)) {
+			t.Fatalf("recycle time %v out of the first half of the recycle period", at)
+		}
+	}
+}
+
+func TestHostConnPoolCertInsideRecyclePeriod(t *testing.T) {
+	opts := &SslOptions{
+		CaPath:   "testdata/pki/ca.crt",
+		CertPath: "testdata/pki/gocql.crt",
+		KeyPath:  "testdata/pki/gocql.key",
+		// the certificate is already inside the recycle period
+		RecycleBeforeExpiry: 100 * 365 * 24 * time.Hour,
+	}
+	source, err := newTLSConfigSource(opts, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expiry := source.get()
+
+	now := time.Now()
+	if at := certRecycleTime(expiry, opts.RecycleBeforeExpiry, now); !at.IsZero() {
+		t.Fatalf("expected no recycle time, got %v", at)
+	}
+
+	session := &Session{
+		cfg:     ClusterConfig{SslOpts: opts},
+		connCfg: &ConnConfig{HostDialer: &defaultHostDialer{tls: source}},
+	}
+	conn := &Conn{certExpiry: expiry}
+	pool := &hostConnPool{session: session, conns: []*Conn{conn}}
+	if expired := pool.expiredConns(now); len(expired) != 0 {
+		t.Fatal("expected the connection to be kept while no later certificate is loaded")
+	}
+
+	// a connection established with an older certificate is renewed
+	conn.certExpiry = expiry.Add(-time.Hour)
+	if expired := pool.expiredConns(now); len(expired) != 1 || expired[0] != conn {
+		t.Fatalf("expected the connection to be replaced, got %v", expired)
+	}
+}
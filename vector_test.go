@@ -314,7 +314,7 @@ func TestVector_SubTypeParsing(t *testing.T) {
 		expected TypeInfo
 	}{
 		{name: "text", custom: "org.apache.cassandra.db.marshal.UTF8Type", expected: NativeType{typ: TypeVarchar}},
-		{name: "set_int", custom: "org.apache.cassandra.db.marshal.SetType(org.apache.cassandra.db.marshal.Int32Type)", expected: CollectionType{NativeType{typ: TypeSet}, nil, NativeType{typ: TypeInt}}},
+		{name: "set_int", custom: "org.apache.cassandra.db.marshal.SetType(org.apache.cassandra.db.marshal.Int32Type)", expected: CollectionType{NativeType{typ: TypeSet}, nil, NativeType{typ: TypeInt}, true}},
 		{
 			name:   "udt",
 			custom: "org.apache.cassandra.db.marshal.UserType(gocql_test,706572736f6e,66697273745f6e616d65:org.apache.cassandra.db.marshal.UTF8Type,6c6173745f6e616d65:org.apache.cassandra.db.marshal.UTF8Type,616765:org.apache.cassandra.db.marshal.Int32Type)",
@@ -327,6 +327,7 @@ func TestVector_SubTypeParsing(t *testing.T) {
 					UDTField{"last_name", NativeType{typ: TypeVarchar}},
 					UDTField{"age", NativeType{typ: TypeInt}},
 				},
+				true,
 			},
 		},
 		{
@@ -339,6 +340,7 @@ func TestVector_SubTypeParsing(t *testing.T) {
 					NativeType{typ: TypeInt},
 					NativeType{typ: TypeVarchar},
 				},
+				true,
 			},
 		},
 		{
@@ -365,6 +367,7 @@ func TestVector_SubTypeParsing(t *testing.T) {
 					NativeType{typ: TypeVarchar},
 					10,
 				},
+				true,
 			},
 		},
 		{
@@ -381,7 +384,9 @@ func TestVector_SubTypeParsing(t *testing.T) {
 						10,
 					},
 					NativeType{typ: TypeVarchar},
+					true,
 				},
+				true,
 			},
 		},
 	}
@@ -0,0 +1,213 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocql
+
+import (
+	"io"
+	"net"
+
+	"github.com/gocql/gocql/internal/wire"
+)
+
+func init() {
+	wire.NewFramer = func(version byte) wire.Framer {
+		return &wireFramer{framer: newFramer(nil, version), version: version}
+	}
+	wire.ReadFrame = readWireFrame
+	wire.ReadSegment = readUncompressedSegment
+}
+
+// wireFramer exposes a framer to the other packages of this module through
+// the wire.Framer interface.
+type wireFramer struct {
+	*framer
+	version byte
+}
+
+func readWireFrame(r io.Reader) (wire.Framer, error) {
+	p := make([]byte, maxFrameHeaderSize)
+	head, err := readHeader(r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newFramer(nil, head.version.version())
+	if err := f.readFrame(r, &head); err != nil {
+		return nil, err
+	}
+
+	return &wireFramer{framer: f, version: byte(head.version)}, nil
+}
+
+func (f *wireFramer) Header() wire.Header {
+	if f.header == nil {
+		return wire.Header{Version: f.version}
+	}
+
+	return wire.Header{
+		Version: byte(f.header.version),
+		Flags:   f.header.flags,
+		Stream:  f.header.stream,
+		Op:      byte(f.header.op),
+		Length:  f.header.length,
+	}
+}
+
+func (f *wireFramer) ReadUint8() uint8 {
+	return f.readByte()
+}
+
+func (f *wireFramer) ReadShort() uint16 {
+	return f.readShort()
+}
+
+func (f *wireFramer) ReadInt() int {
+	return f.readInt()
+}
+
+func (f *wireFramer) ReadLong() int64 {
+	if len(f.buf) < 8 {
+		f.fail("not enough bytes in buffer to read long require 8 got: %d", len(f.buf))
+		return 0
+	}
+
+	n := int64(f.buf[0])<<56 | int64(f.buf[1])<<48 | int64(f.buf[2])<<40 | int64(f.buf[3])<<32 |
+		int64(f.buf[4])<<24 | int64(f.buf[5])<<16 | int64(f.buf[6])<<8 | int64(f.buf[7])
+	f.buf = f.buf[8:]
+	return n
+}
+
+func (f *wireFramer) ReadString() string {
+	return f.readString()
+}
+
+func (f *wireFramer) ReadLongString() string {
+	return f.readLongString()
+}
+
+func (f *wireFramer) ReadBytes() []byte {
+	return f.readBytes()
+}
+
+func (f *wireFramer) ReadShortBytes() []byte {
+	return f.readShortBytes()
+}
+
+func (f *wireFramer) ReadStringList() []string {
+	return f.readStringList()
+}
+
+func (f *wireFramer) ReadStringMap() map[string]string {
+	size := int(f.readShort())
+	if !f.checkCount(size, 4, "string map") {
+		return nil
+	}
+	m := make(map[string]string, size)
+
+	for i := 0; i < size; i++ {
+		k := f.readString()
+		m[k] = f.readString()
+	}
+
+	return m
+}
+
+func (f *wireFramer) ReadBytesMap() map[string][]byte {
+	return f.readBytesMap()
+}
+
+func (f *wireFramer) Err() error {
+	return f.err
+}
+
+func (f *wireFramer) WriteHeader(flags, op byte, stream int) {
+	f.writeHeader(flags, frameOp(op), stream)
+	f.buf[0] = f.version
+}
+
+func (f *wireFramer) WriteUint8(b uint8) {
+	f.writeByte(b)
+}
+
+func (f *wireFramer) WriteShort(n uint16) {
+	f.writeShort(n)
+}
+
+func (f *wireFramer) WriteInt(n int32) {
+	f.writeInt(n)
+}
+
+func (f *wireFramer) WriteLong(n int64) {
+	f.writeLong(n)
+}
+
+func (f *wireFramer) WriteString(s string) {
+	f.writeString(s)
+}
+
+func (f *wireFramer) WriteLongString(s string) {
+	f.writeLongString(s)
+}
+
+func (f *wireFramer) WriteBytes(p []byte) {
+	f.writeBytes(p)
+}
+
+func (f *wireFramer) WriteShortBytes(p []byte) {
+	f.writeShortBytes(p)
+}
+
+func (f *wireFramer) WriteStringList(l []string) {
+	f.writeStringList(l)
+}
+
+func (f *wireFramer) WriteStringMap(m map[string]string) {
+	f.writeStringMap(m)
+}
+
+func (f *wireFramer) WriteStringMultiMap(m map[string][]string) {
+	f.writeShort(uint16(len(m)))
+	for k, v := range m {
+		f.writeString(k)
+		f.writeStringList(v)
+	}
+}
+
+func (f *wireFramer) WriteInet(ip net.IP, port int) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	f.writeByte(byte(len(ip)))
+	f.buf = append(f.buf, ip...)
+	f.writeInt(int32(port))
+}
+
+func (f *wireFramer) Send(w io.Writer, segmented bool) error {
+	if err := f.finish(); err != nil {
+		return err
+	}
+
+	if segmented {
+		if err := f.prepareModernLayout(); err != nil {
+			return err
+		}
+	}
+
+	return f.writeTo(w)
+}